@@ -0,0 +1,143 @@
+package v1alpha1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ApprovalAnnotation is set on an IamRoleServiceAccount (or a Policy referenced by them) requesting sensitive permissions to approve its statements,
+// its value is "<digest of the statements>:<name of the approver>"
+const ApprovalAnnotation = "irsa.voodoo.io/approval"
+
+// ApprovalVerb is the verb an approver must be allowed on the iamroleserviceaccounts (or the policies) approved (checked with a SubjectAccessReview)
+const ApprovalVerb = "approve"
+
+// SensitivePermission is an action pattern & a resource pattern (both may contain the IAM wildcards "*" & "?"),
+// the statements granting a matching action on a matching resource need an approval
+// +kubebuilder:object:generate=false
+type SensitivePermission struct {
+	Action   string
+	Resource string
+}
+
+func (p SensitivePermission) String() string {
+	return p.Action + " on " + p.Resource
+}
+
+// SensitivePermissions is the list of the permissions needing an approval
+// +kubebuilder:object:generate=false
+type SensitivePermissions []SensitivePermission
+
+// ParseSensitivePermissions parses a list of "<action pattern>[=<resource pattern>]" (eg. "iam:*" or "kms:Decrypt=arn:aws:kms:*:*:key/prod-*"),
+// the resource pattern defaults to "*"
+func ParseSensitivePermissions(list []string) (SensitivePermissions, error) {
+	perms := SensitivePermissions{}
+	for _, s := range list {
+		p := SensitivePermission{Resource: "*"}
+		p.Action = s
+		if i := strings.Index(s, "="); i >= 0 { // the actions never contain "=", the resources may
+			p.Action, p.Resource = s[:i], s[i+1:]
+		}
+
+		if p.Action == "" || p.Resource == "" {
+			return nil, fmt.Errorf("%s is an invalid sensitive permission, expected <action pattern>[=<resource pattern>]", s)
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// Match returns the sensitive permissions the statements may grant
+// a statement matches when one of its actions & its resource may overlap the patterns (eg. "*" matches "iam:*")
+func (perms SensitivePermissions) Match(stmts []StatementSpec) []SensitivePermission {
+	matched := []SensitivePermission{}
+	for _, p := range perms {
+		if p.grantedBy(stmts) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+func (p SensitivePermission) grantedBy(stmts []StatementSpec) bool {
+	for _, stm := range stmts {
+		if !globsOverlap(p.Resource, stm.Resource) {
+			continue
+		}
+		for _, a := range stm.Action { // the actions are case insensitive
+			if globsOverlap(strings.ToLower(p.Action), strings.ToLower(a)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globsOverlap tells if a string can be matched by both patterns, which may contain the IAM wildcards "*" & "?"
+// unlike path.Match, "*" also matches "/" (as in the ARNs)
+func globsOverlap(a, b string) bool {
+	seen := map[[2]int]bool{}
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		key := [2]int{i, j}
+		if res, ok := seen[key]; ok {
+			return res
+		}
+
+		var res bool
+		switch {
+		case i == len(a) && j == len(b):
+			res = true
+		case i < len(a) && a[i] == '*': // the star of a matches nothing, or what b matches at j
+			res = overlap(i+1, j) || (j < len(b) && overlap(i, j+1))
+		case j < len(b) && b[j] == '*':
+			res = overlap(i, j+1) || (i < len(a) && overlap(i+1, j))
+		case i < len(a) && j < len(b):
+			res = (a[i] == b[j] || a[i] == '?' || b[j] == '?') && overlap(i+1, j+1)
+		}
+
+		seen[key] = res
+		return res
+	}
+	return overlap(0, 0)
+}
+
+// ApprovalDigest identifies the statements approved, any change (time windows included) invalidates the approval
+func (spec PolicySpec) ApprovalDigest() string {
+	b, err := json.Marshal(spec.Statement)
+	if err != nil { // a slice of plain structs can't fail to be marshalled
+		panic(err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Approval returns the digest & the approver of the approval annotation of the irsa (empty if it isn't approved)
+func (irsa IamRoleServiceAccount) Approval() (digest, approver string, err error) {
+	return ParseApproval(irsa.ObjectMeta.Annotations)
+}
+
+// Approval returns the digest & the approver of the approval annotation of the policy (empty if it isn't approved)
+func (p Policy) Approval() (digest, approver string, err error) {
+	return ParseApproval(p.ObjectMeta.Annotations)
+}
+
+// ParseApproval returns the digest & the approver of the approval annotation found in the annotations (empty if there's none)
+func ParseApproval(annotations map[string]string) (digest, approver string, err error) {
+	value, ok := annotations[ApprovalAnnotation]
+	if !ok {
+		return "", "", nil
+	}
+
+	// the digest is hex encoded, the name of the approver may contain ":" (eg. system:serviceaccount:ns:name)
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("invalid " + ApprovalAnnotation + " annotation, expected <digest>:<approver>")
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApproval(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantDigest   string
+		wantApprover string
+		wantErr      bool
+	}{
+		{name: "not approved"},
+		{name: "approved", annotations: map[string]string{ApprovalAnnotation: "5f2b9c1e:alice"}, wantDigest: "5f2b9c1e", wantApprover: "alice"},
+		{name: "approved by a service account", annotations: map[string]string{ApprovalAnnotation: "5f2b9c1e:system:serviceaccount:ns:approver"}, wantDigest: "5f2b9c1e", wantApprover: "system:serviceaccount:ns:approver"},
+		{name: "without approver", annotations: map[string]string{ApprovalAnnotation: "5f2b9c1e:"}, wantErr: true},
+		{name: "without digest", annotations: map[string]string{ApprovalAnnotation: ":alice"}, wantErr: true},
+		{name: "without separator", annotations: map[string]string{ApprovalAnnotation: "5f2b9c1e"}, wantErr: true},
+		{name: "empty", annotations: map[string]string{ApprovalAnnotation: ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		irsa := IamRoleServiceAccount{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+		digest, approver, err := irsa.Approval()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s : error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if digest != tt.wantDigest || approver != tt.wantApprover {
+			t.Errorf("%s : Approval() = %s, %s, want %s, %s", tt.name, digest, approver, tt.wantDigest, tt.wantApprover)
+		}
+	}
+}
+
+func TestApprovalDigest(t *testing.T) {
+	stmts := []StatementSpec{{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}}}
+	digest := PolicySpec{Statement: stmts}.ApprovalDigest()
+
+	expiresAt := metav1.Unix(1700000000, 0)
+	tests := []struct {
+		name  string
+		stmts []StatementSpec
+		same  bool
+	}{
+		{name: "same statements", stmts: []StatementSpec{{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}}}, same: true},
+		{name: "another resource", stmts: []StatementSpec{{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-*", Action: []string{"kms:Decrypt"}}}},
+		{name: "another action", stmts: []StatementSpec{{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:*"}}}},
+		{name: "a time window", stmts: []StatementSpec{{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}, ExpiresAt: &expiresAt}}},
+	}
+
+	for _, tt := range tests {
+		if got := (PolicySpec{Statement: tt.stmts}.ApprovalDigest() == digest); got != tt.same {
+			t.Errorf("%s : same digest = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
+
+func TestGlobsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "iam:*", b: "iam:CreateRole", want: true},
+		{a: "iam:*", b: "*", want: true},
+		{a: "iam:*", b: "s3:*", want: false},
+		{a: "kms:Decrypt", b: "kms:De*", want: true},
+		{a: "kms:Decrypt", b: "kms:Encrypt", want: false},
+		{a: "arn:aws:kms:*:*:key/prod-*", b: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", want: true},
+		{a: "arn:aws:kms:*:*:key/prod-*", b: "arn:aws:kms:eu-west-1:123456789012:key/staging-db", want: false},
+		{a: "arn:aws:s3:::bucket/*", b: "arn:aws:s3:::bucket/a/b", want: true}, // unlike path.Match, * matches /
+		{a: "s3:Get?bject", b: "s3:GetObject", want: true},
+		{a: "s3:Get?", b: "s3:GetObject", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := globsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("globsOverlap(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := globsOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("globsOverlap(%s, %s) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
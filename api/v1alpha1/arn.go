@@ -0,0 +1,193 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// arnPart tells if the region or account of an ARN must be set
+type arnPart int
+
+const (
+	partRequired arnPart = iota
+	partOptional
+	partForbidden
+)
+
+// arnFormat is a valid shape for the ARNs of the resources of a service
+type arnFormat struct {
+	region   arnPart
+	account  arnPart
+	resource *regexp.Regexp // what comes after the account, wildcards included
+	example  string
+}
+
+// arnFormats lists the valid shapes of the ARNs of the most common services, by service
+// the ARNs of the other services only get their partition, region & account checked
+var arnFormats = map[string][]arnFormat{
+	"s3": {
+		{partForbidden, partForbidden, regexp.MustCompile(`^[a-zA-Z0-9._\-*?]+(/.*)?$`), "arn:<partition>:s3:::<bucket>[/<key>]"},
+		{partRequired, partRequired, regexp.MustCompile(`^(accesspoint|job|storage-lens)/[a-zA-Z0-9._\-*?]+(/.*)?$`), "arn:<partition>:s3:<region>:<account>:accesspoint/<name>"},
+	},
+	"dynamodb": {
+		{partRequired, partRequired, regexp.MustCompile(`^(table/[a-zA-Z0-9._\-*?]+(/(index|stream|backup|export|import)/.+)?|global-table/[a-zA-Z0-9._\-*?]+)$`), "arn:<partition>:dynamodb:<region>:<account>:table/<name>[/index/<name>]"},
+	},
+	"sqs": {
+		{partRequired, partRequired, regexp.MustCompile(`^[a-zA-Z0-9_\-*?]+(\.fifo)?$`), "arn:<partition>:sqs:<region>:<account>:<queue>"},
+	},
+	"sns": {
+		{partRequired, partRequired, regexp.MustCompile(`^[a-zA-Z0-9_\-*?]+(\.fifo)?(:[a-zA-Z0-9\-*?]+)?$`), "arn:<partition>:sns:<region>:<account>:<topic>"},
+	},
+	"kms": {
+		{partRequired, partRequired, regexp.MustCompile(`^(key/[a-zA-Z0-9\-*?]+|alias/[a-zA-Z0-9/_\-*?]+)$`), "arn:<partition>:kms:<region>:<account>:key/<id> or alias/<name>"},
+	},
+	"secretsmanager": {
+		{partRequired, partRequired, regexp.MustCompile(`^secret:[a-zA-Z0-9/_+=.@\-*?]+$`), "arn:<partition>:secretsmanager:<region>:<account>:secret:<name>"},
+	},
+	"ssm": {
+		{partRequired, partOptional, regexp.MustCompile(`^[a-zA-Z\-*?]+/.+$`), "arn:<partition>:ssm:<region>:<account>:parameter/<name>"},
+	},
+	"lambda": {
+		{partRequired, partRequired, regexp.MustCompile(`^(function|layer|event-source-mapping|code-signing-config):[a-zA-Z0-9_\-*?]+(:[a-zA-Z0-9$_\-*?]+)?$`), "arn:<partition>:lambda:<region>:<account>:function:<name>[:<qualifier>]"},
+	},
+	"logs": {
+		{partRequired, partRequired, regexp.MustCompile(`^(log-group|destination):.+$`), "arn:<partition>:logs:<region>:<account>:log-group:<name>"},
+	},
+	"kinesis": {
+		{partRequired, partRequired, regexp.MustCompile(`^stream/[a-zA-Z0-9._\-*?]+(/.*)?$`), "arn:<partition>:kinesis:<region>:<account>:stream/<name>"},
+	},
+	"firehose": {
+		{partRequired, partRequired, regexp.MustCompile(`^deliverystream/[a-zA-Z0-9._\-*?]+$`), "arn:<partition>:firehose:<region>:<account>:deliverystream/<name>"},
+	},
+	"ecr": {
+		{partRequired, partRequired, regexp.MustCompile(`^repository/[a-z0-9._/\-*?]+$`), "arn:<partition>:ecr:<region>:<account>:repository/<name>"},
+	},
+	"es": {
+		{partRequired, partRequired, regexp.MustCompile(`^domain/[a-z0-9\-*?]+(/.*)?$`), "arn:<partition>:es:<region>:<account>:domain/<name>"},
+	},
+	"states": {
+		{partRequired, partRequired, regexp.MustCompile(`^(stateMachine|execution|activity|express):.+$`), "arn:<partition>:states:<region>:<account>:stateMachine:<name>"},
+	},
+	"events": {
+		{partRequired, partRequired, regexp.MustCompile(`^(event-bus|rule|archive|replay|connection|api-destination)/.+$`), "arn:<partition>:events:<region>:<account>:event-bus/<name>"},
+	},
+	"iam": {
+		{partForbidden, partRequired, regexp.MustCompile(`^(root|(role|user|group|policy|instance-profile|oidc-provider|saml-provider|server-certificate|mfa)/.+)$`), "arn:<partition>:iam::<account>:role/<name>"},
+	},
+	"sts": {
+		{partForbidden, partRequired, regexp.MustCompile(`^(assumed-role|federated-user)/.+$`), "arn:<partition>:sts::<account>:assumed-role/<role>/<session>"},
+	},
+	"route53": {
+		{partForbidden, partForbidden, regexp.MustCompile(`^(hostedzone|change|healthcheck|delegationset|trafficpolicy|trafficpolicyinstance|queryloggingconfig)/.+$`), "arn:<partition>:route53:::hostedzone/<id>"},
+	},
+}
+
+// partitionOfRegion maps the region prefixes to the partition they belong to (regions not matching any are in "aws")
+var partitionOfRegion = []struct{ prefix, partition string }{
+	{"cn-", "aws-cn"},
+	{"us-gov-", "aws-us-gov"},
+	{"us-isob-", "aws-iso-b"},
+	{"us-iso-", "aws-iso"},
+}
+
+var (
+	regionRegexp  = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	accountRegexp = regexp.MustCompile(`^\d{12}$`)
+)
+
+// validateResourceARN returns an error if the ARN isn't valid for its service, or if its partition & region don't match
+func validateResourceARN(resource string) error {
+	a, err := arn.Parse(resource)
+	if err != nil {
+		return fmt.Errorf("%s is an invalid ARN", resource)
+	}
+
+	partition := ""
+	for _, p := range []string{"aws", "aws-cn", "aws-us-gov", "aws-iso", "aws-iso-b"} {
+		if a.Partition == p {
+			partition = p
+		}
+	}
+	if partition == "" {
+		return fmt.Errorf("%s : unknown partition %s", resource, a.Partition)
+	}
+
+	if a.Region != "" && !isWildcard(a.Region) {
+		if !regionRegexp.MatchString(a.Region) {
+			return fmt.Errorf("%s : invalid region %s", resource, a.Region)
+		}
+		if expected := regionPartition(a.Region); expected != partition {
+			return fmt.Errorf("%s : region %s belongs to the %s partition, not %s", resource, a.Region, expected, partition)
+		}
+	}
+
+	if a.AccountID != "" && !isWildcard(a.AccountID) && !accountRegexp.MatchString(a.AccountID) && !(a.Service == "iam" && a.AccountID == "aws") {
+		return fmt.Errorf("%s : invalid account %s, it must be 12 digits", resource, a.AccountID)
+	}
+
+	formats, ok := arnFormats[a.Service]
+	if !ok {
+		return nil
+	}
+
+	examples := []string{}
+	for _, f := range formats {
+		if f.matches(a) {
+			return nil
+		}
+		examples = append(examples, f.example)
+	}
+	return fmt.Errorf("%s is not a valid %s ARN, expected %s", resource, a.Service, strings.Join(examples, " or "))
+}
+
+func (f arnFormat) matches(a arn.ARN) bool {
+	return f.region.allows(a.Region) && f.account.allows(a.AccountID) && f.resource.MatchString(a.Resource)
+}
+
+func (p arnPart) allows(value string) bool {
+	switch p {
+	case partRequired:
+		return value != ""
+	case partForbidden:
+		return value == ""
+	default:
+		return true
+	}
+}
+
+func regionPartition(region string) string {
+	for _, p := range partitionOfRegion {
+		if strings.HasPrefix(region, p.prefix) {
+			return p.partition
+		}
+	}
+	return "aws"
+}
+
+// ValidateResourceAccounts returns an error if a resource of the statements belongs (or may belong, with a wildcard) to an account that isn't allowed
+// the resources without account in their ARN (eg. s3 buckets) can't be checked, nothing is checked if no account is allowed explicitly
+func (spec PolicySpec) ValidateResourceAccounts(allowedAccountIDs []string) error {
+	if len(allowedAccountIDs) == 0 {
+		return nil
+	}
+
+	for i, stm := range spec.Statement {
+		a, err := arn.Parse(stm.Resource)
+		if err != nil || a.AccountID == "" || (a.Service == "iam" && a.AccountID == "aws") {
+			continue
+		}
+
+		allowed := false
+		for _, id := range allowedAccountIDs {
+			allowed = allowed || a.AccountID == id
+		}
+		if !allowed {
+			return fmt.Errorf("statement :%d : %s is in account %s, only the resources of %s are allowed", i, stm.Resource, a.AccountID, strings.Join(allowedAccountIDs, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterIrsaLabel is set on the service accounts created by a ClusterIamRoleServiceAccount, its value is the name of the ClusterIamRoleServiceAccount
+const ClusterIrsaLabel = "irsa.voodoo.io/cluster-iamroleserviceaccount"
+
+// NewClusterIamRoleServiceAccount is the ClusterIamRoleServiceAccount constructor
+func NewClusterIamRoleServiceAccount(name string, selector metav1.LabelSelector, policyspec PolicySpec) *ClusterIamRoleServiceAccount {
+	return &ClusterIamRoleServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "irsa.voodoo.io/v1alpha1",
+			Kind:       "ClusterIamRoleServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: ClusterIamRoleServiceAccountSpec{
+			NamespaceSelector: selector,
+			Policy:            policyspec,
+		},
+	}
+}
+
+func (irsa ClusterIamRoleServiceAccount) FullName() string {
+	return irsa.ObjectMeta.Name
+}
+
+// HasStatus is used in tests, should be moved there
+func (irsa ClusterIamRoleServiceAccount) HasStatus(st fmt.Stringer) bool {
+	return irsa.Status.Condition.String() == st.String()
+}
+
+// Validate returns an error if the ClusterIamRoleServiceAccountSpec is not valid
+func (irsa ClusterIamRoleServiceAccount) Validate() error {
+	if _, err := metav1.LabelSelectorAsSelector(&irsa.Spec.NamespaceSelector); err != nil {
+		return fmt.Errorf("invalid namespaceSelector : %s", err)
+	}
+
+	return irsa.Spec.Policy.Validate()
+}
+
+// ClusterIamRoleServiceAccountSpec defines the desired state of ClusterIamRoleServiceAccount
+type ClusterIamRoleServiceAccountSpec struct {
+	// NamespaceSelector selects the namespaces where the service account is created, an empty selector matches all of them
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	Policy            PolicySpec           `json:"policy"`
+}
+
+// ClusterIamRoleServiceAccountStatus defines the observed state of ClusterIamRoleServiceAccount
+type ClusterIamRoleServiceAccountStatus struct {
+	Condition  IrsaCondition `json:"condition"`
+	Reason     string        `json:"reason,omitempty"`
+	Namespaces []string      `json:"namespaces,omitempty"` // the namespaces where the service account has been created
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// ClusterIamRoleServiceAccount is the Schema for the clusteriamroleserviceaccounts API
+// it creates the same service account in every selected namespace, all of them trusted by a single role
+type ClusterIamRoleServiceAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterIamRoleServiceAccountSpec   `json:"spec,omitempty"`
+	Status ClusterIamRoleServiceAccountStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterIamRoleServiceAccountList contains a list of ClusterIamRoleServiceAccount
+type ClusterIamRoleServiceAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterIamRoleServiceAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterIamRoleServiceAccount{}, &ClusterIamRoleServiceAccountList{})
+}
@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ../../hack/iamcatalog -out zz_generated.iamcatalog.go
+
+// validateAction returns an error if the action doesn't match any action of the IAM catalog
+// when it's not a wildcard, the closest action of the catalog is suggested
+func validateAction(action string) error {
+	if action == "*" {
+		return nil
+	}
+
+	parts := strings.SplitN(action, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("%s is not of the form <service>:<action>", action)
+	}
+
+	prefixPattern, actionPattern := strings.ToLower(parts[0]), strings.ToLower(parts[1])
+	services := []string{}
+	for prefix := range iamCatalog {
+		if actionMatches(prefixPattern, prefix) {
+			services = append(services, prefix)
+		}
+	}
+	sort.Strings(services)
+
+	if len(services) == 0 {
+		if isWildcard(parts[0]) {
+			return fmt.Errorf("%s doesn't match any IAM service", parts[0])
+		}
+		return fmt.Errorf("%s is not an IAM service%s", parts[0], suggestion(closest(parts[0], iamServices())))
+	}
+
+	candidates := []string{}
+	for _, s := range services {
+		for _, a := range iamCatalog[s] {
+			if actionMatches(actionPattern, strings.ToLower(a)) {
+				return nil
+			}
+			candidates = append(candidates, s+":"+a)
+		}
+	}
+
+	if isWildcard(action) {
+		return fmt.Errorf("%s doesn't match any IAM action", action)
+	}
+	return fmt.Errorf("%s is not an IAM action%s", action, suggestion(closest(action, candidates)))
+}
+
+func iamServices() []string {
+	services := []string{}
+	for prefix := range iamCatalog {
+		services = append(services, prefix)
+	}
+	sort.Strings(services)
+	return services
+}
+
+func isWildcard(s string) bool {
+	return strings.ContainsAny(s, "*?")
+}
+
+func suggestion(closest string) string {
+	if closest == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s ?", closest)
+}
+
+// closest returns the candidate with the smallest edit distance to s (case insensitive), the first one on ties
+func closest(s string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(s), strings.ToLower(c)); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the levenshtein distance between a & b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,58 @@
+package v1alpha1
+
+import "testing"
+
+func TestValidateAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		wantErr string
+	}{
+		{action: "*"},
+		{action: "s3:GetObject"},
+		{action: "S3:getobject"}, // the actions are case insensitive
+		{action: "s3:Get*"},
+		{action: "*:GetObject"},
+		{action: "s3:GetObjekt", wantErr: "s3:GetObjekt is not an IAM action, did you mean s3:GetObject ?"},
+		{action: "s3:PutObjetAcl", wantErr: "s3:PutObjetAcl is not an IAM action, did you mean s3:PutObjectAcl ?"},
+		{action: "sqs:SendMesage", wantErr: "sqs:SendMesage is not an IAM action, did you mean sqs:SendMessage ?"},
+		{action: "dynamodb:GetIten", wantErr: "dynamodb:GetIten is not an IAM action, did you mean dynamodb:GetItem ?"},
+		{action: "dynamdb:GetItem", wantErr: "dynamdb is not an IAM service, did you mean dynamodb ?"},
+		{action: "s3:Gett*", wantErr: "s3:Gett* doesn't match any IAM action"},
+		{action: "foo*:GetObject", wantErr: "foo* doesn't match any IAM service"},
+		{action: "s3", wantErr: "s3 is not of the form <service>:<action>"},
+		{action: "s3:", wantErr: "s3: is not of the form <service>:<action>"},
+	}
+
+	for _, tt := range tests {
+		err := validateAction(tt.action)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateAction(%s) = %v, want no error", tt.action, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("validateAction(%s) = %v, want %q", tt.action, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "getobject", b: "", want: 9},
+		{a: "getobject", b: "getobject", want: 0},
+		{a: "getobjekt", b: "getobject", want: 1},
+		{a: "getobject", b: "getobjectacl", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package v1alpha1
 
 import (
+	"errors"
 	"fmt"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // NewIamRoleServiceAccount is the IamRoleServiceAccount constructor
@@ -38,19 +40,107 @@ func (irsa IamRoleServiceAccount) IsPendingDeletion() bool {
 }
 
 // Validate returns an error if the IamRoleServiceAccountSpec is not valid
+// its policy must hold the statements of its templates (see PolicyTemplateSpec.Expand)
 func (irsa IamRoleServiceAccount) Validate() error {
+	used := map[string]struct{}{}
+	for _, t := range irsa.Spec.Templates {
+		if t.Name == "" {
+			return errors.New("template without name")
+		}
+		if _, ok := used[t.Name]; ok {
+			return fmt.Errorf("template %s is used twice", t.Name)
+		}
+		used[t.Name] = struct{}{}
+	}
+
+	names := map[string]struct{}{irsa.ObjectMeta.Name: {}}
+	for _, name := range irsa.Spec.AdditionalServiceAccounts {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid service account name %q : %s", name, errs[0])
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("service account %s is listed twice", name)
+		}
+		names[name] = struct{}{}
+	}
+
+	if err := ValidateTrustedPrincipals(irsa.Spec.AdditionalTrustedPrincipals); err != nil {
+		return err
+	}
+
+	refs := map[string]struct{}{}
+	for _, ref := range irsa.Spec.PolicyRefs {
+		if ref.Name == "" {
+			return errors.New("policyRef without name")
+		}
+		if _, ok := refs[ref.Name]; ok {
+			return fmt.Errorf("policy %s is referenced twice", ref.Name)
+		}
+		refs[ref.Name] = struct{}{}
+	}
+
+	if len(irsa.Spec.Policy.Statement) == 0 && len(irsa.Spec.PolicyRefs) > 0 { // the role only gets shared policies
+		return nil
+	}
+
 	return irsa.Spec.Policy.Validate()
 }
 
+// PolicyRefNames returns the names of the shared policies referenced by the irsa
+func (irsa IamRoleServiceAccount) PolicyRefNames() []string {
+	names := []string{}
+	for _, ref := range irsa.Spec.PolicyRefs {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+// ServiceAccountNames returns the names of the service accounts created by the irsa, the first one has the name of the irsa
+func (irsa IamRoleServiceAccount) ServiceAccountNames() []string {
+	return append([]string{irsa.ObjectMeta.Name}, irsa.Spec.AdditionalServiceAccounts...)
+}
+
+// AdditionalServiceAccountRefs returns the additional service accounts trusted by the role of the irsa
+func (irsa IamRoleServiceAccount) AdditionalServiceAccountRefs() []ServiceAccountRef {
+	refs := []ServiceAccountRef{}
+	for _, name := range irsa.Spec.AdditionalServiceAccounts {
+		refs = append(refs, ServiceAccountRef{Namespace: irsa.ObjectMeta.Namespace, Name: name})
+	}
+	return refs
+}
+
 // IamRoleServiceAccountSpec defines the desired state of IamRoleServiceAccount
 type IamRoleServiceAccountSpec struct {
-	Policy PolicySpec `json:"policy"`
+	Policy PolicySpec `json:"policy,omitempty"`
+	// Templates are expanded & appended to the statements of the policy
+	Templates []TemplateRef `json:"templates,omitempty"`
+	// PolicyRefs are Policies of the same namespace, shared by several irsas, attached to the role along with its own policy
+	PolicyRefs []PolicyRef `json:"policyRefs,omitempty"`
+	// AdditionalServiceAccounts are the names of other service accounts of the namespace created along with the one named after the irsa, all of them share its role
+	AdditionalServiceAccounts []string `json:"additionalServiceAccounts,omitempty"`
+	// AdditionalTrustedPrincipals are IAM principals (eg. the role of an SRE during an incident) allowed to assume the role, until they expire
+	AdditionalTrustedPrincipals []TrustedPrincipal `json:"additionalTrustedPrincipals,omitempty"`
+}
+
+// PolicyRef references a Policy of the same namespace
+type PolicyRef struct {
+	Name string `json:"name"`
+}
+
+// TemplateRef references a PolicyTemplate & gives the values of its parameters
+type TemplateRef struct {
+	Name string            `json:"name"`
+	Args map[string]string `json:"args,omitempty"`
 }
 
 // IamRoleServiceAccountStatus defines the observed state of IamRoleServiceAccount
 type IamRoleServiceAccountStatus struct {
 	Condition IrsaCondition `json:"condition"`
 	Reason    string        `json:"reason,omitempty"`
+	// InheritedStatement lists the statements of the NamespaceIrsaDefaults added to the policy
+	InheritedStatement []StatementSpec `json:"inheritedStatement,omitempty"`
+	// AuthorizedDigest identifies the last statements (its own, of its templates, inherited & of its shared policies) whose actions its requester has been allowed to grant
+	AuthorizedDigest string `json:"authorizedDigest,omitempty"`
 }
 
 type IrsaCondition string
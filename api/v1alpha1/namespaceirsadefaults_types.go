@@ -0,0 +1,60 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewNamespaceIrsaDefaults is the NamespaceIrsaDefaults constructor
+func NewNamespaceIrsaDefaults(name, ns string, stm []StatementSpec) *NamespaceIrsaDefaults {
+	return &NamespaceIrsaDefaults{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "irsa.voodoo.io/v1alpha1",
+			Kind:       "NamespaceIrsaDefaults",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: NamespaceIrsaDefaultsSpec{
+			Statement: stm,
+		},
+	}
+}
+
+func (d NamespaceIrsaDefaults) FullName() string {
+	return d.ObjectMeta.Namespace + "/" + d.ObjectMeta.Name
+}
+
+// IsPendingDeletion tells if the defaults are being deleted, they're not inherited anymore
+func (d NamespaceIrsaDefaults) IsPendingDeletion() bool {
+	return !d.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// NamespaceIrsaDefaultsSpec holds the statements inherited by every IamRoleServiceAccount of the namespace
+// they can use the same placeholders as the statements of the IamRoleServiceAccounts (eg. ${irsaName})
+type NamespaceIrsaDefaultsSpec struct {
+	Statement []StatementSpec `json:"statement"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceIrsaDefaults is the Schema for the namespaceirsadefaults API
+type NamespaceIrsaDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceIrsaDefaultsSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceIrsaDefaultsList contains a list of NamespaceIrsaDefaults
+type NamespaceIrsaDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceIrsaDefaults `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceIrsaDefaults{}, &NamespaceIrsaDefaultsList{})
+}
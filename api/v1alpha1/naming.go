@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultNameTemplate is the naming convention historically used by the operator
+	DefaultNameTemplate = "irsa-op-{{ .ClusterName }}-{{ .Namespace }}-{{ .Name }}"
+	// DefaultRootPath is the IAM path under which all the resources of the operator are created
+	DefaultRootPath = "/irsa-operator/"
+
+	awsNameMaxLength = 64 // IAM roles names can't be longer
+	hashLength       = 10
+)
+
+var validAwsName = regexp.MustCompile(`^[\w+=,.@-]+$`)
+
+// Naming generates the names & paths of the resources on AWS
+// names must be unique per AWS account thus the cluster name in them
+// +kubebuilder:object:generate=false
+type Naming struct {
+	ClusterName string
+	RootPath    string
+	tmpl        *template.Template
+}
+
+// NewNaming constructs a Naming, the nameTemplate is a text/template using the .ClusterName, .Namespace & .Name fields
+func NewNaming(cN, nameTemplate, rootPath string) (Naming, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return Naming{}, fmt.Errorf("invalid name template : %s", err)
+	}
+
+	if !strings.HasPrefix(rootPath, "/") || !strings.HasSuffix(rootPath, "/") {
+		return Naming{}, fmt.Errorf("iam path `%s` must begin and end with a /", rootPath)
+	}
+
+	n := Naming{ClusterName: cN, RootPath: rootPath, tmpl: tmpl}
+	if _, err := n.render("namespace", "name"); err != nil { // we ensure the template can actually be used
+		return Naming{}, fmt.Errorf("invalid name template : %s", err)
+	}
+
+	return n, nil
+}
+
+// Name is the name the resource will have on AWS
+// if the rendered template exceeds the IAM limit, it's truncated and suffixed by a hash of the full name (to remain unique & deterministic)
+// the template is checked by the constructor, it can still fail on some inputs (eg. a function called on the namespace)
+func (n Naming) Name(obj metav1.Object) (string, error) {
+	name, err := n.render(obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return "", fmt.Errorf("can't render the name template for %s/%s : %s", obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	if len(name) <= awsNameMaxLength {
+		return name, nil
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	return name[:awsNameMaxLength-hashLength-1] + "-" + hex.EncodeToString(sum[:])[:hashLength], nil
+}
+
+// PathPrefix is the "directory" where the resources of obj will be available
+func (n Naming) PathPrefix(obj metav1.Object) string {
+	return fmt.Sprintf("%s%s/%s/%s/", n.RootPath, n.ClusterName, obj.GetNamespace(), obj.GetName())
+}
+
+// ClusterPathPrefix is the "directory" where all the resources of the cluster are available
+func (n Naming) ClusterPathPrefix() string {
+	return fmt.Sprintf("%s%s/", n.RootPath, n.ClusterName)
+}
+
+func (n Naming) render(ns, name string) (string, error) {
+	var b bytes.Buffer
+	if err := n.tmpl.Execute(&b, struct{ ClusterName, Namespace, Name string }{n.ClusterName, ns, name}); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// validateAwsName returns an error if the name can't be used on AWS
+func validateAwsName(name string) error {
+	if !validAwsName.MatchString(name) {
+		return fmt.Errorf("aws name `%s` contains forbidden characters", name)
+	}
+
+	if len(name) > awsNameMaxLength {
+		return fmt.Errorf("aws name `%s` is too long", name)
+	}
+
+	return nil
+}
+
+// awsPathFromARN extracts the path of an IAM resource from its ARN (eg. arn:aws:iam::123456789012:policy/a/b/name -> /a/b/)
+func awsPathFromARN(arn string) string {
+	resource := arn[strings.LastIndex(arn, ":")+1:] // <type>/<path><name>
+	first, last := strings.Index(resource, "/"), strings.LastIndex(resource, "/")
+	if first < 0 {
+		return "/"
+	}
+	return resource[first : last+1]
+}
+
+// awsNameFromARN extracts the name of an IAM resource from its ARN (the last element of its path)
+func awsNameFromARN(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,81 @@
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestPolicyPath(t *testing.T) {
+	n, err := NewNaming("cluster", DefaultNameTemplate, "/new-path/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		wantPath   string
+		wantPrefix string
+	}{
+		{
+			name:       "not created yet",
+			policy:     *NewPolicy("name", "ns", nil),
+			wantPath:   "/new-path/cluster/ns/name/policy/",
+			wantPrefix: "/new-path/cluster/",
+		},
+		{
+			name:       "recorded in the status",
+			policy:     Policy{Status: PolicyStatus{AwsPath: "/old-path/cluster/ns/name/policy/"}},
+			wantPath:   "/old-path/cluster/ns/name/policy/",
+			wantPrefix: "/old-path/cluster/",
+		},
+		{
+			name:       "created before the path was recorded",
+			policy:     Policy{Spec: PolicySpec{ARN: "arn:aws:iam::123456789012:policy/old-path/cluster/ns/name/policy/irsa-op-cluster-ns-name"}},
+			wantPath:   "/old-path/cluster/ns/name/policy/",
+			wantPrefix: "/old-path/cluster/",
+		},
+		{
+			name:       "created without path",
+			policy:     Policy{Spec: PolicySpec{ARN: "arn:aws:iam::123456789012:policy/irsa-op-cluster-ns-name"}},
+			wantPath:   "/",
+			wantPrefix: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		tt.policy.Name, tt.policy.Namespace = "name", "ns"
+		if got := tt.policy.Path(n); got != tt.wantPath {
+			t.Errorf("%s : Path() = %s, want %s", tt.name, got, tt.wantPath)
+		}
+		if got := tt.policy.ClusterPathPrefix(n); got != tt.wantPrefix {
+			t.Errorf("%s : ClusterPathPrefix() = %s, want %s", tt.name, got, tt.wantPrefix)
+		}
+	}
+}
+
+func TestRolePath(t *testing.T) {
+	n, err := NewNaming("cluster", DefaultNameTemplate, "/new-path/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		role     Role
+		wantPath string
+	}{
+		{name: "not created yet", role: *NewRole("name", "ns"), wantPath: "/new-path/cluster/ns/name/role/"},
+		{name: "recorded in the status", role: Role{Status: RoleStatus{AwsPath: "/old-path/cluster/ns/name/role/"}}, wantPath: "/old-path/cluster/ns/name/role/"},
+		{name: "created before the path was recorded", role: Role{Spec: RoleSpec{RoleARN: "arn:aws:iam::123456789012:role/old-path/cluster/ns/name/role/irsa-op-cluster-ns-name"}}, wantPath: "/old-path/cluster/ns/name/role/"},
+	}
+
+	for _, tt := range tests {
+		tt.role.Name, tt.role.Namespace = "name", "ns"
+		if got := tt.role.Path(n); got != tt.wantPath {
+			t.Errorf("%s : Path() = %s, want %s", tt.name, got, tt.wantPath)
+		}
+		if got, want := tt.role.ClusterPathPrefix(n), tt.wantPath[:len(tt.wantPath)-len("ns/name/role/")]; got != want {
+			t.Errorf("%s : ClusterPathPrefix() = %s, want %s", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// Placeholders holds the values of the placeholders (eg. ${namespace}) the operator resolves in the resources & condition values of the statements
+// +kubebuilder:object:generate=false
+type Placeholders struct {
+	ClusterName string
+	AccountID   string
+	Region      string
+}
+
+// NewPlaceholders takes the account & region of the cluster from the ARN of its oidc provider
+// (arn:aws:iam::<account>:oidc-provider/oidc.eks.<region>.amazonaws.com/id/<id>)
+// they're left empty when the provider isn't the one of an EKS cluster (eg. a self-hosted issuer),
+// the policies using ${accountId} or ${region} then fail to be rendered
+func NewPlaceholders(clusterName, oidcProviderARN string) (Placeholders, error) {
+	a, err := arn.Parse(oidcProviderARN)
+	if err != nil {
+		return Placeholders{}, fmt.Errorf("%s is an invalid ARN", oidcProviderARN)
+	}
+
+	p := Placeholders{ClusterName: clusterName, AccountID: a.AccountID}
+	if submatches := eksIssuerRegexp.FindStringSubmatch(a.Resource); len(submatches) == 2 {
+		p.Region = submatches[1]
+	}
+	return p, nil
+}
+
+var eksIssuerRegexp = regexp.MustCompile(`oidc\.eks\.([a-z0-9-]+)\.amazonaws\.com`)
+
+var placeholderRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// values returns the value of each placeholder for the resource ns/name
+func (p Placeholders) values(ns, name string) map[string]string {
+	return map[string]string{
+		"namespace":   ns,
+		"irsaName":    name,
+		"clusterName": p.ClusterName,
+		"accountId":   p.AccountID,
+		"region":      p.Region,
+	}
+}
+
+// Render returns a copy of the spec where the placeholders are replaced by their values for the resource ns/name
+func (spec PolicySpec) Render(p Placeholders, ns, name string) (PolicySpec, error) {
+	values := p.values(ns, name)
+	rendered := *spec.DeepCopy()
+	for i, stm := range rendered.Statement {
+		resource, err := render(stm.Resource, values)
+		if err != nil {
+			return PolicySpec{}, fmt.Errorf("statement :%d : %s", i, err)
+		}
+		rendered.Statement[i].Resource = resource
+
+		for _, keys := range stm.Condition {
+			for key, condValues := range keys {
+				for j, v := range condValues {
+					if keys[key][j], err = render(v, values); err != nil {
+						return PolicySpec{}, fmt.Errorf("statement :%d : condition %s : %s", i, key, err)
+					}
+				}
+			}
+		}
+	}
+
+	return rendered, nil
+}
+
+// HasPlaceholders tells if the resources or the condition values of the statements hold placeholders
+func (spec PolicySpec) HasPlaceholders() bool {
+	for _, stm := range spec.Statement {
+		if placeholderRegexp.MatchString(stm.Resource) {
+			return true
+		}
+		for _, keys := range stm.Condition {
+			for _, values := range keys {
+				for _, v := range values {
+					if placeholderRegexp.MatchString(v) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func render(s string, values map[string]string) (string, error) {
+	var unknown, missing string
+	rendered := placeholderRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderRegexp.FindStringSubmatch(match)[1]
+		v, ok := values[name]
+		if !ok && unknown == "" {
+			unknown = match
+		}
+		if ok && v == "" && missing == "" {
+			missing = match
+		}
+		return v
+	})
+
+	if missing != "" { // only ${accountId} & ${region} can be missing
+		return "", fmt.Errorf("placeholder %s in %s has no value, it can't be found in the ARN of the oidc provider", missing, s)
+	}
+
+	if unknown != "" {
+		known := []string{}
+		for name := range values {
+			known = append(known, "${"+name+"}")
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown placeholder %s in %s, the available ones are %s", unknown, s, strings.Join(known, ", "))
+	}
+
+	return rendered, nil
+}
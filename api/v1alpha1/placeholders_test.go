@@ -0,0 +1,108 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewPlaceholders(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    Placeholders
+		wantErr bool
+	}{
+		{
+			arn:  "arn:aws:iam::123456789012:oidc-provider/oidc.eks.eu-west-1.amazonaws.com/id/ABCDEF",
+			want: Placeholders{ClusterName: "cluster", AccountID: "123456789012", Region: "eu-west-1"},
+		},
+		{ // self-hosted issuer, the region is unknown
+			arn:  "arn:aws:iam::123456789012:oidc-provider/hydra.local",
+			want: Placeholders{ClusterName: "cluster", AccountID: "123456789012"},
+		},
+		{arn: "hydra.local", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NewPlaceholders("cluster", tt.arn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPlaceholders(%s) error = %v, wantErr %v", tt.arn, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NewPlaceholders(%s) = %+v, want %+v", tt.arn, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	eks := Placeholders{ClusterName: "cluster", AccountID: "123456789012", Region: "eu-west-1"}
+	selfHosted := Placeholders{ClusterName: "cluster", AccountID: "123456789012"}
+
+	tests := []struct {
+		name         string
+		placeholders Placeholders
+		resource     string
+		condition    string
+		want         string
+		wantCond     string
+		wantErr      string
+	}{
+		{
+			name: "every placeholder", placeholders: eks,
+			resource: "arn:aws:sqs:${region}:${accountId}:${clusterName}-${namespace}-${irsaName}",
+			want:     "arn:aws:sqs:eu-west-1:123456789012:cluster-ns-name",
+		},
+		{
+			name: "condition values", placeholders: eks,
+			resource: "*", condition: "${namespace}/*",
+			want: "*", wantCond: "ns/*",
+		},
+		{
+			name: "no region, not used", placeholders: selfHosted,
+			resource: "arn:aws:s3:::${clusterName}-${namespace}/*",
+			want:     "arn:aws:s3:::cluster-ns/*",
+		},
+		{
+			name: "no region, used", placeholders: selfHosted,
+			resource: "arn:aws:sqs:${region}:${accountId}:queue",
+			wantErr:  "placeholder ${region} in arn:aws:sqs:${region}:${accountId}:queue has no value",
+		},
+		{
+			name: "unknown placeholder", placeholders: eks,
+			resource: "arn:aws:s3:::${bucket}",
+			wantErr:  "unknown placeholder ${bucket}",
+		},
+	}
+
+	for _, tt := range tests {
+		stm := StatementSpec{Resource: tt.resource, Action: []string{"s3:GetObject"}}
+		if tt.condition != "" {
+			stm.Condition = Condition{"StringLike": {"s3:prefix": {tt.condition}}}
+		}
+		spec := PolicySpec{Statement: []StatementSpec{stm}}
+
+		got, err := spec.Render(tt.placeholders, "ns", "name")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s : error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s : unexpected error %v", tt.name, err)
+			continue
+		}
+
+		if got.Statement[0].Resource != tt.want {
+			t.Errorf("%s : resource = %s, want %s", tt.name, got.Statement[0].Resource, tt.want)
+		}
+		if tt.condition != "" {
+			if v := got.Statement[0].Condition["StringLike"]["s3:prefix"][0]; v != tt.wantCond {
+				t.Errorf("%s : condition = %s, want %s", tt.name, v, tt.wantCond)
+			}
+			if spec.Statement[0].Condition["StringLike"]["s3:prefix"][0] != tt.condition {
+				t.Errorf("%s : the spec has been modified", tt.name)
+			}
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package v1alpha1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/arn"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -42,7 +48,7 @@ func (p Policy) IsPendingDeletion() bool {
 // PolicySpec describes the policy that must be present on AWS
 type PolicySpec struct {
 	ARN       string          `json:"arn,omitempty"` // the ARN of the aws policy
-	Statement []StatementSpec `json:"statement"`
+	Statement []StatementSpec `json:"statement,omitempty"`
 }
 
 // Validate returns an error if the PolicySpec is not valid
@@ -60,76 +66,264 @@ func (spec PolicySpec) Validate() error {
 	return nil
 }
 
+// Hash identifies the statements of the spec (in their canonical form), it's used to know if they've already been applied on AWS
+func (spec PolicySpec) Hash() string {
+	b, err := json.Marshal(CanonicalStatements(spec.Statement))
+	if err != nil { // a slice of plain structs can't fail to be marshalled
+		panic(err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// MaxPolicyDocumentSize is the number of characters IAM accepts in the document of a managed policy
+	MaxPolicyDocumentSize = 6144
+	// MaxAttachedPoliciesPerRole is the number of managed policies IAM accepts to attach to a role (default quota)
+	MaxAttachedPoliciesPerRole = 10
+	// MaxTrustPolicySize is the number of characters IAM accepts in the trust policy of a role (default quota)
+	MaxTrustPolicySize = 2048
+)
+
+// documentStatement mirrors the statements of the document sent to AWS (see aws.NewPolicyDocumentString)
+// +kubebuilder:object:generate=false
+type documentStatement struct {
+	Effect    string
+	Action    []string
+	Resource  string
+	Condition Condition `json:",omitempty"`
+}
+
+// emptyDocumentSize is the size of {"Version":"2012-10-17","Statement":[]}
+const emptyDocumentSize = 39
+
+func statementSize(s StatementSpec) int {
+	b, err := json.Marshal(documentStatement{Effect: "Allow", Action: s.Action, Resource: s.Resource, Condition: s.Condition})
+	if err != nil { // a plain struct can't fail to be marshalled
+		panic(err)
+	}
+	return len(b)
+}
+
+// DocumentSize returns the number of characters of the policy document holding these statements on AWS
+func DocumentSize(stmts []StatementSpec) int {
+	size := emptyDocumentSize
+	for i, s := range CanonicalStatements(stmts) {
+		if i > 0 {
+			size++ // the comma between statements
+		}
+		size += statementSize(s)
+	}
+	return size
+}
+
+// Shards splits the canonical statements of the spec across as few policy documents as possible, in order
+// each document fits in MaxPolicyDocumentSize, an error is returned if it takes more than maxShards documents
+func (spec PolicySpec) Shards(maxShards int) ([][]StatementSpec, error) {
+	shards := [][]StatementSpec{}
+	current := []StatementSpec{}
+	size := emptyDocumentSize
+	for _, s := range CanonicalStatements(spec.Statement) {
+		sSize := statementSize(s)
+		if emptyDocumentSize+sSize > MaxPolicyDocumentSize {
+			return nil, fmt.Errorf("statement on %s : its document is %d characters long, it exceeds the %d characters IAM accepts in a policy", s.Resource, emptyDocumentSize+sSize, MaxPolicyDocumentSize)
+		}
+
+		if len(current) > 0 {
+			if size+1+sSize <= MaxPolicyDocumentSize {
+				current = append(current, s)
+				size += 1 + sSize
+				continue
+			}
+			shards = append(shards, current)
+		}
+
+		current = []StatementSpec{s}
+		size = emptyDocumentSize + sSize
+	}
+	shards = append(shards, current)
+
+	if len(shards) > maxShards {
+		return nil, fmt.Errorf("the statements (%d characters) need %d policies of at most %d characters, only %d can be attached to the role", DocumentSize(spec.Statement), len(shards), MaxPolicyDocumentSize, maxShards)
+	}
+
+	return shards, nil
+}
+
 // StatementSpec defines an aws statement (Sid is autogenerated & Effect is always "allow")
 type StatementSpec struct {
-	Resource string   `json:"resource"` // ARN of the target aws resource
-	Action   []string `json:"action"`   // the list of requested permissions on the aws resource above
+	Resource  string    `json:"resource"`            // ARN of the target aws resource
+	Action    []string  `json:"action"`              // the list of requested permissions on the aws resource above
+	Condition Condition `json:"condition,omitempty"` // when the permissions are granted
+	// NotBefore & ExpiresAt bound the time window during which the statement is in the policy on AWS (eg. a temporary access)
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
+// Condition holds the values expected for each condition key, by condition operator (eg. {"StringEquals": {"aws:PrincipalTag/team": ["a"]}})
+type Condition map[string]map[string][]string
+
 // Validate returns an error if the StatementSpec is not valid
 func (spec StatementSpec) Validate() error {
-	if !arn.IsARN(spec.Resource) {
-		return fmt.Errorf("%s is an invalid ARN", spec.Resource)
+	if err := validateResourceARN(spec.Resource); err != nil {
+		return err
 	}
 
 	if len(spec.Action) == 0 {
 		return errors.New("empty action array provided")
 	}
 
+	if spec.NotBefore != nil && spec.ExpiresAt != nil && !spec.ExpiresAt.After(spec.NotBefore.Time) {
+		return errors.New("expiresAt must be after notBefore")
+	}
+
+	for op, keys := range spec.Condition {
+		if len(keys) == 0 {
+			return fmt.Errorf("condition %s : no condition key provided", op)
+		}
+		for key, values := range keys {
+			if len(values) == 0 {
+				return fmt.Errorf("condition %s %s : no value provided", op, key)
+			}
+		}
+	}
+
 	for i, a := range spec.Action {
 		if a == "" {
 			return fmt.Errorf("action #%d: empty action provided", i)
 		}
+		if err := validateAction(a); err != nil {
+			return fmt.Errorf("action #%d: %s", i, err)
+		}
 	}
 
 	return nil
 }
 
-// IsSame is used to detect meaningful difference between 2 StatementSpec
-// ie : order of .Action elements is not taken into account
-func (a StatementSpec) IsSame(b StatementSpec) bool {
-	if a.Resource != b.Resource {
-		return false
+// CanonicalStatements returns the statements the way they're sent to AWS :
+// the statements on the same resource (with the same conditions) are merged,
+// duplicated actions & the ones covered by a wildcard of the statement are dropped, then everything is sorted
+func CanonicalStatements(stmts []StatementSpec) []StatementSpec {
+	merged := map[string]*StatementSpec{}
+	keys := []string{}
+	for _, s := range stmts {
+		cond := s.Condition.canonical()
+		key := s.Resource + "|" + cond.key()
+		if _, ok := merged[key]; !ok {
+			merged[key] = &StatementSpec{Resource: s.Resource, Condition: cond}
+			keys = append(keys, key)
+		}
+		merged[key].Action = append(merged[key].Action, s.Action...)
 	}
+	sort.Strings(keys)
 
-	if len(a.Action) != len(b.Action) {
-		return false
+	canonical := []StatementSpec{}
+	for _, k := range keys {
+		s := merged[k]
+		s.Action = compactActions(s.Action)
+		canonical = append(canonical, *s)
+	}
+	return canonical
+}
+
+// canonical returns a copy of the condition with its values deduped & sorted (nil if empty)
+func (c Condition) canonical() Condition {
+	if len(c) == 0 {
+		return nil
 	}
 
-	// we must ignore actions order
-	for _, sA := range a.Action {
-		diff := true
-		for _, sB := range b.Action {
-			if sA == sB {
-				diff = false
+	out := Condition{}
+	for op, keys := range c {
+		out[op] = map[string][]string{}
+		for key, values := range keys {
+			unique := []string{}
+			for _, v := range values {
+				if !containsValue(unique, v) {
+					unique = append(unique, v)
+				}
+			}
+			sort.Strings(unique)
+			out[op][key] = unique
+		}
+	}
+	return out
+}
+
+// key identifies the condition (maps are marshalled with sorted keys)
+func (c Condition) key() string {
+	b, err := json.Marshal(c)
+	if err != nil { // maps of strings can't fail to be marshalled
+		panic(err)
+	}
+	return string(b)
+}
+
+func containsValue(values []string, v string) bool {
+	for _, e := range values {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// compactActions dedupes the actions (they're case insensitive) & drops the ones covered by a wildcard
+func compactActions(actions []string) []string {
+	unique := map[string]string{} // the first spelling of each action, by its lower case form
+	for _, a := range actions {
+		if _, ok := unique[strings.ToLower(a)]; !ok {
+			unique[strings.ToLower(a)] = a
+		}
+	}
+
+	compacted := []string{}
+	for a, spelling := range unique {
+		covered := false
+		for other := range unique {
+			// when 2 wildcards cover each other (eg. "s3:*" & "s3:**"), only the first one is kept
+			if other != a && actionMatches(other, a) && (!actionMatches(a, other) || other < a) {
+				covered = true
 				break
 			}
 		}
-		if diff {
-			return false
+		if !covered {
+			compacted = append(compacted, spelling)
 		}
 	}
-	return true
+	sort.Slice(compacted, func(i, j int) bool { return strings.ToLower(compacted[i]) < strings.ToLower(compacted[j]) })
+	return compacted
+}
+
+// actionMatches tells if the (lower case) action is matched by the pattern, which may contain the IAM wildcards "*" & "?"
+func actionMatches(pattern, action string) bool {
+	matched, err := path.Match(pattern, action)
+	return err == nil && matched
+}
+
+// IsSame is used to detect meaningful difference between 2 StatementSpec
+// ie : order & duplicates of .Action elements are not taken into account
+func (a StatementSpec) IsSame(b StatementSpec) bool {
+	return StatementEquals([]StatementSpec{a}, []StatementSpec{b})
 }
 
 // StatementEquals is used to detect meaningful difference between 2 StatementSpec slices
-// ie : order of elements is not taken into account
+// ie : their canonical forms are compared, so order, duplicates & redundant statements are not taken into account
 func StatementEquals(a, b []StatementSpec) bool {
-	if len(a) != len(b) {
+	cA, cB := CanonicalStatements(a), CanonicalStatements(b)
+	if len(cA) != len(cB) {
 		return false
 	}
 
-	for _, sA := range a {
-		diff := true
-		for _, sB := range b {
-			if sA.IsSame(sB) {
-				diff = false
-				break
-			}
-		}
-		if diff {
+	for i := range cA {
+		if cA[i].Resource != cB[i].Resource || cA[i].Condition.key() != cB[i].Condition.key() || len(cA[i].Action) != len(cB[i].Action) {
 			return false
 		}
+		for j := range cA[i].Action {
+			if !strings.EqualFold(cA[i].Action[j], cB[i].Action[j]) {
+				return false
+			}
+		}
 	}
 	return true
 }
@@ -138,6 +332,116 @@ func StatementEquals(a, b []StatementSpec) bool {
 type PolicyStatus struct {
 	Condition CrCondition `json:"condition"`
 	Reason    string      `json:"reason,omitempty"`
+	AwsName   string      `json:"awsName,omitempty"` // the name chosen for the policy on AWS
+	AwsPath   string      `json:"awsPath,omitempty"` // the IAM path chosen for the policy (& its shards) on AWS
+
+	AppliedHash      string       `json:"appliedHash,omitempty"`      // the Policy.ActiveHash of the statements last applied on AWS
+	AppliedVersionID string       `json:"appliedVersionId,omitempty"` // the IAM version of the policy holding them
+	LastFullSyncTime *metav1.Time `json:"lastFullSyncTime,omitempty"` // the last time the document on AWS has been compared to the spec
+
+	PendingHash  string       `json:"pendingHash,omitempty"`  // the Policy.ActiveHash of the statements waiting to be applied
+	PendingSince *metav1.Time `json:"pendingSince,omitempty"` // since when they're waiting (the spec is debounced)
+
+	Versions []PolicyVersion `json:"versions,omitempty"` // the versions of the policy on AWS created by the operator, the most recent last
+
+	ShardARNs []string `json:"shardARNs,omitempty"` // the additional policies holding the statements that don't fit in this one (see PolicySpec.Shards)
+
+	RenderedStatement []StatementSpec `json:"renderedStatement,omitempty"` // the statements applied on AWS, placeholders resolved (in their canonical form)
+
+	AuthorizedDigest string `json:"authorizedDigest,omitempty"` // identifies the last statements of a shared policy whose actions its requester has been allowed to grant
+}
+
+const (
+	// MaxPolicyVersions is the number of versions IAM keeps for a policy
+	MaxPolicyVersions = 5
+	// RollbackToVersionAnnotation set on a Policy restores the statements of the given (retained) version in its spec (or in the one of the IamRoleServiceAccount owning it)
+	// they're approved & authorized again like any change, the version is then made the default one on AWS & the annotation is removed
+	RollbackToVersionAnnotation = "irsa.voodoo.io/rollback-to-version"
+)
+
+// PolicyVersion is a version of the policy document on AWS
+type PolicyVersion struct {
+	VersionID    string       `json:"versionId"`
+	CreateDate   *metav1.Time `json:"createDate,omitempty"`
+	IsDefault    bool         `json:"isDefault,omitempty"`    // if it's the version currently used on AWS
+	Generation   int64        `json:"generation,omitempty"`   // the generation of the Policy whose spec produced this version (unknown if not created by the operator)
+	DocumentHash string       `json:"documentHash,omitempty"` // the PolicySpec.Hash of the statements of this version
+}
+
+// RecordVersion adds v to the versions of the policy, only the ones retained by IAM are kept
+func (s *PolicyStatus) RecordVersion(v PolicyVersion) {
+	for _, known := range s.Versions {
+		if known.VersionID == v.VersionID {
+			return
+		}
+	}
+
+	s.Versions = append(s.Versions, v)
+	if len(s.Versions) > MaxPolicyVersions {
+		s.Versions = s.Versions[len(s.Versions)-MaxPolicyVersions:]
+	}
+}
+
+// SetRetainedVersions replaces the versions of the policy by the ones actually retained on AWS (sorted from the oldest)
+// what's been recorded about them (generation & document hash) is kept
+func (s *PolicyStatus) SetRetainedVersions(retained []PolicyVersion) {
+	known := map[string]PolicyVersion{}
+	for _, v := range s.Versions {
+		known[v.VersionID] = v
+	}
+
+	versions := []PolicyVersion{}
+	for _, v := range retained {
+		if k, ok := known[v.VersionID]; ok {
+			v.Generation = k.Generation
+			v.DocumentHash = k.DocumentHash
+		}
+		versions = append(versions, v)
+	}
+
+	s.Versions = versions
+}
+
+// Version returns the recorded version with the given ID
+func (s PolicyStatus) Version(versionID string) (PolicyVersion, bool) {
+	for _, v := range s.Versions {
+		if v.VersionID == versionID {
+			return v, true
+		}
+	}
+
+	return PolicyVersion{}, false
+}
+
+// VersionWithHash returns the recorded version holding the statements of the given hash
+func (s PolicyStatus) VersionWithHash(hash string) (PolicyVersion, bool) {
+	for _, v := range s.Versions {
+		if v.DocumentHash != "" && v.DocumentHash == hash {
+			return v, true
+		}
+	}
+
+	return PolicyVersion{}, false
+}
+
+// ActiveHash is the PolicySpec.Hash of the statements sent to AWS at the given time : rendered & in their time window
+func (p Policy) ActiveHash(placeholders Placeholders, now time.Time) (string, error) {
+	spec, err := p.Spec.Render(placeholders, p.Namespace, p.Name)
+	if err != nil {
+		return "", err
+	}
+	return spec.ActiveAt(now).Hash(), nil
+}
+
+// IsApplied tells if the statements of the spec have been applied on AWS as versionID
+// (as long as nobody changed the document on AWS in between, thus the regular full comparisons)
+// the statements out of their time window at the given time aren't applied (see PolicySpec.ActiveAt)
+func (p Policy) IsApplied(versionID string, placeholders Placeholders, now time.Time) bool {
+	hash, err := p.ActiveHash(placeholders, now)
+	return err == nil &&
+		p.Status.AppliedHash != "" &&
+		p.Status.AppliedHash == hash &&
+		p.Status.AppliedVersionID == versionID
 }
 
 func NewPolicyStatus(condition CrCondition, reason string) PolicyStatus {
@@ -160,34 +464,58 @@ type Policy struct {
 }
 
 // Validate returns an error if the Policy is not valid
-func (p Policy) Validate(cN string) error {
+func (p Policy) Validate(n Naming) error {
 	if err := p.Spec.Validate(); err != nil {
 		return err
 	}
 
-	awsName := p.AwsName(cN)
-	if len(awsName) > 64 {
-		return fmt.Errorf("genereated uniqueName ( `%s` ) is too long ", awsName)
+	name, err := p.AwsName(n)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return validateAwsName(name)
 }
 
 // AwsName is the name the resource will have on AWS
-// It must be unique per AWS account thus the naming convention
-func (p Policy) AwsName(cN string) string {
-	return fmt.Sprintf("irsa-op-%s-%s-%s", cN, p.ObjectMeta.Namespace, p.ObjectMeta.Name)
+// once recorded in the status, it's kept even if the naming template changes afterwards
+func (p Policy) AwsName(n Naming) (string, error) {
+	if p.Status.AwsName != "" {
+		return p.Status.AwsName, nil
+	}
+
+	if p.Spec.ARN != "" { // created before the name was recorded in the status
+		return awsNameFromARN(p.Spec.ARN), nil
+	}
+
+	return n.Name(&p.ObjectMeta)
 }
 
-// PathPrefix is the "directory" where the policy will be available
-// It's used to retrieved a policy on AWS
-func (p Policy) PathPrefix(cN string) string {
-	return fmt.Sprintf("/irsa-operator/%s/%s/%s/", cN, p.ObjectMeta.Namespace, p.ObjectMeta.Name)
+// ShardAwsName is the name on AWS of the policy holding the i-th shard of the statements (the first one is held by the policy itself)
+func (p Policy) ShardAwsName(n Naming, i int) (string, error) {
+	name, err := p.AwsName(n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d", name, i), nil
+}
+
+// Path is the "file" where the policy (& its shards) will be available, it's used to retrieve them on AWS
+// once recorded in the status, it's kept even if the IAM path changes afterwards
+func (p Policy) Path(n Naming) string {
+	if p.Status.AwsPath != "" {
+		return p.Status.AwsPath
+	}
+
+	if p.Spec.ARN != "" { // created before the path was recorded in the status
+		return awsPathFromARN(p.Spec.ARN)
+	}
+
+	return fmt.Sprintf("%spolicy/", n.PathPrefix(&p.ObjectMeta))
 }
 
-// Path is the "file" where the policy will be available
-func (p Policy) Path(cN string) string {
-	return fmt.Sprintf("%spolicy/", p.PathPrefix(cN))
+// ClusterPathPrefix is the "directory" of the cluster the policy has been created under (eg. before the IAM path changed)
+func (p Policy) ClusterPathPrefix(n Naming) string {
+	return strings.TrimSuffix(p.Path(n), fmt.Sprintf("%s/%s/policy/", p.Namespace, p.Name))
 }
 
 // +kubebuilder:object:root=true
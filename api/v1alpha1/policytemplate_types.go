@@ -0,0 +1,215 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewPolicyTemplate is the PolicyTemplate constructor
+func NewPolicyTemplate(name string, params []TemplateParameter, stm []StatementSpec) *PolicyTemplate {
+	return &PolicyTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "irsa.voodoo.io/v1alpha1",
+			Kind:       "PolicyTemplate",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: PolicyTemplateSpec{
+			Parameters: params,
+			Statement:  stm,
+		},
+	}
+}
+
+func (t PolicyTemplate) FullName() string {
+	return t.ObjectMeta.Name
+}
+
+// PolicyTemplateSpec describes statements reusable by several IamRoleServiceAccounts
+// the resources & condition values of the statements can use the parameters as ${params.<name>}
+type PolicyTemplateSpec struct {
+	Parameters []TemplateParameter `json:"parameters,omitempty"`
+	Statement  []StatementSpec     `json:"statement"`
+}
+
+// TemplateParameter is a parameter of a PolicyTemplate, its value is given by the IamRoleServiceAccounts using the template
+type TemplateParameter struct {
+	Name string        `json:"name"`
+	Type ParameterType `json:"type,omitempty"`
+	// Default is used when the IamRoleServiceAccount doesn't give any value, the parameter is required if it's not set
+	Default *string `json:"default,omitempty"`
+}
+
+// ParameterType tells which values a parameter accepts
+// +kubebuilder:validation:Enum=string;arn;accountId;region
+type ParameterType string
+
+var (
+	ParamString    ParameterType = "string"
+	ParamARN       ParameterType = "arn"
+	ParamAccountID ParameterType = "accountId"
+	ParamRegion    ParameterType = "region"
+)
+
+var (
+	paramNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+	paramRefRegexp  = regexp.MustCompile(`\$\{params\.([^}]*)\}`)
+)
+
+// Validate returns an error if the PolicyTemplateSpec is not valid
+// the statements are only fully validated once expanded, since the parameters can be anywhere in their resources
+func (spec PolicyTemplateSpec) Validate() error {
+	if len(spec.Statement) == 0 {
+		return errors.New("empty PolicyTemplate.spec.statement")
+	}
+
+	declared := map[string]struct{}{}
+	for _, p := range spec.Parameters {
+		if !paramNameRegexp.MatchString(p.Name) {
+			return fmt.Errorf("invalid parameter name %q", p.Name)
+		}
+		if _, ok := declared[p.Name]; ok {
+			return fmt.Errorf("parameter %s is declared twice", p.Name)
+		}
+		declared[p.Name] = struct{}{}
+
+		if p.Default != nil {
+			if err := p.validateValue(*p.Default); err != nil {
+				return fmt.Errorf("default of %s", err)
+			}
+		}
+	}
+
+	for i, stm := range spec.Statement {
+		for _, s := range stm.templatedStrings() {
+			for _, m := range paramRefRegexp.FindAllStringSubmatch(s, -1) {
+				if _, ok := declared[m[1]]; !ok {
+					return fmt.Errorf("statement :%d : parameter %s is used but not declared", i, m[1])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Expand returns the statements of the template where the parameters are replaced by the args (or their default value)
+// the other placeholders (eg. ${namespace}) are left as is
+func (spec PolicyTemplateSpec) Expand(args map[string]string) ([]StatementSpec, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, p := range spec.Parameters {
+		v, ok := args[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("parameter %s is required", p.Name)
+			}
+			v = *p.Default
+		}
+
+		if err := p.validateValue(v); err != nil {
+			return nil, err
+		}
+		values[p.Name] = v
+	}
+
+	unknown := []string{}
+	for name := range args {
+		if _, ok := values[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameters %s", strings.Join(unknown, ", "))
+	}
+
+	expand := func(s string) string {
+		return paramRefRegexp.ReplaceAllStringFunc(s, func(match string) string {
+			return values[paramRefRegexp.FindStringSubmatch(match)[1]]
+		})
+	}
+
+	stmts := []StatementSpec{}
+	for _, stm := range spec.Statement {
+		expanded := *stm.DeepCopy()
+		expanded.Resource = expand(expanded.Resource)
+		for _, keys := range expanded.Condition {
+			for key, condValues := range keys {
+				for j, v := range condValues {
+					keys[key][j] = expand(v)
+				}
+			}
+		}
+		stmts = append(stmts, expanded)
+	}
+
+	return stmts, nil
+}
+
+func (p TemplateParameter) validateValue(v string) error {
+	switch p.Type {
+	case ParamARN:
+		if _, err := arn.Parse(v); err != nil {
+			return fmt.Errorf("parameter %s : %s is not an ARN", p.Name, v)
+		}
+	case ParamAccountID:
+		if !accountRegexp.MatchString(v) {
+			return fmt.Errorf("parameter %s : %s is not an account id, it must be 12 digits", p.Name, v)
+		}
+	case ParamRegion:
+		if !regionRegexp.MatchString(v) {
+			return fmt.Errorf("parameter %s : %s is not a region", p.Name, v)
+		}
+	default:
+		if v == "" {
+			return fmt.Errorf("parameter %s : empty value", p.Name)
+		}
+	}
+	return nil
+}
+
+// templatedStrings returns the parts of the statement where parameters & placeholders can be used
+func (stm StatementSpec) templatedStrings() []string {
+	s := []string{stm.Resource}
+	for _, keys := range stm.Condition {
+		for _, values := range keys {
+			s = append(s, values...)
+		}
+	}
+	return s
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// PolicyTemplate is the Schema for the policytemplates API
+type PolicyTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PolicyTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PolicyTemplateList contains a list of PolicyTemplate
+type PolicyTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyTemplate{}, &PolicyTemplateList{})
+}
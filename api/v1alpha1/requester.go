@@ -0,0 +1,107 @@
+package v1alpha1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// RequesterAnnotation is set by the admission webhook to the user who last changed the spec of an IamRoleServiceAccount or of a Policy
+// (or asked for the rollback of a Policy), its value is the JSON of a Requester
+const RequesterAnnotation = "irsa.voodoo.io/requester"
+
+// AwsActionsResource is the virtual resource of the SubjectAccessReviews checking a requester may grant an AWS action :
+// the service prefix (eg. "s3") is its name & the action (eg. "getobject", "get*" or "*") is the verb
+const AwsActionsResource = "awsactions"
+
+// Requester is the user (as authenticated by the API server) who requested the statements of an IamRoleServiceAccount or of a Policy
+// +kubebuilder:object:generate=false
+type Requester struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// Requester returns the requester recorded by the admission webhook (ok is false if none has been recorded)
+func (irsa IamRoleServiceAccount) Requester() (_ Requester, ok bool, err error) {
+	return parseRequester(irsa.ObjectMeta.Annotations)
+}
+
+// SetRequester records the requester in the annotations of the irsa
+func (irsa *IamRoleServiceAccount) SetRequester(r Requester) {
+	irsa.ObjectMeta.Annotations = withRequester(irsa.ObjectMeta.Annotations, r)
+}
+
+// Requester returns the user who last changed the spec of the policy or asked for its rollback, recorded by the admission webhook (ok is false if none has been recorded)
+func (p Policy) Requester() (_ Requester, ok bool, err error) {
+	return parseRequester(p.ObjectMeta.Annotations)
+}
+
+// SetRequester records the requester in the annotations of the policy
+func (p *Policy) SetRequester(r Requester) {
+	p.ObjectMeta.Annotations = withRequester(p.ObjectMeta.Annotations, r)
+}
+
+func parseRequester(annotations map[string]string) (_ Requester, ok bool, err error) {
+	value, ok := annotations[RequesterAnnotation]
+	if !ok {
+		return Requester{}, false, nil
+	}
+
+	var r Requester
+	if err := json.Unmarshal([]byte(value), &r); err != nil || r.Username == "" {
+		return Requester{}, false, errors.New("invalid " + RequesterAnnotation + " annotation")
+	}
+	return r, true, nil
+}
+
+func withRequester(annotations map[string]string, r Requester) map[string]string {
+	b, err := json.Marshal(r)
+	if err != nil { // a plain struct can't fail to be marshalled
+		panic(err)
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RequesterAnnotation] = string(b)
+	return annotations
+}
+
+// AuthorizationDigest identifies the statements & the requester allowed to grant their actions, any change of either needs a new check
+func (r Requester) AuthorizationDigest(stmts []StatementSpec) string {
+	b, err := json.Marshal(struct {
+		Requester Requester       `json:"requester"`
+		Statement []StatementSpec `json:"statement"`
+	}{r, stmts})
+	if err != nil { // plain structs can't fail to be marshalled
+		panic(err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// GrantVerbs returns the service prefix of the action & the verbs allowing to grant it, from the most specific to the least :
+// the action itself, then the wildcards covering it (eg. "s3:GetObject" is granted by "getobject", "getobjec*", ..., "g*" & "*")
+func GrantVerbs(action string) (service string, verbs []string) {
+	action = strings.ToLower(action) // the actions are case insensitive
+	parts := strings.SplitN(action, ":", 2)
+	if len(parts) != 2 { // "*" covers every service
+		return action, []string{"*"}
+	}
+
+	service, name := parts[0], parts[1]
+	verbs = []string{name}
+	literal := name // the wildcards can only cover the literal prefix of the name
+	if i := strings.IndexAny(name, "*?"); i >= 0 {
+		literal = name[:i]
+	}
+	for i := len(literal); i >= 0; i-- {
+		if v := literal[:i] + "*"; v != name {
+			verbs = append(verbs, v)
+		}
+	}
+	return service, verbs
+}
@@ -3,7 +3,11 @@ package v1alpha1
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/arn"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -33,24 +37,50 @@ func (r Role) HasStatus(st fmt.Stringer) bool {
 	return r.Status.Condition.String() == st.String()
 }
 
-// Validate returns an error if the Policy is not valid
-func (r Role) Validate(cN string) error {
+// Validate returns an error if the Role is not valid
+func (r Role) Validate(n Naming) error {
 	if err := r.Spec.Validate(); err != nil {
 		return err
 	}
 
-	awsName := r.AwsName(cN)
-	if len(awsName) > 64 {
-		return fmt.Errorf("aws name is too long : %s", awsName)
+	name, err := r.AwsName(n)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return validateAwsName(name)
 }
 
 // AwsName is the name the resource will have on AWS
-// It must be unique per AWS account thus the naming convention
-func (r Role) AwsName(cN string) string {
-	return fmt.Sprintf("irsa-op-%s-%s-%s", cN, r.ObjectMeta.Namespace, r.ObjectMeta.Name)
+// once recorded in the status, it's kept even if the naming template changes afterwards
+func (r Role) AwsName(n Naming) (string, error) {
+	if r.Status.AwsName != "" {
+		return r.Status.AwsName, nil
+	}
+
+	if r.Spec.RoleARN != "" { // created before the name was recorded in the status
+		return awsNameFromARN(r.Spec.RoleARN), nil
+	}
+
+	return n.Name(&r.ObjectMeta)
+}
+
+// Path is the "file" where the role will be available
+// once recorded in the status, it's kept even if the IAM path changes afterwards
+func (r Role) Path(n Naming) string {
+	if r.Status.AwsPath != "" {
+		return r.Status.AwsPath
+	}
+
+	if r.Spec.RoleARN != "" { // created before the path was recorded in the status
+		return awsPathFromARN(r.Spec.RoleARN)
+	}
+
+	return fmt.Sprintf("%srole/", n.PathPrefix(&r.ObjectMeta))
+}
+
+// ClusterPathPrefix is the "directory" of the cluster the role has been created under (eg. before the IAM path changed)
+func (r Role) ClusterPathPrefix(n Naming) string {
+	return strings.TrimSuffix(r.Path(n), fmt.Sprintf("%s/%s/role/", r.Namespace, r.Name))
 }
 
 // IsPendingDeletion helps us to detect if the resource should be deleted
@@ -60,25 +90,147 @@ func (r Role) IsPendingDeletion() bool {
 
 // RoleSpec defines the desired state of Role
 type RoleSpec struct {
-	ServiceAccountName             string `json:"serviceAccountName"`
-	PolicyARN                      string `json:"policyarn,omitempty"`
-	RoleARN                        string `json:"rolearn,omitempty"`
-	PermissionsBoundariesPolicyArn string `json:"permissionsBoundariesPolicyARN,omitempty"`
+	ServiceAccountName             string              `json:"serviceAccountName,omitempty"`
+	ServiceAccounts                []ServiceAccountRef `json:"serviceAccounts,omitempty"` // the service accounts trusted by the role along with the one above
+	PolicyARN                      string              `json:"policyarn,omitempty"`
+	PolicyShardARNs                []string            `json:"policyShardARNs,omitempty"` // the additional policies holding the statements that don't fit in the one above
+	PolicyRefs                     []string            `json:"policyRefs,omitempty"`      // the shared Policies (of the same namespace) attached to the role
+	AdditionalTrustedPrincipals    []TrustedPrincipal  `json:"additionalTrustedPrincipals,omitempty"`
+	RoleARN                        string              `json:"rolearn,omitempty"`
+	PermissionsBoundariesPolicyArn string              `json:"permissionsBoundariesPolicyARN,omitempty"`
 }
 
 // Validate returns an error if the RoleSpec is not valid
 func (spec RoleSpec) Validate() error {
-	if spec.ServiceAccountName == "" {
+	if spec.ServiceAccountName == "" && len(spec.ServiceAccounts) == 0 {
 		return errors.New("empty string provided as spec.ServiceAccountName")
 	}
 
+	for _, sa := range spec.ServiceAccounts {
+		if sa.Namespace == "" || sa.Name == "" {
+			return fmt.Errorf("invalid service account %s/%s", sa.Namespace, sa.Name)
+		}
+	}
+
+	return ValidateTrustedPrincipals(spec.AdditionalTrustedPrincipals)
+}
+
+// TrustedPrincipal is an IAM principal (eg. the role of an SRE) allowed to assume the role along with the service accounts
+type TrustedPrincipal struct {
+	ARN        string `json:"arn"`
+	ExternalID string `json:"externalID,omitempty"` // required as sts:ExternalId when assuming the role, if set
+	// ExpiresAt is when the principal is removed from the trust policy, it's trusted until the entry is removed if not set
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// IsActive tells if the principal must be in the trust policy at the given time
+func (p TrustedPrincipal) IsActive(now time.Time) bool {
+	return p.ExpiresAt == nil || now.Before(p.ExpiresAt.Time)
+}
+
+// String is used in the events & status reasons
+func (p TrustedPrincipal) String() string {
+	if p.ExpiresAt == nil {
+		return p.ARN
+	}
+	return fmt.Sprintf("%s (until %s)", p.ARN, p.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// ValidateTrustedPrincipals returns an error if a principal isn't an IAM or STS ARN or is listed twice
+func ValidateTrustedPrincipals(principals []TrustedPrincipal) error {
+	seen := map[string]struct{}{}
+	for _, p := range principals {
+		a, err := arn.Parse(p.ARN)
+		if err != nil || (a.Service != "iam" && a.Service != "sts") {
+			return fmt.Errorf("trusted principal %q is not an IAM ARN", p.ARN)
+		}
+		if _, ok := seen[p.ARN]; ok {
+			return fmt.Errorf("trusted principal %s is listed twice", p.ARN)
+		}
+		seen[p.ARN] = struct{}{}
+	}
 	return nil
 }
 
+// TrustedPrincipalsEqual tells if both lists hold the same principals, in the same order
+func TrustedPrincipalsEqual(a, b []TrustedPrincipal) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ARN != b[i].ARN || a[i].ExternalID != b[i].ExternalID || !a[i].ExpiresAt.Equal(b[i].ExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// ActivePrincipals returns the additional principals the trust policy must hold at the given time, sorted by ARN
+func (r Role) ActivePrincipals(now time.Time) []TrustedPrincipal {
+	active := []TrustedPrincipal{}
+	for _, p := range r.Spec.AdditionalTrustedPrincipals {
+		if p.IsActive(now) {
+			active = append(active, p)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ARN < active[j].ARN })
+	return active
+}
+
+// NextPrincipalExpiry returns how long until the next active principal expires (0 if none will)
+func (r Role) NextPrincipalExpiry(now time.Time) time.Duration {
+	var next time.Duration
+	for _, p := range r.ActivePrincipals(now) {
+		if p.ExpiresAt == nil {
+			continue
+		}
+		if d := p.ExpiresAt.Sub(now); next == 0 || d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+// ServiceAccountRef identifies a service account, possibly in another namespace than the role
+type ServiceAccountRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// TrustedSubjects returns the subjects (system:serviceaccount:<namespace>:<name>) of the service accounts allowed to assume the role, sorted
+func (r Role) TrustedSubjects() []string {
+	subjects := []string{}
+	add := func(ns, name string) {
+		s := fmt.Sprintf("system:serviceaccount:%s:%s", ns, name)
+		for _, existing := range subjects {
+			if existing == s {
+				return
+			}
+		}
+		subjects = append(subjects, s)
+	}
+
+	if r.Spec.ServiceAccountName != "" {
+		add(r.Namespace, r.Spec.ServiceAccountName)
+	}
+	for _, sa := range r.Spec.ServiceAccounts {
+		add(sa.Namespace, sa.Name)
+	}
+
+	sort.Strings(subjects)
+	return subjects
+}
+
 // RoleStatus defines the observed state of Role
 type RoleStatus struct {
-	Condition CrCondition `json:"condition"`
-	Reason    string      `json:"reason,omitempty"`
+	Condition       CrCondition `json:"condition"`
+	Reason          string      `json:"reason,omitempty"`
+	AwsName         string      `json:"awsName,omitempty"`         // the name chosen for the role on AWS
+	AwsPath         string      `json:"awsPath,omitempty"`         // the IAM path chosen for the role on AWS
+	TrustedSubjects []string    `json:"trustedSubjects,omitempty"` // the service accounts trusted by the role on AWS
+	// TrustedPrincipals are the additional principals currently trusted by the role on AWS (the expired ones are removed)
+	TrustedPrincipals []TrustedPrincipal `json:"trustedPrincipals,omitempty"`
+	LastResyncTime    *metav1.Time       `json:"lastResyncTime,omitempty"` // the last time the policies attached to the role on AWS have been read bypassing the cache
 }
 
 func NewRoleStatus(condition CrCondition, reason string) RoleStatus {
@@ -0,0 +1,77 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NoopStatement stands for a policy whose statements are all out of their time window :
+// IAM rejects the documents without statement, sts:GetCallerIdentity is allowed to anybody anyway
+var NoopStatement = StatementSpec{Resource: "*", Action: []string{"sts:GetCallerIdentity"}}
+
+// IsActive tells if the statement must be in the policy on AWS at the given time
+func (spec StatementSpec) IsActive(now time.Time) bool {
+	if spec.NotBefore != nil && now.Before(spec.NotBefore.Time) {
+		return false
+	}
+	return spec.ExpiresAt == nil || now.Before(spec.ExpiresAt.Time)
+}
+
+// ActiveAt returns a copy of the spec only holding the statements active at the given time
+// (NoopStatement if none is), it's what's applied on AWS
+func (spec PolicySpec) ActiveAt(now time.Time) PolicySpec {
+	active := *spec.DeepCopy()
+	active.Statement = []StatementSpec{}
+	for _, stm := range spec.Statement {
+		if stm.IsActive(now) {
+			active.Statement = append(active.Statement, *stm.DeepCopy())
+		}
+	}
+
+	if len(active.Statement) == 0 {
+		active.Statement = []StatementSpec{*NoopStatement.DeepCopy()}
+	}
+	return active
+}
+
+// HasTimeWindows tells if a statement is limited to a time window
+func (spec PolicySpec) HasTimeWindows() bool {
+	for _, stm := range spec.Statement {
+		if stm.NotBefore != nil || stm.ExpiresAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NextBoundary returns how long until a statement enters or leaves its time window (0 if none will)
+func (spec PolicySpec) NextBoundary(now time.Time) time.Duration {
+	var next time.Duration
+	for _, stm := range spec.Statement {
+		for _, t := range []*metav1.Time{stm.NotBefore, stm.ExpiresAt} {
+			if t == nil || !t.After(now) {
+				continue
+			}
+			if d := t.Sub(now); next == 0 || d < next {
+				next = d
+			}
+		}
+	}
+	return next
+}
+
+// StatementSpecEquals tells if both lists hold the same statements, time windows included
+// (unlike StatementEquals, which only compares what's applied on AWS)
+func StatementSpecEquals(a, b []StatementSpec) bool {
+	if !StatementEquals(a, b) || len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].NotBefore.Equal(b[i].NotBefore) || !a[i].ExpiresAt.Equal(b[i].ExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
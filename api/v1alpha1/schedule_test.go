@@ -0,0 +1,49 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestActiveAt(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	always := StatementSpec{Resource: "arn:aws:s3:::always", Action: []string{"s3:GetObject"}}
+	started := StatementSpec{Resource: "arn:aws:s3:::started", Action: []string{"s3:GetObject"}, NotBefore: at(-time.Hour)}
+	upcoming := StatementSpec{Resource: "arn:aws:s3:::upcoming", Action: []string{"s3:GetObject"}, NotBefore: at(time.Hour)}
+	expired := StatementSpec{Resource: "arn:aws:s3:::expired", Action: []string{"s3:GetObject"}, ExpiresAt: at(-time.Minute)}
+	expiring := StatementSpec{Resource: "arn:aws:s3:::expiring", Action: []string{"s3:GetObject"}, ExpiresAt: at(30 * time.Minute)}
+	window := StatementSpec{Resource: "arn:aws:s3:::window", Action: []string{"s3:GetObject"}, NotBefore: at(2 * time.Hour), ExpiresAt: at(3 * time.Hour)}
+	endsNow := StatementSpec{Resource: "arn:aws:s3:::ends-now", Action: []string{"s3:GetObject"}, ExpiresAt: at(0)}
+
+	tests := []struct {
+		name         string
+		stmts        []StatementSpec
+		wantActive   []StatementSpec
+		wantBoundary time.Duration
+	}{
+		{name: "no time window", stmts: []StatementSpec{always}, wantActive: []StatementSpec{always}},
+		{name: "started", stmts: []StatementSpec{always, started}, wantActive: []StatementSpec{always, started}},
+		{name: "upcoming", stmts: []StatementSpec{always, upcoming}, wantActive: []StatementSpec{always}, wantBoundary: time.Hour},
+		{name: "expired", stmts: []StatementSpec{expired, always}, wantActive: []StatementSpec{always}},
+		{name: "the expiry is excluded", stmts: []StatementSpec{endsNow, always}, wantActive: []StatementSpec{always}},
+		{name: "the closest boundary", stmts: []StatementSpec{window, expiring, upcoming}, wantActive: []StatementSpec{expiring}, wantBoundary: 30 * time.Minute},
+		{name: "none active", stmts: []StatementSpec{expired, window}, wantActive: []StatementSpec{NoopStatement}, wantBoundary: 2 * time.Hour},
+		{name: "no statement", stmts: nil, wantActive: []StatementSpec{NoopStatement}},
+	}
+
+	for _, tt := range tests {
+		spec := PolicySpec{Statement: tt.stmts}
+		if got := spec.ActiveAt(now).Statement; !StatementSpecEquals(got, tt.wantActive) {
+			t.Errorf("%s : ActiveAt = %+v, want %+v", tt.name, got, tt.wantActive)
+		}
+		if got := spec.NextBoundary(now); got != tt.wantBoundary {
+			t.Errorf("%s : NextBoundary = %s, want %s", tt.name, got, tt.wantBoundary)
+		}
+	}
+}
@@ -6,6 +6,7 @@ type CrCondition string
 var (
 	CrSubmitted   CrCondition = ""
 	CrPending     CrCondition = "pending"
+	CrForbidden   CrCondition = "forbidden"
 	CrProgressing CrCondition = "progressing"
 	CrOK          CrCondition = "created"
 	CrDeleting    CrCondition = "deleting"
@@ -0,0 +1,9342 @@
+// Code generated by hack/iamcatalog. DO NOT EDIT.
+
+package v1alpha1
+
+// iamCatalog lists the actions of each IAM service prefix (lower case)
+var iamCatalog = map[string][]string{
+	"a4b": {
+		"ApproveSkill",
+		"AssociateContactWithAddressBook",
+		"AssociateDeviceWithNetworkProfile",
+		"AssociateDeviceWithRoom",
+		"AssociateSkillGroupWithRoom",
+		"AssociateSkillWithSkillGroup",
+		"AssociateSkillWithUsers",
+		"CreateAddressBook",
+		"CreateBusinessReportSchedule",
+		"CreateConferenceProvider",
+		"CreateContact",
+		"CreateGatewayGroup",
+		"CreateNetworkProfile",
+		"CreateProfile",
+		"CreateRoom",
+		"CreateSkillGroup",
+		"CreateUser",
+		"DeleteAddressBook",
+		"DeleteBusinessReportSchedule",
+		"DeleteConferenceProvider",
+		"DeleteContact",
+		"DeleteDevice",
+		"DeleteDeviceUsageData",
+		"DeleteGatewayGroup",
+		"DeleteNetworkProfile",
+		"DeleteProfile",
+		"DeleteRoom",
+		"DeleteRoomSkillParameter",
+		"DeleteSkillAuthorization",
+		"DeleteSkillGroup",
+		"DeleteUser",
+		"DisassociateContactFromAddressBook",
+		"DisassociateDeviceFromRoom",
+		"DisassociateSkillFromSkillGroup",
+		"DisassociateSkillFromUsers",
+		"DisassociateSkillGroupFromRoom",
+		"ForgetSmartHomeAppliances",
+		"GetAddressBook",
+		"GetConferencePreference",
+		"GetConferenceProvider",
+		"GetContact",
+		"GetDevice",
+		"GetGateway",
+		"GetGatewayGroup",
+		"GetInvitationConfiguration",
+		"GetNetworkProfile",
+		"GetProfile",
+		"GetRoom",
+		"GetRoomSkillParameter",
+		"GetSkillGroup",
+		"ListBusinessReportSchedules",
+		"ListConferenceProviders",
+		"ListDeviceEvents",
+		"ListGatewayGroups",
+		"ListGateways",
+		"ListSkills",
+		"ListSkillsStoreCategories",
+		"ListSkillsStoreSkillsByCategory",
+		"ListSmartHomeAppliances",
+		"ListTags",
+		"PutConferencePreference",
+		"PutInvitationConfiguration",
+		"PutRoomSkillParameter",
+		"PutSkillAuthorization",
+		"RegisterAVSDevice",
+		"RejectSkill",
+		"ResolveRoom",
+		"RevokeInvitation",
+		"SearchAddressBooks",
+		"SearchContacts",
+		"SearchDevices",
+		"SearchNetworkProfiles",
+		"SearchProfiles",
+		"SearchRooms",
+		"SearchSkillGroups",
+		"SearchUsers",
+		"SendAnnouncement",
+		"SendInvitation",
+		"StartDeviceSync",
+		"StartSmartHomeApplianceDiscovery",
+		"TagResource",
+		"UntagResource",
+		"UpdateAddressBook",
+		"UpdateBusinessReportSchedule",
+		"UpdateConferenceProvider",
+		"UpdateContact",
+		"UpdateDevice",
+		"UpdateGateway",
+		"UpdateGatewayGroup",
+		"UpdateNetworkProfile",
+		"UpdateProfile",
+		"UpdateRoom",
+		"UpdateSkillGroup",
+	},
+	"access-analyzer": {
+		"ApplyArchiveRule",
+		"CreateAccessPreview",
+		"CreateAnalyzer",
+		"CreateArchiveRule",
+		"DeleteAnalyzer",
+		"DeleteArchiveRule",
+		"GetAccessPreview",
+		"GetAnalyzedResource",
+		"GetAnalyzer",
+		"GetArchiveRule",
+		"GetFinding",
+		"ListAccessPreviewFindings",
+		"ListAccessPreviews",
+		"ListAnalyzedResources",
+		"ListAnalyzers",
+		"ListArchiveRules",
+		"ListFindings",
+		"ListTagsForResource",
+		"StartResourceScan",
+		"TagResource",
+		"UntagResource",
+		"UpdateArchiveRule",
+		"UpdateFindings",
+	},
+	"acm": {
+		"AddTagsToCertificate",
+		"DeleteCertificate",
+		"DescribeCertificate",
+		"ExportCertificate",
+		"GetAccountConfiguration",
+		"GetCertificate",
+		"ImportCertificate",
+		"ListCertificates",
+		"ListTagsForCertificate",
+		"PutAccountConfiguration",
+		"RemoveTagsFromCertificate",
+		"RenewCertificate",
+		"RequestCertificate",
+		"ResendValidationEmail",
+		"UpdateCertificateOptions",
+	},
+	"acm-pca": {
+		"CreateCertificateAuthority",
+		"CreateCertificateAuthorityAuditReport",
+		"CreatePermission",
+		"DeleteCertificateAuthority",
+		"DeletePermission",
+		"DeletePolicy",
+		"DescribeCertificateAuthority",
+		"DescribeCertificateAuthorityAuditReport",
+		"GetCertificate",
+		"GetCertificateAuthorityCertificate",
+		"GetCertificateAuthorityCsr",
+		"GetPolicy",
+		"ImportCertificateAuthorityCertificate",
+		"IssueCertificate",
+		"ListCertificateAuthorities",
+		"ListPermissions",
+		"ListTags",
+		"PutPolicy",
+		"RestoreCertificateAuthority",
+		"RevokeCertificate",
+		"TagCertificateAuthority",
+		"UntagCertificateAuthority",
+		"UpdateCertificateAuthority",
+	},
+	"airflow": {
+		"CreateCliToken",
+		"CreateEnvironment",
+		"CreateWebLoginToken",
+		"DeleteEnvironment",
+		"GetEnvironment",
+		"ListEnvironments",
+		"ListTagsForResource",
+		"PublishMetrics",
+		"TagResource",
+		"UntagResource",
+		"UpdateEnvironment",
+	},
+	"amplify": {
+		"CreateApp",
+		"CreateBackendEnvironment",
+		"CreateBranch",
+		"CreateDeployment",
+		"CreateDomainAssociation",
+		"CreateWebhook",
+		"DeleteApp",
+		"DeleteBackendEnvironment",
+		"DeleteBranch",
+		"DeleteDomainAssociation",
+		"DeleteJob",
+		"DeleteWebhook",
+		"GenerateAccessLogs",
+		"GetApp",
+		"GetArtifactUrl",
+		"GetBackendEnvironment",
+		"GetBranch",
+		"GetDomainAssociation",
+		"GetJob",
+		"GetWebhook",
+		"ListApps",
+		"ListArtifacts",
+		"ListBackendEnvironments",
+		"ListBranches",
+		"ListDomainAssociations",
+		"ListJobs",
+		"ListTagsForResource",
+		"ListWebhooks",
+		"StartDeployment",
+		"StartJob",
+		"StopJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateApp",
+		"UpdateBranch",
+		"UpdateDomainAssociation",
+		"UpdateWebhook",
+	},
+	"amplifybackend": {
+		"CloneBackend",
+		"CreateBackend",
+		"CreateBackendAPI",
+		"CreateBackendAuth",
+		"CreateBackendConfig",
+		"CreateToken",
+		"DeleteBackend",
+		"DeleteBackendAPI",
+		"DeleteBackendAuth",
+		"DeleteToken",
+		"GenerateBackendAPIModels",
+		"GetBackend",
+		"GetBackendAPI",
+		"GetBackendAPIModels",
+		"GetBackendAuth",
+		"GetBackendJob",
+		"GetToken",
+		"ListBackendJobs",
+		"RemoveAllBackends",
+		"RemoveBackendConfig",
+		"UpdateBackendAPI",
+		"UpdateBackendAuth",
+		"UpdateBackendConfig",
+		"UpdateBackendJob",
+	},
+	"apigateway": {
+		"AddCertificateToDomain",
+		"DELETE",
+		"GET",
+		"HEAD",
+		"OPTIONS",
+		"PATCH",
+		"POST",
+		"PUT",
+		"RemoveCertificateFromDomain",
+		"SetWebACL",
+		"UpdateRestApiPolicy",
+	},
+	"app-integrations": {
+		"CreateEventIntegration",
+		"DeleteEventIntegration",
+		"GetEventIntegration",
+		"ListEventIntegrationAssociations",
+		"ListEventIntegrations",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateEventIntegration",
+	},
+	"appconfig": {
+		"CreateApplication",
+		"CreateConfigurationProfile",
+		"CreateDeploymentStrategy",
+		"CreateEnvironment",
+		"CreateHostedConfigurationVersion",
+		"DeleteApplication",
+		"DeleteConfigurationProfile",
+		"DeleteDeploymentStrategy",
+		"DeleteEnvironment",
+		"DeleteHostedConfigurationVersion",
+		"GetApplication",
+		"GetConfiguration",
+		"GetConfigurationProfile",
+		"GetDeployment",
+		"GetDeploymentStrategy",
+		"GetEnvironment",
+		"GetHostedConfigurationVersion",
+		"ListApplications",
+		"ListConfigurationProfiles",
+		"ListDeploymentStrategies",
+		"ListDeployments",
+		"ListEnvironments",
+		"ListHostedConfigurationVersions",
+		"ListTagsForResource",
+		"StartDeployment",
+		"StopDeployment",
+		"TagResource",
+		"UntagResource",
+		"UpdateApplication",
+		"UpdateConfigurationProfile",
+		"UpdateDeploymentStrategy",
+		"UpdateEnvironment",
+		"ValidateConfiguration",
+	},
+	"appflow": {
+		"CreateConnectorProfile",
+		"CreateFlow",
+		"DeleteConnectorProfile",
+		"DeleteFlow",
+		"DescribeConnectorEntity",
+		"DescribeConnectorProfiles",
+		"DescribeConnectors",
+		"DescribeFlow",
+		"DescribeFlowExecutionRecords",
+		"ListConnectorEntities",
+		"ListFlows",
+		"ListTagsForResource",
+		"StartFlow",
+		"StopFlow",
+		"TagResource",
+		"UntagResource",
+		"UpdateConnectorProfile",
+		"UpdateFlow",
+	},
+	"application-autoscaling": {
+		"DeleteScalingPolicy",
+		"DeleteScheduledAction",
+		"DeregisterScalableTarget",
+		"DescribeScalableTargets",
+		"DescribeScalingActivities",
+		"DescribeScalingPolicies",
+		"DescribeScheduledActions",
+		"PutScalingPolicy",
+		"PutScheduledAction",
+		"RegisterScalableTarget",
+	},
+	"applicationinsights": {
+		"CreateApplication",
+		"CreateComponent",
+		"CreateLogPattern",
+		"DeleteApplication",
+		"DeleteComponent",
+		"DeleteLogPattern",
+		"DescribeApplication",
+		"DescribeComponent",
+		"DescribeComponentConfiguration",
+		"DescribeComponentConfigurationRecommendation",
+		"DescribeLogPattern",
+		"DescribeObservation",
+		"DescribeProblem",
+		"DescribeProblemObservations",
+		"ListApplications",
+		"ListComponents",
+		"ListConfigurationHistory",
+		"ListLogPatternSets",
+		"ListLogPatterns",
+		"ListProblems",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateApplication",
+		"UpdateComponent",
+		"UpdateComponentConfiguration",
+		"UpdateLogPattern",
+	},
+	"appmesh": {
+		"CreateGatewayRoute",
+		"CreateMesh",
+		"CreateRoute",
+		"CreateVirtualGateway",
+		"CreateVirtualNode",
+		"CreateVirtualRouter",
+		"CreateVirtualService",
+		"DeleteGatewayRoute",
+		"DeleteMesh",
+		"DeleteRoute",
+		"DeleteVirtualGateway",
+		"DeleteVirtualNode",
+		"DeleteVirtualRouter",
+		"DeleteVirtualService",
+		"DescribeGatewayRoute",
+		"DescribeMesh",
+		"DescribeRoute",
+		"DescribeVirtualGateway",
+		"DescribeVirtualNode",
+		"DescribeVirtualRouter",
+		"DescribeVirtualService",
+		"ListGatewayRoutes",
+		"ListMeshes",
+		"ListRoutes",
+		"ListTagsForResource",
+		"ListVirtualGateways",
+		"ListVirtualNodes",
+		"ListVirtualRouters",
+		"ListVirtualServices",
+		"TagResource",
+		"UntagResource",
+		"UpdateGatewayRoute",
+		"UpdateMesh",
+		"UpdateRoute",
+		"UpdateVirtualGateway",
+		"UpdateVirtualNode",
+		"UpdateVirtualRouter",
+		"UpdateVirtualService",
+	},
+	"appstream": {
+		"AssociateFleet",
+		"BatchAssociateUserStack",
+		"BatchDisassociateUserStack",
+		"CopyImage",
+		"CreateDirectoryConfig",
+		"CreateFleet",
+		"CreateImageBuilder",
+		"CreateImageBuilderStreamingURL",
+		"CreateStack",
+		"CreateStreamingURL",
+		"CreateUsageReportSubscription",
+		"CreateUser",
+		"DeleteDirectoryConfig",
+		"DeleteFleet",
+		"DeleteImage",
+		"DeleteImageBuilder",
+		"DeleteImagePermissions",
+		"DeleteStack",
+		"DeleteUsageReportSubscription",
+		"DeleteUser",
+		"DescribeDirectoryConfigs",
+		"DescribeFleets",
+		"DescribeImageBuilders",
+		"DescribeImagePermissions",
+		"DescribeImages",
+		"DescribeSessions",
+		"DescribeStacks",
+		"DescribeUsageReportSubscriptions",
+		"DescribeUserStackAssociations",
+		"DescribeUsers",
+		"DisableUser",
+		"DisassociateFleet",
+		"EnableUser",
+		"ExpireSession",
+		"ListAssociatedFleets",
+		"ListAssociatedStacks",
+		"ListTagsForResource",
+		"StartFleet",
+		"StartImageBuilder",
+		"StopFleet",
+		"StopImageBuilder",
+		"TagResource",
+		"UntagResource",
+		"UpdateDirectoryConfig",
+		"UpdateFleet",
+		"UpdateImagePermissions",
+		"UpdateStack",
+	},
+	"appsync": {
+		"CreateApiCache",
+		"CreateApiKey",
+		"CreateDataSource",
+		"CreateFunction",
+		"CreateGraphqlApi",
+		"CreateResolver",
+		"CreateType",
+		"DeleteApiCache",
+		"DeleteApiKey",
+		"DeleteDataSource",
+		"DeleteFunction",
+		"DeleteGraphqlApi",
+		"DeleteResolver",
+		"DeleteType",
+		"FlushApiCache",
+		"GetApiCache",
+		"GetDataSource",
+		"GetFunction",
+		"GetGraphqlApi",
+		"GetIntrospectionSchema",
+		"GetResolver",
+		"GetSchemaCreationStatus",
+		"GetType",
+		"GraphQL",
+		"ListApiKeys",
+		"ListDataSources",
+		"ListFunctions",
+		"ListGraphqlApis",
+		"ListResolvers",
+		"ListResolversByFunction",
+		"ListTagsForResource",
+		"ListTypes",
+		"StartSchemaCreation",
+		"TagResource",
+		"UntagResource",
+		"UpdateApiCache",
+		"UpdateApiKey",
+		"UpdateDataSource",
+		"UpdateFunction",
+		"UpdateGraphqlApi",
+		"UpdateResolver",
+		"UpdateType",
+	},
+	"aps": {
+		"CreateWorkspace",
+		"DeleteWorkspace",
+		"DescribeWorkspace",
+		"ListWorkspaces",
+		"UpdateWorkspaceAlias",
+	},
+	"athena": {
+		"BatchGetNamedQuery",
+		"BatchGetQueryExecution",
+		"CreateDataCatalog",
+		"CreateNamedQuery",
+		"CreatePreparedStatement",
+		"CreateWorkGroup",
+		"DeleteDataCatalog",
+		"DeleteNamedQuery",
+		"DeletePreparedStatement",
+		"DeleteWorkGroup",
+		"GetDataCatalog",
+		"GetDatabase",
+		"GetNamedQuery",
+		"GetPreparedStatement",
+		"GetQueryExecution",
+		"GetQueryResults",
+		"GetTableMetadata",
+		"GetWorkGroup",
+		"ListDataCatalogs",
+		"ListDatabases",
+		"ListEngineVersions",
+		"ListNamedQueries",
+		"ListPreparedStatements",
+		"ListQueryExecutions",
+		"ListTableMetadata",
+		"ListTagsForResource",
+		"ListWorkGroups",
+		"StartQueryExecution",
+		"StopQueryExecution",
+		"TagResource",
+		"UntagResource",
+		"UpdateDataCatalog",
+		"UpdatePreparedStatement",
+		"UpdateWorkGroup",
+	},
+	"auditmanager": {
+		"AssociateAssessmentReportEvidenceFolder",
+		"BatchAssociateAssessmentReportEvidence",
+		"BatchCreateDelegationByAssessment",
+		"BatchDeleteDelegationByAssessment",
+		"BatchDisassociateAssessmentReportEvidence",
+		"BatchImportEvidenceToAssessmentControl",
+		"CreateAssessment",
+		"CreateAssessmentFramework",
+		"CreateAssessmentReport",
+		"CreateControl",
+		"DeleteAssessment",
+		"DeleteAssessmentFramework",
+		"DeleteAssessmentReport",
+		"DeleteControl",
+		"DeregisterAccount",
+		"DeregisterOrganizationAdminAccount",
+		"DisassociateAssessmentReportEvidenceFolder",
+		"GetAccountStatus",
+		"GetAssessment",
+		"GetAssessmentFramework",
+		"GetAssessmentReportUrl",
+		"GetChangeLogs",
+		"GetControl",
+		"GetDelegations",
+		"GetEvidence",
+		"GetEvidenceByEvidenceFolder",
+		"GetEvidenceFolder",
+		"GetEvidenceFoldersByAssessment",
+		"GetEvidenceFoldersByAssessmentControl",
+		"GetOrganizationAdminAccount",
+		"GetServicesInScope",
+		"GetSettings",
+		"ListAssessmentFrameworks",
+		"ListAssessmentReports",
+		"ListAssessments",
+		"ListControls",
+		"ListKeywordsForDataSource",
+		"ListNotifications",
+		"ListTagsForResource",
+		"RegisterAccount",
+		"RegisterOrganizationAdminAccount",
+		"TagResource",
+		"UntagResource",
+		"UpdateAssessment",
+		"UpdateAssessmentControl",
+		"UpdateAssessmentControlSetStatus",
+		"UpdateAssessmentFramework",
+		"UpdateAssessmentStatus",
+		"UpdateControl",
+		"UpdateSettings",
+		"ValidateAssessmentReportIntegrity",
+	},
+	"autoscaling": {
+		"AttachInstances",
+		"AttachLoadBalancerTargetGroups",
+		"AttachLoadBalancers",
+		"BatchDeleteScheduledAction",
+		"BatchPutScheduledUpdateGroupAction",
+		"CancelInstanceRefresh",
+		"CompleteLifecycleAction",
+		"CreateAutoScalingGroup",
+		"CreateLaunchConfiguration",
+		"CreateOrUpdateTags",
+		"DeleteAutoScalingGroup",
+		"DeleteLaunchConfiguration",
+		"DeleteLifecycleHook",
+		"DeleteNotificationConfiguration",
+		"DeletePolicy",
+		"DeleteScheduledAction",
+		"DeleteTags",
+		"DescribeAccountLimits",
+		"DescribeAdjustmentTypes",
+		"DescribeAutoScalingGroups",
+		"DescribeAutoScalingInstances",
+		"DescribeAutoScalingNotificationTypes",
+		"DescribeInstanceRefreshes",
+		"DescribeLaunchConfigurations",
+		"DescribeLifecycleHookTypes",
+		"DescribeLifecycleHooks",
+		"DescribeLoadBalancerTargetGroups",
+		"DescribeLoadBalancers",
+		"DescribeMetricCollectionTypes",
+		"DescribeNotificationConfigurations",
+		"DescribePolicies",
+		"DescribeScalingActivities",
+		"DescribeScalingProcessTypes",
+		"DescribeScheduledActions",
+		"DescribeTags",
+		"DescribeTerminationPolicyTypes",
+		"DetachInstances",
+		"DetachLoadBalancerTargetGroups",
+		"DetachLoadBalancers",
+		"DisableMetricsCollection",
+		"EnableMetricsCollection",
+		"EnterStandby",
+		"ExecutePolicy",
+		"ExitStandby",
+		"PutLifecycleHook",
+		"PutNotificationConfiguration",
+		"PutScalingPolicy",
+		"PutScheduledUpdateGroupAction",
+		"RecordLifecycleActionHeartbeat",
+		"ResumeProcesses",
+		"SetDesiredCapacity",
+		"SetInstanceHealth",
+		"SetInstanceProtection",
+		"StartInstanceRefresh",
+		"SuspendProcesses",
+		"TerminateInstanceInAutoScalingGroup",
+		"UpdateAutoScalingGroup",
+	},
+	"autoscaling-plans": {
+		"CreateScalingPlan",
+		"DeleteScalingPlan",
+		"DescribeScalingPlanResources",
+		"DescribeScalingPlans",
+		"GetScalingPlanResourceForecastData",
+		"UpdateScalingPlan",
+	},
+	"aws-marketplace": {
+		"BatchMeterUsage",
+		"CancelChangeSet",
+		"DescribeChangeSet",
+		"DescribeEntity",
+		"GetEntitlements",
+		"ListChangeSets",
+		"ListEntities",
+		"MeterUsage",
+		"RegisterUsage",
+		"ResolveCustomer",
+		"StartChangeSet",
+	},
+	"backup": {
+		"CreateBackupPlan",
+		"CreateBackupSelection",
+		"CreateBackupVault",
+		"DeleteBackupPlan",
+		"DeleteBackupSelection",
+		"DeleteBackupVault",
+		"DeleteBackupVaultAccessPolicy",
+		"DeleteBackupVaultNotifications",
+		"DeleteRecoveryPoint",
+		"DescribeBackupJob",
+		"DescribeBackupVault",
+		"DescribeCopyJob",
+		"DescribeGlobalSettings",
+		"DescribeProtectedResource",
+		"DescribeRecoveryPoint",
+		"DescribeRegionSettings",
+		"DescribeRestoreJob",
+		"DisassociateRecoveryPoint",
+		"ExportBackupPlanTemplate",
+		"GetBackupPlan",
+		"GetBackupPlanFromJSON",
+		"GetBackupPlanFromTemplate",
+		"GetBackupSelection",
+		"GetBackupVaultAccessPolicy",
+		"GetBackupVaultNotifications",
+		"GetRecoveryPointRestoreMetadata",
+		"GetSupportedResourceTypes",
+		"ListBackupJobs",
+		"ListBackupPlanTemplates",
+		"ListBackupPlanVersions",
+		"ListBackupPlans",
+		"ListBackupSelections",
+		"ListBackupVaults",
+		"ListCopyJobs",
+		"ListProtectedResources",
+		"ListRecoveryPointsByBackupVault",
+		"ListRecoveryPointsByResource",
+		"ListRestoreJobs",
+		"ListTags",
+		"PutBackupVaultAccessPolicy",
+		"PutBackupVaultNotifications",
+		"StartBackupJob",
+		"StartCopyJob",
+		"StartRestoreJob",
+		"StopBackupJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateBackupPlan",
+		"UpdateGlobalSettings",
+		"UpdateRecoveryPointLifecycle",
+		"UpdateRegionSettings",
+	},
+	"batch": {
+		"CancelJob",
+		"CreateComputeEnvironment",
+		"CreateJobQueue",
+		"DeleteComputeEnvironment",
+		"DeleteJobQueue",
+		"DeregisterJobDefinition",
+		"DescribeComputeEnvironments",
+		"DescribeJobDefinitions",
+		"DescribeJobQueues",
+		"DescribeJobs",
+		"ListJobs",
+		"ListTagsForResource",
+		"RegisterJobDefinition",
+		"SubmitJob",
+		"TagResource",
+		"TerminateJob",
+		"UntagResource",
+		"UpdateComputeEnvironment",
+		"UpdateJobQueue",
+	},
+	"braket": {
+		"CancelQuantumTask",
+		"CreateQuantumTask",
+		"GetDevice",
+		"GetQuantumTask",
+		"ListTagsForResource",
+		"SearchDevices",
+		"SearchQuantumTasks",
+		"TagResource",
+		"UntagResource",
+	},
+	"budgets": {
+		"CreateBudget",
+		"CreateBudgetAction",
+		"CreateNotification",
+		"CreateSubscriber",
+		"DeleteBudget",
+		"DeleteBudgetAction",
+		"DeleteNotification",
+		"DeleteSubscriber",
+		"DescribeBudget",
+		"DescribeBudgetAction",
+		"DescribeBudgetActionHistories",
+		"DescribeBudgetActionsForAccount",
+		"DescribeBudgetActionsForBudget",
+		"DescribeBudgetPerformanceHistory",
+		"DescribeBudgets",
+		"DescribeNotificationsForBudget",
+		"DescribeSubscribersForNotification",
+		"ExecuteBudgetAction",
+		"UpdateBudget",
+		"UpdateBudgetAction",
+		"UpdateNotification",
+		"UpdateSubscriber",
+	},
+	"cassandra": {
+		"Alter",
+		"Create",
+		"Drop",
+		"Modify",
+		"Restore",
+		"Select",
+	},
+	"ce": {
+		"CreateAnomalyMonitor",
+		"CreateAnomalySubscription",
+		"CreateCostCategoryDefinition",
+		"DeleteAnomalyMonitor",
+		"DeleteAnomalySubscription",
+		"DeleteCostCategoryDefinition",
+		"DescribeCostCategoryDefinition",
+		"GetAnomalies",
+		"GetAnomalyMonitors",
+		"GetAnomalySubscriptions",
+		"GetCostAndUsage",
+		"GetCostAndUsageWithResources",
+		"GetCostCategories",
+		"GetCostForecast",
+		"GetDimensionValues",
+		"GetReservationCoverage",
+		"GetReservationPurchaseRecommendation",
+		"GetReservationUtilization",
+		"GetRightsizingRecommendation",
+		"GetSavingsPlansCoverage",
+		"GetSavingsPlansPurchaseRecommendation",
+		"GetSavingsPlansUtilization",
+		"GetSavingsPlansUtilizationDetails",
+		"GetTags",
+		"GetUsageForecast",
+		"ListCostCategoryDefinitions",
+		"ProvideAnomalyFeedback",
+		"UpdateAnomalyMonitor",
+		"UpdateAnomalySubscription",
+		"UpdateCostCategoryDefinition",
+	},
+	"chime": {
+		"AssociatePhoneNumberWithUser",
+		"AssociatePhoneNumbersWithVoiceConnector",
+		"AssociatePhoneNumbersWithVoiceConnectorGroup",
+		"AssociateSigninDelegateGroupsWithAccount",
+		"BatchCreateAttendee",
+		"BatchCreateRoomMembership",
+		"BatchDeletePhoneNumber",
+		"BatchSuspendUser",
+		"BatchUnsuspendUser",
+		"BatchUpdatePhoneNumber",
+		"BatchUpdateUser",
+		"CreateAccount",
+		"CreateAppInstance",
+		"CreateAppInstanceAdmin",
+		"CreateAppInstanceUser",
+		"CreateAttendee",
+		"CreateBot",
+		"CreateChannel",
+		"CreateChannelBan",
+		"CreateChannelMembership",
+		"CreateChannelModerator",
+		"CreateMeeting",
+		"CreateMeetingDialOut",
+		"CreateMeetingWithAttendees",
+		"CreatePhoneNumberOrder",
+		"CreateProxySession",
+		"CreateRoom",
+		"CreateRoomMembership",
+		"CreateSipMediaApplication",
+		"CreateSipMediaApplicationCall",
+		"CreateSipRule",
+		"CreateUser",
+		"CreateVoiceConnector",
+		"CreateVoiceConnectorGroup",
+		"DeleteAccount",
+		"DeleteAppInstance",
+		"DeleteAppInstanceAdmin",
+		"DeleteAppInstanceStreamingConfigurations",
+		"DeleteAppInstanceUser",
+		"DeleteAttendee",
+		"DeleteChannel",
+		"DeleteChannelBan",
+		"DeleteChannelMembership",
+		"DeleteChannelMessage",
+		"DeleteChannelModerator",
+		"DeleteEventsConfiguration",
+		"DeleteMeeting",
+		"DeletePhoneNumber",
+		"DeleteProxySession",
+		"DeleteRoom",
+		"DeleteRoomMembership",
+		"DeleteSipMediaApplication",
+		"DeleteSipRule",
+		"DeleteVoiceConnector",
+		"DeleteVoiceConnectorEmergencyCallingConfiguration",
+		"DeleteVoiceConnectorGroup",
+		"DeleteVoiceConnectorOrigination",
+		"DeleteVoiceConnectorProxy",
+		"DeleteVoiceConnectorStreamingConfiguration",
+		"DeleteVoiceConnectorTermination",
+		"DeleteVoiceConnectorTerminationCredentials",
+		"DescribeAppInstance",
+		"DescribeAppInstanceAdmin",
+		"DescribeAppInstanceUser",
+		"DescribeChannel",
+		"DescribeChannelBan",
+		"DescribeChannelMembership",
+		"DescribeChannelMembershipForAppInstanceUser",
+		"DescribeChannelModeratedByAppInstanceUser",
+		"DescribeChannelModerator",
+		"DisassociatePhoneNumberFromUser",
+		"DisassociatePhoneNumbersFromVoiceConnector",
+		"DisassociatePhoneNumbersFromVoiceConnectorGroup",
+		"DisassociateSigninDelegateGroupsFromAccount",
+		"GetAccount",
+		"GetAccountSettings",
+		"GetAppInstanceRetentionSettings",
+		"GetAppInstanceStreamingConfigurations",
+		"GetAttendee",
+		"GetBot",
+		"GetChannelMessage",
+		"GetEventsConfiguration",
+		"GetGlobalSettings",
+		"GetMeeting",
+		"GetMessagingSessionEndpoint",
+		"GetPhoneNumber",
+		"GetPhoneNumberOrder",
+		"GetPhoneNumberSettings",
+		"GetProxySession",
+		"GetRetentionSettings",
+		"GetRoom",
+		"GetSipMediaApplication",
+		"GetSipMediaApplicationLoggingConfiguration",
+		"GetSipRule",
+		"GetUser",
+		"GetUserSettings",
+		"GetVoiceConnector",
+		"GetVoiceConnectorEmergencyCallingConfiguration",
+		"GetVoiceConnectorGroup",
+		"GetVoiceConnectorLoggingConfiguration",
+		"GetVoiceConnectorOrigination",
+		"GetVoiceConnectorProxy",
+		"GetVoiceConnectorStreamingConfiguration",
+		"GetVoiceConnectorTermination",
+		"GetVoiceConnectorTerminationHealth",
+		"InviteUsers",
+		"ListAccounts",
+		"ListAppInstanceAdmins",
+		"ListAppInstanceUsers",
+		"ListAppInstances",
+		"ListAttendeeTags",
+		"ListAttendees",
+		"ListBots",
+		"ListChannelBans",
+		"ListChannelMemberships",
+		"ListChannelMembershipsForAppInstanceUser",
+		"ListChannelMessages",
+		"ListChannelModerators",
+		"ListChannels",
+		"ListChannelsModeratedByAppInstanceUser",
+		"ListMeetingTags",
+		"ListMeetings",
+		"ListPhoneNumberOrders",
+		"ListPhoneNumbers",
+		"ListProxySessions",
+		"ListRoomMemberships",
+		"ListRooms",
+		"ListSipMediaApplications",
+		"ListSipRules",
+		"ListTagsForResource",
+		"ListUsers",
+		"ListVoiceConnectorGroups",
+		"ListVoiceConnectorTerminationCredentials",
+		"ListVoiceConnectors",
+		"LogoutUser",
+		"PutAppInstanceRetentionSettings",
+		"PutAppInstanceStreamingConfigurations",
+		"PutEventsConfiguration",
+		"PutRetentionSettings",
+		"PutSipMediaApplicationLoggingConfiguration",
+		"PutVoiceConnectorEmergencyCallingConfiguration",
+		"PutVoiceConnectorLoggingConfiguration",
+		"PutVoiceConnectorOrigination",
+		"PutVoiceConnectorProxy",
+		"PutVoiceConnectorStreamingConfiguration",
+		"PutVoiceConnectorTermination",
+		"PutVoiceConnectorTerminationCredentials",
+		"RedactChannelMessage",
+		"RedactConversationMessage",
+		"RedactRoomMessage",
+		"RegenerateSecurityToken",
+		"ResetPersonalPIN",
+		"RestorePhoneNumber",
+		"SearchAvailablePhoneNumbers",
+		"SendChannelMessage",
+		"TagAttendee",
+		"TagMeeting",
+		"TagResource",
+		"UntagAttendee",
+		"UntagMeeting",
+		"UntagResource",
+		"UpdateAccount",
+		"UpdateAccountSettings",
+		"UpdateAppInstance",
+		"UpdateAppInstanceUser",
+		"UpdateBot",
+		"UpdateChannel",
+		"UpdateChannelMessage",
+		"UpdateChannelReadMarker",
+		"UpdateGlobalSettings",
+		"UpdatePhoneNumber",
+		"UpdatePhoneNumberSettings",
+		"UpdateProxySession",
+		"UpdateRoom",
+		"UpdateRoomMembership",
+		"UpdateSipMediaApplication",
+		"UpdateSipRule",
+		"UpdateUser",
+		"UpdateUserSettings",
+		"UpdateVoiceConnector",
+		"UpdateVoiceConnectorGroup",
+	},
+	"cloud9": {
+		"CreateEnvironmentEC2",
+		"CreateEnvironmentMembership",
+		"DeleteEnvironment",
+		"DeleteEnvironmentMembership",
+		"DescribeEnvironmentMemberships",
+		"DescribeEnvironmentStatus",
+		"DescribeEnvironments",
+		"ListEnvironments",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateEnvironment",
+		"UpdateEnvironmentMembership",
+	},
+	"clouddirectory": {
+		"AddFacetToObject",
+		"ApplySchema",
+		"AttachObject",
+		"AttachPolicy",
+		"AttachToIndex",
+		"AttachTypedLink",
+		"BatchRead",
+		"BatchWrite",
+		"CreateDirectory",
+		"CreateFacet",
+		"CreateIndex",
+		"CreateObject",
+		"CreateSchema",
+		"CreateTypedLinkFacet",
+		"DeleteDirectory",
+		"DeleteFacet",
+		"DeleteObject",
+		"DeleteSchema",
+		"DeleteTypedLinkFacet",
+		"DetachFromIndex",
+		"DetachObject",
+		"DetachPolicy",
+		"DetachTypedLink",
+		"DisableDirectory",
+		"EnableDirectory",
+		"GetAppliedSchemaVersion",
+		"GetDirectory",
+		"GetFacet",
+		"GetLinkAttributes",
+		"GetObjectAttributes",
+		"GetObjectInformation",
+		"GetSchemaAsJson",
+		"GetTypedLinkFacetInformation",
+		"ListAppliedSchemaArns",
+		"ListAttachedIndices",
+		"ListDevelopmentSchemaArns",
+		"ListDirectories",
+		"ListFacetAttributes",
+		"ListFacetNames",
+		"ListIncomingTypedLinks",
+		"ListIndex",
+		"ListManagedSchemaArns",
+		"ListObjectAttributes",
+		"ListObjectChildren",
+		"ListObjectParentPaths",
+		"ListObjectParents",
+		"ListObjectPolicies",
+		"ListOutgoingTypedLinks",
+		"ListPolicyAttachments",
+		"ListPublishedSchemaArns",
+		"ListTagsForResource",
+		"ListTypedLinkFacetAttributes",
+		"ListTypedLinkFacetNames",
+		"LookupPolicy",
+		"PublishSchema",
+		"PutSchemaFromJson",
+		"RemoveFacetFromObject",
+		"TagResource",
+		"UntagResource",
+		"UpdateFacet",
+		"UpdateLinkAttributes",
+		"UpdateObjectAttributes",
+		"UpdateSchema",
+		"UpdateTypedLinkFacet",
+		"UpgradeAppliedSchema",
+		"UpgradePublishedSchema",
+	},
+	"cloudformation": {
+		"CancelUpdateStack",
+		"ContinueUpdateRollback",
+		"CreateChangeSet",
+		"CreateStack",
+		"CreateStackInstances",
+		"CreateStackSet",
+		"DeleteChangeSet",
+		"DeleteStack",
+		"DeleteStackInstances",
+		"DeleteStackSet",
+		"DeregisterType",
+		"DescribeAccountLimits",
+		"DescribeChangeSet",
+		"DescribeStackDriftDetectionStatus",
+		"DescribeStackEvents",
+		"DescribeStackInstance",
+		"DescribeStackResource",
+		"DescribeStackResourceDrifts",
+		"DescribeStackResources",
+		"DescribeStackSet",
+		"DescribeStackSetOperation",
+		"DescribeStacks",
+		"DescribeType",
+		"DescribeTypeRegistration",
+		"DetectStackDrift",
+		"DetectStackResourceDrift",
+		"DetectStackSetDrift",
+		"EstimateTemplateCost",
+		"ExecuteChangeSet",
+		"GetStackPolicy",
+		"GetTemplate",
+		"GetTemplateSummary",
+		"ListChangeSets",
+		"ListExports",
+		"ListImports",
+		"ListStackInstances",
+		"ListStackResources",
+		"ListStackSetOperationResults",
+		"ListStackSetOperations",
+		"ListStackSets",
+		"ListStacks",
+		"ListTypeRegistrations",
+		"ListTypeVersions",
+		"ListTypes",
+		"RecordHandlerProgress",
+		"RegisterType",
+		"SetStackPolicy",
+		"SetTypeDefaultVersion",
+		"SignalResource",
+		"StopStackSetOperation",
+		"UpdateStack",
+		"UpdateStackInstances",
+		"UpdateStackSet",
+		"UpdateTerminationProtection",
+		"ValidateTemplate",
+	},
+	"cloudfront": {
+		"CreateCachePolicy2020_05_31",
+		"CreateCloudFrontOriginAccessIdentity2020_05_31",
+		"CreateDistribution2020_05_31",
+		"CreateDistributionWithTags2020_05_31",
+		"CreateFieldLevelEncryptionConfig2020_05_31",
+		"CreateFieldLevelEncryptionProfile2020_05_31",
+		"CreateInvalidation2020_05_31",
+		"CreateKeyGroup2020_05_31",
+		"CreateMonitoringSubscription2020_05_31",
+		"CreateOriginRequestPolicy2020_05_31",
+		"CreatePublicKey2020_05_31",
+		"CreateRealtimeLogConfig2020_05_31",
+		"CreateStreamingDistribution2020_05_31",
+		"CreateStreamingDistributionWithTags2020_05_31",
+		"DeleteCachePolicy2020_05_31",
+		"DeleteCloudFrontOriginAccessIdentity2020_05_31",
+		"DeleteDistribution2020_05_31",
+		"DeleteFieldLevelEncryptionConfig2020_05_31",
+		"DeleteFieldLevelEncryptionProfile2020_05_31",
+		"DeleteKeyGroup2020_05_31",
+		"DeleteMonitoringSubscription2020_05_31",
+		"DeleteOriginRequestPolicy2020_05_31",
+		"DeletePublicKey2020_05_31",
+		"DeleteRealtimeLogConfig2020_05_31",
+		"DeleteStreamingDistribution2020_05_31",
+		"GetCachePolicy2020_05_31",
+		"GetCachePolicyConfig2020_05_31",
+		"GetCloudFrontOriginAccessIdentity2020_05_31",
+		"GetCloudFrontOriginAccessIdentityConfig2020_05_31",
+		"GetDistribution2020_05_31",
+		"GetDistributionConfig2020_05_31",
+		"GetFieldLevelEncryption2020_05_31",
+		"GetFieldLevelEncryptionConfig2020_05_31",
+		"GetFieldLevelEncryptionProfile2020_05_31",
+		"GetFieldLevelEncryptionProfileConfig2020_05_31",
+		"GetInvalidation2020_05_31",
+		"GetKeyGroup2020_05_31",
+		"GetKeyGroupConfig2020_05_31",
+		"GetMonitoringSubscription2020_05_31",
+		"GetOriginRequestPolicy2020_05_31",
+		"GetOriginRequestPolicyConfig2020_05_31",
+		"GetPublicKey2020_05_31",
+		"GetPublicKeyConfig2020_05_31",
+		"GetRealtimeLogConfig2020_05_31",
+		"GetStreamingDistribution2020_05_31",
+		"GetStreamingDistributionConfig2020_05_31",
+		"ListCachePolicies2020_05_31",
+		"ListCloudFrontOriginAccessIdentities2020_05_31",
+		"ListDistributions2020_05_31",
+		"ListDistributionsByCachePolicyId2020_05_31",
+		"ListDistributionsByKeyGroup2020_05_31",
+		"ListDistributionsByOriginRequestPolicyId2020_05_31",
+		"ListDistributionsByRealtimeLogConfig2020_05_31",
+		"ListDistributionsByWebACLId2020_05_31",
+		"ListFieldLevelEncryptionConfigs2020_05_31",
+		"ListFieldLevelEncryptionProfiles2020_05_31",
+		"ListInvalidations2020_05_31",
+		"ListKeyGroups2020_05_31",
+		"ListOriginRequestPolicies2020_05_31",
+		"ListPublicKeys2020_05_31",
+		"ListRealtimeLogConfigs2020_05_31",
+		"ListStreamingDistributions2020_05_31",
+		"ListTagsForResource2020_05_31",
+		"TagResource2020_05_31",
+		"UntagResource2020_05_31",
+		"UpdateCachePolicy2020_05_31",
+		"UpdateCloudFrontOriginAccessIdentity2020_05_31",
+		"UpdateDistribution2020_05_31",
+		"UpdateFieldLevelEncryptionConfig2020_05_31",
+		"UpdateFieldLevelEncryptionProfile2020_05_31",
+		"UpdateKeyGroup2020_05_31",
+		"UpdateOriginRequestPolicy2020_05_31",
+		"UpdatePublicKey2020_05_31",
+		"UpdateRealtimeLogConfig2020_05_31",
+		"UpdateStreamingDistribution2020_05_31",
+	},
+	"cloudhsm": {
+		"AddTagsToResource",
+		"CopyBackupToRegion",
+		"CreateCluster",
+		"CreateHapg",
+		"CreateHsm",
+		"CreateLunaClient",
+		"DeleteBackup",
+		"DeleteCluster",
+		"DeleteHapg",
+		"DeleteHsm",
+		"DeleteLunaClient",
+		"DescribeBackups",
+		"DescribeClusters",
+		"DescribeHapg",
+		"DescribeHsm",
+		"DescribeLunaClient",
+		"GetConfig",
+		"InitializeCluster",
+		"ListAvailableZones",
+		"ListHapgs",
+		"ListHsms",
+		"ListLunaClients",
+		"ListTags",
+		"ListTagsForResource",
+		"ModifyBackupAttributes",
+		"ModifyCluster",
+		"ModifyHapg",
+		"ModifyHsm",
+		"ModifyLunaClient",
+		"RemoveTagsFromResource",
+		"RestoreBackup",
+		"TagResource",
+		"UntagResource",
+	},
+	"cloudsearch": {
+		"BuildSuggesters",
+		"CreateDomain",
+		"DefineAnalysisScheme",
+		"DefineExpression",
+		"DefineIndexField",
+		"DefineSuggester",
+		"DeleteAnalysisScheme",
+		"DeleteDomain",
+		"DeleteExpression",
+		"DeleteIndexField",
+		"DeleteSuggester",
+		"DescribeAnalysisSchemes",
+		"DescribeAvailabilityOptions",
+		"DescribeDomainEndpointOptions",
+		"DescribeDomains",
+		"DescribeExpressions",
+		"DescribeIndexFields",
+		"DescribeScalingParameters",
+		"DescribeServiceAccessPolicies",
+		"DescribeSuggesters",
+		"IndexDocuments",
+		"ListDomainNames",
+		"Search",
+		"Suggest",
+		"UpdateAvailabilityOptions",
+		"UpdateDomainEndpointOptions",
+		"UpdateScalingParameters",
+		"UpdateServiceAccessPolicies",
+		"UploadDocuments",
+	},
+	"cloudtrail": {
+		"AddTags",
+		"CreateTrail",
+		"DeleteTrail",
+		"DescribeTrails",
+		"GetEventSelectors",
+		"GetInsightSelectors",
+		"GetTrail",
+		"GetTrailStatus",
+		"ListPublicKeys",
+		"ListTags",
+		"ListTrails",
+		"LookupEvents",
+		"PutEventSelectors",
+		"PutInsightSelectors",
+		"RemoveTags",
+		"StartLogging",
+		"StopLogging",
+		"UpdateTrail",
+	},
+	"cloudwatch": {
+		"DeleteAlarms",
+		"DeleteAnomalyDetector",
+		"DeleteDashboards",
+		"DeleteInsightRules",
+		"DescribeAlarmHistory",
+		"DescribeAlarms",
+		"DescribeAlarmsForMetric",
+		"DescribeAnomalyDetectors",
+		"DescribeInsightRules",
+		"DisableAlarmActions",
+		"DisableInsightRules",
+		"EnableAlarmActions",
+		"EnableInsightRules",
+		"GetDashboard",
+		"GetInsightRuleReport",
+		"GetMetricData",
+		"GetMetricStatistics",
+		"GetMetricWidgetImage",
+		"ListDashboards",
+		"ListMetrics",
+		"ListTagsForResource",
+		"PutAnomalyDetector",
+		"PutCompositeAlarm",
+		"PutDashboard",
+		"PutInsightRule",
+		"PutMetricAlarm",
+		"PutMetricData",
+		"SetAlarmState",
+		"TagResource",
+		"UntagResource",
+	},
+	"codeartifact": {
+		"AssociateExternalConnection",
+		"CopyPackageVersions",
+		"CreateDomain",
+		"CreateRepository",
+		"DeleteDomain",
+		"DeleteDomainPermissionsPolicy",
+		"DeletePackageVersions",
+		"DeleteRepository",
+		"DeleteRepositoryPermissionsPolicy",
+		"DescribeDomain",
+		"DescribePackageVersion",
+		"DescribeRepository",
+		"DisassociateExternalConnection",
+		"DisposePackageVersions",
+		"GetAuthorizationToken",
+		"GetDomainPermissionsPolicy",
+		"GetPackageVersionAsset",
+		"GetPackageVersionReadme",
+		"GetRepositoryEndpoint",
+		"GetRepositoryPermissionsPolicy",
+		"ListDomains",
+		"ListPackageVersionAssets",
+		"ListPackageVersionDependencies",
+		"ListPackageVersions",
+		"ListPackages",
+		"ListRepositories",
+		"ListRepositoriesInDomain",
+		"ListTagsForResource",
+		"PutDomainPermissionsPolicy",
+		"PutRepositoryPermissionsPolicy",
+		"ReadFromRepository",
+		"TagResource",
+		"UntagResource",
+		"UpdatePackageVersionsStatus",
+		"UpdateRepository",
+	},
+	"codebuild": {
+		"BatchDeleteBuilds",
+		"BatchGetBuildBatches",
+		"BatchGetBuilds",
+		"BatchGetProjects",
+		"BatchGetReportGroups",
+		"BatchGetReports",
+		"CreateProject",
+		"CreateReportGroup",
+		"CreateWebhook",
+		"DeleteBuildBatch",
+		"DeleteProject",
+		"DeleteReport",
+		"DeleteReportGroup",
+		"DeleteResourcePolicy",
+		"DeleteSourceCredentials",
+		"DeleteWebhook",
+		"DescribeCodeCoverages",
+		"DescribeTestCases",
+		"GetReportGroupTrend",
+		"GetResourcePolicy",
+		"ImportSourceCredentials",
+		"InvalidateProjectCache",
+		"ListBuildBatches",
+		"ListBuildBatchesForProject",
+		"ListBuilds",
+		"ListBuildsForProject",
+		"ListCuratedEnvironmentImages",
+		"ListProjects",
+		"ListReportGroups",
+		"ListReports",
+		"ListReportsForReportGroup",
+		"ListSharedProjects",
+		"ListSharedReportGroups",
+		"ListSourceCredentials",
+		"PutResourcePolicy",
+		"RetryBuild",
+		"RetryBuildBatch",
+		"StartBuild",
+		"StartBuildBatch",
+		"StopBuild",
+		"StopBuildBatch",
+		"UpdateProject",
+		"UpdateReportGroup",
+		"UpdateWebhook",
+	},
+	"codecommit": {
+		"AssociateApprovalRuleTemplateWithRepository",
+		"BatchAssociateApprovalRuleTemplateWithRepositories",
+		"BatchDescribeMergeConflicts",
+		"BatchDisassociateApprovalRuleTemplateFromRepositories",
+		"BatchGetCommits",
+		"BatchGetRepositories",
+		"CreateApprovalRuleTemplate",
+		"CreateBranch",
+		"CreateCommit",
+		"CreatePullRequest",
+		"CreatePullRequestApprovalRule",
+		"CreateRepository",
+		"CreateUnreferencedMergeCommit",
+		"DeleteApprovalRuleTemplate",
+		"DeleteBranch",
+		"DeleteCommentContent",
+		"DeleteFile",
+		"DeletePullRequestApprovalRule",
+		"DeleteRepository",
+		"DescribeMergeConflicts",
+		"DescribePullRequestEvents",
+		"DisassociateApprovalRuleTemplateFromRepository",
+		"EvaluatePullRequestApprovalRules",
+		"GetApprovalRuleTemplate",
+		"GetBlob",
+		"GetBranch",
+		"GetComment",
+		"GetCommentReactions",
+		"GetCommentsForComparedCommit",
+		"GetCommentsForPullRequest",
+		"GetCommit",
+		"GetDifferences",
+		"GetFile",
+		"GetFolder",
+		"GetMergeCommit",
+		"GetMergeConflicts",
+		"GetMergeOptions",
+		"GetPullRequest",
+		"GetPullRequestApprovalStates",
+		"GetPullRequestOverrideState",
+		"GetRepository",
+		"GetRepositoryTriggers",
+		"GitPull",
+		"GitPush",
+		"ListApprovalRuleTemplates",
+		"ListAssociatedApprovalRuleTemplatesForRepository",
+		"ListBranches",
+		"ListPullRequests",
+		"ListRepositories",
+		"ListRepositoriesForApprovalRuleTemplate",
+		"ListTagsForResource",
+		"MergeBranchesByFastForward",
+		"MergeBranchesBySquash",
+		"MergeBranchesByThreeWay",
+		"MergePullRequestByFastForward",
+		"MergePullRequestBySquash",
+		"MergePullRequestByThreeWay",
+		"OverridePullRequestApprovalRules",
+		"PostCommentForComparedCommit",
+		"PostCommentForPullRequest",
+		"PostCommentReply",
+		"PutCommentReaction",
+		"PutFile",
+		"PutRepositoryTriggers",
+		"TagResource",
+		"TestRepositoryTriggers",
+		"UntagResource",
+		"UpdateApprovalRuleTemplateContent",
+		"UpdateApprovalRuleTemplateDescription",
+		"UpdateApprovalRuleTemplateName",
+		"UpdateComment",
+		"UpdateDefaultBranch",
+		"UpdatePullRequestApprovalRuleContent",
+		"UpdatePullRequestApprovalState",
+		"UpdatePullRequestDescription",
+		"UpdatePullRequestStatus",
+		"UpdatePullRequestTitle",
+		"UpdateRepositoryDescription",
+		"UpdateRepositoryName",
+	},
+	"codedeploy": {
+		"AddTagsToOnPremisesInstances",
+		"BatchGetApplicationRevisions",
+		"BatchGetApplications",
+		"BatchGetDeploymentGroups",
+		"BatchGetDeploymentInstances",
+		"BatchGetDeploymentTargets",
+		"BatchGetDeployments",
+		"BatchGetOnPremisesInstances",
+		"ContinueDeployment",
+		"CreateApplication",
+		"CreateDeployment",
+		"CreateDeploymentConfig",
+		"CreateDeploymentGroup",
+		"DeleteApplication",
+		"DeleteDeploymentConfig",
+		"DeleteDeploymentGroup",
+		"DeleteGitHubAccountToken",
+		"DeleteResourcesByExternalId",
+		"DeregisterOnPremisesInstance",
+		"GetApplication",
+		"GetApplicationRevision",
+		"GetDeployment",
+		"GetDeploymentConfig",
+		"GetDeploymentGroup",
+		"GetDeploymentInstance",
+		"GetDeploymentTarget",
+		"GetOnPremisesInstance",
+		"ListApplicationRevisions",
+		"ListApplications",
+		"ListDeploymentConfigs",
+		"ListDeploymentGroups",
+		"ListDeploymentInstances",
+		"ListDeploymentTargets",
+		"ListDeployments",
+		"ListGitHubAccountTokenNames",
+		"ListOnPremisesInstances",
+		"ListTagsForResource",
+		"PutLifecycleEventHookExecutionStatus",
+		"RegisterApplicationRevision",
+		"RegisterOnPremisesInstance",
+		"RemoveTagsFromOnPremisesInstances",
+		"SkipWaitTimeForInstanceTermination",
+		"StopDeployment",
+		"TagResource",
+		"UntagResource",
+		"UpdateApplication",
+		"UpdateDeploymentGroup",
+	},
+	"codeguru-profiler": {
+		"AddNotificationChannels",
+		"BatchGetFrameMetricData",
+		"ConfigureAgent",
+		"CreateProfilingGroup",
+		"DeleteProfilingGroup",
+		"DescribeProfilingGroup",
+		"GetFindingsReportAccountSummary",
+		"GetNotificationConfiguration",
+		"GetPolicy",
+		"GetProfile",
+		"GetRecommendations",
+		"ListFindingsReports",
+		"ListProfileTimes",
+		"ListProfilingGroups",
+		"ListTagsForResource",
+		"PostAgentProfile",
+		"PutPermission",
+		"RemoveNotificationChannel",
+		"RemovePermission",
+		"SubmitFeedback",
+		"TagResource",
+		"UntagResource",
+		"UpdateProfilingGroup",
+	},
+	"codeguru-reviewer": {
+		"AssociateRepository",
+		"CreateCodeReview",
+		"DescribeCodeReview",
+		"DescribeRecommendationFeedback",
+		"DescribeRepositoryAssociation",
+		"DisassociateRepository",
+		"ListCodeReviews",
+		"ListRecommendationFeedback",
+		"ListRecommendations",
+		"ListRepositoryAssociations",
+		"ListTagsForResource",
+		"PutRecommendationFeedback",
+		"TagResource",
+		"UntagResource",
+	},
+	"codepipeline": {
+		"AcknowledgeJob",
+		"AcknowledgeThirdPartyJob",
+		"CreateCustomActionType",
+		"CreatePipeline",
+		"DeleteCustomActionType",
+		"DeletePipeline",
+		"DeleteWebhook",
+		"DeregisterWebhookWithThirdParty",
+		"DisableStageTransition",
+		"EnableStageTransition",
+		"GetActionType",
+		"GetJobDetails",
+		"GetPipeline",
+		"GetPipelineExecution",
+		"GetPipelineState",
+		"GetThirdPartyJobDetails",
+		"ListActionExecutions",
+		"ListActionTypes",
+		"ListPipelineExecutions",
+		"ListPipelines",
+		"ListTagsForResource",
+		"ListWebhooks",
+		"PollForJobs",
+		"PollForThirdPartyJobs",
+		"PutActionRevision",
+		"PutApprovalResult",
+		"PutJobFailureResult",
+		"PutJobSuccessResult",
+		"PutThirdPartyJobFailureResult",
+		"PutThirdPartyJobSuccessResult",
+		"PutWebhook",
+		"RegisterWebhookWithThirdParty",
+		"RetryStageExecution",
+		"StartPipelineExecution",
+		"StopPipelineExecution",
+		"TagResource",
+		"UntagResource",
+		"UpdateActionType",
+		"UpdatePipeline",
+	},
+	"codestar": {
+		"AssociateTeamMember",
+		"CreateProject",
+		"CreateUserProfile",
+		"DeleteProject",
+		"DeleteUserProfile",
+		"DescribeProject",
+		"DescribeUserProfile",
+		"DisassociateTeamMember",
+		"ListProjects",
+		"ListResources",
+		"ListTagsForProject",
+		"ListTeamMembers",
+		"ListUserProfiles",
+		"TagProject",
+		"UntagProject",
+		"UpdateProject",
+		"UpdateTeamMember",
+		"UpdateUserProfile",
+	},
+	"codestar-connections": {
+		"CreateConnection",
+		"CreateHost",
+		"DeleteConnection",
+		"DeleteHost",
+		"GetConnection",
+		"GetHost",
+		"ListConnections",
+		"ListHosts",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateHost",
+	},
+	"codestar-notifications": {
+		"CreateNotificationRule",
+		"DeleteNotificationRule",
+		"DeleteTarget",
+		"DescribeNotificationRule",
+		"ListEventTypes",
+		"ListNotificationRules",
+		"ListTagsForResource",
+		"ListTargets",
+		"Subscribe",
+		"TagResource",
+		"Unsubscribe",
+		"UntagResource",
+		"UpdateNotificationRule",
+	},
+	"cognito-identity": {
+		"CreateIdentityPool",
+		"DeleteIdentities",
+		"DeleteIdentityPool",
+		"DescribeIdentity",
+		"DescribeIdentityPool",
+		"GetCredentialsForIdentity",
+		"GetId",
+		"GetIdentityPoolRoles",
+		"GetOpenIdToken",
+		"GetOpenIdTokenForDeveloperIdentity",
+		"GetPrincipalTagAttributeMap",
+		"ListIdentities",
+		"ListIdentityPools",
+		"ListTagsForResource",
+		"LookupDeveloperIdentity",
+		"MergeDeveloperIdentities",
+		"SetIdentityPoolRoles",
+		"SetPrincipalTagAttributeMap",
+		"TagResource",
+		"UnlinkDeveloperIdentity",
+		"UnlinkIdentity",
+		"UntagResource",
+		"UpdateIdentityPool",
+	},
+	"cognito-idp": {
+		"AddCustomAttributes",
+		"AdminAddUserToGroup",
+		"AdminConfirmSignUp",
+		"AdminCreateUser",
+		"AdminDeleteUser",
+		"AdminDeleteUserAttributes",
+		"AdminDisableProviderForUser",
+		"AdminDisableUser",
+		"AdminEnableUser",
+		"AdminForgetDevice",
+		"AdminGetDevice",
+		"AdminGetUser",
+		"AdminInitiateAuth",
+		"AdminLinkProviderForUser",
+		"AdminListDevices",
+		"AdminListGroupsForUser",
+		"AdminListUserAuthEvents",
+		"AdminRemoveUserFromGroup",
+		"AdminResetUserPassword",
+		"AdminRespondToAuthChallenge",
+		"AdminSetUserMFAPreference",
+		"AdminSetUserPassword",
+		"AdminSetUserSettings",
+		"AdminUpdateAuthEventFeedback",
+		"AdminUpdateDeviceStatus",
+		"AdminUpdateUserAttributes",
+		"AdminUserGlobalSignOut",
+		"AssociateSoftwareToken",
+		"ChangePassword",
+		"ConfirmDevice",
+		"ConfirmForgotPassword",
+		"ConfirmSignUp",
+		"CreateGroup",
+		"CreateIdentityProvider",
+		"CreateResourceServer",
+		"CreateUserImportJob",
+		"CreateUserPool",
+		"CreateUserPoolClient",
+		"CreateUserPoolDomain",
+		"DeleteGroup",
+		"DeleteIdentityProvider",
+		"DeleteResourceServer",
+		"DeleteUser",
+		"DeleteUserAttributes",
+		"DeleteUserPool",
+		"DeleteUserPoolClient",
+		"DeleteUserPoolDomain",
+		"DescribeIdentityProvider",
+		"DescribeResourceServer",
+		"DescribeRiskConfiguration",
+		"DescribeUserImportJob",
+		"DescribeUserPool",
+		"DescribeUserPoolClient",
+		"DescribeUserPoolDomain",
+		"ForgetDevice",
+		"ForgotPassword",
+		"GetCSVHeader",
+		"GetDevice",
+		"GetGroup",
+		"GetIdentityProviderByIdentifier",
+		"GetSigningCertificate",
+		"GetUICustomization",
+		"GetUser",
+		"GetUserAttributeVerificationCode",
+		"GetUserPoolMfaConfig",
+		"GlobalSignOut",
+		"InitiateAuth",
+		"ListDevices",
+		"ListGroups",
+		"ListIdentityProviders",
+		"ListResourceServers",
+		"ListTagsForResource",
+		"ListUserImportJobs",
+		"ListUserPoolClients",
+		"ListUserPools",
+		"ListUsers",
+		"ListUsersInGroup",
+		"ResendConfirmationCode",
+		"RespondToAuthChallenge",
+		"SetRiskConfiguration",
+		"SetUICustomization",
+		"SetUserMFAPreference",
+		"SetUserPoolMfaConfig",
+		"SetUserSettings",
+		"SignUp",
+		"StartUserImportJob",
+		"StopUserImportJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateAuthEventFeedback",
+		"UpdateDeviceStatus",
+		"UpdateGroup",
+		"UpdateIdentityProvider",
+		"UpdateResourceServer",
+		"UpdateUserAttributes",
+		"UpdateUserPool",
+		"UpdateUserPoolClient",
+		"UpdateUserPoolDomain",
+		"VerifySoftwareToken",
+		"VerifyUserAttribute",
+	},
+	"cognito-sync": {
+		"BulkPublish",
+		"DeleteDataset",
+		"DescribeDataset",
+		"DescribeIdentityPoolUsage",
+		"DescribeIdentityUsage",
+		"GetBulkPublishDetails",
+		"GetCognitoEvents",
+		"GetIdentityPoolConfiguration",
+		"ListDatasets",
+		"ListIdentityPoolUsage",
+		"ListRecords",
+		"RegisterDevice",
+		"SetCognitoEvents",
+		"SetIdentityPoolConfiguration",
+		"SubscribeToDataset",
+		"UnsubscribeFromDataset",
+		"UpdateRecords",
+	},
+	"comprehend": {
+		"BatchDetectDominantLanguage",
+		"BatchDetectEntities",
+		"BatchDetectKeyPhrases",
+		"BatchDetectSentiment",
+		"BatchDetectSyntax",
+		"ClassifyDocument",
+		"ContainsPiiEntities",
+		"CreateDocumentClassifier",
+		"CreateEndpoint",
+		"CreateEntityRecognizer",
+		"DeleteDocumentClassifier",
+		"DeleteEndpoint",
+		"DeleteEntityRecognizer",
+		"DescribeDocumentClassificationJob",
+		"DescribeDocumentClassifier",
+		"DescribeDominantLanguageDetectionJob",
+		"DescribeEndpoint",
+		"DescribeEntitiesDetectionJob",
+		"DescribeEntityRecognizer",
+		"DescribeEventsDetectionJob",
+		"DescribeKeyPhrasesDetectionJob",
+		"DescribePiiEntitiesDetectionJob",
+		"DescribeSentimentDetectionJob",
+		"DescribeTopicsDetectionJob",
+		"DetectDominantLanguage",
+		"DetectEntities",
+		"DetectKeyPhrases",
+		"DetectPiiEntities",
+		"DetectSentiment",
+		"DetectSyntax",
+		"ListDocumentClassificationJobs",
+		"ListDocumentClassifiers",
+		"ListDominantLanguageDetectionJobs",
+		"ListEndpoints",
+		"ListEntitiesDetectionJobs",
+		"ListEntityRecognizers",
+		"ListEventsDetectionJobs",
+		"ListKeyPhrasesDetectionJobs",
+		"ListPiiEntitiesDetectionJobs",
+		"ListSentimentDetectionJobs",
+		"ListTagsForResource",
+		"ListTopicsDetectionJobs",
+		"StartDocumentClassificationJob",
+		"StartDominantLanguageDetectionJob",
+		"StartEntitiesDetectionJob",
+		"StartEventsDetectionJob",
+		"StartKeyPhrasesDetectionJob",
+		"StartPiiEntitiesDetectionJob",
+		"StartSentimentDetectionJob",
+		"StartTopicsDetectionJob",
+		"StopDominantLanguageDetectionJob",
+		"StopEntitiesDetectionJob",
+		"StopEventsDetectionJob",
+		"StopKeyPhrasesDetectionJob",
+		"StopPiiEntitiesDetectionJob",
+		"StopSentimentDetectionJob",
+		"StopTrainingDocumentClassifier",
+		"StopTrainingEntityRecognizer",
+		"TagResource",
+		"UntagResource",
+		"UpdateEndpoint",
+	},
+	"comprehendmedical": {
+		"DescribeEntitiesDetectionV2Job",
+		"DescribeICD10CMInferenceJob",
+		"DescribePHIDetectionJob",
+		"DescribeRxNormInferenceJob",
+		"DetectEntities",
+		"DetectEntitiesV2",
+		"DetectPHI",
+		"InferICD10CM",
+		"InferRxNorm",
+		"ListEntitiesDetectionV2Jobs",
+		"ListICD10CMInferenceJobs",
+		"ListPHIDetectionJobs",
+		"ListRxNormInferenceJobs",
+		"StartEntitiesDetectionV2Job",
+		"StartICD10CMInferenceJob",
+		"StartPHIDetectionJob",
+		"StartRxNormInferenceJob",
+		"StopEntitiesDetectionV2Job",
+		"StopICD10CMInferenceJob",
+		"StopPHIDetectionJob",
+		"StopRxNormInferenceJob",
+	},
+	"compute-optimizer": {
+		"DescribeRecommendationExportJobs",
+		"ExportAutoScalingGroupRecommendations",
+		"ExportEC2InstanceRecommendations",
+		"GetAutoScalingGroupRecommendations",
+		"GetEBSVolumeRecommendations",
+		"GetEC2InstanceRecommendations",
+		"GetEC2RecommendationProjectedMetrics",
+		"GetEnrollmentStatus",
+		"GetLambdaFunctionRecommendations",
+		"GetRecommendationSummaries",
+		"UpdateEnrollmentStatus",
+	},
+	"config": {
+		"BatchGetAggregateResourceConfig",
+		"BatchGetResourceConfig",
+		"DeleteAggregationAuthorization",
+		"DeleteConfigRule",
+		"DeleteConfigurationAggregator",
+		"DeleteConfigurationRecorder",
+		"DeleteConformancePack",
+		"DeleteDeliveryChannel",
+		"DeleteEvaluationResults",
+		"DeleteOrganizationConfigRule",
+		"DeleteOrganizationConformancePack",
+		"DeletePendingAggregationRequest",
+		"DeleteRemediationConfiguration",
+		"DeleteRemediationExceptions",
+		"DeleteResourceConfig",
+		"DeleteRetentionConfiguration",
+		"DeleteStoredQuery",
+		"DeliverConfigSnapshot",
+		"DescribeAggregateComplianceByConfigRules",
+		"DescribeAggregationAuthorizations",
+		"DescribeComplianceByConfigRule",
+		"DescribeComplianceByResource",
+		"DescribeConfigRuleEvaluationStatus",
+		"DescribeConfigRules",
+		"DescribeConfigurationAggregatorSourcesStatus",
+		"DescribeConfigurationAggregators",
+		"DescribeConfigurationRecorderStatus",
+		"DescribeConfigurationRecorders",
+		"DescribeConformancePackCompliance",
+		"DescribeConformancePackStatus",
+		"DescribeConformancePacks",
+		"DescribeDeliveryChannelStatus",
+		"DescribeDeliveryChannels",
+		"DescribeOrganizationConfigRuleStatuses",
+		"DescribeOrganizationConfigRules",
+		"DescribeOrganizationConformancePackStatuses",
+		"DescribeOrganizationConformancePacks",
+		"DescribePendingAggregationRequests",
+		"DescribeRemediationConfigurations",
+		"DescribeRemediationExceptions",
+		"DescribeRemediationExecutionStatus",
+		"DescribeRetentionConfigurations",
+		"GetAggregateComplianceDetailsByConfigRule",
+		"GetAggregateConfigRuleComplianceSummary",
+		"GetAggregateDiscoveredResourceCounts",
+		"GetAggregateResourceConfig",
+		"GetComplianceDetailsByConfigRule",
+		"GetComplianceDetailsByResource",
+		"GetComplianceSummaryByConfigRule",
+		"GetComplianceSummaryByResourceType",
+		"GetConformancePackComplianceDetails",
+		"GetConformancePackComplianceSummary",
+		"GetDiscoveredResourceCounts",
+		"GetOrganizationConfigRuleDetailedStatus",
+		"GetOrganizationConformancePackDetailedStatus",
+		"GetResourceConfigHistory",
+		"GetStoredQuery",
+		"ListAggregateDiscoveredResources",
+		"ListDiscoveredResources",
+		"ListStoredQueries",
+		"ListTagsForResource",
+		"PutAggregationAuthorization",
+		"PutConfigRule",
+		"PutConfigurationAggregator",
+		"PutConfigurationRecorder",
+		"PutConformancePack",
+		"PutDeliveryChannel",
+		"PutEvaluations",
+		"PutExternalEvaluation",
+		"PutOrganizationConfigRule",
+		"PutOrganizationConformancePack",
+		"PutRemediationConfigurations",
+		"PutRemediationExceptions",
+		"PutResourceConfig",
+		"PutRetentionConfiguration",
+		"PutStoredQuery",
+		"SelectAggregateResourceConfig",
+		"SelectResourceConfig",
+		"StartConfigRulesEvaluation",
+		"StartConfigurationRecorder",
+		"StartRemediationExecution",
+		"StopConfigurationRecorder",
+		"TagResource",
+		"UntagResource",
+	},
+	"connect": {
+		"AssociateApprovedOrigin",
+		"AssociateInstanceStorageConfig",
+		"AssociateLambdaFunction",
+		"AssociateLexBot",
+		"AssociateQueueQuickConnects",
+		"AssociateRoutingProfileQueues",
+		"AssociateSecurityKey",
+		"CreateContactFlow",
+		"CreateInstance",
+		"CreateIntegrationAssociation",
+		"CreateQueue",
+		"CreateQuickConnect",
+		"CreateRoutingProfile",
+		"CreateUseCase",
+		"CreateUser",
+		"CreateUserHierarchyGroup",
+		"DeleteInstance",
+		"DeleteIntegrationAssociation",
+		"DeleteQuickConnect",
+		"DeleteUseCase",
+		"DeleteUser",
+		"DeleteUserHierarchyGroup",
+		"DescribeContactFlow",
+		"DescribeHoursOfOperation",
+		"DescribeInstance",
+		"DescribeInstanceAttribute",
+		"DescribeInstanceStorageConfig",
+		"DescribeQueue",
+		"DescribeQuickConnect",
+		"DescribeRoutingProfile",
+		"DescribeUser",
+		"DescribeUserHierarchyGroup",
+		"DescribeUserHierarchyStructure",
+		"DisassociateApprovedOrigin",
+		"DisassociateInstanceStorageConfig",
+		"DisassociateLambdaFunction",
+		"DisassociateLexBot",
+		"DisassociateQueueQuickConnects",
+		"DisassociateRoutingProfileQueues",
+		"DisassociateSecurityKey",
+		"GetContactAttributes",
+		"GetCurrentMetricData",
+		"GetFederationToken",
+		"GetMetricData",
+		"ListApprovedOrigins",
+		"ListContactFlows",
+		"ListHoursOfOperations",
+		"ListInstanceAttributes",
+		"ListInstanceStorageConfigs",
+		"ListInstances",
+		"ListIntegrationAssociations",
+		"ListLambdaFunctions",
+		"ListLexBots",
+		"ListPhoneNumbers",
+		"ListPrompts",
+		"ListQueueQuickConnects",
+		"ListQueues",
+		"ListQuickConnects",
+		"ListRealtimeContactAnalysisSegments",
+		"ListRoutingProfileQueues",
+		"ListRoutingProfiles",
+		"ListSecurityKeys",
+		"ListSecurityProfiles",
+		"ListTagsForResource",
+		"ListUseCases",
+		"ListUserHierarchyGroups",
+		"ListUsers",
+		"ResumeContactRecording",
+		"StartChatContact",
+		"StartContactRecording",
+		"StartOutboundVoiceContact",
+		"StartTaskContact",
+		"StopContact",
+		"StopContactRecording",
+		"SuspendContactRecording",
+		"TagResource",
+		"UntagResource",
+		"UpdateContactAttributes",
+		"UpdateContactFlowContent",
+		"UpdateContactFlowName",
+		"UpdateInstanceAttribute",
+		"UpdateInstanceStorageConfig",
+		"UpdateQueueHoursOfOperation",
+		"UpdateQueueMaxContacts",
+		"UpdateQueueName",
+		"UpdateQueueOutboundCallerConfig",
+		"UpdateQueueStatus",
+		"UpdateQuickConnectConfig",
+		"UpdateQuickConnectName",
+		"UpdateRoutingProfileConcurrency",
+		"UpdateRoutingProfileDefaultOutboundQueue",
+		"UpdateRoutingProfileName",
+		"UpdateRoutingProfileQueues",
+		"UpdateUserHierarchy",
+		"UpdateUserHierarchyGroupName",
+		"UpdateUserHierarchyStructure",
+		"UpdateUserIdentityInfo",
+		"UpdateUserPhoneConfig",
+		"UpdateUserRoutingProfile",
+		"UpdateUserSecurityProfiles",
+	},
+	"cur": {
+		"DeleteReportDefinition",
+		"DescribeReportDefinitions",
+		"ModifyReportDefinition",
+		"PutReportDefinition",
+	},
+	"databrew": {
+		"BatchDeleteRecipeVersion",
+		"CreateDataset",
+		"CreateProfileJob",
+		"CreateProject",
+		"CreateRecipe",
+		"CreateRecipeJob",
+		"CreateSchedule",
+		"DeleteDataset",
+		"DeleteJob",
+		"DeleteProject",
+		"DeleteRecipeVersion",
+		"DeleteSchedule",
+		"DescribeDataset",
+		"DescribeJob",
+		"DescribeJobRun",
+		"DescribeProject",
+		"DescribeRecipe",
+		"DescribeSchedule",
+		"ListDatasets",
+		"ListJobRuns",
+		"ListJobs",
+		"ListProjects",
+		"ListRecipeVersions",
+		"ListRecipes",
+		"ListSchedules",
+		"ListTagsForResource",
+		"PublishRecipe",
+		"SendProjectSessionAction",
+		"StartJobRun",
+		"StartProjectSession",
+		"StopJobRun",
+		"TagResource",
+		"UntagResource",
+		"UpdateDataset",
+		"UpdateProfileJob",
+		"UpdateProject",
+		"UpdateRecipe",
+		"UpdateRecipeJob",
+		"UpdateSchedule",
+	},
+	"dataexchange": {
+		"CancelJob",
+		"CreateDataSet",
+		"CreateJob",
+		"CreateRevision",
+		"DeleteAsset",
+		"DeleteDataSet",
+		"DeleteRevision",
+		"GetAsset",
+		"GetDataSet",
+		"GetJob",
+		"GetRevision",
+		"ListDataSetRevisions",
+		"ListDataSets",
+		"ListJobs",
+		"ListRevisionAssets",
+		"ListTagsForResource",
+		"StartJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateAsset",
+		"UpdateDataSet",
+		"UpdateRevision",
+	},
+	"datapipeline": {
+		"ActivatePipeline",
+		"AddTags",
+		"CreatePipeline",
+		"DeactivatePipeline",
+		"DeletePipeline",
+		"DescribeObjects",
+		"DescribePipelines",
+		"EvaluateExpression",
+		"GetPipelineDefinition",
+		"ListPipelines",
+		"PollForTask",
+		"PutPipelineDefinition",
+		"QueryObjects",
+		"RemoveTags",
+		"ReportTaskProgress",
+		"ReportTaskRunnerHeartbeat",
+		"SetStatus",
+		"SetTaskStatus",
+		"ValidatePipelineDefinition",
+	},
+	"datasync": {
+		"CancelTaskExecution",
+		"CreateAgent",
+		"CreateLocationEfs",
+		"CreateLocationFsxWindows",
+		"CreateLocationNfs",
+		"CreateLocationObjectStorage",
+		"CreateLocationS3",
+		"CreateLocationSmb",
+		"CreateTask",
+		"DeleteAgent",
+		"DeleteLocation",
+		"DeleteTask",
+		"DescribeAgent",
+		"DescribeLocationEfs",
+		"DescribeLocationFsxWindows",
+		"DescribeLocationNfs",
+		"DescribeLocationObjectStorage",
+		"DescribeLocationS3",
+		"DescribeLocationSmb",
+		"DescribeTask",
+		"DescribeTaskExecution",
+		"ListAgents",
+		"ListLocations",
+		"ListTagsForResource",
+		"ListTaskExecutions",
+		"ListTasks",
+		"StartTaskExecution",
+		"TagResource",
+		"UntagResource",
+		"UpdateAgent",
+		"UpdateLocationNfs",
+		"UpdateLocationObjectStorage",
+		"UpdateLocationSmb",
+		"UpdateTask",
+		"UpdateTaskExecution",
+	},
+	"dax": {
+		"CreateCluster",
+		"CreateParameterGroup",
+		"CreateSubnetGroup",
+		"DecreaseReplicationFactor",
+		"DeleteCluster",
+		"DeleteParameterGroup",
+		"DeleteSubnetGroup",
+		"DescribeClusters",
+		"DescribeDefaultParameters",
+		"DescribeEvents",
+		"DescribeParameterGroups",
+		"DescribeParameters",
+		"DescribeSubnetGroups",
+		"IncreaseReplicationFactor",
+		"ListTags",
+		"RebootNode",
+		"TagResource",
+		"UntagResource",
+		"UpdateCluster",
+		"UpdateParameterGroup",
+		"UpdateSubnetGroup",
+	},
+	"detective": {
+		"AcceptInvitation",
+		"CreateGraph",
+		"CreateMembers",
+		"DeleteGraph",
+		"DeleteMembers",
+		"DisassociateMembership",
+		"GetMembers",
+		"ListGraphs",
+		"ListInvitations",
+		"ListMembers",
+		"RejectInvitation",
+		"StartMonitoringMember",
+	},
+	"devicefarm": {
+		"CreateDevicePool",
+		"CreateInstanceProfile",
+		"CreateNetworkProfile",
+		"CreateProject",
+		"CreateRemoteAccessSession",
+		"CreateTestGridProject",
+		"CreateTestGridUrl",
+		"CreateUpload",
+		"CreateVPCEConfiguration",
+		"DeleteDevicePool",
+		"DeleteInstanceProfile",
+		"DeleteNetworkProfile",
+		"DeleteProject",
+		"DeleteRemoteAccessSession",
+		"DeleteRun",
+		"DeleteTestGridProject",
+		"DeleteUpload",
+		"DeleteVPCEConfiguration",
+		"GetAccountSettings",
+		"GetDevice",
+		"GetDeviceInstance",
+		"GetDevicePool",
+		"GetDevicePoolCompatibility",
+		"GetInstanceProfile",
+		"GetJob",
+		"GetNetworkProfile",
+		"GetOfferingStatus",
+		"GetProject",
+		"GetRemoteAccessSession",
+		"GetRun",
+		"GetSuite",
+		"GetTest",
+		"GetTestGridProject",
+		"GetTestGridSession",
+		"GetUpload",
+		"GetVPCEConfiguration",
+		"InstallToRemoteAccessSession",
+		"ListArtifacts",
+		"ListDeviceInstances",
+		"ListDevicePools",
+		"ListDevices",
+		"ListInstanceProfiles",
+		"ListJobs",
+		"ListNetworkProfiles",
+		"ListOfferingPromotions",
+		"ListOfferingTransactions",
+		"ListOfferings",
+		"ListProjects",
+		"ListRemoteAccessSessions",
+		"ListRuns",
+		"ListSamples",
+		"ListSuites",
+		"ListTagsForResource",
+		"ListTestGridProjects",
+		"ListTestGridSessionActions",
+		"ListTestGridSessionArtifacts",
+		"ListTestGridSessions",
+		"ListTests",
+		"ListUniqueProblems",
+		"ListUploads",
+		"ListVPCEConfigurations",
+		"PurchaseOffering",
+		"RenewOffering",
+		"ScheduleRun",
+		"StopJob",
+		"StopRemoteAccessSession",
+		"StopRun",
+		"TagResource",
+		"UntagResource",
+		"UpdateDeviceInstance",
+		"UpdateDevicePool",
+		"UpdateInstanceProfile",
+		"UpdateNetworkProfile",
+		"UpdateProject",
+		"UpdateTestGridProject",
+		"UpdateUpload",
+		"UpdateVPCEConfiguration",
+	},
+	"devops-guru": {
+		"AddNotificationChannel",
+		"DescribeAccountHealth",
+		"DescribeAccountOverview",
+		"DescribeAnomaly",
+		"DescribeFeedback",
+		"DescribeInsight",
+		"DescribeResourceCollectionHealth",
+		"DescribeServiceIntegration",
+		"GetResourceCollection",
+		"ListAnomaliesForInsight",
+		"ListEvents",
+		"ListInsights",
+		"ListNotificationChannels",
+		"ListRecommendations",
+		"PutFeedback",
+		"RemoveNotificationChannel",
+		"SearchInsights",
+		"UpdateResourceCollection",
+		"UpdateServiceIntegration",
+	},
+	"directconnect": {
+		"AcceptDirectConnectGatewayAssociationProposal",
+		"AllocateConnectionOnInterconnect",
+		"AllocateHostedConnection",
+		"AllocatePrivateVirtualInterface",
+		"AllocatePublicVirtualInterface",
+		"AllocateTransitVirtualInterface",
+		"AssociateConnectionWithLag",
+		"AssociateHostedConnection",
+		"AssociateVirtualInterface",
+		"ConfirmConnection",
+		"ConfirmPrivateVirtualInterface",
+		"ConfirmPublicVirtualInterface",
+		"ConfirmTransitVirtualInterface",
+		"CreateBGPPeer",
+		"CreateConnection",
+		"CreateDirectConnectGateway",
+		"CreateDirectConnectGatewayAssociation",
+		"CreateDirectConnectGatewayAssociationProposal",
+		"CreateInterconnect",
+		"CreateLag",
+		"CreatePrivateVirtualInterface",
+		"CreatePublicVirtualInterface",
+		"CreateTransitVirtualInterface",
+		"DeleteBGPPeer",
+		"DeleteConnection",
+		"DeleteDirectConnectGateway",
+		"DeleteDirectConnectGatewayAssociation",
+		"DeleteDirectConnectGatewayAssociationProposal",
+		"DeleteInterconnect",
+		"DeleteLag",
+		"DeleteVirtualInterface",
+		"DescribeConnectionLoa",
+		"DescribeConnections",
+		"DescribeConnectionsOnInterconnect",
+		"DescribeDirectConnectGatewayAssociationProposals",
+		"DescribeDirectConnectGatewayAssociations",
+		"DescribeDirectConnectGatewayAttachments",
+		"DescribeDirectConnectGateways",
+		"DescribeHostedConnections",
+		"DescribeInterconnectLoa",
+		"DescribeInterconnects",
+		"DescribeLags",
+		"DescribeLoa",
+		"DescribeLocations",
+		"DescribeTags",
+		"DescribeVirtualGateways",
+		"DescribeVirtualInterfaces",
+		"DisassociateConnectionFromLag",
+		"ListVirtualInterfaceTestHistory",
+		"StartBgpFailoverTest",
+		"StopBgpFailoverTest",
+		"TagResource",
+		"UntagResource",
+		"UpdateDirectConnectGatewayAssociation",
+		"UpdateLag",
+		"UpdateVirtualInterfaceAttributes",
+	},
+	"discovery": {
+		"AssociateConfigurationItemsToApplication",
+		"BatchDeleteImportData",
+		"CreateApplication",
+		"CreateTags",
+		"DeleteApplications",
+		"DeleteTags",
+		"DescribeAgents",
+		"DescribeConfigurations",
+		"DescribeContinuousExports",
+		"DescribeExportConfigurations",
+		"DescribeExportTasks",
+		"DescribeImportTasks",
+		"DescribeTags",
+		"DisassociateConfigurationItemsFromApplication",
+		"ExportConfigurations",
+		"GetDiscoverySummary",
+		"ListConfigurations",
+		"ListServerNeighbors",
+		"StartContinuousExport",
+		"StartDataCollectionByAgentIds",
+		"StartExportTask",
+		"StartImportTask",
+		"StopContinuousExport",
+		"StopDataCollectionByAgentIds",
+		"UpdateApplication",
+	},
+	"dlm": {
+		"CreateLifecyclePolicy",
+		"DeleteLifecyclePolicy",
+		"GetLifecyclePolicies",
+		"GetLifecyclePolicy",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateLifecyclePolicy",
+	},
+	"dms": {
+		"AddTagsToResource",
+		"ApplyPendingMaintenanceAction",
+		"CancelReplicationTaskAssessmentRun",
+		"CreateEndpoint",
+		"CreateEventSubscription",
+		"CreateReplicationInstance",
+		"CreateReplicationSubnetGroup",
+		"CreateReplicationTask",
+		"DeleteCertificate",
+		"DeleteConnection",
+		"DeleteEndpoint",
+		"DeleteEventSubscription",
+		"DeleteReplicationInstance",
+		"DeleteReplicationSubnetGroup",
+		"DeleteReplicationTask",
+		"DeleteReplicationTaskAssessmentRun",
+		"DescribeAccountAttributes",
+		"DescribeApplicableIndividualAssessments",
+		"DescribeCertificates",
+		"DescribeConnections",
+		"DescribeEndpointTypes",
+		"DescribeEndpoints",
+		"DescribeEventCategories",
+		"DescribeEventSubscriptions",
+		"DescribeEvents",
+		"DescribeOrderableReplicationInstances",
+		"DescribePendingMaintenanceActions",
+		"DescribeRefreshSchemasStatus",
+		"DescribeReplicationInstanceTaskLogs",
+		"DescribeReplicationInstances",
+		"DescribeReplicationSubnetGroups",
+		"DescribeReplicationTaskAssessmentResults",
+		"DescribeReplicationTaskAssessmentRuns",
+		"DescribeReplicationTaskIndividualAssessments",
+		"DescribeReplicationTasks",
+		"DescribeSchemas",
+		"DescribeTableStatistics",
+		"ImportCertificate",
+		"ListTagsForResource",
+		"ModifyEndpoint",
+		"ModifyEventSubscription",
+		"ModifyReplicationInstance",
+		"ModifyReplicationSubnetGroup",
+		"ModifyReplicationTask",
+		"MoveReplicationTask",
+		"RebootReplicationInstance",
+		"RefreshSchemas",
+		"ReloadTables",
+		"RemoveTagsFromResource",
+		"StartReplicationTask",
+		"StartReplicationTaskAssessment",
+		"StartReplicationTaskAssessmentRun",
+		"StopReplicationTask",
+		"TestConnection",
+	},
+	"ds": {
+		"AcceptSharedDirectory",
+		"AddIpRoutes",
+		"AddRegion",
+		"AddTagsToResource",
+		"CancelSchemaExtension",
+		"ConnectDirectory",
+		"CreateAlias",
+		"CreateComputer",
+		"CreateConditionalForwarder",
+		"CreateDirectory",
+		"CreateLogSubscription",
+		"CreateMicrosoftAD",
+		"CreateSnapshot",
+		"CreateTrust",
+		"DeleteConditionalForwarder",
+		"DeleteDirectory",
+		"DeleteLogSubscription",
+		"DeleteSnapshot",
+		"DeleteTrust",
+		"DeregisterCertificate",
+		"DeregisterEventTopic",
+		"DescribeCertificate",
+		"DescribeConditionalForwarders",
+		"DescribeDirectories",
+		"DescribeDomainControllers",
+		"DescribeEventTopics",
+		"DescribeLDAPSSettings",
+		"DescribeRegions",
+		"DescribeSharedDirectories",
+		"DescribeSnapshots",
+		"DescribeTrusts",
+		"DisableClientAuthentication",
+		"DisableLDAPS",
+		"DisableRadius",
+		"DisableSso",
+		"EnableClientAuthentication",
+		"EnableLDAPS",
+		"EnableRadius",
+		"EnableSso",
+		"GetDirectoryLimits",
+		"GetSnapshotLimits",
+		"ListCertificates",
+		"ListIpRoutes",
+		"ListLogSubscriptions",
+		"ListSchemaExtensions",
+		"ListTagsForResource",
+		"RegisterCertificate",
+		"RegisterEventTopic",
+		"RejectSharedDirectory",
+		"RemoveIpRoutes",
+		"RemoveRegion",
+		"RemoveTagsFromResource",
+		"ResetUserPassword",
+		"RestoreFromSnapshot",
+		"ShareDirectory",
+		"StartSchemaExtension",
+		"UnshareDirectory",
+		"UpdateConditionalForwarder",
+		"UpdateNumberOfDomainControllers",
+		"UpdateRadius",
+		"UpdateTrust",
+		"VerifyTrust",
+	},
+	"dynamodb": {
+		"BatchExecuteStatement",
+		"BatchGetItem",
+		"BatchWriteItem",
+		"ConditionCheckItem",
+		"CreateBackup",
+		"CreateGlobalTable",
+		"CreateTable",
+		"DeleteBackup",
+		"DeleteItem",
+		"DeleteTable",
+		"DescribeBackup",
+		"DescribeContinuousBackups",
+		"DescribeContributorInsights",
+		"DescribeEndpoints",
+		"DescribeExport",
+		"DescribeGlobalTable",
+		"DescribeGlobalTableSettings",
+		"DescribeKinesisStreamingDestination",
+		"DescribeLimits",
+		"DescribeStream",
+		"DescribeTable",
+		"DescribeTableReplicaAutoScaling",
+		"DescribeTimeToLive",
+		"DisableKinesisStreamingDestination",
+		"EnableKinesisStreamingDestination",
+		"ExecuteStatement",
+		"ExecuteTransaction",
+		"ExportTableToPointInTime",
+		"GetItem",
+		"GetRecords",
+		"GetShardIterator",
+		"ListBackups",
+		"ListContributorInsights",
+		"ListExports",
+		"ListGlobalTables",
+		"ListStreams",
+		"ListTables",
+		"ListTagsOfResource",
+		"PartiQLDelete",
+		"PartiQLInsert",
+		"PartiQLSelect",
+		"PartiQLUpdate",
+		"PutItem",
+		"Query",
+		"RestoreTableFromBackup",
+		"RestoreTableToPointInTime",
+		"Scan",
+		"TagResource",
+		"TransactGetItems",
+		"TransactWriteItems",
+		"UntagResource",
+		"UpdateContinuousBackups",
+		"UpdateContributorInsights",
+		"UpdateGlobalTable",
+		"UpdateGlobalTableSettings",
+		"UpdateItem",
+		"UpdateTable",
+		"UpdateTableReplicaAutoScaling",
+		"UpdateTimeToLive",
+	},
+	"ebs": {
+		"CompleteSnapshot",
+		"GetSnapshotBlock",
+		"ListChangedBlocks",
+		"ListSnapshotBlocks",
+		"PutSnapshotBlock",
+		"StartSnapshot",
+	},
+	"ec2": {
+		"AcceptReservedInstancesExchangeQuote",
+		"AcceptTransitGatewayMulticastDomainAssociations",
+		"AcceptTransitGatewayPeeringAttachment",
+		"AcceptTransitGatewayVpcAttachment",
+		"AcceptVpcEndpointConnections",
+		"AcceptVpcPeeringConnection",
+		"AdvertiseByoipCidr",
+		"AllocateAddress",
+		"AllocateHosts",
+		"ApplySecurityGroupsToClientVpnTargetNetwork",
+		"AssignIpv6Addresses",
+		"AssignPrivateIpAddresses",
+		"AssociateAddress",
+		"AssociateClientVpnTargetNetwork",
+		"AssociateDhcpOptions",
+		"AssociateEnclaveCertificateIamRole",
+		"AssociateIamInstanceProfile",
+		"AssociateRouteTable",
+		"AssociateSubnetCidrBlock",
+		"AssociateTransitGatewayMulticastDomain",
+		"AssociateTransitGatewayRouteTable",
+		"AssociateVpcCidrBlock",
+		"AttachClassicLinkVpc",
+		"AttachInternetGateway",
+		"AttachNetworkInterface",
+		"AttachVolume",
+		"AttachVpnGateway",
+		"AuthorizeClientVpnIngress",
+		"AuthorizeSecurityGroupEgress",
+		"AuthorizeSecurityGroupIngress",
+		"BundleInstance",
+		"CancelBundleTask",
+		"CancelCapacityReservation",
+		"CancelConversionTask",
+		"CancelExportTask",
+		"CancelImportTask",
+		"CancelReservedInstancesListing",
+		"CancelSpotFleetRequests",
+		"CancelSpotInstanceRequests",
+		"ConfirmProductInstance",
+		"CopyFpgaImage",
+		"CopyImage",
+		"CopySnapshot",
+		"CreateCapacityReservation",
+		"CreateCarrierGateway",
+		"CreateClientVpnEndpoint",
+		"CreateClientVpnRoute",
+		"CreateCustomerGateway",
+		"CreateDefaultSubnet",
+		"CreateDefaultVpc",
+		"CreateDhcpOptions",
+		"CreateEgressOnlyInternetGateway",
+		"CreateFleet",
+		"CreateFlowLogs",
+		"CreateFpgaImage",
+		"CreateImage",
+		"CreateInstanceExportTask",
+		"CreateInternetGateway",
+		"CreateKeyPair",
+		"CreateLaunchTemplate",
+		"CreateLaunchTemplateVersion",
+		"CreateLocalGatewayRoute",
+		"CreateLocalGatewayRouteTableVpcAssociation",
+		"CreateManagedPrefixList",
+		"CreateNatGateway",
+		"CreateNetworkAcl",
+		"CreateNetworkAclEntry",
+		"CreateNetworkInsightsPath",
+		"CreateNetworkInterface",
+		"CreateNetworkInterfacePermission",
+		"CreatePlacementGroup",
+		"CreateReservedInstancesListing",
+		"CreateRoute",
+		"CreateRouteTable",
+		"CreateSecurityGroup",
+		"CreateSnapshot",
+		"CreateSnapshots",
+		"CreateSpotDatafeedSubscription",
+		"CreateSubnet",
+		"CreateTags",
+		"CreateTrafficMirrorFilter",
+		"CreateTrafficMirrorFilterRule",
+		"CreateTrafficMirrorSession",
+		"CreateTrafficMirrorTarget",
+		"CreateTransitGateway",
+		"CreateTransitGatewayConnect",
+		"CreateTransitGatewayConnectPeer",
+		"CreateTransitGatewayMulticastDomain",
+		"CreateTransitGatewayPeeringAttachment",
+		"CreateTransitGatewayPrefixListReference",
+		"CreateTransitGatewayRoute",
+		"CreateTransitGatewayRouteTable",
+		"CreateTransitGatewayVpcAttachment",
+		"CreateVolume",
+		"CreateVpc",
+		"CreateVpcEndpoint",
+		"CreateVpcEndpointConnectionNotification",
+		"CreateVpcEndpointServiceConfiguration",
+		"CreateVpcPeeringConnection",
+		"CreateVpnConnection",
+		"CreateVpnConnectionRoute",
+		"CreateVpnGateway",
+		"DeleteCarrierGateway",
+		"DeleteClientVpnEndpoint",
+		"DeleteClientVpnRoute",
+		"DeleteCustomerGateway",
+		"DeleteDhcpOptions",
+		"DeleteEgressOnlyInternetGateway",
+		"DeleteFleets",
+		"DeleteFlowLogs",
+		"DeleteFpgaImage",
+		"DeleteInternetGateway",
+		"DeleteKeyPair",
+		"DeleteLaunchTemplate",
+		"DeleteLaunchTemplateVersions",
+		"DeleteLocalGatewayRoute",
+		"DeleteLocalGatewayRouteTableVpcAssociation",
+		"DeleteManagedPrefixList",
+		"DeleteNatGateway",
+		"DeleteNetworkAcl",
+		"DeleteNetworkAclEntry",
+		"DeleteNetworkInsightsAnalysis",
+		"DeleteNetworkInsightsPath",
+		"DeleteNetworkInterface",
+		"DeleteNetworkInterfacePermission",
+		"DeletePlacementGroup",
+		"DeleteQueuedReservedInstances",
+		"DeleteRoute",
+		"DeleteRouteTable",
+		"DeleteSecurityGroup",
+		"DeleteSnapshot",
+		"DeleteSpotDatafeedSubscription",
+		"DeleteSubnet",
+		"DeleteTags",
+		"DeleteTrafficMirrorFilter",
+		"DeleteTrafficMirrorFilterRule",
+		"DeleteTrafficMirrorSession",
+		"DeleteTrafficMirrorTarget",
+		"DeleteTransitGateway",
+		"DeleteTransitGatewayConnect",
+		"DeleteTransitGatewayConnectPeer",
+		"DeleteTransitGatewayMulticastDomain",
+		"DeleteTransitGatewayPeeringAttachment",
+		"DeleteTransitGatewayPrefixListReference",
+		"DeleteTransitGatewayRoute",
+		"DeleteTransitGatewayRouteTable",
+		"DeleteTransitGatewayVpcAttachment",
+		"DeleteVolume",
+		"DeleteVpc",
+		"DeleteVpcEndpointConnectionNotifications",
+		"DeleteVpcEndpointServiceConfigurations",
+		"DeleteVpcEndpoints",
+		"DeleteVpcPeeringConnection",
+		"DeleteVpnConnection",
+		"DeleteVpnConnectionRoute",
+		"DeleteVpnGateway",
+		"DeprovisionByoipCidr",
+		"DeregisterImage",
+		"DeregisterInstanceEventNotificationAttributes",
+		"DeregisterTransitGatewayMulticastGroupMembers",
+		"DeregisterTransitGatewayMulticastGroupSources",
+		"DescribeAccountAttributes",
+		"DescribeAddresses",
+		"DescribeAddressesAttribute",
+		"DescribeAggregateIdFormat",
+		"DescribeAvailabilityZones",
+		"DescribeBundleTasks",
+		"DescribeByoipCidrs",
+		"DescribeCapacityReservations",
+		"DescribeCarrierGateways",
+		"DescribeClassicLinkInstances",
+		"DescribeClientVpnAuthorizationRules",
+		"DescribeClientVpnConnections",
+		"DescribeClientVpnEndpoints",
+		"DescribeClientVpnRoutes",
+		"DescribeClientVpnTargetNetworks",
+		"DescribeCoipPools",
+		"DescribeConversionTasks",
+		"DescribeCustomerGateways",
+		"DescribeDhcpOptions",
+		"DescribeEgressOnlyInternetGateways",
+		"DescribeElasticGpus",
+		"DescribeExportImageTasks",
+		"DescribeExportTasks",
+		"DescribeFastSnapshotRestores",
+		"DescribeFleetHistory",
+		"DescribeFleetInstances",
+		"DescribeFleets",
+		"DescribeFlowLogs",
+		"DescribeFpgaImageAttribute",
+		"DescribeFpgaImages",
+		"DescribeHostReservationOfferings",
+		"DescribeHostReservations",
+		"DescribeHosts",
+		"DescribeIamInstanceProfileAssociations",
+		"DescribeIdFormat",
+		"DescribeIdentityIdFormat",
+		"DescribeImageAttribute",
+		"DescribeImages",
+		"DescribeImportImageTasks",
+		"DescribeImportSnapshotTasks",
+		"DescribeInstanceAttribute",
+		"DescribeInstanceCreditSpecifications",
+		"DescribeInstanceEventNotificationAttributes",
+		"DescribeInstanceStatus",
+		"DescribeInstanceTypeOfferings",
+		"DescribeInstanceTypes",
+		"DescribeInstances",
+		"DescribeInternetGateways",
+		"DescribeIpv6Pools",
+		"DescribeKeyPairs",
+		"DescribeLaunchTemplateVersions",
+		"DescribeLaunchTemplates",
+		"DescribeLocalGatewayRouteTableVirtualInterfaceGroupAssociations",
+		"DescribeLocalGatewayRouteTableVpcAssociations",
+		"DescribeLocalGatewayRouteTables",
+		"DescribeLocalGatewayVirtualInterfaceGroups",
+		"DescribeLocalGatewayVirtualInterfaces",
+		"DescribeLocalGateways",
+		"DescribeManagedPrefixLists",
+		"DescribeMovingAddresses",
+		"DescribeNatGateways",
+		"DescribeNetworkAcls",
+		"DescribeNetworkInsightsAnalyses",
+		"DescribeNetworkInsightsPaths",
+		"DescribeNetworkInterfaceAttribute",
+		"DescribeNetworkInterfacePermissions",
+		"DescribeNetworkInterfaces",
+		"DescribePlacementGroups",
+		"DescribePrefixLists",
+		"DescribePrincipalIdFormat",
+		"DescribePublicIpv4Pools",
+		"DescribeRegions",
+		"DescribeReservedInstances",
+		"DescribeReservedInstancesListings",
+		"DescribeReservedInstancesModifications",
+		"DescribeReservedInstancesOfferings",
+		"DescribeRouteTables",
+		"DescribeScheduledInstanceAvailability",
+		"DescribeScheduledInstances",
+		"DescribeSecurityGroupReferences",
+		"DescribeSecurityGroups",
+		"DescribeSnapshotAttribute",
+		"DescribeSnapshots",
+		"DescribeSpotDatafeedSubscription",
+		"DescribeSpotFleetInstances",
+		"DescribeSpotFleetRequestHistory",
+		"DescribeSpotFleetRequests",
+		"DescribeSpotInstanceRequests",
+		"DescribeSpotPriceHistory",
+		"DescribeStaleSecurityGroups",
+		"DescribeSubnets",
+		"DescribeTags",
+		"DescribeTrafficMirrorFilters",
+		"DescribeTrafficMirrorSessions",
+		"DescribeTrafficMirrorTargets",
+		"DescribeTransitGatewayAttachments",
+		"DescribeTransitGatewayConnectPeers",
+		"DescribeTransitGatewayConnects",
+		"DescribeTransitGatewayMulticastDomains",
+		"DescribeTransitGatewayPeeringAttachments",
+		"DescribeTransitGatewayRouteTables",
+		"DescribeTransitGatewayVpcAttachments",
+		"DescribeTransitGateways",
+		"DescribeVolumeAttribute",
+		"DescribeVolumeStatus",
+		"DescribeVolumes",
+		"DescribeVolumesModifications",
+		"DescribeVpcAttribute",
+		"DescribeVpcClassicLink",
+		"DescribeVpcClassicLinkDnsSupport",
+		"DescribeVpcEndpointConnectionNotifications",
+		"DescribeVpcEndpointConnections",
+		"DescribeVpcEndpointServiceConfigurations",
+		"DescribeVpcEndpointServicePermissions",
+		"DescribeVpcEndpointServices",
+		"DescribeVpcEndpoints",
+		"DescribeVpcPeeringConnections",
+		"DescribeVpcs",
+		"DescribeVpnConnections",
+		"DescribeVpnGateways",
+		"DetachClassicLinkVpc",
+		"DetachInternetGateway",
+		"DetachNetworkInterface",
+		"DetachVolume",
+		"DetachVpnGateway",
+		"DisableEbsEncryptionByDefault",
+		"DisableFastSnapshotRestores",
+		"DisableTransitGatewayRouteTablePropagation",
+		"DisableVgwRoutePropagation",
+		"DisableVpcClassicLink",
+		"DisableVpcClassicLinkDnsSupport",
+		"DisassociateAddress",
+		"DisassociateClientVpnTargetNetwork",
+		"DisassociateEnclaveCertificateIamRole",
+		"DisassociateIamInstanceProfile",
+		"DisassociateRouteTable",
+		"DisassociateSubnetCidrBlock",
+		"DisassociateTransitGatewayMulticastDomain",
+		"DisassociateTransitGatewayRouteTable",
+		"DisassociateVpcCidrBlock",
+		"EnableEbsEncryptionByDefault",
+		"EnableFastSnapshotRestores",
+		"EnableTransitGatewayRouteTablePropagation",
+		"EnableVgwRoutePropagation",
+		"EnableVolumeIO",
+		"EnableVpcClassicLink",
+		"EnableVpcClassicLinkDnsSupport",
+		"ExportClientVpnClientCertificateRevocationList",
+		"ExportClientVpnClientConfiguration",
+		"ExportImage",
+		"ExportTransitGatewayRoutes",
+		"GetAssociatedEnclaveCertificateIamRoles",
+		"GetAssociatedIpv6PoolCidrs",
+		"GetCapacityReservationUsage",
+		"GetCoipPoolUsage",
+		"GetConsoleOutput",
+		"GetConsoleScreenshot",
+		"GetDefaultCreditSpecification",
+		"GetEbsDefaultKmsKeyId",
+		"GetEbsEncryptionByDefault",
+		"GetGroupsForCapacityReservation",
+		"GetHostReservationPurchasePreview",
+		"GetLaunchTemplateData",
+		"GetManagedPrefixListAssociations",
+		"GetManagedPrefixListEntries",
+		"GetPasswordData",
+		"GetReservedInstancesExchangeQuote",
+		"GetTransitGatewayAttachmentPropagations",
+		"GetTransitGatewayMulticastDomainAssociations",
+		"GetTransitGatewayPrefixListReferences",
+		"GetTransitGatewayRouteTableAssociations",
+		"GetTransitGatewayRouteTablePropagations",
+		"ImportClientVpnClientCertificateRevocationList",
+		"ImportImage",
+		"ImportInstance",
+		"ImportKeyPair",
+		"ImportSnapshot",
+		"ImportVolume",
+		"ModifyAddressAttribute",
+		"ModifyAvailabilityZoneGroup",
+		"ModifyCapacityReservation",
+		"ModifyClientVpnEndpoint",
+		"ModifyDefaultCreditSpecification",
+		"ModifyEbsDefaultKmsKeyId",
+		"ModifyFleet",
+		"ModifyFpgaImageAttribute",
+		"ModifyHosts",
+		"ModifyIdFormat",
+		"ModifyIdentityIdFormat",
+		"ModifyImageAttribute",
+		"ModifyInstanceAttribute",
+		"ModifyInstanceCapacityReservationAttributes",
+		"ModifyInstanceCreditSpecification",
+		"ModifyInstanceEventStartTime",
+		"ModifyInstanceMetadataOptions",
+		"ModifyInstancePlacement",
+		"ModifyLaunchTemplate",
+		"ModifyManagedPrefixList",
+		"ModifyNetworkInterfaceAttribute",
+		"ModifyReservedInstances",
+		"ModifySnapshotAttribute",
+		"ModifySpotFleetRequest",
+		"ModifySubnetAttribute",
+		"ModifyTrafficMirrorFilterNetworkServices",
+		"ModifyTrafficMirrorFilterRule",
+		"ModifyTrafficMirrorSession",
+		"ModifyTransitGateway",
+		"ModifyTransitGatewayPrefixListReference",
+		"ModifyTransitGatewayVpcAttachment",
+		"ModifyVolume",
+		"ModifyVolumeAttribute",
+		"ModifyVpcAttribute",
+		"ModifyVpcEndpoint",
+		"ModifyVpcEndpointConnectionNotification",
+		"ModifyVpcEndpointServiceConfiguration",
+		"ModifyVpcEndpointServicePermissions",
+		"ModifyVpcPeeringConnectionOptions",
+		"ModifyVpcTenancy",
+		"ModifyVpnConnection",
+		"ModifyVpnConnectionOptions",
+		"ModifyVpnTunnelCertificate",
+		"ModifyVpnTunnelOptions",
+		"MonitorInstances",
+		"MoveAddressToVpc",
+		"ProvisionByoipCidr",
+		"PurchaseHostReservation",
+		"PurchaseReservedInstancesOffering",
+		"PurchaseScheduledInstances",
+		"RebootInstances",
+		"RegisterImage",
+		"RegisterInstanceEventNotificationAttributes",
+		"RegisterTransitGatewayMulticastGroupMembers",
+		"RegisterTransitGatewayMulticastGroupSources",
+		"RejectTransitGatewayMulticastDomainAssociations",
+		"RejectTransitGatewayPeeringAttachment",
+		"RejectTransitGatewayVpcAttachment",
+		"RejectVpcEndpointConnections",
+		"RejectVpcPeeringConnection",
+		"ReleaseAddress",
+		"ReleaseHosts",
+		"ReplaceIamInstanceProfileAssociation",
+		"ReplaceNetworkAclAssociation",
+		"ReplaceNetworkAclEntry",
+		"ReplaceRoute",
+		"ReplaceRouteTableAssociation",
+		"ReplaceTransitGatewayRoute",
+		"ReportInstanceStatus",
+		"RequestSpotFleet",
+		"RequestSpotInstances",
+		"ResetAddressAttribute",
+		"ResetEbsDefaultKmsKeyId",
+		"ResetFpgaImageAttribute",
+		"ResetImageAttribute",
+		"ResetInstanceAttribute",
+		"ResetNetworkInterfaceAttribute",
+		"ResetSnapshotAttribute",
+		"RestoreAddressToClassic",
+		"RestoreManagedPrefixListVersion",
+		"RevokeClientVpnIngress",
+		"RevokeSecurityGroupEgress",
+		"RevokeSecurityGroupIngress",
+		"RunInstances",
+		"RunScheduledInstances",
+		"SearchLocalGatewayRoutes",
+		"SearchTransitGatewayMulticastGroups",
+		"SearchTransitGatewayRoutes",
+		"SendDiagnosticInterrupt",
+		"StartInstances",
+		"StartNetworkInsightsAnalysis",
+		"StartVpcEndpointServicePrivateDnsVerification",
+		"StopInstances",
+		"TerminateClientVpnConnections",
+		"TerminateInstances",
+		"UnassignIpv6Addresses",
+		"UnassignPrivateIpAddresses",
+		"UnmonitorInstances",
+		"UpdateSecurityGroupRuleDescriptionsEgress",
+		"UpdateSecurityGroupRuleDescriptionsIngress",
+		"WithdrawByoipCidr",
+	},
+	"ec2-instance-connect": {
+		"SendSSHPublicKey",
+	},
+	"ec2messages": {
+		"AcknowledgeMessage",
+		"DeleteMessage",
+		"FailMessage",
+		"GetEndpoint",
+		"GetMessages",
+		"SendReply",
+	},
+	"ecr": {
+		"BatchCheckLayerAvailability",
+		"BatchDeleteImage",
+		"BatchGetImage",
+		"CompleteLayerUpload",
+		"CreateRepository",
+		"DeleteLifecyclePolicy",
+		"DeleteRegistryPolicy",
+		"DeleteRepository",
+		"DeleteRepositoryPolicy",
+		"DescribeImageScanFindings",
+		"DescribeImages",
+		"DescribeRegistry",
+		"DescribeRepositories",
+		"GetAuthorizationToken",
+		"GetDownloadUrlForLayer",
+		"GetLifecyclePolicy",
+		"GetLifecyclePolicyPreview",
+		"GetRegistryPolicy",
+		"GetRepositoryPolicy",
+		"InitiateLayerUpload",
+		"ListImages",
+		"ListTagsForResource",
+		"PutImage",
+		"PutImageScanningConfiguration",
+		"PutImageTagMutability",
+		"PutLifecyclePolicy",
+		"PutRegistryPolicy",
+		"PutReplicationConfiguration",
+		"SetRepositoryPolicy",
+		"StartImageScan",
+		"StartLifecyclePolicyPreview",
+		"TagResource",
+		"UntagResource",
+		"UploadLayerPart",
+	},
+	"ecr-public": {
+		"BatchCheckLayerAvailability",
+		"BatchDeleteImage",
+		"CompleteLayerUpload",
+		"CreateRepository",
+		"DeleteRepository",
+		"DeleteRepositoryPolicy",
+		"DescribeImageTags",
+		"DescribeImages",
+		"DescribeRegistries",
+		"DescribeRepositories",
+		"GetAuthorizationToken",
+		"GetRegistryCatalogData",
+		"GetRepositoryCatalogData",
+		"GetRepositoryPolicy",
+		"InitiateLayerUpload",
+		"ListTagsForResource",
+		"PutImage",
+		"PutRegistryCatalogData",
+		"PutRepositoryCatalogData",
+		"SetRepositoryPolicy",
+		"TagResource",
+		"UntagResource",
+		"UploadLayerPart",
+	},
+	"ecs": {
+		"CreateCapacityProvider",
+		"CreateCluster",
+		"CreateService",
+		"CreateTaskSet",
+		"DeleteAccountSetting",
+		"DeleteAttributes",
+		"DeleteCapacityProvider",
+		"DeleteCluster",
+		"DeleteService",
+		"DeleteTaskSet",
+		"DeregisterContainerInstance",
+		"DeregisterTaskDefinition",
+		"DescribeCapacityProviders",
+		"DescribeClusters",
+		"DescribeContainerInstances",
+		"DescribeServices",
+		"DescribeTaskDefinition",
+		"DescribeTaskSets",
+		"DescribeTasks",
+		"DiscoverPollEndpoint",
+		"ListAccountSettings",
+		"ListAttributes",
+		"ListClusters",
+		"ListContainerInstances",
+		"ListServices",
+		"ListTagsForResource",
+		"ListTaskDefinitionFamilies",
+		"ListTaskDefinitions",
+		"ListTasks",
+		"PutAccountSetting",
+		"PutAccountSettingDefault",
+		"PutAttributes",
+		"PutClusterCapacityProviders",
+		"RegisterContainerInstance",
+		"RegisterTaskDefinition",
+		"RunTask",
+		"StartTask",
+		"StopTask",
+		"SubmitAttachmentStateChanges",
+		"SubmitContainerStateChange",
+		"SubmitTaskStateChange",
+		"TagResource",
+		"UntagResource",
+		"UpdateCapacityProvider",
+		"UpdateClusterSettings",
+		"UpdateContainerAgent",
+		"UpdateContainerInstancesState",
+		"UpdateService",
+		"UpdateServicePrimaryTaskSet",
+		"UpdateTaskSet",
+	},
+	"eks": {
+		"AccessKubernetesApi",
+		"AssociateEncryptionConfig",
+		"AssociateIdentityProviderConfig",
+		"CreateAddon",
+		"CreateCluster",
+		"CreateFargateProfile",
+		"CreateNodegroup",
+		"DeleteAddon",
+		"DeleteCluster",
+		"DeleteFargateProfile",
+		"DeleteNodegroup",
+		"DescribeAddon",
+		"DescribeAddonVersions",
+		"DescribeCluster",
+		"DescribeFargateProfile",
+		"DescribeIdentityProviderConfig",
+		"DescribeNodegroup",
+		"DescribeUpdate",
+		"DisassociateIdentityProviderConfig",
+		"ListAddons",
+		"ListClusters",
+		"ListFargateProfiles",
+		"ListIdentityProviderConfigs",
+		"ListNodegroups",
+		"ListTagsForResource",
+		"ListUpdates",
+		"TagResource",
+		"UntagResource",
+		"UpdateAddon",
+		"UpdateClusterConfig",
+		"UpdateClusterVersion",
+		"UpdateNodegroupConfig",
+		"UpdateNodegroupVersion",
+	},
+	"elastic-inference": {
+		"DescribeAcceleratorOfferings",
+		"DescribeAcceleratorTypes",
+		"DescribeAccelerators",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+	},
+	"elasticache": {
+		"AddTagsToResource",
+		"AuthorizeCacheSecurityGroupIngress",
+		"BatchApplyUpdateAction",
+		"BatchStopUpdateAction",
+		"CompleteMigration",
+		"CopySnapshot",
+		"CreateCacheCluster",
+		"CreateCacheParameterGroup",
+		"CreateCacheSecurityGroup",
+		"CreateCacheSubnetGroup",
+		"CreateGlobalReplicationGroup",
+		"CreateReplicationGroup",
+		"CreateSnapshot",
+		"CreateUser",
+		"CreateUserGroup",
+		"DecreaseNodeGroupsInGlobalReplicationGroup",
+		"DecreaseReplicaCount",
+		"DeleteCacheCluster",
+		"DeleteCacheParameterGroup",
+		"DeleteCacheSecurityGroup",
+		"DeleteCacheSubnetGroup",
+		"DeleteGlobalReplicationGroup",
+		"DeleteReplicationGroup",
+		"DeleteSnapshot",
+		"DeleteUser",
+		"DeleteUserGroup",
+		"DescribeCacheClusters",
+		"DescribeCacheEngineVersions",
+		"DescribeCacheParameterGroups",
+		"DescribeCacheParameters",
+		"DescribeCacheSecurityGroups",
+		"DescribeCacheSubnetGroups",
+		"DescribeEngineDefaultParameters",
+		"DescribeEvents",
+		"DescribeGlobalReplicationGroups",
+		"DescribeReplicationGroups",
+		"DescribeReservedCacheNodes",
+		"DescribeReservedCacheNodesOfferings",
+		"DescribeServiceUpdates",
+		"DescribeSnapshots",
+		"DescribeUpdateActions",
+		"DescribeUserGroups",
+		"DescribeUsers",
+		"DisassociateGlobalReplicationGroup",
+		"FailoverGlobalReplicationGroup",
+		"IncreaseNodeGroupsInGlobalReplicationGroup",
+		"IncreaseReplicaCount",
+		"ListAllowedNodeTypeModifications",
+		"ListTagsForResource",
+		"ModifyCacheCluster",
+		"ModifyCacheParameterGroup",
+		"ModifyCacheSubnetGroup",
+		"ModifyGlobalReplicationGroup",
+		"ModifyReplicationGroup",
+		"ModifyReplicationGroupShardConfiguration",
+		"ModifyUser",
+		"ModifyUserGroup",
+		"PurchaseReservedCacheNodesOffering",
+		"RebalanceSlotsInGlobalReplicationGroup",
+		"RebootCacheCluster",
+		"RemoveTagsFromResource",
+		"ResetCacheParameterGroup",
+		"RevokeCacheSecurityGroupIngress",
+		"StartMigration",
+		"TestFailover",
+	},
+	"elasticbeanstalk": {
+		"AbortEnvironmentUpdate",
+		"ApplyEnvironmentManagedAction",
+		"AssociateEnvironmentOperationsRole",
+		"CheckDNSAvailability",
+		"ComposeEnvironments",
+		"CreateApplication",
+		"CreateApplicationVersion",
+		"CreateConfigurationTemplate",
+		"CreateEnvironment",
+		"CreatePlatformVersion",
+		"CreateStorageLocation",
+		"DeleteApplication",
+		"DeleteApplicationVersion",
+		"DeleteConfigurationTemplate",
+		"DeleteEnvironmentConfiguration",
+		"DeletePlatformVersion",
+		"DescribeAccountAttributes",
+		"DescribeApplicationVersions",
+		"DescribeApplications",
+		"DescribeConfigurationOptions",
+		"DescribeConfigurationSettings",
+		"DescribeEnvironmentHealth",
+		"DescribeEnvironmentManagedActionHistory",
+		"DescribeEnvironmentManagedActions",
+		"DescribeEnvironmentResources",
+		"DescribeEnvironments",
+		"DescribeEvents",
+		"DescribeInstancesHealth",
+		"DescribePlatformVersion",
+		"DisassociateEnvironmentOperationsRole",
+		"ListAvailableSolutionStacks",
+		"ListPlatformBranches",
+		"ListPlatformVersions",
+		"ListTagsForResource",
+		"RebuildEnvironment",
+		"RequestEnvironmentInfo",
+		"RestartAppServer",
+		"RetrieveEnvironmentInfo",
+		"SwapEnvironmentCNAMEs",
+		"TerminateEnvironment",
+		"UpdateApplication",
+		"UpdateApplicationResourceLifecycle",
+		"UpdateApplicationVersion",
+		"UpdateConfigurationTemplate",
+		"UpdateEnvironment",
+		"UpdateTagsForResource",
+		"ValidateConfigurationSettings",
+	},
+	"elasticfilesystem": {
+		"ClientMount",
+		"ClientRootAccess",
+		"ClientWrite",
+		"CreateAccessPoint",
+		"CreateFileSystem",
+		"CreateMountTarget",
+		"CreateTags",
+		"DeleteAccessPoint",
+		"DeleteFileSystem",
+		"DeleteFileSystemPolicy",
+		"DeleteMountTarget",
+		"DeleteTags",
+		"DescribeAccessPoints",
+		"DescribeBackupPolicy",
+		"DescribeFileSystemPolicy",
+		"DescribeFileSystems",
+		"DescribeLifecycleConfiguration",
+		"DescribeMountTargetSecurityGroups",
+		"DescribeMountTargets",
+		"DescribeTags",
+		"ListTagsForResource",
+		"ModifyMountTargetSecurityGroups",
+		"PutBackupPolicy",
+		"PutFileSystemPolicy",
+		"PutLifecycleConfiguration",
+		"TagResource",
+		"UntagResource",
+		"UpdateFileSystem",
+	},
+	"elasticloadbalancing": {
+		"AddListenerCertificates",
+		"AddTags",
+		"ApplySecurityGroupsToLoadBalancer",
+		"AttachLoadBalancerToSubnets",
+		"ConfigureHealthCheck",
+		"CreateAppCookieStickinessPolicy",
+		"CreateLBCookieStickinessPolicy",
+		"CreateListener",
+		"CreateLoadBalancer",
+		"CreateLoadBalancerListeners",
+		"CreateLoadBalancerPolicy",
+		"CreateRule",
+		"CreateTargetGroup",
+		"DeleteListener",
+		"DeleteLoadBalancer",
+		"DeleteLoadBalancerListeners",
+		"DeleteLoadBalancerPolicy",
+		"DeleteRule",
+		"DeleteTargetGroup",
+		"DeregisterInstancesFromLoadBalancer",
+		"DeregisterTargets",
+		"DescribeAccountLimits",
+		"DescribeInstanceHealth",
+		"DescribeListenerCertificates",
+		"DescribeListeners",
+		"DescribeLoadBalancerAttributes",
+		"DescribeLoadBalancerPolicies",
+		"DescribeLoadBalancerPolicyTypes",
+		"DescribeLoadBalancers",
+		"DescribeRules",
+		"DescribeSSLPolicies",
+		"DescribeTags",
+		"DescribeTargetGroupAttributes",
+		"DescribeTargetGroups",
+		"DescribeTargetHealth",
+		"DetachLoadBalancerFromSubnets",
+		"DisableAvailabilityZonesForLoadBalancer",
+		"EnableAvailabilityZonesForLoadBalancer",
+		"ModifyListener",
+		"ModifyLoadBalancerAttributes",
+		"ModifyRule",
+		"ModifyTargetGroup",
+		"ModifyTargetGroupAttributes",
+		"RegisterInstancesWithLoadBalancer",
+		"RegisterTargets",
+		"RemoveListenerCertificates",
+		"RemoveTags",
+		"SetIpAddressType",
+		"SetLoadBalancerListenerSSLCertificate",
+		"SetLoadBalancerPoliciesForBackendServer",
+		"SetLoadBalancerPoliciesOfListener",
+		"SetRulePriorities",
+		"SetSecurityGroups",
+		"SetSubnets",
+	},
+	"elasticmapreduce": {
+		"AddInstanceFleet",
+		"AddInstanceGroups",
+		"AddJobFlowSteps",
+		"AddTags",
+		"CancelSteps",
+		"CreateSecurityConfiguration",
+		"CreateStudio",
+		"CreateStudioSessionMapping",
+		"DeleteSecurityConfiguration",
+		"DeleteStudio",
+		"DeleteStudioSessionMapping",
+		"DescribeCluster",
+		"DescribeJobFlows",
+		"DescribeNotebookExecution",
+		"DescribeSecurityConfiguration",
+		"DescribeStep",
+		"DescribeStudio",
+		"GetBlockPublicAccessConfiguration",
+		"GetManagedScalingPolicy",
+		"GetStudioSessionMapping",
+		"ListBootstrapActions",
+		"ListClusters",
+		"ListInstanceFleets",
+		"ListInstanceGroups",
+		"ListInstances",
+		"ListNotebookExecutions",
+		"ListSecurityConfigurations",
+		"ListSteps",
+		"ListStudioSessionMappings",
+		"ListStudios",
+		"ModifyCluster",
+		"ModifyInstanceFleet",
+		"ModifyInstanceGroups",
+		"PutAutoScalingPolicy",
+		"PutBlockPublicAccessConfiguration",
+		"PutManagedScalingPolicy",
+		"RemoveAutoScalingPolicy",
+		"RemoveManagedScalingPolicy",
+		"RemoveTags",
+		"RunJobFlow",
+		"SetTerminationProtection",
+		"SetVisibleToAllUsers",
+		"StartNotebookExecution",
+		"StopNotebookExecution",
+		"TerminateJobFlows",
+		"UpdateStudio",
+		"UpdateStudioSessionMapping",
+	},
+	"elastictranscoder": {
+		"CancelJob",
+		"CreateJob",
+		"CreatePipeline",
+		"CreatePreset",
+		"DeletePipeline",
+		"DeletePreset",
+		"ListJobsByPipeline",
+		"ListJobsByStatus",
+		"ListPipelines",
+		"ListPresets",
+		"ReadJob",
+		"ReadPipeline",
+		"ReadPreset",
+		"TestRole",
+		"UpdatePipeline",
+		"UpdatePipelineNotifications",
+		"UpdatePipelineStatus",
+	},
+	"emr-containers": {
+		"CancelJobRun",
+		"CreateManagedEndpoint",
+		"CreateVirtualCluster",
+		"DeleteManagedEndpoint",
+		"DeleteVirtualCluster",
+		"DescribeJobRun",
+		"DescribeManagedEndpoint",
+		"DescribeVirtualCluster",
+		"ListJobRuns",
+		"ListManagedEndpoints",
+		"ListTagsForResource",
+		"ListVirtualClusters",
+		"StartJobRun",
+		"TagResource",
+		"UntagResource",
+	},
+	"es": {
+		"AcceptInboundCrossClusterSearchConnection",
+		"AddTags",
+		"AssociatePackage",
+		"CancelElasticsearchServiceSoftwareUpdate",
+		"CreateElasticsearchDomain",
+		"CreateOutboundCrossClusterSearchConnection",
+		"CreatePackage",
+		"DeleteElasticsearchDomain",
+		"DeleteElasticsearchServiceRole",
+		"DeleteInboundCrossClusterSearchConnection",
+		"DeleteOutboundCrossClusterSearchConnection",
+		"DeletePackage",
+		"DescribeDomainAutoTunes",
+		"DescribeElasticsearchDomain",
+		"DescribeElasticsearchDomainConfig",
+		"DescribeElasticsearchDomains",
+		"DescribeElasticsearchInstanceTypeLimits",
+		"DescribeInboundCrossClusterSearchConnections",
+		"DescribeOutboundCrossClusterSearchConnections",
+		"DescribePackages",
+		"DescribeReservedElasticsearchInstanceOfferings",
+		"DescribeReservedElasticsearchInstances",
+		"DissociatePackage",
+		"ESHttpDelete",
+		"ESHttpGet",
+		"ESHttpHead",
+		"ESHttpPatch",
+		"ESHttpPost",
+		"ESHttpPut",
+		"GetCompatibleElasticsearchVersions",
+		"GetPackageVersionHistory",
+		"GetUpgradeHistory",
+		"GetUpgradeStatus",
+		"ListDomainNames",
+		"ListDomainsForPackage",
+		"ListElasticsearchInstanceTypes",
+		"ListElasticsearchVersions",
+		"ListPackagesForDomain",
+		"ListTags",
+		"PurchaseReservedElasticsearchInstanceOffering",
+		"RejectInboundCrossClusterSearchConnection",
+		"RemoveTags",
+		"StartElasticsearchServiceSoftwareUpdate",
+		"UpdateElasticsearchDomainConfig",
+		"UpdatePackage",
+		"UpgradeElasticsearchDomain",
+	},
+	"events": {
+		"ActivateEventSource",
+		"CancelReplay",
+		"CreateApiDestination",
+		"CreateArchive",
+		"CreateConnection",
+		"CreateEventBus",
+		"CreatePartnerEventSource",
+		"DeactivateEventSource",
+		"DeauthorizeConnection",
+		"DeleteApiDestination",
+		"DeleteArchive",
+		"DeleteConnection",
+		"DeleteEventBus",
+		"DeletePartnerEventSource",
+		"DeleteRule",
+		"DescribeApiDestination",
+		"DescribeArchive",
+		"DescribeConnection",
+		"DescribeEventBus",
+		"DescribeEventSource",
+		"DescribePartnerEventSource",
+		"DescribeReplay",
+		"DescribeRule",
+		"DisableRule",
+		"EnableRule",
+		"ListApiDestinations",
+		"ListArchives",
+		"ListConnections",
+		"ListEventBuses",
+		"ListEventSources",
+		"ListPartnerEventSourceAccounts",
+		"ListPartnerEventSources",
+		"ListReplays",
+		"ListRuleNamesByTarget",
+		"ListRules",
+		"ListTagsForResource",
+		"ListTargetsByRule",
+		"PutEvents",
+		"PutPartnerEvents",
+		"PutPermission",
+		"PutRule",
+		"PutTargets",
+		"RemovePermission",
+		"RemoveTargets",
+		"StartReplay",
+		"TagResource",
+		"TestEventPattern",
+		"UntagResource",
+		"UpdateApiDestination",
+		"UpdateArchive",
+		"UpdateConnection",
+	},
+	"execute-api": {
+		"InvalidateCache",
+		"Invoke",
+		"ManageConnections",
+	},
+	"firehose": {
+		"CreateDeliveryStream",
+		"DeleteDeliveryStream",
+		"DescribeDeliveryStream",
+		"ListDeliveryStreams",
+		"ListTagsForDeliveryStream",
+		"PutRecord",
+		"PutRecordBatch",
+		"StartDeliveryStreamEncryption",
+		"StopDeliveryStreamEncryption",
+		"TagDeliveryStream",
+		"UntagDeliveryStream",
+		"UpdateDestination",
+	},
+	"fms": {
+		"AssociateAdminAccount",
+		"DeleteAppsList",
+		"DeleteNotificationChannel",
+		"DeletePolicy",
+		"DeleteProtocolsList",
+		"DisassociateAdminAccount",
+		"GetAdminAccount",
+		"GetAppsList",
+		"GetComplianceDetail",
+		"GetNotificationChannel",
+		"GetPolicy",
+		"GetProtectionStatus",
+		"GetProtocolsList",
+		"GetViolationDetails",
+		"ListAppsLists",
+		"ListComplianceStatus",
+		"ListMemberAccounts",
+		"ListPolicies",
+		"ListProtocolsLists",
+		"ListTagsForResource",
+		"PutAppsList",
+		"PutNotificationChannel",
+		"PutPolicy",
+		"PutProtocolsList",
+		"TagResource",
+		"UntagResource",
+	},
+	"forecast": {
+		"CreateDataset",
+		"CreateDatasetGroup",
+		"CreateDatasetImportJob",
+		"CreateForecast",
+		"CreateForecastExportJob",
+		"CreatePredictor",
+		"CreatePredictorBacktestExportJob",
+		"DeleteDataset",
+		"DeleteDatasetGroup",
+		"DeleteDatasetImportJob",
+		"DeleteForecast",
+		"DeleteForecastExportJob",
+		"DeletePredictor",
+		"DeletePredictorBacktestExportJob",
+		"DescribeDataset",
+		"DescribeDatasetGroup",
+		"DescribeDatasetImportJob",
+		"DescribeForecast",
+		"DescribeForecastExportJob",
+		"DescribePredictor",
+		"DescribePredictorBacktestExportJob",
+		"GetAccuracyMetrics",
+		"ListDatasetGroups",
+		"ListDatasetImportJobs",
+		"ListDatasets",
+		"ListForecastExportJobs",
+		"ListForecasts",
+		"ListPredictorBacktestExportJobs",
+		"ListPredictors",
+		"ListTagsForResource",
+		"QueryForecast",
+		"StopResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateDatasetGroup",
+	},
+	"frauddetector": {
+		"BatchCreateVariable",
+		"BatchGetVariable",
+		"CreateDetectorVersion",
+		"CreateModel",
+		"CreateModelVersion",
+		"CreateRule",
+		"CreateVariable",
+		"DeleteDetector",
+		"DeleteDetectorVersion",
+		"DeleteEntityType",
+		"DeleteEvent",
+		"DeleteEventType",
+		"DeleteExternalModel",
+		"DeleteLabel",
+		"DeleteModel",
+		"DeleteModelVersion",
+		"DeleteOutcome",
+		"DeleteRule",
+		"DeleteVariable",
+		"DescribeDetector",
+		"DescribeModelVersions",
+		"GetDetectorVersion",
+		"GetDetectors",
+		"GetEntityTypes",
+		"GetEventPrediction",
+		"GetEventTypes",
+		"GetExternalModels",
+		"GetKMSEncryptionKey",
+		"GetLabels",
+		"GetModelVersion",
+		"GetModels",
+		"GetOutcomes",
+		"GetRules",
+		"GetVariables",
+		"ListTagsForResource",
+		"PutDetector",
+		"PutEntityType",
+		"PutEventType",
+		"PutExternalModel",
+		"PutKMSEncryptionKey",
+		"PutLabel",
+		"PutOutcome",
+		"TagResource",
+		"UntagResource",
+		"UpdateDetectorVersion",
+		"UpdateDetectorVersionMetadata",
+		"UpdateDetectorVersionStatus",
+		"UpdateModel",
+		"UpdateModelVersion",
+		"UpdateModelVersionStatus",
+		"UpdateRuleMetadata",
+		"UpdateRuleVersion",
+		"UpdateVariable",
+	},
+	"fsx": {
+		"AssociateFileSystemAliases",
+		"CancelDataRepositoryTask",
+		"CreateBackup",
+		"CreateDataRepositoryTask",
+		"CreateFileSystem",
+		"CreateFileSystemFromBackup",
+		"DeleteBackup",
+		"DeleteFileSystem",
+		"DescribeBackups",
+		"DescribeDataRepositoryTasks",
+		"DescribeFileSystemAliases",
+		"DescribeFileSystems",
+		"DisassociateFileSystemAliases",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateFileSystem",
+	},
+	"gamelift": {
+		"AcceptMatch",
+		"ClaimGameServer",
+		"CreateAlias",
+		"CreateBuild",
+		"CreateFleet",
+		"CreateGameServerGroup",
+		"CreateGameSession",
+		"CreateGameSessionQueue",
+		"CreateMatchmakingConfiguration",
+		"CreateMatchmakingRuleSet",
+		"CreatePlayerSession",
+		"CreatePlayerSessions",
+		"CreateScript",
+		"CreateVpcPeeringAuthorization",
+		"CreateVpcPeeringConnection",
+		"DeleteAlias",
+		"DeleteBuild",
+		"DeleteFleet",
+		"DeleteGameServerGroup",
+		"DeleteGameSessionQueue",
+		"DeleteMatchmakingConfiguration",
+		"DeleteMatchmakingRuleSet",
+		"DeleteScalingPolicy",
+		"DeleteScript",
+		"DeleteVpcPeeringAuthorization",
+		"DeleteVpcPeeringConnection",
+		"DeregisterGameServer",
+		"DescribeAlias",
+		"DescribeBuild",
+		"DescribeEC2InstanceLimits",
+		"DescribeFleetAttributes",
+		"DescribeFleetCapacity",
+		"DescribeFleetEvents",
+		"DescribeFleetPortSettings",
+		"DescribeFleetUtilization",
+		"DescribeGameServer",
+		"DescribeGameServerGroup",
+		"DescribeGameServerInstances",
+		"DescribeGameSessionDetails",
+		"DescribeGameSessionPlacement",
+		"DescribeGameSessionQueues",
+		"DescribeGameSessions",
+		"DescribeInstances",
+		"DescribeMatchmaking",
+		"DescribeMatchmakingConfigurations",
+		"DescribeMatchmakingRuleSets",
+		"DescribePlayerSessions",
+		"DescribeRuntimeConfiguration",
+		"DescribeScalingPolicies",
+		"DescribeScript",
+		"DescribeVpcPeeringAuthorizations",
+		"DescribeVpcPeeringConnections",
+		"GetGameSessionLogUrl",
+		"GetInstanceAccess",
+		"ListAliases",
+		"ListBuilds",
+		"ListFleets",
+		"ListGameServerGroups",
+		"ListGameServers",
+		"ListScripts",
+		"ListTagsForResource",
+		"PutScalingPolicy",
+		"RegisterGameServer",
+		"RequestUploadCredentials",
+		"ResolveAlias",
+		"ResumeGameServerGroup",
+		"SearchGameSessions",
+		"StartFleetActions",
+		"StartGameSessionPlacement",
+		"StartMatchBackfill",
+		"StartMatchmaking",
+		"StopFleetActions",
+		"StopGameSessionPlacement",
+		"StopMatchmaking",
+		"SuspendGameServerGroup",
+		"TagResource",
+		"UntagResource",
+		"UpdateAlias",
+		"UpdateBuild",
+		"UpdateFleetAttributes",
+		"UpdateFleetCapacity",
+		"UpdateFleetPortSettings",
+		"UpdateGameServer",
+		"UpdateGameServerGroup",
+		"UpdateGameSession",
+		"UpdateGameSessionQueue",
+		"UpdateMatchmakingConfiguration",
+		"UpdateRuntimeConfiguration",
+		"UpdateScript",
+		"ValidateMatchmakingRuleSet",
+	},
+	"geo": {
+		"AssociateTrackerConsumer",
+		"BatchDeleteGeofence",
+		"BatchEvaluateGeofences",
+		"BatchGetDevicePosition",
+		"BatchPutGeofence",
+		"BatchUpdateDevicePosition",
+		"CreateGeofenceCollection",
+		"CreateMap",
+		"CreatePlaceIndex",
+		"CreateTracker",
+		"DeleteGeofenceCollection",
+		"DeleteMap",
+		"DeletePlaceIndex",
+		"DeleteTracker",
+		"DescribeGeofenceCollection",
+		"DescribeMap",
+		"DescribePlaceIndex",
+		"DescribeTracker",
+		"DisassociateTrackerConsumer",
+		"GetDevicePosition",
+		"GetDevicePositionHistory",
+		"GetGeofence",
+		"GetMapGlyphs",
+		"GetMapSprites",
+		"GetMapStyleDescriptor",
+		"GetMapTile",
+		"ListGeofenceCollections",
+		"ListGeofences",
+		"ListMaps",
+		"ListPlaceIndexes",
+		"ListTrackerConsumers",
+		"ListTrackers",
+		"PutGeofence",
+		"SearchPlaceIndexForPosition",
+		"SearchPlaceIndexForText",
+	},
+	"glacier": {
+		"AbortMultipartUpload",
+		"AbortVaultLock",
+		"AddTagsToVault",
+		"CompleteMultipartUpload",
+		"CompleteVaultLock",
+		"CreateVault",
+		"DeleteArchive",
+		"DeleteVault",
+		"DeleteVaultAccessPolicy",
+		"DeleteVaultNotifications",
+		"DescribeJob",
+		"DescribeVault",
+		"GetDataRetrievalPolicy",
+		"GetJobOutput",
+		"GetVaultAccessPolicy",
+		"GetVaultLock",
+		"GetVaultNotifications",
+		"InitiateJob",
+		"InitiateMultipartUpload",
+		"InitiateVaultLock",
+		"ListJobs",
+		"ListMultipartUploads",
+		"ListParts",
+		"ListProvisionedCapacity",
+		"ListTagsForVault",
+		"ListVaults",
+		"PurchaseProvisionedCapacity",
+		"RemoveTagsFromVault",
+		"SetDataRetrievalPolicy",
+		"SetVaultAccessPolicy",
+		"SetVaultNotifications",
+		"UploadArchive",
+		"UploadMultipartPart",
+	},
+	"globalaccelerator": {
+		"AddCustomRoutingEndpoints",
+		"AdvertiseByoipCidr",
+		"AllowCustomRoutingTraffic",
+		"CreateAccelerator",
+		"CreateCustomRoutingAccelerator",
+		"CreateCustomRoutingEndpointGroup",
+		"CreateCustomRoutingListener",
+		"CreateEndpointGroup",
+		"CreateListener",
+		"DeleteAccelerator",
+		"DeleteCustomRoutingAccelerator",
+		"DeleteCustomRoutingEndpointGroup",
+		"DeleteCustomRoutingListener",
+		"DeleteEndpointGroup",
+		"DeleteListener",
+		"DenyCustomRoutingTraffic",
+		"DeprovisionByoipCidr",
+		"DescribeAccelerator",
+		"DescribeAcceleratorAttributes",
+		"DescribeCustomRoutingAccelerator",
+		"DescribeCustomRoutingAcceleratorAttributes",
+		"DescribeCustomRoutingEndpointGroup",
+		"DescribeCustomRoutingListener",
+		"DescribeEndpointGroup",
+		"DescribeListener",
+		"ListAccelerators",
+		"ListByoipCidrs",
+		"ListCustomRoutingAccelerators",
+		"ListCustomRoutingEndpointGroups",
+		"ListCustomRoutingListeners",
+		"ListCustomRoutingPortMappings",
+		"ListCustomRoutingPortMappingsByDestination",
+		"ListEndpointGroups",
+		"ListListeners",
+		"ListTagsForResource",
+		"ProvisionByoipCidr",
+		"RemoveCustomRoutingEndpoints",
+		"TagResource",
+		"UntagResource",
+		"UpdateAccelerator",
+		"UpdateAcceleratorAttributes",
+		"UpdateCustomRoutingAccelerator",
+		"UpdateCustomRoutingAcceleratorAttributes",
+		"UpdateCustomRoutingListener",
+		"UpdateEndpointGroup",
+		"UpdateListener",
+		"WithdrawByoipCidr",
+	},
+	"glue": {
+		"BatchCreatePartition",
+		"BatchDeleteConnection",
+		"BatchDeletePartition",
+		"BatchDeleteTable",
+		"BatchDeleteTableVersion",
+		"BatchGetCrawlers",
+		"BatchGetDevEndpoints",
+		"BatchGetJobs",
+		"BatchGetPartition",
+		"BatchGetTriggers",
+		"BatchGetWorkflows",
+		"BatchStopJobRun",
+		"BatchUpdatePartition",
+		"CancelMLTaskRun",
+		"CheckSchemaVersionValidity",
+		"CreateClassifier",
+		"CreateConnection",
+		"CreateCrawler",
+		"CreateDatabase",
+		"CreateDevEndpoint",
+		"CreateJob",
+		"CreateMLTransform",
+		"CreatePartition",
+		"CreatePartitionIndex",
+		"CreateRegistry",
+		"CreateSchema",
+		"CreateScript",
+		"CreateSecurityConfiguration",
+		"CreateTable",
+		"CreateTrigger",
+		"CreateUserDefinedFunction",
+		"CreateWorkflow",
+		"DeleteClassifier",
+		"DeleteColumnStatisticsForPartition",
+		"DeleteColumnStatisticsForTable",
+		"DeleteConnection",
+		"DeleteCrawler",
+		"DeleteDatabase",
+		"DeleteDevEndpoint",
+		"DeleteJob",
+		"DeleteMLTransform",
+		"DeletePartition",
+		"DeletePartitionIndex",
+		"DeleteRegistry",
+		"DeleteResourcePolicy",
+		"DeleteSchema",
+		"DeleteSchemaVersions",
+		"DeleteSecurityConfiguration",
+		"DeleteTable",
+		"DeleteTableVersion",
+		"DeleteTrigger",
+		"DeleteUserDefinedFunction",
+		"DeleteWorkflow",
+		"GetCatalogImportStatus",
+		"GetClassifier",
+		"GetClassifiers",
+		"GetColumnStatisticsForPartition",
+		"GetColumnStatisticsForTable",
+		"GetConnection",
+		"GetConnections",
+		"GetCrawler",
+		"GetCrawlerMetrics",
+		"GetCrawlers",
+		"GetDataCatalogEncryptionSettings",
+		"GetDatabase",
+		"GetDatabases",
+		"GetDataflowGraph",
+		"GetDevEndpoint",
+		"GetDevEndpoints",
+		"GetJob",
+		"GetJobBookmark",
+		"GetJobRun",
+		"GetJobRuns",
+		"GetJobs",
+		"GetMLTaskRun",
+		"GetMLTaskRuns",
+		"GetMLTransform",
+		"GetMLTransforms",
+		"GetMapping",
+		"GetPartition",
+		"GetPartitionIndexes",
+		"GetPartitions",
+		"GetPlan",
+		"GetRegistry",
+		"GetResourcePolicies",
+		"GetResourcePolicy",
+		"GetSchema",
+		"GetSchemaByDefinition",
+		"GetSchemaVersion",
+		"GetSchemaVersionsDiff",
+		"GetSecurityConfiguration",
+		"GetSecurityConfigurations",
+		"GetTable",
+		"GetTableVersion",
+		"GetTableVersions",
+		"GetTables",
+		"GetTags",
+		"GetTrigger",
+		"GetTriggers",
+		"GetUserDefinedFunction",
+		"GetUserDefinedFunctions",
+		"GetWorkflow",
+		"GetWorkflowRun",
+		"GetWorkflowRunProperties",
+		"GetWorkflowRuns",
+		"ImportCatalogToGlue",
+		"ListCrawlers",
+		"ListDevEndpoints",
+		"ListJobs",
+		"ListMLTransforms",
+		"ListRegistries",
+		"ListSchemaVersions",
+		"ListSchemas",
+		"ListTriggers",
+		"ListWorkflows",
+		"PutDataCatalogEncryptionSettings",
+		"PutResourcePolicy",
+		"PutSchemaVersionMetadata",
+		"PutWorkflowRunProperties",
+		"QuerySchemaVersionMetadata",
+		"RegisterSchemaVersion",
+		"RemoveSchemaVersionMetadata",
+		"ResetJobBookmark",
+		"ResumeWorkflowRun",
+		"SearchTables",
+		"StartCrawler",
+		"StartCrawlerSchedule",
+		"StartExportLabelsTaskRun",
+		"StartImportLabelsTaskRun",
+		"StartJobRun",
+		"StartMLEvaluationTaskRun",
+		"StartMLLabelingSetGenerationTaskRun",
+		"StartTrigger",
+		"StartWorkflowRun",
+		"StopCrawler",
+		"StopCrawlerSchedule",
+		"StopTrigger",
+		"StopWorkflowRun",
+		"TagResource",
+		"UntagResource",
+		"UpdateClassifier",
+		"UpdateColumnStatisticsForPartition",
+		"UpdateColumnStatisticsForTable",
+		"UpdateConnection",
+		"UpdateCrawler",
+		"UpdateCrawlerSchedule",
+		"UpdateDatabase",
+		"UpdateDevEndpoint",
+		"UpdateJob",
+		"UpdateMLTransform",
+		"UpdatePartition",
+		"UpdateRegistry",
+		"UpdateSchema",
+		"UpdateTable",
+		"UpdateTrigger",
+		"UpdateUserDefinedFunction",
+		"UpdateWorkflow",
+	},
+	"greengrass": {
+		"AssociateRoleToGroup",
+		"AssociateServiceRoleToAccount",
+		"CancelDeployment",
+		"CreateComponentVersion",
+		"CreateConnectorDefinition",
+		"CreateConnectorDefinitionVersion",
+		"CreateCoreDefinition",
+		"CreateCoreDefinitionVersion",
+		"CreateDeployment",
+		"CreateDeviceDefinition",
+		"CreateDeviceDefinitionVersion",
+		"CreateFunctionDefinition",
+		"CreateFunctionDefinitionVersion",
+		"CreateGroup",
+		"CreateGroupCertificateAuthority",
+		"CreateGroupVersion",
+		"CreateLoggerDefinition",
+		"CreateLoggerDefinitionVersion",
+		"CreateResourceDefinition",
+		"CreateResourceDefinitionVersion",
+		"CreateSoftwareUpdateJob",
+		"CreateSubscriptionDefinition",
+		"CreateSubscriptionDefinitionVersion",
+		"DeleteComponent",
+		"DeleteConnectorDefinition",
+		"DeleteCoreDefinition",
+		"DeleteCoreDevice",
+		"DeleteDeviceDefinition",
+		"DeleteFunctionDefinition",
+		"DeleteGroup",
+		"DeleteLoggerDefinition",
+		"DeleteResourceDefinition",
+		"DeleteSubscriptionDefinition",
+		"DescribeComponent",
+		"DisassociateRoleFromGroup",
+		"DisassociateServiceRoleFromAccount",
+		"GetAssociatedRole",
+		"GetBulkDeploymentStatus",
+		"GetComponent",
+		"GetComponentVersionArtifact",
+		"GetConnectivityInfo",
+		"GetConnectorDefinition",
+		"GetConnectorDefinitionVersion",
+		"GetCoreDefinition",
+		"GetCoreDefinitionVersion",
+		"GetCoreDevice",
+		"GetDeployment",
+		"GetDeploymentStatus",
+		"GetDeviceDefinition",
+		"GetDeviceDefinitionVersion",
+		"GetFunctionDefinition",
+		"GetFunctionDefinitionVersion",
+		"GetGroup",
+		"GetGroupCertificateAuthority",
+		"GetGroupCertificateConfiguration",
+		"GetGroupVersion",
+		"GetLoggerDefinition",
+		"GetLoggerDefinitionVersion",
+		"GetResourceDefinition",
+		"GetResourceDefinitionVersion",
+		"GetServiceRoleForAccount",
+		"GetSubscriptionDefinition",
+		"GetSubscriptionDefinitionVersion",
+		"GetThingRuntimeConfiguration",
+		"ListBulkDeploymentDetailedReports",
+		"ListBulkDeployments",
+		"ListComponentVersions",
+		"ListComponents",
+		"ListConnectorDefinitionVersions",
+		"ListConnectorDefinitions",
+		"ListCoreDefinitionVersions",
+		"ListCoreDefinitions",
+		"ListCoreDevices",
+		"ListDeployments",
+		"ListDeviceDefinitionVersions",
+		"ListDeviceDefinitions",
+		"ListEffectiveDeployments",
+		"ListFunctionDefinitionVersions",
+		"ListFunctionDefinitions",
+		"ListGroupCertificateAuthorities",
+		"ListGroupVersions",
+		"ListGroups",
+		"ListInstalledComponents",
+		"ListLoggerDefinitionVersions",
+		"ListLoggerDefinitions",
+		"ListResourceDefinitionVersions",
+		"ListResourceDefinitions",
+		"ListSubscriptionDefinitionVersions",
+		"ListSubscriptionDefinitions",
+		"ListTagsForResource",
+		"ResetDeployments",
+		"ResolveComponentCandidates",
+		"StartBulkDeployment",
+		"StopBulkDeployment",
+		"TagResource",
+		"UntagResource",
+		"UpdateConnectivityInfo",
+		"UpdateConnectorDefinition",
+		"UpdateCoreDefinition",
+		"UpdateDeviceDefinition",
+		"UpdateFunctionDefinition",
+		"UpdateGroup",
+		"UpdateGroupCertificateConfiguration",
+		"UpdateLoggerDefinition",
+		"UpdateResourceDefinition",
+		"UpdateSubscriptionDefinition",
+		"UpdateThingRuntimeConfiguration",
+	},
+	"groundstation": {
+		"CancelContact",
+		"CreateConfig",
+		"CreateDataflowEndpointGroup",
+		"CreateMissionProfile",
+		"DeleteConfig",
+		"DeleteDataflowEndpointGroup",
+		"DeleteMissionProfile",
+		"DescribeContact",
+		"GetConfig",
+		"GetDataflowEndpointGroup",
+		"GetMinuteUsage",
+		"GetMissionProfile",
+		"GetSatellite",
+		"ListConfigs",
+		"ListContacts",
+		"ListDataflowEndpointGroups",
+		"ListGroundStations",
+		"ListMissionProfiles",
+		"ListSatellites",
+		"ListTagsForResource",
+		"ReserveContact",
+		"TagResource",
+		"UntagResource",
+		"UpdateConfig",
+		"UpdateMissionProfile",
+	},
+	"guardduty": {
+		"AcceptInvitation",
+		"ArchiveFindings",
+		"CreateDetector",
+		"CreateFilter",
+		"CreateIPSet",
+		"CreateMembers",
+		"CreatePublishingDestination",
+		"CreateSampleFindings",
+		"CreateThreatIntelSet",
+		"DeclineInvitations",
+		"DeleteDetector",
+		"DeleteFilter",
+		"DeleteIPSet",
+		"DeleteInvitations",
+		"DeleteMembers",
+		"DeletePublishingDestination",
+		"DeleteThreatIntelSet",
+		"DescribeOrganizationConfiguration",
+		"DescribePublishingDestination",
+		"DisableOrganizationAdminAccount",
+		"DisassociateFromMasterAccount",
+		"DisassociateMembers",
+		"EnableOrganizationAdminAccount",
+		"GetDetector",
+		"GetFilter",
+		"GetFindings",
+		"GetFindingsStatistics",
+		"GetIPSet",
+		"GetInvitationsCount",
+		"GetMasterAccount",
+		"GetMemberDetectors",
+		"GetMembers",
+		"GetThreatIntelSet",
+		"GetUsageStatistics",
+		"InviteMembers",
+		"ListDetectors",
+		"ListFilters",
+		"ListFindings",
+		"ListIPSets",
+		"ListInvitations",
+		"ListMembers",
+		"ListOrganizationAdminAccounts",
+		"ListPublishingDestinations",
+		"ListTagsForResource",
+		"ListThreatIntelSets",
+		"StartMonitoringMembers",
+		"StopMonitoringMembers",
+		"TagResource",
+		"UnarchiveFindings",
+		"UntagResource",
+		"UpdateDetector",
+		"UpdateFilter",
+		"UpdateFindingsFeedback",
+		"UpdateIPSet",
+		"UpdateMemberDetectors",
+		"UpdateOrganizationConfiguration",
+		"UpdatePublishingDestination",
+		"UpdateThreatIntelSet",
+	},
+	"health": {
+		"DescribeAffectedAccountsForOrganization",
+		"DescribeAffectedEntities",
+		"DescribeAffectedEntitiesForOrganization",
+		"DescribeEntityAggregates",
+		"DescribeEventAggregates",
+		"DescribeEventDetails",
+		"DescribeEventDetailsForOrganization",
+		"DescribeEventTypes",
+		"DescribeEvents",
+		"DescribeEventsForOrganization",
+		"DescribeHealthServiceStatusForOrganization",
+		"DisableHealthServiceAccessForOrganization",
+		"EnableHealthServiceAccessForOrganization",
+	},
+	"healthlake": {
+		"CreateFHIRDatastore",
+		"DeleteFHIRDatastore",
+		"DescribeFHIRDatastore",
+		"DescribeFHIRExportJob",
+		"DescribeFHIRImportJob",
+		"ListFHIRDatastores",
+		"StartFHIRExportJob",
+		"StartFHIRImportJob",
+	},
+	"honeycode": {
+		"BatchCreateTableRows",
+		"BatchDeleteTableRows",
+		"BatchUpdateTableRows",
+		"BatchUpsertTableRows",
+		"DescribeTableDataImportJob",
+		"GetScreenData",
+		"InvokeScreenAutomation",
+		"ListTableColumns",
+		"ListTableRows",
+		"ListTables",
+		"QueryTableRows",
+		"StartTableDataImportJob",
+	},
+	"iam": {
+		"AddClientIDToOpenIDConnectProvider",
+		"AddRoleToInstanceProfile",
+		"AddUserToGroup",
+		"AttachGroupPolicy",
+		"AttachRolePolicy",
+		"AttachUserPolicy",
+		"ChangePassword",
+		"CreateAccessKey",
+		"CreateAccountAlias",
+		"CreateGroup",
+		"CreateInstanceProfile",
+		"CreateLoginProfile",
+		"CreateOpenIDConnectProvider",
+		"CreatePolicy",
+		"CreatePolicyVersion",
+		"CreateRole",
+		"CreateSAMLProvider",
+		"CreateServiceLinkedRole",
+		"CreateServiceSpecificCredential",
+		"CreateUser",
+		"CreateVirtualMFADevice",
+		"DeactivateMFADevice",
+		"DeleteAccessKey",
+		"DeleteAccountAlias",
+		"DeleteAccountPasswordPolicy",
+		"DeleteGroup",
+		"DeleteGroupPolicy",
+		"DeleteInstanceProfile",
+		"DeleteLoginProfile",
+		"DeleteOpenIDConnectProvider",
+		"DeletePolicy",
+		"DeletePolicyVersion",
+		"DeleteRole",
+		"DeleteRolePermissionsBoundary",
+		"DeleteRolePolicy",
+		"DeleteSAMLProvider",
+		"DeleteSSHPublicKey",
+		"DeleteServerCertificate",
+		"DeleteServiceLinkedRole",
+		"DeleteServiceSpecificCredential",
+		"DeleteSigningCertificate",
+		"DeleteUser",
+		"DeleteUserPermissionsBoundary",
+		"DeleteUserPolicy",
+		"DeleteVirtualMFADevice",
+		"DetachGroupPolicy",
+		"DetachRolePolicy",
+		"DetachUserPolicy",
+		"EnableMFADevice",
+		"GenerateCredentialReport",
+		"GenerateOrganizationsAccessReport",
+		"GenerateServiceLastAccessedDetails",
+		"GetAccessKeyLastUsed",
+		"GetAccountAuthorizationDetails",
+		"GetAccountPasswordPolicy",
+		"GetAccountSummary",
+		"GetContextKeysForCustomPolicy",
+		"GetContextKeysForPrincipalPolicy",
+		"GetCredentialReport",
+		"GetGroup",
+		"GetGroupPolicy",
+		"GetInstanceProfile",
+		"GetLoginProfile",
+		"GetOpenIDConnectProvider",
+		"GetOrganizationsAccessReport",
+		"GetPolicy",
+		"GetPolicyVersion",
+		"GetRole",
+		"GetRolePolicy",
+		"GetSAMLProvider",
+		"GetSSHPublicKey",
+		"GetServerCertificate",
+		"GetServiceLastAccessedDetails",
+		"GetServiceLastAccessedDetailsWithEntities",
+		"GetServiceLinkedRoleDeletionStatus",
+		"GetUser",
+		"GetUserPolicy",
+		"ListAccessKeys",
+		"ListAccountAliases",
+		"ListAttachedGroupPolicies",
+		"ListAttachedRolePolicies",
+		"ListAttachedUserPolicies",
+		"ListEntitiesForPolicy",
+		"ListGroupPolicies",
+		"ListGroups",
+		"ListGroupsForUser",
+		"ListInstanceProfileTags",
+		"ListInstanceProfiles",
+		"ListInstanceProfilesForRole",
+		"ListMFADeviceTags",
+		"ListMFADevices",
+		"ListOpenIDConnectProviderTags",
+		"ListOpenIDConnectProviders",
+		"ListPolicies",
+		"ListPoliciesGrantingServiceAccess",
+		"ListPolicyTags",
+		"ListPolicyVersions",
+		"ListRolePolicies",
+		"ListRoleTags",
+		"ListRoles",
+		"ListSAMLProviderTags",
+		"ListSAMLProviders",
+		"ListSSHPublicKeys",
+		"ListServerCertificateTags",
+		"ListServerCertificates",
+		"ListServiceSpecificCredentials",
+		"ListSigningCertificates",
+		"ListUserPolicies",
+		"ListUserTags",
+		"ListUsers",
+		"ListVirtualMFADevices",
+		"PassRole",
+		"PutGroupPolicy",
+		"PutRolePermissionsBoundary",
+		"PutRolePolicy",
+		"PutUserPermissionsBoundary",
+		"PutUserPolicy",
+		"RemoveClientIDFromOpenIDConnectProvider",
+		"RemoveRoleFromInstanceProfile",
+		"RemoveUserFromGroup",
+		"ResetServiceSpecificCredential",
+		"ResyncMFADevice",
+		"SetDefaultPolicyVersion",
+		"SetSecurityTokenServicePreferences",
+		"SimulateCustomPolicy",
+		"SimulatePrincipalPolicy",
+		"TagInstanceProfile",
+		"TagMFADevice",
+		"TagOpenIDConnectProvider",
+		"TagPolicy",
+		"TagRole",
+		"TagSAMLProvider",
+		"TagServerCertificate",
+		"TagUser",
+		"UntagInstanceProfile",
+		"UntagMFADevice",
+		"UntagOpenIDConnectProvider",
+		"UntagPolicy",
+		"UntagRole",
+		"UntagSAMLProvider",
+		"UntagServerCertificate",
+		"UntagUser",
+		"UpdateAccessKey",
+		"UpdateAccountPasswordPolicy",
+		"UpdateAssumeRolePolicy",
+		"UpdateGroup",
+		"UpdateLoginProfile",
+		"UpdateOpenIDConnectProviderThumbprint",
+		"UpdateRole",
+		"UpdateRoleDescription",
+		"UpdateSAMLProvider",
+		"UpdateSSHPublicKey",
+		"UpdateServerCertificate",
+		"UpdateServiceSpecificCredential",
+		"UpdateSigningCertificate",
+		"UpdateUser",
+		"UploadSSHPublicKey",
+		"UploadServerCertificate",
+		"UploadSigningCertificate",
+	},
+	"identitystore": {
+		"DescribeGroup",
+		"DescribeUser",
+		"ListGroups",
+		"ListUsers",
+	},
+	"imagebuilder": {
+		"CancelImageCreation",
+		"CreateComponent",
+		"CreateContainerRecipe",
+		"CreateDistributionConfiguration",
+		"CreateImage",
+		"CreateImagePipeline",
+		"CreateImageRecipe",
+		"CreateInfrastructureConfiguration",
+		"DeleteComponent",
+		"DeleteContainerRecipe",
+		"DeleteDistributionConfiguration",
+		"DeleteImage",
+		"DeleteImagePipeline",
+		"DeleteImageRecipe",
+		"DeleteInfrastructureConfiguration",
+		"GetComponent",
+		"GetComponentPolicy",
+		"GetContainerRecipe",
+		"GetContainerRecipePolicy",
+		"GetDistributionConfiguration",
+		"GetImage",
+		"GetImagePipeline",
+		"GetImagePolicy",
+		"GetImageRecipe",
+		"GetImageRecipePolicy",
+		"GetInfrastructureConfiguration",
+		"ImportComponent",
+		"ListComponentBuildVersions",
+		"ListComponents",
+		"ListContainerRecipes",
+		"ListDistributionConfigurations",
+		"ListImageBuildVersions",
+		"ListImagePackages",
+		"ListImagePipelineImages",
+		"ListImagePipelines",
+		"ListImageRecipes",
+		"ListImages",
+		"ListInfrastructureConfigurations",
+		"ListTagsForResource",
+		"PutComponentPolicy",
+		"PutContainerRecipePolicy",
+		"PutImagePolicy",
+		"PutImageRecipePolicy",
+		"StartImagePipelineExecution",
+		"TagResource",
+		"UntagResource",
+		"UpdateDistributionConfiguration",
+		"UpdateImagePipeline",
+		"UpdateInfrastructureConfiguration",
+	},
+	"importexport": {
+		"CancelJob",
+		"CreateJob",
+		"GetShippingLabel",
+		"GetStatus",
+		"ListJobs",
+		"UpdateJob",
+	},
+	"inspector": {
+		"AddAttributesToFindings",
+		"CreateAssessmentTarget",
+		"CreateAssessmentTemplate",
+		"CreateExclusionsPreview",
+		"CreateResourceGroup",
+		"DeleteAssessmentRun",
+		"DeleteAssessmentTarget",
+		"DeleteAssessmentTemplate",
+		"DescribeAssessmentRuns",
+		"DescribeAssessmentTargets",
+		"DescribeAssessmentTemplates",
+		"DescribeCrossAccountAccessRole",
+		"DescribeExclusions",
+		"DescribeFindings",
+		"DescribeResourceGroups",
+		"DescribeRulesPackages",
+		"GetAssessmentReport",
+		"GetExclusionsPreview",
+		"GetTelemetryMetadata",
+		"ListAssessmentRunAgents",
+		"ListAssessmentRuns",
+		"ListAssessmentTargets",
+		"ListAssessmentTemplates",
+		"ListEventSubscriptions",
+		"ListExclusions",
+		"ListFindings",
+		"ListRulesPackages",
+		"ListTagsForResource",
+		"PreviewAgents",
+		"RegisterCrossAccountAccessRole",
+		"RemoveAttributesFromFindings",
+		"SetTagsForResource",
+		"StartAssessmentRun",
+		"StopAssessmentRun",
+		"SubscribeToEvent",
+		"UnsubscribeFromEvent",
+		"UpdateAssessmentTarget",
+	},
+	"iot": {
+		"AcceptCertificateTransfer",
+		"AddThingToBillingGroup",
+		"AddThingToThingGroup",
+		"AssociateTargetsWithJob",
+		"AttachPolicy",
+		"AttachPrincipalPolicy",
+		"AttachSecurityProfile",
+		"AttachThingPrincipal",
+		"CancelAuditMitigationActionsTask",
+		"CancelAuditTask",
+		"CancelCertificateTransfer",
+		"CancelDetectMitigationActionsTask",
+		"CancelJob",
+		"CancelJobExecution",
+		"ClearDefaultAuthorizer",
+		"CloseTunnel",
+		"ConfirmTopicRuleDestination",
+		"Connect",
+		"CreateAuditSuppression",
+		"CreateAuthorizer",
+		"CreateBillingGroup",
+		"CreateCertificateFromCsr",
+		"CreateCustomMetric",
+		"CreateDimension",
+		"CreateDomainConfiguration",
+		"CreateDynamicThingGroup",
+		"CreateJob",
+		"CreateKeysAndCertificate",
+		"CreateMitigationAction",
+		"CreateOTAUpdate",
+		"CreatePolicy",
+		"CreatePolicyVersion",
+		"CreateProvisioningClaim",
+		"CreateProvisioningTemplate",
+		"CreateProvisioningTemplateVersion",
+		"CreateRoleAlias",
+		"CreateScheduledAudit",
+		"CreateSecurityProfile",
+		"CreateStream",
+		"CreateThing",
+		"CreateThingGroup",
+		"CreateThingType",
+		"CreateTopicRule",
+		"CreateTopicRuleDestination",
+		"DeleteAccountAuditConfiguration",
+		"DeleteAuditSuppression",
+		"DeleteAuthorizer",
+		"DeleteBillingGroup",
+		"DeleteCACertificate",
+		"DeleteCertificate",
+		"DeleteCustomMetric",
+		"DeleteDimension",
+		"DeleteDomainConfiguration",
+		"DeleteDynamicThingGroup",
+		"DeleteJob",
+		"DeleteJobExecution",
+		"DeleteMitigationAction",
+		"DeleteOTAUpdate",
+		"DeletePolicy",
+		"DeletePolicyVersion",
+		"DeleteProvisioningTemplate",
+		"DeleteProvisioningTemplateVersion",
+		"DeleteRegistrationCode",
+		"DeleteRoleAlias",
+		"DeleteScheduledAudit",
+		"DeleteSecurityProfile",
+		"DeleteStream",
+		"DeleteThing",
+		"DeleteThingGroup",
+		"DeleteThingShadow",
+		"DeleteThingType",
+		"DeleteTopicRule",
+		"DeleteTopicRuleDestination",
+		"DeleteV2LoggingLevel",
+		"DeprecateThingType",
+		"DescribeAccountAuditConfiguration",
+		"DescribeAuditFinding",
+		"DescribeAuditMitigationActionsTask",
+		"DescribeAuditSuppression",
+		"DescribeAuditTask",
+		"DescribeAuthorizer",
+		"DescribeBillingGroup",
+		"DescribeCACertificate",
+		"DescribeCertificate",
+		"DescribeCustomMetric",
+		"DescribeDefaultAuthorizer",
+		"DescribeDetectMitigationActionsTask",
+		"DescribeDimension",
+		"DescribeDomainConfiguration",
+		"DescribeEndpoint",
+		"DescribeEventConfigurations",
+		"DescribeIndex",
+		"DescribeJob",
+		"DescribeJobExecution",
+		"DescribeMitigationAction",
+		"DescribeProvisioningTemplate",
+		"DescribeProvisioningTemplateVersion",
+		"DescribeRoleAlias",
+		"DescribeScheduledAudit",
+		"DescribeSecurityProfile",
+		"DescribeStream",
+		"DescribeThing",
+		"DescribeThingGroup",
+		"DescribeThingRegistrationTask",
+		"DescribeThingType",
+		"DescribeTunnel",
+		"DetachPolicy",
+		"DetachPrincipalPolicy",
+		"DetachSecurityProfile",
+		"DetachThingPrincipal",
+		"DisableTopicRule",
+		"EnableTopicRule",
+		"GetBehaviorModelTrainingSummaries",
+		"GetCardinality",
+		"GetEffectivePolicies",
+		"GetIndexingConfiguration",
+		"GetJobDocument",
+		"GetLoggingOptions",
+		"GetOTAUpdate",
+		"GetPercentiles",
+		"GetPolicy",
+		"GetPolicyVersion",
+		"GetRegistrationCode",
+		"GetStatistics",
+		"GetThingShadow",
+		"GetTopicRule",
+		"GetTopicRuleDestination",
+		"GetV2LoggingOptions",
+		"ListActiveViolations",
+		"ListAttachedPolicies",
+		"ListAuditFindings",
+		"ListAuditMitigationActionsExecutions",
+		"ListAuditMitigationActionsTasks",
+		"ListAuditSuppressions",
+		"ListAuditTasks",
+		"ListAuthorizers",
+		"ListBillingGroups",
+		"ListCACertificates",
+		"ListCertificates",
+		"ListCertificatesByCA",
+		"ListCustomMetrics",
+		"ListDetectMitigationActionsExecutions",
+		"ListDetectMitigationActionsTasks",
+		"ListDimensions",
+		"ListDomainConfigurations",
+		"ListIndices",
+		"ListJobExecutionsForJob",
+		"ListJobExecutionsForThing",
+		"ListJobs",
+		"ListMitigationActions",
+		"ListNamedShadowsForThing",
+		"ListOTAUpdates",
+		"ListOutgoingCertificates",
+		"ListPolicies",
+		"ListPolicyPrincipals",
+		"ListPolicyVersions",
+		"ListPrincipalPolicies",
+		"ListPrincipalThings",
+		"ListProvisioningTemplateVersions",
+		"ListProvisioningTemplates",
+		"ListRoleAliases",
+		"ListScheduledAudits",
+		"ListSecurityProfiles",
+		"ListSecurityProfilesForTarget",
+		"ListStreams",
+		"ListTagsForResource",
+		"ListTargetsForPolicy",
+		"ListTargetsForSecurityProfile",
+		"ListThingGroups",
+		"ListThingGroupsForThing",
+		"ListThingPrincipals",
+		"ListThingRegistrationTaskReports",
+		"ListThingRegistrationTasks",
+		"ListThingTypes",
+		"ListThings",
+		"ListThingsInBillingGroup",
+		"ListThingsInThingGroup",
+		"ListTopicRuleDestinations",
+		"ListTopicRules",
+		"ListTunnels",
+		"ListV2LoggingLevels",
+		"ListViolationEvents",
+		"OpenTunnel",
+		"Publish",
+		"Receive",
+		"RegisterCACertificate",
+		"RegisterCertificate",
+		"RegisterCertificateWithoutCA",
+		"RegisterThing",
+		"RejectCertificateTransfer",
+		"RemoveThingFromBillingGroup",
+		"RemoveThingFromThingGroup",
+		"ReplaceTopicRule",
+		"RetainPublish",
+		"SearchIndex",
+		"SetDefaultAuthorizer",
+		"SetDefaultPolicyVersion",
+		"SetLoggingOptions",
+		"SetV2LoggingLevel",
+		"SetV2LoggingOptions",
+		"StartAuditMitigationActionsTask",
+		"StartDetectMitigationActionsTask",
+		"StartOnDemandAuditTask",
+		"StartThingRegistrationTask",
+		"StopThingRegistrationTask",
+		"Subscribe",
+		"TagResource",
+		"TestAuthorization",
+		"TestInvokeAuthorizer",
+		"TransferCertificate",
+		"UntagResource",
+		"UpdateAccountAuditConfiguration",
+		"UpdateAuditSuppression",
+		"UpdateAuthorizer",
+		"UpdateBillingGroup",
+		"UpdateCACertificate",
+		"UpdateCertificate",
+		"UpdateCustomMetric",
+		"UpdateDimension",
+		"UpdateDomainConfiguration",
+		"UpdateDynamicThingGroup",
+		"UpdateEventConfigurations",
+		"UpdateIndexingConfiguration",
+		"UpdateJob",
+		"UpdateMitigationAction",
+		"UpdateProvisioningTemplate",
+		"UpdateRoleAlias",
+		"UpdateScheduledAudit",
+		"UpdateSecurityProfile",
+		"UpdateStream",
+		"UpdateThing",
+		"UpdateThingGroup",
+		"UpdateThingGroupsForThing",
+		"UpdateThingShadow",
+		"UpdateTopicRuleDestination",
+		"ValidateSecurityProfileBehaviors",
+	},
+	"iot1click": {
+		"AssociateDeviceWithPlacement",
+		"ClaimDevicesByClaimCode",
+		"CreatePlacement",
+		"CreateProject",
+		"DeletePlacement",
+		"DeleteProject",
+		"DescribeDevice",
+		"DescribePlacement",
+		"DescribeProject",
+		"DisassociateDeviceFromPlacement",
+		"FinalizeDeviceClaim",
+		"GetDeviceMethods",
+		"GetDevicesInPlacement",
+		"InitiateDeviceClaim",
+		"InvokeDeviceMethod",
+		"ListDeviceEvents",
+		"ListDevices",
+		"ListPlacements",
+		"ListProjects",
+		"ListTagsForResource",
+		"TagResource",
+		"UnclaimDevice",
+		"UntagResource",
+		"UpdateDeviceState",
+		"UpdatePlacement",
+		"UpdateProject",
+	},
+	"iotanalytics": {
+		"BatchPutMessage",
+		"CancelPipelineReprocessing",
+		"CreateChannel",
+		"CreateDataset",
+		"CreateDatasetContent",
+		"CreateDatastore",
+		"CreatePipeline",
+		"DeleteChannel",
+		"DeleteDataset",
+		"DeleteDatasetContent",
+		"DeleteDatastore",
+		"DeletePipeline",
+		"DescribeChannel",
+		"DescribeDataset",
+		"DescribeDatastore",
+		"DescribeLoggingOptions",
+		"DescribePipeline",
+		"GetDatasetContent",
+		"ListChannels",
+		"ListDatasetContents",
+		"ListDatasets",
+		"ListDatastores",
+		"ListPipelines",
+		"ListTagsForResource",
+		"PutLoggingOptions",
+		"RunPipelineActivity",
+		"SampleChannelData",
+		"StartPipelineReprocessing",
+		"TagResource",
+		"UntagResource",
+		"UpdateChannel",
+		"UpdateDataset",
+		"UpdateDatastore",
+		"UpdatePipeline",
+	},
+	"iotdeviceadvisor": {
+		"CreateSuiteDefinition",
+		"DeleteSuiteDefinition",
+		"GetSuiteDefinition",
+		"GetSuiteRun",
+		"GetSuiteRunReport",
+		"ListSuiteDefinitions",
+		"ListSuiteRuns",
+		"ListTagsForResource",
+		"ListTestCases",
+		"StartSuiteRun",
+		"TagResource",
+		"UntagResource",
+		"UpdateSuiteDefinition",
+	},
+	"iotevents": {
+		"BatchPutMessage",
+		"BatchUpdateDetector",
+		"CreateDetectorModel",
+		"CreateInput",
+		"DeleteDetectorModel",
+		"DeleteInput",
+		"DescribeDetector",
+		"DescribeDetectorModel",
+		"DescribeDetectorModelAnalysis",
+		"DescribeInput",
+		"DescribeLoggingOptions",
+		"GetDetectorModelAnalysisResults",
+		"ListDetectorModelVersions",
+		"ListDetectorModels",
+		"ListDetectors",
+		"ListInputs",
+		"ListTagsForResource",
+		"PutLoggingOptions",
+		"StartDetectorModelAnalysis",
+		"TagResource",
+		"UntagResource",
+		"UpdateDetectorModel",
+		"UpdateInput",
+	},
+	"iotfleethub": {
+		"CreateApplication",
+		"DeleteApplication",
+		"DescribeApplication",
+		"ListApplications",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateApplication",
+	},
+	"iotjobsdata": {
+		"DescribeJobExecution",
+		"GetPendingJobExecutions",
+		"StartNextPendingJobExecution",
+		"UpdateJobExecution",
+	},
+	"iotsitewise": {
+		"AssociateAssets",
+		"BatchAssociateProjectAssets",
+		"BatchDisassociateProjectAssets",
+		"BatchPutAssetPropertyValue",
+		"CreateAccessPolicy",
+		"CreateAsset",
+		"CreateAssetModel",
+		"CreateDashboard",
+		"CreateGateway",
+		"CreatePortal",
+		"CreateProject",
+		"DeleteAccessPolicy",
+		"DeleteAsset",
+		"DeleteAssetModel",
+		"DeleteDashboard",
+		"DeleteGateway",
+		"DeletePortal",
+		"DeleteProject",
+		"DescribeAccessPolicy",
+		"DescribeAsset",
+		"DescribeAssetModel",
+		"DescribeAssetProperty",
+		"DescribeDashboard",
+		"DescribeDefaultEncryptionConfiguration",
+		"DescribeGateway",
+		"DescribeGatewayCapabilityConfiguration",
+		"DescribeLoggingOptions",
+		"DescribePortal",
+		"DescribeProject",
+		"DisassociateAssets",
+		"GetAssetPropertyAggregates",
+		"GetAssetPropertyValue",
+		"GetAssetPropertyValueHistory",
+		"ListAccessPolicies",
+		"ListAssetModels",
+		"ListAssetRelationships",
+		"ListAssets",
+		"ListAssociatedAssets",
+		"ListDashboards",
+		"ListGateways",
+		"ListPortals",
+		"ListProjectAssets",
+		"ListProjects",
+		"ListTagsForResource",
+		"PutDefaultEncryptionConfiguration",
+		"PutLoggingOptions",
+		"TagResource",
+		"UntagResource",
+		"UpdateAccessPolicy",
+		"UpdateAsset",
+		"UpdateAssetModel",
+		"UpdateAssetProperty",
+		"UpdateDashboard",
+		"UpdateGateway",
+		"UpdateGatewayCapabilityConfiguration",
+		"UpdatePortal",
+		"UpdateProject",
+	},
+	"iotthingsgraph": {
+		"AssociateEntityToThing",
+		"CreateFlowTemplate",
+		"CreateSystemInstance",
+		"CreateSystemTemplate",
+		"DeleteFlowTemplate",
+		"DeleteNamespace",
+		"DeleteSystemInstance",
+		"DeleteSystemTemplate",
+		"DeploySystemInstance",
+		"DeprecateFlowTemplate",
+		"DeprecateSystemTemplate",
+		"DescribeNamespace",
+		"DissociateEntityFromThing",
+		"GetEntities",
+		"GetFlowTemplate",
+		"GetFlowTemplateRevisions",
+		"GetNamespaceDeletionStatus",
+		"GetSystemInstance",
+		"GetSystemTemplate",
+		"GetSystemTemplateRevisions",
+		"GetUploadStatus",
+		"ListFlowExecutionMessages",
+		"ListTagsForResource",
+		"SearchEntities",
+		"SearchFlowExecutions",
+		"SearchFlowTemplates",
+		"SearchSystemInstances",
+		"SearchSystemTemplates",
+		"SearchThings",
+		"TagResource",
+		"UndeploySystemInstance",
+		"UntagResource",
+		"UpdateFlowTemplate",
+		"UpdateSystemTemplate",
+		"UploadEntityDefinitions",
+	},
+	"iotwireless": {
+		"AssociateAwsAccountWithPartnerAccount",
+		"AssociateWirelessDeviceWithThing",
+		"AssociateWirelessGatewayWithCertificate",
+		"AssociateWirelessGatewayWithThing",
+		"CreateDestination",
+		"CreateDeviceProfile",
+		"CreateServiceProfile",
+		"CreateWirelessDevice",
+		"CreateWirelessGateway",
+		"CreateWirelessGatewayTask",
+		"CreateWirelessGatewayTaskDefinition",
+		"DeleteDestination",
+		"DeleteDeviceProfile",
+		"DeleteServiceProfile",
+		"DeleteWirelessDevice",
+		"DeleteWirelessGateway",
+		"DeleteWirelessGatewayTask",
+		"DeleteWirelessGatewayTaskDefinition",
+		"DisassociateAwsAccountFromPartnerAccount",
+		"DisassociateWirelessDeviceFromThing",
+		"DisassociateWirelessGatewayFromCertificate",
+		"DisassociateWirelessGatewayFromThing",
+		"GetDestination",
+		"GetDeviceProfile",
+		"GetPartnerAccount",
+		"GetServiceEndpoint",
+		"GetServiceProfile",
+		"GetWirelessDevice",
+		"GetWirelessDeviceStatistics",
+		"GetWirelessGateway",
+		"GetWirelessGatewayCertificate",
+		"GetWirelessGatewayFirmwareInformation",
+		"GetWirelessGatewayStatistics",
+		"GetWirelessGatewayTask",
+		"GetWirelessGatewayTaskDefinition",
+		"ListDestinations",
+		"ListDeviceProfiles",
+		"ListPartnerAccounts",
+		"ListServiceProfiles",
+		"ListTagsForResource",
+		"ListWirelessDevices",
+		"ListWirelessGatewayTaskDefinitions",
+		"ListWirelessGateways",
+		"SendDataToWirelessDevice",
+		"TagResource",
+		"TestWirelessDevice",
+		"UntagResource",
+		"UpdateDestination",
+		"UpdatePartnerAccount",
+		"UpdateWirelessDevice",
+		"UpdateWirelessGateway",
+	},
+	"ivs": {
+		"BatchGetChannel",
+		"BatchGetStreamKey",
+		"CreateChannel",
+		"CreateStreamKey",
+		"DeleteChannel",
+		"DeletePlaybackKeyPair",
+		"DeleteStreamKey",
+		"GetChannel",
+		"GetPlaybackKeyPair",
+		"GetStream",
+		"GetStreamKey",
+		"ImportPlaybackKeyPair",
+		"ListChannels",
+		"ListPlaybackKeyPairs",
+		"ListStreamKeys",
+		"ListStreams",
+		"ListTagsForResource",
+		"PutMetadata",
+		"StopStream",
+		"TagResource",
+		"UntagResource",
+		"UpdateChannel",
+	},
+	"kafka": {
+		"BatchAssociateScramSecret",
+		"BatchDisassociateScramSecret",
+		"CreateCluster",
+		"CreateConfiguration",
+		"DeleteCluster",
+		"DeleteConfiguration",
+		"DescribeCluster",
+		"DescribeClusterOperation",
+		"DescribeConfiguration",
+		"DescribeConfigurationRevision",
+		"GetBootstrapBrokers",
+		"GetCompatibleKafkaVersions",
+		"ListClusterOperations",
+		"ListClusters",
+		"ListConfigurationRevisions",
+		"ListConfigurations",
+		"ListKafkaVersions",
+		"ListNodes",
+		"ListScramSecrets",
+		"ListTagsForResource",
+		"RebootBroker",
+		"TagResource",
+		"UntagResource",
+		"UpdateBrokerCount",
+		"UpdateBrokerStorage",
+		"UpdateBrokerType",
+		"UpdateClusterConfiguration",
+		"UpdateClusterKafkaVersion",
+		"UpdateConfiguration",
+		"UpdateMonitoring",
+	},
+	"kafka-cluster": {
+		"AlterCluster",
+		"AlterGroup",
+		"AlterTopic",
+		"Connect",
+		"CreateTopic",
+		"DeleteGroup",
+		"DeleteTopic",
+		"DescribeCluster",
+		"DescribeGroup",
+		"DescribeTopic",
+		"ReadData",
+		"WriteData",
+	},
+	"kendra": {
+		"BatchDeleteDocument",
+		"BatchPutDocument",
+		"CreateDataSource",
+		"CreateFaq",
+		"CreateIndex",
+		"CreateThesaurus",
+		"DeleteDataSource",
+		"DeleteFaq",
+		"DeleteIndex",
+		"DeleteThesaurus",
+		"DescribeDataSource",
+		"DescribeFaq",
+		"DescribeIndex",
+		"DescribeThesaurus",
+		"ListDataSourceSyncJobs",
+		"ListDataSources",
+		"ListFaqs",
+		"ListIndices",
+		"ListTagsForResource",
+		"ListThesauri",
+		"Query",
+		"StartDataSourceSyncJob",
+		"StopDataSourceSyncJob",
+		"SubmitFeedback",
+		"TagResource",
+		"UntagResource",
+		"UpdateDataSource",
+		"UpdateIndex",
+		"UpdateThesaurus",
+	},
+	"kinesis": {
+		"AddTagsToStream",
+		"CreateStream",
+		"DecreaseStreamRetentionPeriod",
+		"DeleteStream",
+		"DeregisterStreamConsumer",
+		"DescribeLimits",
+		"DescribeStream",
+		"DescribeStreamConsumer",
+		"DescribeStreamSummary",
+		"DisableEnhancedMonitoring",
+		"EnableEnhancedMonitoring",
+		"GetRecords",
+		"GetShardIterator",
+		"IncreaseStreamRetentionPeriod",
+		"ListShards",
+		"ListStreamConsumers",
+		"ListStreams",
+		"ListTagsForStream",
+		"MergeShards",
+		"PutRecord",
+		"PutRecords",
+		"RegisterStreamConsumer",
+		"RemoveTagsFromStream",
+		"SplitShard",
+		"StartStreamEncryption",
+		"StopStreamEncryption",
+		"SubscribeToShard",
+		"UpdateShardCount",
+	},
+	"kinesisanalytics": {
+		"AddApplicationCloudWatchLoggingOption",
+		"AddApplicationInput",
+		"AddApplicationInputProcessingConfiguration",
+		"AddApplicationOutput",
+		"AddApplicationReferenceDataSource",
+		"AddApplicationVpcConfiguration",
+		"CreateApplication",
+		"CreateApplicationPresignedUrl",
+		"CreateApplicationSnapshot",
+		"DeleteApplication",
+		"DeleteApplicationCloudWatchLoggingOption",
+		"DeleteApplicationInputProcessingConfiguration",
+		"DeleteApplicationOutput",
+		"DeleteApplicationReferenceDataSource",
+		"DeleteApplicationSnapshot",
+		"DeleteApplicationVpcConfiguration",
+		"DescribeApplication",
+		"DescribeApplicationSnapshot",
+		"DiscoverInputSchema",
+		"ListApplicationSnapshots",
+		"ListApplications",
+		"ListTagsForResource",
+		"StartApplication",
+		"StopApplication",
+		"TagResource",
+		"UntagResource",
+		"UpdateApplication",
+	},
+	"kinesisvideo": {
+		"CreateSignalingChannel",
+		"CreateStream",
+		"DeleteSignalingChannel",
+		"DeleteStream",
+		"DescribeSignalingChannel",
+		"DescribeStream",
+		"GetClip",
+		"GetDASHStreamingSessionURL",
+		"GetDataEndpoint",
+		"GetHLSStreamingSessionURL",
+		"GetIceServerConfig",
+		"GetMedia",
+		"GetMediaForFragmentList",
+		"GetSignalingChannelEndpoint",
+		"ListFragments",
+		"ListSignalingChannels",
+		"ListStreams",
+		"ListTagsForResource",
+		"ListTagsForStream",
+		"SendAlexaOfferToMaster",
+		"TagResource",
+		"TagStream",
+		"UntagResource",
+		"UntagStream",
+		"UpdateDataRetention",
+		"UpdateSignalingChannel",
+		"UpdateStream",
+	},
+	"kms": {
+		"CancelKeyDeletion",
+		"ConnectCustomKeyStore",
+		"CreateAlias",
+		"CreateCustomKeyStore",
+		"CreateGrant",
+		"CreateKey",
+		"Decrypt",
+		"DeleteAlias",
+		"DeleteCustomKeyStore",
+		"DeleteImportedKeyMaterial",
+		"DescribeCustomKeyStores",
+		"DescribeKey",
+		"DisableKey",
+		"DisableKeyRotation",
+		"DisconnectCustomKeyStore",
+		"EnableKey",
+		"EnableKeyRotation",
+		"Encrypt",
+		"GenerateDataKey",
+		"GenerateDataKeyPair",
+		"GenerateDataKeyPairWithoutPlaintext",
+		"GenerateDataKeyWithoutPlaintext",
+		"GenerateRandom",
+		"GetKeyPolicy",
+		"GetKeyRotationStatus",
+		"GetParametersForImport",
+		"GetPublicKey",
+		"ImportKeyMaterial",
+		"ListAliases",
+		"ListGrants",
+		"ListKeyPolicies",
+		"ListKeys",
+		"ListResourceTags",
+		"ListRetirableGrants",
+		"PutKeyPolicy",
+		"ReEncrypt",
+		"RetireGrant",
+		"RevokeGrant",
+		"ScheduleKeyDeletion",
+		"Sign",
+		"TagResource",
+		"UntagResource",
+		"UpdateAlias",
+		"UpdateCustomKeyStore",
+		"UpdateKeyDescription",
+		"Verify",
+	},
+	"lakeformation": {
+		"BatchGrantPermissions",
+		"BatchRevokePermissions",
+		"DeregisterResource",
+		"DescribeResource",
+		"GetDataLakeSettings",
+		"GetEffectivePermissionsForPath",
+		"GrantPermissions",
+		"ListPermissions",
+		"ListResources",
+		"PutDataLakeSettings",
+		"RegisterResource",
+		"RevokePermissions",
+		"UpdateResource",
+	},
+	"lambda": {
+		"AddLayerVersionPermission",
+		"AddPermission",
+		"CreateAlias",
+		"CreateCodeSigningConfig",
+		"CreateEventSourceMapping",
+		"CreateFunction",
+		"DeleteAlias",
+		"DeleteCodeSigningConfig",
+		"DeleteEventSourceMapping",
+		"DeleteFunction",
+		"DeleteFunctionCodeSigningConfig",
+		"DeleteFunctionConcurrency",
+		"DeleteFunctionEventInvokeConfig",
+		"DeleteLayerVersion",
+		"DeleteProvisionedConcurrencyConfig",
+		"DisableReplication",
+		"EnableReplication",
+		"GetAccountSettings",
+		"GetAlias",
+		"GetCodeSigningConfig",
+		"GetEventSourceMapping",
+		"GetFunction",
+		"GetFunctionCodeSigningConfig",
+		"GetFunctionConcurrency",
+		"GetFunctionConfiguration",
+		"GetFunctionEventInvokeConfig",
+		"GetLayerVersion",
+		"GetLayerVersionByArn",
+		"GetLayerVersionPolicy",
+		"GetPolicy",
+		"GetProvisionedConcurrencyConfig",
+		"Invoke",
+		"InvokeAsync",
+		"InvokeFunction",
+		"ListAliases",
+		"ListCodeSigningConfigs",
+		"ListEventSourceMappings",
+		"ListFunctionEventInvokeConfigs",
+		"ListFunctions",
+		"ListFunctionsByCodeSigningConfig",
+		"ListLayerVersions",
+		"ListLayers",
+		"ListProvisionedConcurrencyConfigs",
+		"ListTags",
+		"ListVersionsByFunction",
+		"PublishLayerVersion",
+		"PublishVersion",
+		"PutFunctionCodeSigningConfig",
+		"PutFunctionConcurrency",
+		"PutFunctionEventInvokeConfig",
+		"PutProvisionedConcurrencyConfig",
+		"RemoveLayerVersionPermission",
+		"RemovePermission",
+		"TagResource",
+		"UntagResource",
+		"UpdateAlias",
+		"UpdateCodeSigningConfig",
+		"UpdateEventSourceMapping",
+		"UpdateFunctionCode",
+		"UpdateFunctionConfiguration",
+		"UpdateFunctionEventInvokeConfig",
+	},
+	"lex": {
+		"BuildBotLocale",
+		"CreateBot",
+		"CreateBotAlias",
+		"CreateBotLocale",
+		"CreateBotVersion",
+		"CreateIntent",
+		"CreateIntentVersion",
+		"CreateSlot",
+		"CreateSlotType",
+		"CreateSlotTypeVersion",
+		"DeleteBot",
+		"DeleteBotAlias",
+		"DeleteBotChannelAssociation",
+		"DeleteBotLocale",
+		"DeleteBotVersion",
+		"DeleteIntent",
+		"DeleteIntentVersion",
+		"DeleteSession",
+		"DeleteSlot",
+		"DeleteSlotType",
+		"DeleteSlotTypeVersion",
+		"DeleteUtterances",
+		"DescribeBot",
+		"DescribeBotAlias",
+		"DescribeBotLocale",
+		"DescribeBotVersion",
+		"DescribeIntent",
+		"DescribeSlot",
+		"DescribeSlotType",
+		"GetBot",
+		"GetBotAlias",
+		"GetBotAliases",
+		"GetBotChannelAssociation",
+		"GetBotChannelAssociations",
+		"GetBotVersions",
+		"GetBots",
+		"GetBuiltinIntent",
+		"GetBuiltinIntents",
+		"GetBuiltinSlotTypes",
+		"GetExport",
+		"GetImport",
+		"GetIntent",
+		"GetIntentVersions",
+		"GetIntents",
+		"GetSession",
+		"GetSlotType",
+		"GetSlotTypeVersions",
+		"GetSlotTypes",
+		"GetUtterancesView",
+		"ListBotAliases",
+		"ListBotLocales",
+		"ListBotVersions",
+		"ListBots",
+		"ListBuiltInIntents",
+		"ListBuiltInSlotTypes",
+		"ListIntents",
+		"ListSlotTypes",
+		"ListSlots",
+		"ListTagsForResource",
+		"PostContent",
+		"PostText",
+		"PutBot",
+		"PutBotAlias",
+		"PutIntent",
+		"PutSession",
+		"PutSlotType",
+		"RecognizeText",
+		"RecognizeUtterance",
+		"StartConversation",
+		"StartImport",
+		"TagResource",
+		"UntagResource",
+		"UpdateBot",
+		"UpdateBotAlias",
+		"UpdateBotLocale",
+		"UpdateIntent",
+		"UpdateSlot",
+		"UpdateSlotType",
+	},
+	"license-manager": {
+		"AcceptGrant",
+		"CheckInLicense",
+		"CheckoutBorrowLicense",
+		"CheckoutLicense",
+		"CreateGrant",
+		"CreateGrantVersion",
+		"CreateLicense",
+		"CreateLicenseConfiguration",
+		"CreateLicenseVersion",
+		"CreateToken",
+		"DeleteGrant",
+		"DeleteLicense",
+		"DeleteLicenseConfiguration",
+		"DeleteToken",
+		"ExtendLicenseConsumption",
+		"GetAccessToken",
+		"GetGrant",
+		"GetLicense",
+		"GetLicenseConfiguration",
+		"GetLicenseUsage",
+		"GetServiceSettings",
+		"ListAssociationsForLicenseConfiguration",
+		"ListDistributedGrants",
+		"ListFailuresForLicenseConfigurationOperations",
+		"ListLicenseConfigurations",
+		"ListLicenseSpecificationsForResource",
+		"ListLicenseVersions",
+		"ListLicenses",
+		"ListReceivedGrants",
+		"ListReceivedLicenses",
+		"ListResourceInventory",
+		"ListTagsForResource",
+		"ListTokens",
+		"ListUsageForLicenseConfiguration",
+		"RejectGrant",
+		"TagResource",
+		"UntagResource",
+		"UpdateLicenseConfiguration",
+		"UpdateLicenseSpecificationsForResource",
+		"UpdateServiceSettings",
+	},
+	"lightsail": {
+		"AllocateStaticIp",
+		"AttachCertificateToDistribution",
+		"AttachDisk",
+		"AttachInstancesToLoadBalancer",
+		"AttachLoadBalancerTlsCertificate",
+		"AttachStaticIp",
+		"CloseInstancePublicPorts",
+		"CopySnapshot",
+		"CreateCertificate",
+		"CreateCloudFormationStack",
+		"CreateContactMethod",
+		"CreateContainerService",
+		"CreateContainerServiceDeployment",
+		"CreateContainerServiceRegistryLogin",
+		"CreateDisk",
+		"CreateDiskFromSnapshot",
+		"CreateDiskSnapshot",
+		"CreateDistribution",
+		"CreateDomain",
+		"CreateDomainEntry",
+		"CreateInstanceSnapshot",
+		"CreateInstances",
+		"CreateInstancesFromSnapshot",
+		"CreateKeyPair",
+		"CreateLoadBalancer",
+		"CreateLoadBalancerTlsCertificate",
+		"CreateRelationalDatabase",
+		"CreateRelationalDatabaseFromSnapshot",
+		"CreateRelationalDatabaseSnapshot",
+		"DeleteAlarm",
+		"DeleteAutoSnapshot",
+		"DeleteCertificate",
+		"DeleteContactMethod",
+		"DeleteContainerImage",
+		"DeleteContainerService",
+		"DeleteDisk",
+		"DeleteDiskSnapshot",
+		"DeleteDistribution",
+		"DeleteDomain",
+		"DeleteDomainEntry",
+		"DeleteInstance",
+		"DeleteInstanceSnapshot",
+		"DeleteKeyPair",
+		"DeleteKnownHostKeys",
+		"DeleteLoadBalancer",
+		"DeleteLoadBalancerTlsCertificate",
+		"DeleteRelationalDatabase",
+		"DeleteRelationalDatabaseSnapshot",
+		"DetachCertificateFromDistribution",
+		"DetachDisk",
+		"DetachInstancesFromLoadBalancer",
+		"DetachStaticIp",
+		"DisableAddOn",
+		"DownloadDefaultKeyPair",
+		"EnableAddOn",
+		"ExportSnapshot",
+		"GetActiveNames",
+		"GetAlarms",
+		"GetAutoSnapshots",
+		"GetBlueprints",
+		"GetBundles",
+		"GetCertificates",
+		"GetCloudFormationStackRecords",
+		"GetContactMethods",
+		"GetContainerAPIMetadata",
+		"GetContainerImages",
+		"GetContainerLog",
+		"GetContainerServiceDeployments",
+		"GetContainerServiceMetricData",
+		"GetContainerServicePowers",
+		"GetContainerServices",
+		"GetDisk",
+		"GetDiskSnapshot",
+		"GetDiskSnapshots",
+		"GetDisks",
+		"GetDistributionBundles",
+		"GetDistributionLatestCacheReset",
+		"GetDistributionMetricData",
+		"GetDistributions",
+		"GetDomain",
+		"GetDomains",
+		"GetExportSnapshotRecords",
+		"GetInstance",
+		"GetInstanceAccessDetails",
+		"GetInstanceMetricData",
+		"GetInstancePortStates",
+		"GetInstanceSnapshot",
+		"GetInstanceSnapshots",
+		"GetInstanceState",
+		"GetInstances",
+		"GetKeyPair",
+		"GetKeyPairs",
+		"GetLoadBalancer",
+		"GetLoadBalancerMetricData",
+		"GetLoadBalancerTlsCertificates",
+		"GetLoadBalancers",
+		"GetOperation",
+		"GetOperations",
+		"GetOperationsForResource",
+		"GetRegions",
+		"GetRelationalDatabase",
+		"GetRelationalDatabaseBlueprints",
+		"GetRelationalDatabaseBundles",
+		"GetRelationalDatabaseEvents",
+		"GetRelationalDatabaseLogEvents",
+		"GetRelationalDatabaseLogStreams",
+		"GetRelationalDatabaseMasterUserPassword",
+		"GetRelationalDatabaseMetricData",
+		"GetRelationalDatabaseParameters",
+		"GetRelationalDatabaseSnapshot",
+		"GetRelationalDatabaseSnapshots",
+		"GetRelationalDatabases",
+		"GetStaticIp",
+		"GetStaticIps",
+		"ImportKeyPair",
+		"IsVpcPeered",
+		"OpenInstancePublicPorts",
+		"PeerVpc",
+		"PutAlarm",
+		"PutInstancePublicPorts",
+		"RebootInstance",
+		"RebootRelationalDatabase",
+		"RegisterContainerImage",
+		"ReleaseStaticIp",
+		"ResetDistributionCache",
+		"SendContactMethodVerification",
+		"SetIpAddressType",
+		"StartInstance",
+		"StartRelationalDatabase",
+		"StopInstance",
+		"StopRelationalDatabase",
+		"TagResource",
+		"TestAlarm",
+		"UnpeerVpc",
+		"UntagResource",
+		"UpdateContainerService",
+		"UpdateDistribution",
+		"UpdateDistributionBundle",
+		"UpdateDomainEntry",
+		"UpdateLoadBalancerAttribute",
+		"UpdateRelationalDatabase",
+		"UpdateRelationalDatabaseParameters",
+	},
+	"logs": {
+		"AssociateKmsKey",
+		"CancelExportTask",
+		"CreateExportTask",
+		"CreateLogGroup",
+		"CreateLogStream",
+		"DeleteDestination",
+		"DeleteLogGroup",
+		"DeleteLogStream",
+		"DeleteMetricFilter",
+		"DeleteQueryDefinition",
+		"DeleteResourcePolicy",
+		"DeleteRetentionPolicy",
+		"DeleteSubscriptionFilter",
+		"DescribeDestinations",
+		"DescribeExportTasks",
+		"DescribeLogGroups",
+		"DescribeLogStreams",
+		"DescribeMetricFilters",
+		"DescribeQueries",
+		"DescribeQueryDefinitions",
+		"DescribeResourcePolicies",
+		"DescribeSubscriptionFilters",
+		"DisassociateKmsKey",
+		"FilterLogEvents",
+		"GetLogEvents",
+		"GetLogGroupFields",
+		"GetLogRecord",
+		"GetQueryResults",
+		"ListTagsLogGroup",
+		"PutDestination",
+		"PutDestinationPolicy",
+		"PutLogEvents",
+		"PutMetricFilter",
+		"PutQueryDefinition",
+		"PutResourcePolicy",
+		"PutRetentionPolicy",
+		"PutSubscriptionFilter",
+		"StartQuery",
+		"StopQuery",
+		"TagLogGroup",
+		"TestMetricFilter",
+		"UntagLogGroup",
+	},
+	"lookoutvision": {
+		"CreateDataset",
+		"CreateModel",
+		"CreateProject",
+		"DeleteDataset",
+		"DeleteModel",
+		"DeleteProject",
+		"DescribeDataset",
+		"DescribeModel",
+		"DescribeProject",
+		"DetectAnomalies",
+		"ListDatasetEntries",
+		"ListModels",
+		"ListProjects",
+		"ListTagsForResource",
+		"StartModel",
+		"StopModel",
+		"TagResource",
+		"UntagResource",
+		"UpdateDatasetEntries",
+	},
+	"machinelearning": {
+		"AddTags",
+		"CreateBatchPrediction",
+		"CreateDataSourceFromRDS",
+		"CreateDataSourceFromRedshift",
+		"CreateDataSourceFromS3",
+		"CreateEvaluation",
+		"CreateMLModel",
+		"CreateRealtimeEndpoint",
+		"DeleteBatchPrediction",
+		"DeleteDataSource",
+		"DeleteEvaluation",
+		"DeleteMLModel",
+		"DeleteRealtimeEndpoint",
+		"DeleteTags",
+		"DescribeBatchPredictions",
+		"DescribeDataSources",
+		"DescribeEvaluations",
+		"DescribeMLModels",
+		"DescribeTags",
+		"GetBatchPrediction",
+		"GetDataSource",
+		"GetEvaluation",
+		"GetMLModel",
+		"Predict",
+		"UpdateBatchPrediction",
+		"UpdateDataSource",
+		"UpdateEvaluation",
+		"UpdateMLModel",
+	},
+	"macie": {
+		"AssociateMemberAccount",
+		"AssociateS3Resources",
+		"DisassociateMemberAccount",
+		"DisassociateS3Resources",
+		"ListMemberAccounts",
+		"ListS3Resources",
+		"UpdateS3Resources",
+	},
+	"macie2": {
+		"AcceptInvitation",
+		"BatchGetCustomDataIdentifiers",
+		"CreateClassificationJob",
+		"CreateCustomDataIdentifier",
+		"CreateFindingsFilter",
+		"CreateInvitations",
+		"CreateMember",
+		"CreateSampleFindings",
+		"DeclineInvitations",
+		"DeleteCustomDataIdentifier",
+		"DeleteFindingsFilter",
+		"DeleteInvitations",
+		"DeleteMember",
+		"DescribeBuckets",
+		"DescribeClassificationJob",
+		"DescribeOrganizationConfiguration",
+		"DisableMacie",
+		"DisableOrganizationAdminAccount",
+		"DisassociateFromAdministratorAccount",
+		"DisassociateFromMasterAccount",
+		"DisassociateMember",
+		"EnableMacie",
+		"EnableOrganizationAdminAccount",
+		"GetAdministratorAccount",
+		"GetBucketStatistics",
+		"GetClassificationExportConfiguration",
+		"GetCustomDataIdentifier",
+		"GetFindingStatistics",
+		"GetFindings",
+		"GetFindingsFilter",
+		"GetInvitationsCount",
+		"GetMacieSession",
+		"GetMasterAccount",
+		"GetMember",
+		"GetUsageStatistics",
+		"GetUsageTotals",
+		"ListClassificationJobs",
+		"ListCustomDataIdentifiers",
+		"ListFindings",
+		"ListFindingsFilters",
+		"ListInvitations",
+		"ListMembers",
+		"ListOrganizationAdminAccounts",
+		"ListTagsForResource",
+		"PutClassificationExportConfiguration",
+		"TagResource",
+		"TestCustomDataIdentifier",
+		"UntagResource",
+		"UpdateClassificationJob",
+		"UpdateFindingsFilter",
+		"UpdateMacieSession",
+		"UpdateMemberSession",
+		"UpdateOrganizationConfiguration",
+	},
+	"managedblockchain": {
+		"CreateMember",
+		"CreateNetwork",
+		"CreateNode",
+		"CreateProposal",
+		"DeleteMember",
+		"DeleteNode",
+		"GetMember",
+		"GetNetwork",
+		"GetNode",
+		"GetProposal",
+		"ListInvitations",
+		"ListMembers",
+		"ListNetworks",
+		"ListNodes",
+		"ListProposalVotes",
+		"ListProposals",
+		"ListTagsForResource",
+		"RejectInvitation",
+		"TagResource",
+		"UntagResource",
+		"UpdateMember",
+		"UpdateNode",
+		"VoteOnProposal",
+	},
+	"marketplacecommerceanalytics": {
+		"GenerateDataSet",
+		"StartSupportDataExport",
+	},
+	"mechanicalturk": {
+		"AcceptQualificationRequest",
+		"ApproveAssignment",
+		"AssociateQualificationWithWorker",
+		"CreateAdditionalAssignmentsForHIT",
+		"CreateHIT",
+		"CreateHITType",
+		"CreateHITWithHITType",
+		"CreateQualificationType",
+		"CreateWorkerBlock",
+		"DeleteHIT",
+		"DeleteQualificationType",
+		"DeleteWorkerBlock",
+		"DisassociateQualificationFromWorker",
+		"GetAccountBalance",
+		"GetAssignment",
+		"GetFileUploadURL",
+		"GetHIT",
+		"GetQualificationScore",
+		"GetQualificationType",
+		"ListAssignmentsForHIT",
+		"ListBonusPayments",
+		"ListHITs",
+		"ListHITsForQualificationType",
+		"ListQualificationRequests",
+		"ListQualificationTypes",
+		"ListReviewPolicyResultsForHIT",
+		"ListReviewableHITs",
+		"ListWorkerBlocks",
+		"ListWorkersWithQualificationType",
+		"NotifyWorkers",
+		"RejectAssignment",
+		"RejectQualificationRequest",
+		"SendBonus",
+		"SendTestEventNotification",
+		"UpdateExpirationForHIT",
+		"UpdateHITReviewStatus",
+		"UpdateHITTypeOfHIT",
+		"UpdateNotificationSettings",
+		"UpdateQualificationType",
+	},
+	"mediaconnect": {
+		"AddFlowOutputs",
+		"AddFlowSources",
+		"AddFlowVpcInterfaces",
+		"CreateFlow",
+		"DeleteFlow",
+		"DescribeFlow",
+		"DescribeOffering",
+		"DescribeReservation",
+		"GrantFlowEntitlements",
+		"ListEntitlements",
+		"ListFlows",
+		"ListOfferings",
+		"ListReservations",
+		"ListTagsForResource",
+		"PurchaseOffering",
+		"RemoveFlowOutput",
+		"RemoveFlowSource",
+		"RemoveFlowVpcInterface",
+		"RevokeFlowEntitlement",
+		"StartFlow",
+		"StopFlow",
+		"TagResource",
+		"UntagResource",
+		"UpdateFlow",
+		"UpdateFlowEntitlement",
+		"UpdateFlowOutput",
+		"UpdateFlowSource",
+	},
+	"mediaconvert": {
+		"AssociateCertificate",
+		"CancelJob",
+		"CreateJob",
+		"CreateJobTemplate",
+		"CreatePreset",
+		"CreateQueue",
+		"DeleteJobTemplate",
+		"DeletePreset",
+		"DeleteQueue",
+		"DescribeEndpoints",
+		"DisassociateCertificate",
+		"GetJob",
+		"GetJobTemplate",
+		"GetPreset",
+		"GetQueue",
+		"ListJobTemplates",
+		"ListJobs",
+		"ListPresets",
+		"ListQueues",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateJobTemplate",
+		"UpdatePreset",
+		"UpdateQueue",
+	},
+	"medialive": {
+		"AcceptInputDeviceTransfer",
+		"BatchDelete",
+		"BatchStart",
+		"BatchStop",
+		"BatchUpdateSchedule",
+		"CancelInputDeviceTransfer",
+		"CreateChannel",
+		"CreateInput",
+		"CreateInputSecurityGroup",
+		"CreateMultiplex",
+		"CreateMultiplexProgram",
+		"CreatePartnerInput",
+		"CreateTags",
+		"DeleteChannel",
+		"DeleteInput",
+		"DeleteInputSecurityGroup",
+		"DeleteMultiplex",
+		"DeleteMultiplexProgram",
+		"DeleteReservation",
+		"DeleteSchedule",
+		"DeleteTags",
+		"DescribeChannel",
+		"DescribeInput",
+		"DescribeInputDevice",
+		"DescribeInputDeviceThumbnail",
+		"DescribeInputSecurityGroup",
+		"DescribeMultiplex",
+		"DescribeMultiplexProgram",
+		"DescribeOffering",
+		"DescribeReservation",
+		"DescribeSchedule",
+		"ListChannels",
+		"ListInputDeviceTransfers",
+		"ListInputDevices",
+		"ListInputSecurityGroups",
+		"ListInputs",
+		"ListMultiplexPrograms",
+		"ListMultiplexes",
+		"ListOfferings",
+		"ListReservations",
+		"ListTagsForResource",
+		"PurchaseOffering",
+		"RejectInputDeviceTransfer",
+		"StartChannel",
+		"StartMultiplex",
+		"StopChannel",
+		"StopMultiplex",
+		"TransferInputDevice",
+		"UpdateChannel",
+		"UpdateChannelClass",
+		"UpdateInput",
+		"UpdateInputDevice",
+		"UpdateInputSecurityGroup",
+		"UpdateMultiplex",
+		"UpdateMultiplexProgram",
+		"UpdateReservation",
+	},
+	"mediapackage": {
+		"ConfigureLogs",
+		"CreateChannel",
+		"CreateHarvestJob",
+		"CreateOriginEndpoint",
+		"DeleteChannel",
+		"DeleteOriginEndpoint",
+		"DescribeChannel",
+		"DescribeHarvestJob",
+		"DescribeOriginEndpoint",
+		"ListChannels",
+		"ListHarvestJobs",
+		"ListOriginEndpoints",
+		"ListTagsForResource",
+		"RotateChannelCredentials",
+		"RotateIngestEndpointCredentials",
+		"TagResource",
+		"UntagResource",
+		"UpdateChannel",
+		"UpdateOriginEndpoint",
+	},
+	"mediapackage-vod": {
+		"ConfigureLogs",
+		"CreateAsset",
+		"CreatePackagingConfiguration",
+		"CreatePackagingGroup",
+		"DeleteAsset",
+		"DeletePackagingConfiguration",
+		"DeletePackagingGroup",
+		"DescribeAsset",
+		"DescribePackagingConfiguration",
+		"DescribePackagingGroup",
+		"ListAssets",
+		"ListPackagingConfigurations",
+		"ListPackagingGroups",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdatePackagingGroup",
+	},
+	"mediastore": {
+		"CreateContainer",
+		"DeleteContainer",
+		"DeleteContainerPolicy",
+		"DeleteCorsPolicy",
+		"DeleteLifecyclePolicy",
+		"DeleteMetricPolicy",
+		"DeleteObject",
+		"DescribeContainer",
+		"DescribeObject",
+		"GetContainerPolicy",
+		"GetCorsPolicy",
+		"GetLifecyclePolicy",
+		"GetMetricPolicy",
+		"GetObject",
+		"ListContainers",
+		"ListItems",
+		"ListTagsForResource",
+		"PutContainerPolicy",
+		"PutCorsPolicy",
+		"PutLifecyclePolicy",
+		"PutMetricPolicy",
+		"PutObject",
+		"StartAccessLogging",
+		"StopAccessLogging",
+		"TagResource",
+		"UntagResource",
+	},
+	"mediatailor": {
+		"DeletePlaybackConfiguration",
+		"GetPlaybackConfiguration",
+		"ListPlaybackConfigurations",
+		"ListTagsForResource",
+		"PutPlaybackConfiguration",
+		"TagResource",
+		"UntagResource",
+	},
+	"mgh": {
+		"AssociateCreatedArtifact",
+		"AssociateDiscoveredResource",
+		"CreateHomeRegionControl",
+		"CreateProgressUpdateStream",
+		"DeleteProgressUpdateStream",
+		"DescribeApplicationState",
+		"DescribeHomeRegionControls",
+		"DescribeMigrationTask",
+		"DisassociateCreatedArtifact",
+		"DisassociateDiscoveredResource",
+		"GetHomeRegion",
+		"ImportMigrationTask",
+		"ListApplicationStates",
+		"ListCreatedArtifacts",
+		"ListDiscoveredResources",
+		"ListMigrationTasks",
+		"ListProgressUpdateStreams",
+		"NotifyApplicationState",
+		"NotifyMigrationTaskState",
+		"PutResourceAttributes",
+	},
+	"mobileanalytics": {
+		"PutEvents",
+	},
+	"mobilehub": {
+		"CreateProject",
+		"DeleteProject",
+		"DescribeBundle",
+		"DescribeProject",
+		"ExportBundle",
+		"ExportProject",
+		"ListBundles",
+		"ListProjects",
+		"UpdateProject",
+	},
+	"mobiletargeting": {
+		"CreateApp",
+		"CreateCampaign",
+		"CreateEmailTemplate",
+		"CreateExportJob",
+		"CreateImportJob",
+		"CreateJourney",
+		"CreatePushTemplate",
+		"CreateRecommenderConfiguration",
+		"CreateSegment",
+		"CreateSmsTemplate",
+		"CreateVoiceTemplate",
+		"DeleteAdmChannel",
+		"DeleteApnsChannel",
+		"DeleteApnsSandboxChannel",
+		"DeleteApnsVoipChannel",
+		"DeleteApnsVoipSandboxChannel",
+		"DeleteApp",
+		"DeleteBaiduChannel",
+		"DeleteCampaign",
+		"DeleteEmailChannel",
+		"DeleteEmailTemplate",
+		"DeleteEndpoint",
+		"DeleteEventStream",
+		"DeleteGcmChannel",
+		"DeleteJourney",
+		"DeletePushTemplate",
+		"DeleteRecommenderConfiguration",
+		"DeleteSegment",
+		"DeleteSmsChannel",
+		"DeleteSmsTemplate",
+		"DeleteUserEndpoints",
+		"DeleteVoiceChannel",
+		"DeleteVoiceTemplate",
+		"GetAdmChannel",
+		"GetApnsChannel",
+		"GetApnsSandboxChannel",
+		"GetApnsVoipChannel",
+		"GetApnsVoipSandboxChannel",
+		"GetApp",
+		"GetApplicationDateRangeKpi",
+		"GetApplicationSettings",
+		"GetApps",
+		"GetBaiduChannel",
+		"GetCampaign",
+		"GetCampaignActivities",
+		"GetCampaignDateRangeKpi",
+		"GetCampaignVersion",
+		"GetCampaignVersions",
+		"GetCampaigns",
+		"GetChannels",
+		"GetEmailChannel",
+		"GetEmailTemplate",
+		"GetEndpoint",
+		"GetEventStream",
+		"GetExportJob",
+		"GetExportJobs",
+		"GetGcmChannel",
+		"GetImportJob",
+		"GetImportJobs",
+		"GetJourney",
+		"GetJourneyDateRangeKpi",
+		"GetJourneyExecutionActivityMetrics",
+		"GetJourneyExecutionMetrics",
+		"GetPushTemplate",
+		"GetRecommenderConfiguration",
+		"GetRecommenderConfigurations",
+		"GetSegment",
+		"GetSegmentExportJobs",
+		"GetSegmentImportJobs",
+		"GetSegmentVersion",
+		"GetSegmentVersions",
+		"GetSegments",
+		"GetSmsChannel",
+		"GetSmsTemplate",
+		"GetUserEndpoints",
+		"GetVoiceChannel",
+		"GetVoiceTemplate",
+		"ListJourneys",
+		"ListTagsForResource",
+		"ListTemplateVersions",
+		"ListTemplates",
+		"PhoneNumberValidate",
+		"PutEventStream",
+		"PutEvents",
+		"RemoveAttributes",
+		"SendMessages",
+		"SendUsersMessages",
+		"TagResource",
+		"UntagResource",
+		"UpdateAdmChannel",
+		"UpdateApnsChannel",
+		"UpdateApnsSandboxChannel",
+		"UpdateApnsVoipChannel",
+		"UpdateApnsVoipSandboxChannel",
+		"UpdateApplicationSettings",
+		"UpdateBaiduChannel",
+		"UpdateCampaign",
+		"UpdateEmailChannel",
+		"UpdateEmailTemplate",
+		"UpdateEndpoint",
+		"UpdateEndpointsBatch",
+		"UpdateGcmChannel",
+		"UpdateJourney",
+		"UpdateJourneyState",
+		"UpdatePushTemplate",
+		"UpdateRecommenderConfiguration",
+		"UpdateSegment",
+		"UpdateSmsChannel",
+		"UpdateSmsTemplate",
+		"UpdateTemplateActiveVersion",
+		"UpdateVoiceChannel",
+		"UpdateVoiceTemplate",
+	},
+	"mq": {
+		"CreateBroker",
+		"CreateConfiguration",
+		"CreateTags",
+		"CreateUser",
+		"DeleteBroker",
+		"DeleteTags",
+		"DeleteUser",
+		"DescribeBroker",
+		"DescribeBrokerEngineTypes",
+		"DescribeBrokerInstanceOptions",
+		"DescribeConfiguration",
+		"DescribeConfigurationRevision",
+		"DescribeUser",
+		"ListBrokers",
+		"ListConfigurationRevisions",
+		"ListConfigurations",
+		"ListTags",
+		"ListUsers",
+		"RebootBroker",
+		"UpdateBroker",
+		"UpdateConfiguration",
+		"UpdateUser",
+	},
+	"neptune-db": {
+		"connect",
+	},
+	"network-firewall": {
+		"AssociateFirewallPolicy",
+		"AssociateSubnets",
+		"CreateFirewall",
+		"CreateFirewallPolicy",
+		"CreateRuleGroup",
+		"DeleteFirewall",
+		"DeleteFirewallPolicy",
+		"DeleteResourcePolicy",
+		"DeleteRuleGroup",
+		"DescribeFirewall",
+		"DescribeFirewallPolicy",
+		"DescribeLoggingConfiguration",
+		"DescribeResourcePolicy",
+		"DescribeRuleGroup",
+		"DisassociateSubnets",
+		"ListFirewallPolicies",
+		"ListFirewalls",
+		"ListRuleGroups",
+		"ListTagsForResource",
+		"PutResourcePolicy",
+		"TagResource",
+		"UntagResource",
+		"UpdateFirewallDeleteProtection",
+		"UpdateFirewallDescription",
+		"UpdateFirewallPolicy",
+		"UpdateFirewallPolicyChangeProtection",
+		"UpdateLoggingConfiguration",
+		"UpdateRuleGroup",
+		"UpdateSubnetChangeProtection",
+	},
+	"networkmanager": {
+		"AssociateCustomerGateway",
+		"AssociateLink",
+		"AssociateTransitGatewayConnectPeer",
+		"CreateConnection",
+		"CreateDevice",
+		"CreateGlobalNetwork",
+		"CreateLink",
+		"CreateSite",
+		"DeleteConnection",
+		"DeleteDevice",
+		"DeleteGlobalNetwork",
+		"DeleteLink",
+		"DeleteSite",
+		"DeregisterTransitGateway",
+		"DescribeGlobalNetworks",
+		"DisassociateCustomerGateway",
+		"DisassociateLink",
+		"DisassociateTransitGatewayConnectPeer",
+		"GetConnections",
+		"GetCustomerGatewayAssociations",
+		"GetDevices",
+		"GetLinkAssociations",
+		"GetLinks",
+		"GetSites",
+		"GetTransitGatewayConnectPeerAssociations",
+		"GetTransitGatewayRegistrations",
+		"ListTagsForResource",
+		"RegisterTransitGateway",
+		"TagResource",
+		"UntagResource",
+		"UpdateConnection",
+		"UpdateDevice",
+		"UpdateGlobalNetwork",
+		"UpdateLink",
+		"UpdateSite",
+	},
+	"opsworks": {
+		"AssignInstance",
+		"AssignVolume",
+		"AssociateElasticIp",
+		"AttachElasticLoadBalancer",
+		"CloneStack",
+		"CreateApp",
+		"CreateDeployment",
+		"CreateInstance",
+		"CreateLayer",
+		"CreateStack",
+		"CreateUserProfile",
+		"DeleteApp",
+		"DeleteInstance",
+		"DeleteLayer",
+		"DeleteStack",
+		"DeleteUserProfile",
+		"DeregisterEcsCluster",
+		"DeregisterElasticIp",
+		"DeregisterInstance",
+		"DeregisterRdsDbInstance",
+		"DeregisterVolume",
+		"DescribeAgentVersions",
+		"DescribeApps",
+		"DescribeCommands",
+		"DescribeDeployments",
+		"DescribeEcsClusters",
+		"DescribeElasticIps",
+		"DescribeElasticLoadBalancers",
+		"DescribeInstances",
+		"DescribeLayers",
+		"DescribeLoadBasedAutoScaling",
+		"DescribeMyUserProfile",
+		"DescribeOperatingSystems",
+		"DescribePermissions",
+		"DescribeRaidArrays",
+		"DescribeRdsDbInstances",
+		"DescribeServiceErrors",
+		"DescribeStackProvisioningParameters",
+		"DescribeStackSummary",
+		"DescribeStacks",
+		"DescribeTimeBasedAutoScaling",
+		"DescribeUserProfiles",
+		"DescribeVolumes",
+		"DetachElasticLoadBalancer",
+		"DisassociateElasticIp",
+		"GetHostnameSuggestion",
+		"GrantAccess",
+		"ListTags",
+		"RebootInstance",
+		"RegisterEcsCluster",
+		"RegisterElasticIp",
+		"RegisterInstance",
+		"RegisterRdsDbInstance",
+		"RegisterVolume",
+		"SetLoadBasedAutoScaling",
+		"SetPermission",
+		"SetTimeBasedAutoScaling",
+		"StartInstance",
+		"StartStack",
+		"StopInstance",
+		"StopStack",
+		"TagResource",
+		"UnassignInstance",
+		"UnassignVolume",
+		"UntagResource",
+		"UpdateApp",
+		"UpdateElasticIp",
+		"UpdateInstance",
+		"UpdateLayer",
+		"UpdateMyUserProfile",
+		"UpdateRdsDbInstance",
+		"UpdateStack",
+		"UpdateUserProfile",
+		"UpdateVolume",
+	},
+	"opsworks-cm": {
+		"AssociateNode",
+		"CreateBackup",
+		"CreateServer",
+		"DeleteBackup",
+		"DeleteServer",
+		"DescribeAccountAttributes",
+		"DescribeBackups",
+		"DescribeEvents",
+		"DescribeNodeAssociationStatus",
+		"DescribeServers",
+		"DisassociateNode",
+		"ExportServerEngineAttribute",
+		"ListTagsForResource",
+		"RestoreServer",
+		"StartMaintenance",
+		"TagResource",
+		"UntagResource",
+		"UpdateServer",
+		"UpdateServerEngineAttributes",
+	},
+	"organizations": {
+		"AcceptHandshake",
+		"AttachPolicy",
+		"CancelHandshake",
+		"CreateAccount",
+		"CreateGovCloudAccount",
+		"CreateOrganization",
+		"CreateOrganizationalUnit",
+		"CreatePolicy",
+		"DeclineHandshake",
+		"DeleteOrganization",
+		"DeleteOrganizationalUnit",
+		"DeletePolicy",
+		"DeregisterDelegatedAdministrator",
+		"DescribeAccount",
+		"DescribeCreateAccountStatus",
+		"DescribeEffectivePolicy",
+		"DescribeHandshake",
+		"DescribeOrganization",
+		"DescribeOrganizationalUnit",
+		"DescribePolicy",
+		"DetachPolicy",
+		"DisableAWSServiceAccess",
+		"DisablePolicyType",
+		"EnableAWSServiceAccess",
+		"EnableAllFeatures",
+		"EnablePolicyType",
+		"InviteAccountToOrganization",
+		"LeaveOrganization",
+		"ListAWSServiceAccessForOrganization",
+		"ListAccounts",
+		"ListAccountsForParent",
+		"ListChildren",
+		"ListCreateAccountStatus",
+		"ListDelegatedAdministrators",
+		"ListDelegatedServicesForAccount",
+		"ListHandshakesForAccount",
+		"ListHandshakesForOrganization",
+		"ListOrganizationalUnitsForParent",
+		"ListParents",
+		"ListPolicies",
+		"ListPoliciesForTarget",
+		"ListRoots",
+		"ListTagsForResource",
+		"ListTargetsForPolicy",
+		"MoveAccount",
+		"RegisterDelegatedAdministrator",
+		"RemoveAccountFromOrganization",
+		"TagResource",
+		"UntagResource",
+		"UpdateOrganizationalUnit",
+		"UpdatePolicy",
+	},
+	"outposts": {
+		"CreateOutpost",
+		"DeleteOutpost",
+		"DeleteSite",
+		"GetOutpost",
+		"GetOutpostInstanceTypes",
+		"ListOutposts",
+		"ListSites",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+	},
+	"personalize": {
+		"CreateBatchInferenceJob",
+		"CreateCampaign",
+		"CreateDataset",
+		"CreateDatasetGroup",
+		"CreateDatasetImportJob",
+		"CreateEventTracker",
+		"CreateFilter",
+		"CreateSchema",
+		"CreateSolution",
+		"CreateSolutionVersion",
+		"DeleteCampaign",
+		"DeleteDataset",
+		"DeleteDatasetGroup",
+		"DeleteEventTracker",
+		"DeleteFilter",
+		"DeleteSchema",
+		"DeleteSolution",
+		"DescribeAlgorithm",
+		"DescribeBatchInferenceJob",
+		"DescribeCampaign",
+		"DescribeDataset",
+		"DescribeDatasetGroup",
+		"DescribeDatasetImportJob",
+		"DescribeEventTracker",
+		"DescribeFeatureTransformation",
+		"DescribeFilter",
+		"DescribeRecipe",
+		"DescribeSchema",
+		"DescribeSolution",
+		"DescribeSolutionVersion",
+		"GetPersonalizedRanking",
+		"GetRecommendations",
+		"GetSolutionMetrics",
+		"ListBatchInferenceJobs",
+		"ListCampaigns",
+		"ListDatasetGroups",
+		"ListDatasetImportJobs",
+		"ListDatasets",
+		"ListEventTrackers",
+		"ListFilters",
+		"ListRecipes",
+		"ListSchemas",
+		"ListSolutionVersions",
+		"ListSolutions",
+		"PutEvents",
+		"PutItems",
+		"PutUsers",
+		"UpdateCampaign",
+	},
+	"pi": {
+		"DescribeDimensionKeys",
+		"GetResourceMetrics",
+	},
+	"polly": {
+		"DeleteLexicon",
+		"DescribeVoices",
+		"GetLexicon",
+		"GetSpeechSynthesisTask",
+		"ListLexicons",
+		"ListSpeechSynthesisTasks",
+		"PutLexicon",
+		"StartSpeechSynthesisTask",
+		"SynthesizeSpeech",
+	},
+	"pricing": {
+		"DescribeServices",
+		"GetAttributeValues",
+		"GetProducts",
+	},
+	"profile": {
+		"AddProfileKey",
+		"CreateDomain",
+		"CreateProfile",
+		"DeleteDomain",
+		"DeleteIntegration",
+		"DeleteProfile",
+		"DeleteProfileKey",
+		"DeleteProfileObject",
+		"DeleteProfileObjectType",
+		"GetDomain",
+		"GetIntegration",
+		"GetProfileObjectType",
+		"GetProfileObjectTypeTemplate",
+		"ListAccountIntegrations",
+		"ListDomains",
+		"ListIntegrations",
+		"ListProfileObjectTypeTemplates",
+		"ListProfileObjectTypes",
+		"ListProfileObjects",
+		"ListTagsForResource",
+		"PutIntegration",
+		"PutProfileObject",
+		"PutProfileObjectType",
+		"SearchProfiles",
+		"TagResource",
+		"UntagResource",
+		"UpdateDomain",
+		"UpdateProfile",
+	},
+	"qldb": {
+		"CancelJournalKinesisStream",
+		"CreateLedger",
+		"DeleteLedger",
+		"DescribeJournalKinesisStream",
+		"DescribeJournalS3Export",
+		"DescribeLedger",
+		"ExportJournalToS3",
+		"GetBlock",
+		"GetDigest",
+		"GetRevision",
+		"ListJournalKinesisStreamsForLedger",
+		"ListJournalS3Exports",
+		"ListJournalS3ExportsForLedger",
+		"ListLedgers",
+		"ListTagsForResource",
+		"SendCommand",
+		"StreamJournalToKinesis",
+		"TagResource",
+		"UntagResource",
+		"UpdateLedger",
+	},
+	"quicksight": {
+		"CancelIngestion",
+		"CreateAccountCustomization",
+		"CreateAnalysis",
+		"CreateDashboard",
+		"CreateDataSet",
+		"CreateDataSource",
+		"CreateGroup",
+		"CreateGroupMembership",
+		"CreateIAMPolicyAssignment",
+		"CreateIngestion",
+		"CreateNamespace",
+		"CreateTemplate",
+		"CreateTemplateAlias",
+		"CreateTheme",
+		"CreateThemeAlias",
+		"DeleteAccountCustomization",
+		"DeleteAnalysis",
+		"DeleteDashboard",
+		"DeleteDataSet",
+		"DeleteDataSource",
+		"DeleteGroup",
+		"DeleteGroupMembership",
+		"DeleteIAMPolicyAssignment",
+		"DeleteNamespace",
+		"DeleteTemplate",
+		"DeleteTemplateAlias",
+		"DeleteTheme",
+		"DeleteThemeAlias",
+		"DeleteUser",
+		"DeleteUserByPrincipalId",
+		"DescribeAccountCustomization",
+		"DescribeAccountSettings",
+		"DescribeAnalysis",
+		"DescribeAnalysisPermissions",
+		"DescribeDashboard",
+		"DescribeDashboardPermissions",
+		"DescribeDataSet",
+		"DescribeDataSetPermissions",
+		"DescribeDataSource",
+		"DescribeDataSourcePermissions",
+		"DescribeGroup",
+		"DescribeIAMPolicyAssignment",
+		"DescribeIngestion",
+		"DescribeNamespace",
+		"DescribeTemplate",
+		"DescribeTemplateAlias",
+		"DescribeTemplatePermissions",
+		"DescribeTheme",
+		"DescribeThemeAlias",
+		"DescribeThemePermissions",
+		"DescribeUser",
+		"GetDashboardEmbedUrl",
+		"GetSessionEmbedUrl",
+		"ListAnalyses",
+		"ListDashboardVersions",
+		"ListDashboards",
+		"ListDataSets",
+		"ListDataSources",
+		"ListGroupMemberships",
+		"ListGroups",
+		"ListIAMPolicyAssignments",
+		"ListIAMPolicyAssignmentsForUser",
+		"ListIngestions",
+		"ListNamespaces",
+		"ListTagsForResource",
+		"ListTemplateAliases",
+		"ListTemplateVersions",
+		"ListTemplates",
+		"ListThemeAliases",
+		"ListThemeVersions",
+		"ListThemes",
+		"ListUserGroups",
+		"ListUsers",
+		"RegisterUser",
+		"RestoreAnalysis",
+		"SearchAnalyses",
+		"SearchDashboards",
+		"TagResource",
+		"UntagResource",
+		"UpdateAccountCustomization",
+		"UpdateAccountSettings",
+		"UpdateAnalysis",
+		"UpdateAnalysisPermissions",
+		"UpdateDashboard",
+		"UpdateDashboardPermissions",
+		"UpdateDashboardPublishedVersion",
+		"UpdateDataSet",
+		"UpdateDataSetPermissions",
+		"UpdateDataSource",
+		"UpdateDataSourcePermissions",
+		"UpdateGroup",
+		"UpdateIAMPolicyAssignment",
+		"UpdateTemplate",
+		"UpdateTemplateAlias",
+		"UpdateTemplatePermissions",
+		"UpdateTheme",
+		"UpdateThemeAlias",
+		"UpdateThemePermissions",
+		"UpdateUser",
+	},
+	"ram": {
+		"AcceptResourceShareInvitation",
+		"AssociateResourceShare",
+		"AssociateResourceSharePermission",
+		"CreateResourceShare",
+		"DeleteResourceShare",
+		"DisassociateResourceShare",
+		"DisassociateResourceSharePermission",
+		"EnableSharingWithAwsOrganization",
+		"GetPermission",
+		"GetResourcePolicies",
+		"GetResourceShareAssociations",
+		"GetResourceShareInvitations",
+		"GetResourceShares",
+		"ListPendingInvitationResources",
+		"ListPermissions",
+		"ListPrincipals",
+		"ListResourceSharePermissions",
+		"ListResourceTypes",
+		"ListResources",
+		"PromoteResourceShareCreatedFromPolicy",
+		"RejectResourceShareInvitation",
+		"TagResource",
+		"UntagResource",
+		"UpdateResourceShare",
+	},
+	"rds": {
+		"AddRoleToDBCluster",
+		"AddRoleToDBInstance",
+		"AddSourceIdentifierToSubscription",
+		"AddTagsToResource",
+		"ApplyPendingMaintenanceAction",
+		"AuthorizeDBSecurityGroupIngress",
+		"BacktrackDBCluster",
+		"CancelExportTask",
+		"CopyDBClusterParameterGroup",
+		"CopyDBClusterSnapshot",
+		"CopyDBParameterGroup",
+		"CopyDBSnapshot",
+		"CopyOptionGroup",
+		"CreateCustomAvailabilityZone",
+		"CreateDBCluster",
+		"CreateDBClusterEndpoint",
+		"CreateDBClusterParameterGroup",
+		"CreateDBClusterSnapshot",
+		"CreateDBInstance",
+		"CreateDBInstanceReadReplica",
+		"CreateDBParameterGroup",
+		"CreateDBProxy",
+		"CreateDBProxyEndpoint",
+		"CreateDBSecurityGroup",
+		"CreateDBSnapshot",
+		"CreateDBSubnetGroup",
+		"CreateEventSubscription",
+		"CreateGlobalCluster",
+		"CreateOptionGroup",
+		"CrossRegionCommunication",
+		"DeleteCustomAvailabilityZone",
+		"DeleteDBCluster",
+		"DeleteDBClusterEndpoint",
+		"DeleteDBClusterParameterGroup",
+		"DeleteDBClusterSnapshot",
+		"DeleteDBInstance",
+		"DeleteDBInstanceAutomatedBackup",
+		"DeleteDBParameterGroup",
+		"DeleteDBProxy",
+		"DeleteDBProxyEndpoint",
+		"DeleteDBSecurityGroup",
+		"DeleteDBSnapshot",
+		"DeleteDBSubnetGroup",
+		"DeleteEventSubscription",
+		"DeleteGlobalCluster",
+		"DeleteInstallationMedia",
+		"DeleteOptionGroup",
+		"DeregisterDBProxyTargets",
+		"DescribeAccountAttributes",
+		"DescribeCertificates",
+		"DescribeCustomAvailabilityZones",
+		"DescribeDBClusterBacktracks",
+		"DescribeDBClusterEndpoints",
+		"DescribeDBClusterParameterGroups",
+		"DescribeDBClusterParameters",
+		"DescribeDBClusterSnapshotAttributes",
+		"DescribeDBClusterSnapshots",
+		"DescribeDBClusters",
+		"DescribeDBEngineVersions",
+		"DescribeDBInstanceAutomatedBackups",
+		"DescribeDBInstances",
+		"DescribeDBLogFiles",
+		"DescribeDBParameterGroups",
+		"DescribeDBParameters",
+		"DescribeDBProxies",
+		"DescribeDBProxyEndpoints",
+		"DescribeDBProxyTargetGroups",
+		"DescribeDBProxyTargets",
+		"DescribeDBSecurityGroups",
+		"DescribeDBSnapshotAttributes",
+		"DescribeDBSnapshots",
+		"DescribeDBSubnetGroups",
+		"DescribeEngineDefaultClusterParameters",
+		"DescribeEngineDefaultParameters",
+		"DescribeEventCategories",
+		"DescribeEventSubscriptions",
+		"DescribeEvents",
+		"DescribeExportTasks",
+		"DescribeGlobalClusters",
+		"DescribeInstallationMedia",
+		"DescribeOptionGroupOptions",
+		"DescribeOptionGroups",
+		"DescribeOrderableDBInstanceOptions",
+		"DescribePendingMaintenanceActions",
+		"DescribeReservedDBInstances",
+		"DescribeReservedDBInstancesOfferings",
+		"DescribeSourceRegions",
+		"DescribeValidDBInstanceModifications",
+		"DownloadDBLogFilePortion",
+		"FailoverDBCluster",
+		"FailoverGlobalCluster",
+		"ImportInstallationMedia",
+		"ListTagsForResource",
+		"ModifyCertificates",
+		"ModifyCurrentDBClusterCapacity",
+		"ModifyDBCluster",
+		"ModifyDBClusterEndpoint",
+		"ModifyDBClusterParameterGroup",
+		"ModifyDBClusterSnapshotAttribute",
+		"ModifyDBInstance",
+		"ModifyDBParameterGroup",
+		"ModifyDBProxy",
+		"ModifyDBProxyEndpoint",
+		"ModifyDBProxyTargetGroup",
+		"ModifyDBSnapshot",
+		"ModifyDBSnapshotAttribute",
+		"ModifyDBSubnetGroup",
+		"ModifyEventSubscription",
+		"ModifyGlobalCluster",
+		"ModifyOptionGroup",
+		"PromoteReadReplica",
+		"PromoteReadReplicaDBCluster",
+		"PurchaseReservedDBInstancesOffering",
+		"RebootDBInstance",
+		"RegisterDBProxyTargets",
+		"RemoveFromGlobalCluster",
+		"RemoveRoleFromDBCluster",
+		"RemoveRoleFromDBInstance",
+		"RemoveSourceIdentifierFromSubscription",
+		"RemoveTagsFromResource",
+		"ResetDBClusterParameterGroup",
+		"ResetDBParameterGroup",
+		"RestoreDBClusterFromS3",
+		"RestoreDBClusterFromSnapshot",
+		"RestoreDBClusterToPointInTime",
+		"RestoreDBInstanceFromDBSnapshot",
+		"RestoreDBInstanceFromS3",
+		"RestoreDBInstanceToPointInTime",
+		"RevokeDBSecurityGroupIngress",
+		"StartActivityStream",
+		"StartDBCluster",
+		"StartDBInstance",
+		"StartDBInstanceAutomatedBackupsReplication",
+		"StartExportTask",
+		"StopActivityStream",
+		"StopDBCluster",
+		"StopDBInstance",
+		"StopDBInstanceAutomatedBackupsReplication",
+	},
+	"rds-data": {
+		"BatchExecuteStatement",
+		"BeginTransaction",
+		"CommitTransaction",
+		"ExecuteSql",
+		"ExecuteStatement",
+		"RollbackTransaction",
+	},
+	"rds-db": {
+		"connect",
+	},
+	"redshift": {
+		"AcceptReservedNodeExchange",
+		"AuthorizeClusterSecurityGroupIngress",
+		"AuthorizeSnapshotAccess",
+		"BatchDeleteClusterSnapshots",
+		"BatchModifyClusterSnapshots",
+		"CancelResize",
+		"CopyClusterSnapshot",
+		"CreateCluster",
+		"CreateClusterParameterGroup",
+		"CreateClusterSecurityGroup",
+		"CreateClusterSnapshot",
+		"CreateClusterSubnetGroup",
+		"CreateEventSubscription",
+		"CreateHsmClientCertificate",
+		"CreateHsmConfiguration",
+		"CreateScheduledAction",
+		"CreateSnapshotCopyGrant",
+		"CreateSnapshotSchedule",
+		"CreateTags",
+		"CreateUsageLimit",
+		"DeleteCluster",
+		"DeleteClusterParameterGroup",
+		"DeleteClusterSecurityGroup",
+		"DeleteClusterSnapshot",
+		"DeleteClusterSubnetGroup",
+		"DeleteEventSubscription",
+		"DeleteHsmClientCertificate",
+		"DeleteHsmConfiguration",
+		"DeleteScheduledAction",
+		"DeleteSnapshotCopyGrant",
+		"DeleteSnapshotSchedule",
+		"DeleteTags",
+		"DeleteUsageLimit",
+		"DescribeAccountAttributes",
+		"DescribeClusterDbRevisions",
+		"DescribeClusterParameterGroups",
+		"DescribeClusterParameters",
+		"DescribeClusterSecurityGroups",
+		"DescribeClusterSnapshots",
+		"DescribeClusterSubnetGroups",
+		"DescribeClusterTracks",
+		"DescribeClusterVersions",
+		"DescribeClusters",
+		"DescribeDefaultClusterParameters",
+		"DescribeEventCategories",
+		"DescribeEventSubscriptions",
+		"DescribeEvents",
+		"DescribeHsmClientCertificates",
+		"DescribeHsmConfigurations",
+		"DescribeLoggingStatus",
+		"DescribeNodeConfigurationOptions",
+		"DescribeOrderableClusterOptions",
+		"DescribeReservedNodeOfferings",
+		"DescribeReservedNodes",
+		"DescribeResize",
+		"DescribeScheduledActions",
+		"DescribeSnapshotCopyGrants",
+		"DescribeSnapshotSchedules",
+		"DescribeStorage",
+		"DescribeTableRestoreStatus",
+		"DescribeTags",
+		"DescribeUsageLimits",
+		"DisableLogging",
+		"DisableSnapshotCopy",
+		"EnableLogging",
+		"EnableSnapshotCopy",
+		"GetClusterCredentials",
+		"GetReservedNodeExchangeOfferings",
+		"ModifyCluster",
+		"ModifyClusterDbRevision",
+		"ModifyClusterIamRoles",
+		"ModifyClusterMaintenance",
+		"ModifyClusterParameterGroup",
+		"ModifyClusterSnapshot",
+		"ModifyClusterSnapshotSchedule",
+		"ModifyClusterSubnetGroup",
+		"ModifyEventSubscription",
+		"ModifyScheduledAction",
+		"ModifySnapshotCopyRetentionPeriod",
+		"ModifySnapshotSchedule",
+		"ModifyUsageLimit",
+		"PauseCluster",
+		"PurchaseReservedNodeOffering",
+		"RebootCluster",
+		"ResetClusterParameterGroup",
+		"ResizeCluster",
+		"RestoreFromClusterSnapshot",
+		"RestoreTableFromClusterSnapshot",
+		"ResumeCluster",
+		"RevokeClusterSecurityGroupIngress",
+		"RevokeSnapshotAccess",
+		"RotateEncryptionKey",
+	},
+	"redshift-data": {
+		"CancelStatement",
+		"DescribeStatement",
+		"DescribeTable",
+		"ExecuteStatement",
+		"GetStatementResult",
+		"ListDatabases",
+		"ListSchemas",
+		"ListStatements",
+		"ListTables",
+	},
+	"rekognition": {
+		"CompareFaces",
+		"CreateCollection",
+		"CreateProject",
+		"CreateProjectVersion",
+		"CreateStreamProcessor",
+		"DeleteCollection",
+		"DeleteFaces",
+		"DeleteProject",
+		"DeleteProjectVersion",
+		"DeleteStreamProcessor",
+		"DescribeCollection",
+		"DescribeProjectVersions",
+		"DescribeProjects",
+		"DescribeStreamProcessor",
+		"DetectCustomLabels",
+		"DetectFaces",
+		"DetectLabels",
+		"DetectModerationLabels",
+		"DetectProtectiveEquipment",
+		"DetectText",
+		"GetCelebrityInfo",
+		"GetCelebrityRecognition",
+		"GetContentModeration",
+		"GetFaceDetection",
+		"GetFaceSearch",
+		"GetLabelDetection",
+		"GetPersonTracking",
+		"GetSegmentDetection",
+		"GetTextDetection",
+		"IndexFaces",
+		"ListCollections",
+		"ListFaces",
+		"ListStreamProcessors",
+		"RecognizeCelebrities",
+		"SearchFaces",
+		"SearchFacesByImage",
+		"StartCelebrityRecognition",
+		"StartContentModeration",
+		"StartFaceDetection",
+		"StartFaceSearch",
+		"StartLabelDetection",
+		"StartPersonTracking",
+		"StartProjectVersion",
+		"StartSegmentDetection",
+		"StartStreamProcessor",
+		"StartTextDetection",
+		"StopProjectVersion",
+		"StopStreamProcessor",
+	},
+	"resource-groups": {
+		"CreateGroup",
+		"DeleteGroup",
+		"GetGroup",
+		"GetGroupConfiguration",
+		"GetGroupQuery",
+		"GetTags",
+		"GroupResources",
+		"ListGroupResources",
+		"ListGroups",
+		"PutGroupConfiguration",
+		"SearchResources",
+		"Tag",
+		"UngroupResources",
+		"Untag",
+		"UpdateGroup",
+		"UpdateGroupQuery",
+	},
+	"robomaker": {
+		"BatchDeleteWorlds",
+		"BatchDescribeSimulationJob",
+		"CancelDeploymentJob",
+		"CancelSimulationJob",
+		"CancelSimulationJobBatch",
+		"CancelWorldExportJob",
+		"CancelWorldGenerationJob",
+		"CreateDeploymentJob",
+		"CreateFleet",
+		"CreateRobot",
+		"CreateRobotApplication",
+		"CreateRobotApplicationVersion",
+		"CreateSimulationApplication",
+		"CreateSimulationApplicationVersion",
+		"CreateSimulationJob",
+		"CreateWorldExportJob",
+		"CreateWorldGenerationJob",
+		"CreateWorldTemplate",
+		"DeleteFleet",
+		"DeleteRobot",
+		"DeleteRobotApplication",
+		"DeleteSimulationApplication",
+		"DeleteWorldTemplate",
+		"DeregisterRobot",
+		"DescribeDeploymentJob",
+		"DescribeFleet",
+		"DescribeRobot",
+		"DescribeRobotApplication",
+		"DescribeSimulationApplication",
+		"DescribeSimulationJob",
+		"DescribeSimulationJobBatch",
+		"DescribeWorld",
+		"DescribeWorldExportJob",
+		"DescribeWorldGenerationJob",
+		"DescribeWorldTemplate",
+		"GetWorldTemplateBody",
+		"ListDeploymentJobs",
+		"ListFleets",
+		"ListRobotApplications",
+		"ListRobots",
+		"ListSimulationApplications",
+		"ListSimulationJobBatches",
+		"ListSimulationJobs",
+		"ListTagsForResource",
+		"ListWorldExportJobs",
+		"ListWorldGenerationJobs",
+		"ListWorldTemplates",
+		"ListWorlds",
+		"RegisterRobot",
+		"RestartSimulationJob",
+		"StartSimulationJobBatch",
+		"SyncDeploymentJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateRobotApplication",
+		"UpdateSimulationApplication",
+		"UpdateWorldTemplate",
+	},
+	"route53": {
+		"ActivateKeySigningKey",
+		"AssociateVPCWithHostedZone",
+		"ChangeResourceRecordSets",
+		"ChangeTagsForResource",
+		"CreateHealthCheck",
+		"CreateHostedZone",
+		"CreateKeySigningKey",
+		"CreateQueryLoggingConfig",
+		"CreateReusableDelegationSet",
+		"CreateTrafficPolicy",
+		"CreateTrafficPolicyInstance",
+		"CreateTrafficPolicyVersion",
+		"CreateVPCAssociationAuthorization",
+		"DeactivateKeySigningKey",
+		"DeleteHealthCheck",
+		"DeleteHostedZone",
+		"DeleteKeySigningKey",
+		"DeleteQueryLoggingConfig",
+		"DeleteReusableDelegationSet",
+		"DeleteTrafficPolicy",
+		"DeleteTrafficPolicyInstance",
+		"DeleteVPCAssociationAuthorization",
+		"DisableHostedZoneDNSSEC",
+		"DisassociateVPCFromHostedZone",
+		"EnableHostedZoneDNSSEC",
+		"GetAccountLimit",
+		"GetChange",
+		"GetCheckerIpRanges",
+		"GetDNSSEC",
+		"GetGeoLocation",
+		"GetHealthCheck",
+		"GetHealthCheckCount",
+		"GetHealthCheckLastFailureReason",
+		"GetHealthCheckStatus",
+		"GetHostedZone",
+		"GetHostedZoneCount",
+		"GetHostedZoneLimit",
+		"GetQueryLoggingConfig",
+		"GetReusableDelegationSet",
+		"GetReusableDelegationSetLimit",
+		"GetTrafficPolicy",
+		"GetTrafficPolicyInstance",
+		"GetTrafficPolicyInstanceCount",
+		"ListGeoLocations",
+		"ListHealthChecks",
+		"ListHostedZones",
+		"ListHostedZonesByName",
+		"ListHostedZonesByVPC",
+		"ListQueryLoggingConfigs",
+		"ListResourceRecordSets",
+		"ListReusableDelegationSets",
+		"ListTagsForResource",
+		"ListTagsForResources",
+		"ListTrafficPolicies",
+		"ListTrafficPolicyInstances",
+		"ListTrafficPolicyInstancesByHostedZone",
+		"ListTrafficPolicyInstancesByPolicy",
+		"ListTrafficPolicyVersions",
+		"ListVPCAssociationAuthorizations",
+		"TestDNSAnswer",
+		"UpdateHealthCheck",
+		"UpdateHostedZoneComment",
+		"UpdateTrafficPolicyComment",
+		"UpdateTrafficPolicyInstance",
+	},
+	"route53domains": {
+		"AcceptDomainTransferFromAnotherAwsAccount",
+		"CancelDomainTransferToAnotherAwsAccount",
+		"CheckDomainAvailability",
+		"CheckDomainTransferability",
+		"DeleteTagsForDomain",
+		"DisableDomainAutoRenew",
+		"DisableDomainTransferLock",
+		"EnableDomainAutoRenew",
+		"EnableDomainTransferLock",
+		"GetContactReachabilityStatus",
+		"GetDomainDetail",
+		"GetDomainSuggestions",
+		"GetOperationDetail",
+		"ListDomains",
+		"ListOperations",
+		"ListTagsForDomain",
+		"RegisterDomain",
+		"RejectDomainTransferFromAnotherAwsAccount",
+		"RenewDomain",
+		"ResendContactReachabilityEmail",
+		"RetrieveDomainAuthCode",
+		"TransferDomain",
+		"TransferDomainToAnotherAwsAccount",
+		"UpdateDomainContact",
+		"UpdateDomainContactPrivacy",
+		"UpdateDomainNameservers",
+		"UpdateTagsForDomain",
+		"ViewBilling",
+	},
+	"route53resolver": {
+		"AssociateResolverEndpointIpAddress",
+		"AssociateResolverQueryLogConfig",
+		"AssociateResolverRule",
+		"CreateResolverEndpoint",
+		"CreateResolverQueryLogConfig",
+		"CreateResolverRule",
+		"DeleteResolverEndpoint",
+		"DeleteResolverQueryLogConfig",
+		"DeleteResolverRule",
+		"DisassociateResolverEndpointIpAddress",
+		"DisassociateResolverQueryLogConfig",
+		"DisassociateResolverRule",
+		"GetResolverDnssecConfig",
+		"GetResolverEndpoint",
+		"GetResolverQueryLogConfig",
+		"GetResolverQueryLogConfigAssociation",
+		"GetResolverQueryLogConfigPolicy",
+		"GetResolverRule",
+		"GetResolverRuleAssociation",
+		"GetResolverRulePolicy",
+		"ListResolverDnssecConfigs",
+		"ListResolverEndpointIpAddresses",
+		"ListResolverEndpoints",
+		"ListResolverQueryLogConfigAssociations",
+		"ListResolverQueryLogConfigs",
+		"ListResolverRuleAssociations",
+		"ListResolverRules",
+		"ListTagsForResource",
+		"PutResolverQueryLogConfigPolicy",
+		"PutResolverRulePolicy",
+		"TagResource",
+		"UntagResource",
+		"UpdateResolverDnssecConfig",
+		"UpdateResolverEndpoint",
+		"UpdateResolverRule",
+	},
+	"s3": {
+		"AbortMultipartUpload",
+		"BypassGovernanceRetention",
+		"CompleteMultipartUpload",
+		"CopyObject",
+		"CreateAccessPoint",
+		"CreateBucket",
+		"CreateJob",
+		"CreateMultipartUpload",
+		"DeleteAccessPoint",
+		"DeleteAccessPointPolicy",
+		"DeleteBucket",
+		"DeleteBucketAnalyticsConfiguration",
+		"DeleteBucketCors",
+		"DeleteBucketEncryption",
+		"DeleteBucketIntelligentTieringConfiguration",
+		"DeleteBucketInventoryConfiguration",
+		"DeleteBucketLifecycle",
+		"DeleteBucketLifecycleConfiguration",
+		"DeleteBucketMetricsConfiguration",
+		"DeleteBucketOwnershipControls",
+		"DeleteBucketPolicy",
+		"DeleteBucketReplication",
+		"DeleteBucketTagging",
+		"DeleteBucketWebsite",
+		"DeleteJobTagging",
+		"DeleteObject",
+		"DeleteObjectTagging",
+		"DeleteObjectVersion",
+		"DeleteObjectVersionTagging",
+		"DeleteObjects",
+		"DeletePublicAccessBlock",
+		"DeleteStorageLensConfiguration",
+		"DeleteStorageLensConfigurationTagging",
+		"DescribeJob",
+		"GetAccelerateConfiguration",
+		"GetAccessPoint",
+		"GetAccessPointPolicy",
+		"GetAccessPointPolicyStatus",
+		"GetAnalyticsConfiguration",
+		"GetBucket",
+		"GetBucketAccelerateConfiguration",
+		"GetBucketAcl",
+		"GetBucketAnalyticsConfiguration",
+		"GetBucketCors",
+		"GetBucketEncryption",
+		"GetBucketIntelligentTieringConfiguration",
+		"GetBucketInventoryConfiguration",
+		"GetBucketLifecycle",
+		"GetBucketLifecycleConfiguration",
+		"GetBucketLocation",
+		"GetBucketLogging",
+		"GetBucketMetricsConfiguration",
+		"GetBucketNotification",
+		"GetBucketNotificationConfiguration",
+		"GetBucketObjectLockConfiguration",
+		"GetBucketOwnershipControls",
+		"GetBucketPolicy",
+		"GetBucketPolicyStatus",
+		"GetBucketReplication",
+		"GetBucketRequestPayment",
+		"GetBucketTagging",
+		"GetBucketVersioning",
+		"GetBucketWebsite",
+		"GetEncryptionConfiguration",
+		"GetIntelligentTieringConfiguration",
+		"GetInventoryConfiguration",
+		"GetJobTagging",
+		"GetLifecycleConfiguration",
+		"GetMetricsConfiguration",
+		"GetObject",
+		"GetObjectAcl",
+		"GetObjectLegalHold",
+		"GetObjectLockConfiguration",
+		"GetObjectRetention",
+		"GetObjectTagging",
+		"GetObjectTorrent",
+		"GetObjectVersion",
+		"GetObjectVersionAcl",
+		"GetObjectVersionForReplication",
+		"GetObjectVersionTagging",
+		"GetPublicAccessBlock",
+		"GetReplicationConfiguration",
+		"GetStorageLensConfiguration",
+		"GetStorageLensConfigurationTagging",
+		"HeadBucket",
+		"HeadObject",
+		"ListAccessPoints",
+		"ListAllMyBuckets",
+		"ListBucket",
+		"ListBucketAnalyticsConfigurations",
+		"ListBucketIntelligentTieringConfigurations",
+		"ListBucketInventoryConfigurations",
+		"ListBucketMetricsConfigurations",
+		"ListBucketMultipartUploads",
+		"ListBucketVersions",
+		"ListBuckets",
+		"ListJobs",
+		"ListMultipartUploadParts",
+		"ListMultipartUploads",
+		"ListObjectVersions",
+		"ListObjects",
+		"ListObjectsV2",
+		"ListParts",
+		"ListRegionalBuckets",
+		"ListStorageLensConfigurations",
+		"ObjectOwnerOverrideToBucketOwner",
+		"PutAccelerateConfiguration",
+		"PutAccessPointPolicy",
+		"PutAnalyticsConfiguration",
+		"PutBucketAccelerateConfiguration",
+		"PutBucketAcl",
+		"PutBucketAnalyticsConfiguration",
+		"PutBucketCors",
+		"PutBucketEncryption",
+		"PutBucketIntelligentTieringConfiguration",
+		"PutBucketInventoryConfiguration",
+		"PutBucketLifecycle",
+		"PutBucketLifecycleConfiguration",
+		"PutBucketLogging",
+		"PutBucketMetricsConfiguration",
+		"PutBucketNotification",
+		"PutBucketNotificationConfiguration",
+		"PutBucketObjectLockConfiguration",
+		"PutBucketOwnershipControls",
+		"PutBucketPolicy",
+		"PutBucketReplication",
+		"PutBucketRequestPayment",
+		"PutBucketTagging",
+		"PutBucketVersioning",
+		"PutBucketWebsite",
+		"PutEncryptionConfiguration",
+		"PutIntelligentTieringConfiguration",
+		"PutInventoryConfiguration",
+		"PutJobTagging",
+		"PutLifecycleConfiguration",
+		"PutMetricsConfiguration",
+		"PutObject",
+		"PutObjectAcl",
+		"PutObjectLegalHold",
+		"PutObjectLockConfiguration",
+		"PutObjectRetention",
+		"PutObjectTagging",
+		"PutObjectVersionAcl",
+		"PutObjectVersionTagging",
+		"PutPublicAccessBlock",
+		"PutReplicationConfiguration",
+		"PutStorageLensConfiguration",
+		"PutStorageLensConfigurationTagging",
+		"ReplicateDelete",
+		"ReplicateObject",
+		"ReplicateTags",
+		"RestoreObject",
+		"SelectObjectContent",
+		"UpdateJobPriority",
+		"UpdateJobStatus",
+		"UploadPart",
+		"UploadPartCopy",
+	},
+	"s3-outposts": {
+		"CreateEndpoint",
+		"DeleteEndpoint",
+		"ListEndpoints",
+	},
+	"sagemaker": {
+		"AddAssociation",
+		"AddTags",
+		"AssociateTrialComponent",
+		"CreateAction",
+		"CreateAlgorithm",
+		"CreateApp",
+		"CreateAppImageConfig",
+		"CreateArtifact",
+		"CreateAutoMLJob",
+		"CreateCodeRepository",
+		"CreateCompilationJob",
+		"CreateContext",
+		"CreateDataQualityJobDefinition",
+		"CreateDeviceFleet",
+		"CreateDomain",
+		"CreateEdgePackagingJob",
+		"CreateEndpoint",
+		"CreateEndpointConfig",
+		"CreateExperiment",
+		"CreateFeatureGroup",
+		"CreateFlowDefinition",
+		"CreateHumanTaskUi",
+		"CreateHyperParameterTuningJob",
+		"CreateImage",
+		"CreateImageVersion",
+		"CreateLabelingJob",
+		"CreateModel",
+		"CreateModelBiasJobDefinition",
+		"CreateModelExplainabilityJobDefinition",
+		"CreateModelPackage",
+		"CreateModelPackageGroup",
+		"CreateModelQualityJobDefinition",
+		"CreateMonitoringSchedule",
+		"CreateNotebookInstance",
+		"CreateNotebookInstanceLifecycleConfig",
+		"CreatePipeline",
+		"CreatePresignedDomainUrl",
+		"CreatePresignedNotebookInstanceUrl",
+		"CreateProcessingJob",
+		"CreateProject",
+		"CreateTrainingJob",
+		"CreateTransformJob",
+		"CreateTrial",
+		"CreateTrialComponent",
+		"CreateUserProfile",
+		"CreateWorkforce",
+		"CreateWorkteam",
+		"DeleteAction",
+		"DeleteAlgorithm",
+		"DeleteApp",
+		"DeleteAppImageConfig",
+		"DeleteArtifact",
+		"DeleteAssociation",
+		"DeleteCodeRepository",
+		"DeleteContext",
+		"DeleteDataQualityJobDefinition",
+		"DeleteDeviceFleet",
+		"DeleteDomain",
+		"DeleteEndpoint",
+		"DeleteEndpointConfig",
+		"DeleteExperiment",
+		"DeleteFeatureGroup",
+		"DeleteFlowDefinition",
+		"DeleteHumanLoop",
+		"DeleteHumanTaskUi",
+		"DeleteImage",
+		"DeleteImageVersion",
+		"DeleteModel",
+		"DeleteModelBiasJobDefinition",
+		"DeleteModelExplainabilityJobDefinition",
+		"DeleteModelPackage",
+		"DeleteModelPackageGroup",
+		"DeleteModelPackageGroupPolicy",
+		"DeleteModelQualityJobDefinition",
+		"DeleteMonitoringSchedule",
+		"DeleteNotebookInstance",
+		"DeleteNotebookInstanceLifecycleConfig",
+		"DeletePipeline",
+		"DeleteProject",
+		"DeleteRecord",
+		"DeleteTags",
+		"DeleteTrial",
+		"DeleteTrialComponent",
+		"DeleteUserProfile",
+		"DeleteWorkforce",
+		"DeleteWorkteam",
+		"DeregisterDevices",
+		"DescribeAction",
+		"DescribeAlgorithm",
+		"DescribeApp",
+		"DescribeAppImageConfig",
+		"DescribeArtifact",
+		"DescribeAutoMLJob",
+		"DescribeCodeRepository",
+		"DescribeCompilationJob",
+		"DescribeContext",
+		"DescribeDataQualityJobDefinition",
+		"DescribeDevice",
+		"DescribeDeviceFleet",
+		"DescribeDomain",
+		"DescribeEdgePackagingJob",
+		"DescribeEndpoint",
+		"DescribeEndpointConfig",
+		"DescribeExperiment",
+		"DescribeFeatureGroup",
+		"DescribeFlowDefinition",
+		"DescribeHumanLoop",
+		"DescribeHumanTaskUi",
+		"DescribeHyperParameterTuningJob",
+		"DescribeImage",
+		"DescribeImageVersion",
+		"DescribeLabelingJob",
+		"DescribeModel",
+		"DescribeModelBiasJobDefinition",
+		"DescribeModelExplainabilityJobDefinition",
+		"DescribeModelPackage",
+		"DescribeModelPackageGroup",
+		"DescribeModelQualityJobDefinition",
+		"DescribeMonitoringSchedule",
+		"DescribeNotebookInstance",
+		"DescribeNotebookInstanceLifecycleConfig",
+		"DescribePipeline",
+		"DescribePipelineDefinitionForExecution",
+		"DescribePipelineExecution",
+		"DescribeProcessingJob",
+		"DescribeProject",
+		"DescribeSubscribedWorkteam",
+		"DescribeTrainingJob",
+		"DescribeTransformJob",
+		"DescribeTrial",
+		"DescribeTrialComponent",
+		"DescribeUserProfile",
+		"DescribeWorkforce",
+		"DescribeWorkteam",
+		"DisableSagemakerServicecatalogPortfolio",
+		"DisassociateTrialComponent",
+		"EnableSagemakerServicecatalogPortfolio",
+		"GetDeviceFleetReport",
+		"GetDeviceRegistration",
+		"GetModelPackageGroupPolicy",
+		"GetRecord",
+		"GetSagemakerServicecatalogPortfolioStatus",
+		"GetSearchSuggestions",
+		"InvokeEndpoint",
+		"ListActions",
+		"ListAlgorithms",
+		"ListAppImageConfigs",
+		"ListApps",
+		"ListArtifacts",
+		"ListAssociations",
+		"ListAutoMLJobs",
+		"ListCandidatesForAutoMLJob",
+		"ListCodeRepositories",
+		"ListCompilationJobs",
+		"ListContexts",
+		"ListDataQualityJobDefinitions",
+		"ListDeviceFleets",
+		"ListDevices",
+		"ListDomains",
+		"ListEdgePackagingJobs",
+		"ListEndpointConfigs",
+		"ListEndpoints",
+		"ListExperiments",
+		"ListFeatureGroups",
+		"ListFlowDefinitions",
+		"ListHumanLoops",
+		"ListHumanTaskUis",
+		"ListHyperParameterTuningJobs",
+		"ListImageVersions",
+		"ListImages",
+		"ListLabelingJobs",
+		"ListLabelingJobsForWorkteam",
+		"ListModelBiasJobDefinitions",
+		"ListModelExplainabilityJobDefinitions",
+		"ListModelPackageGroups",
+		"ListModelPackages",
+		"ListModelQualityJobDefinitions",
+		"ListModels",
+		"ListMonitoringExecutions",
+		"ListMonitoringSchedules",
+		"ListNotebookInstanceLifecycleConfigs",
+		"ListNotebookInstances",
+		"ListPipelineExecutionSteps",
+		"ListPipelineExecutions",
+		"ListPipelineParametersForExecution",
+		"ListPipelines",
+		"ListProcessingJobs",
+		"ListProjects",
+		"ListSubscribedWorkteams",
+		"ListTags",
+		"ListTrainingJobs",
+		"ListTrainingJobsForHyperParameterTuningJob",
+		"ListTransformJobs",
+		"ListTrialComponents",
+		"ListTrials",
+		"ListUserProfiles",
+		"ListWorkforces",
+		"ListWorkteams",
+		"PutModelPackageGroupPolicy",
+		"PutRecord",
+		"RegisterDevices",
+		"RenderUiTemplate",
+		"Search",
+		"SendHeartbeat",
+		"StartHumanLoop",
+		"StartMonitoringSchedule",
+		"StartNotebookInstance",
+		"StartPipelineExecution",
+		"StopAutoMLJob",
+		"StopCompilationJob",
+		"StopEdgePackagingJob",
+		"StopHumanLoop",
+		"StopHyperParameterTuningJob",
+		"StopLabelingJob",
+		"StopMonitoringSchedule",
+		"StopNotebookInstance",
+		"StopPipelineExecution",
+		"StopProcessingJob",
+		"StopTrainingJob",
+		"StopTransformJob",
+		"UpdateAction",
+		"UpdateAppImageConfig",
+		"UpdateArtifact",
+		"UpdateCodeRepository",
+		"UpdateContext",
+		"UpdateDeviceFleet",
+		"UpdateDevices",
+		"UpdateDomain",
+		"UpdateEndpoint",
+		"UpdateEndpointWeightsAndCapacities",
+		"UpdateExperiment",
+		"UpdateImage",
+		"UpdateModelPackage",
+		"UpdateMonitoringSchedule",
+		"UpdateNotebookInstance",
+		"UpdateNotebookInstanceLifecycleConfig",
+		"UpdatePipeline",
+		"UpdatePipelineExecution",
+		"UpdateTrainingJob",
+		"UpdateTrial",
+		"UpdateTrialComponent",
+		"UpdateUserProfile",
+		"UpdateWorkforce",
+		"UpdateWorkteam",
+	},
+	"savingsplans": {
+		"CreateSavingsPlan",
+		"DeleteQueuedSavingsPlan",
+		"DescribeSavingsPlanRates",
+		"DescribeSavingsPlans",
+		"DescribeSavingsPlansOfferingRates",
+		"DescribeSavingsPlansOfferings",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+	},
+	"schemas": {
+		"CreateDiscoverer",
+		"CreateRegistry",
+		"CreateSchema",
+		"DeleteDiscoverer",
+		"DeleteRegistry",
+		"DeleteResourcePolicy",
+		"DeleteSchema",
+		"DeleteSchemaVersion",
+		"DescribeCodeBinding",
+		"DescribeDiscoverer",
+		"DescribeRegistry",
+		"DescribeSchema",
+		"ExportSchema",
+		"GetCodeBindingSource",
+		"GetDiscoveredSchema",
+		"GetResourcePolicy",
+		"ListDiscoverers",
+		"ListRegistries",
+		"ListSchemaVersions",
+		"ListSchemas",
+		"ListTagsForResource",
+		"PutCodeBinding",
+		"PutResourcePolicy",
+		"SearchSchemas",
+		"StartDiscoverer",
+		"StopDiscoverer",
+		"TagResource",
+		"UntagResource",
+		"UpdateDiscoverer",
+		"UpdateRegistry",
+		"UpdateSchema",
+	},
+	"sdb": {
+		"BatchDeleteAttributes",
+		"BatchPutAttributes",
+		"CreateDomain",
+		"DeleteAttributes",
+		"DeleteDomain",
+		"DomainMetadata",
+		"GetAttributes",
+		"ListDomains",
+		"PutAttributes",
+		"Select",
+	},
+	"secretsmanager": {
+		"CancelRotateSecret",
+		"CreateSecret",
+		"DeleteResourcePolicy",
+		"DeleteSecret",
+		"DescribeSecret",
+		"GetRandomPassword",
+		"GetResourcePolicy",
+		"GetSecretValue",
+		"ListSecretVersionIds",
+		"ListSecrets",
+		"PutResourcePolicy",
+		"PutSecretValue",
+		"RemoveRegionsFromReplication",
+		"ReplicateSecretToRegions",
+		"RestoreSecret",
+		"RotateSecret",
+		"StopReplicationToReplica",
+		"TagResource",
+		"UntagResource",
+		"UpdateSecret",
+		"UpdateSecretVersionStage",
+		"ValidateResourcePolicy",
+	},
+	"securityhub": {
+		"AcceptInvitation",
+		"BatchDisableStandards",
+		"BatchEnableStandards",
+		"BatchImportFindings",
+		"BatchUpdateFindings",
+		"CreateActionTarget",
+		"CreateInsight",
+		"CreateMembers",
+		"DeclineInvitations",
+		"DeleteActionTarget",
+		"DeleteInsight",
+		"DeleteInvitations",
+		"DeleteMembers",
+		"DescribeActionTargets",
+		"DescribeHub",
+		"DescribeOrganizationConfiguration",
+		"DescribeProducts",
+		"DescribeStandards",
+		"DescribeStandardsControls",
+		"DisableImportFindingsForProduct",
+		"DisableOrganizationAdminAccount",
+		"DisableSecurityHub",
+		"DisassociateFromMasterAccount",
+		"DisassociateMembers",
+		"EnableImportFindingsForProduct",
+		"EnableOrganizationAdminAccount",
+		"EnableSecurityHub",
+		"GetEnabledStandards",
+		"GetFindings",
+		"GetInsightResults",
+		"GetInsights",
+		"GetInvitationsCount",
+		"GetMasterAccount",
+		"GetMembers",
+		"InviteMembers",
+		"ListEnabledProductsForImport",
+		"ListInvitations",
+		"ListMembers",
+		"ListOrganizationAdminAccounts",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateActionTarget",
+		"UpdateFindings",
+		"UpdateInsight",
+		"UpdateOrganizationConfiguration",
+		"UpdateSecurityHubConfiguration",
+		"UpdateStandardsControl",
+	},
+	"serverlessrepo": {
+		"CreateApplication",
+		"CreateApplicationVersion",
+		"CreateCloudFormationChangeSet",
+		"CreateCloudFormationTemplate",
+		"DeleteApplication",
+		"GetApplication",
+		"GetApplicationPolicy",
+		"GetCloudFormationTemplate",
+		"ListApplicationDependencies",
+		"ListApplicationVersions",
+		"ListApplications",
+		"PutApplicationPolicy",
+		"UnshareApplication",
+		"UpdateApplication",
+	},
+	"servicecatalog": {
+		"AcceptPortfolioShare",
+		"AssociateAttributeGroup",
+		"AssociateBudgetWithResource",
+		"AssociatePrincipalWithPortfolio",
+		"AssociateProductWithPortfolio",
+		"AssociateResource",
+		"AssociateServiceActionWithProvisioningArtifact",
+		"AssociateTagOptionWithResource",
+		"BatchAssociateServiceActionWithProvisioningArtifact",
+		"BatchDisassociateServiceActionFromProvisioningArtifact",
+		"CopyProduct",
+		"CreateApplication",
+		"CreateAttributeGroup",
+		"CreateConstraint",
+		"CreatePortfolio",
+		"CreatePortfolioShare",
+		"CreateProduct",
+		"CreateProvisionedProductPlan",
+		"CreateProvisioningArtifact",
+		"CreateServiceAction",
+		"CreateTagOption",
+		"DeleteApplication",
+		"DeleteAttributeGroup",
+		"DeleteConstraint",
+		"DeletePortfolio",
+		"DeletePortfolioShare",
+		"DeleteProduct",
+		"DeleteProvisionedProductPlan",
+		"DeleteProvisioningArtifact",
+		"DeleteServiceAction",
+		"DeleteTagOption",
+		"DescribeConstraint",
+		"DescribeCopyProductStatus",
+		"DescribePortfolio",
+		"DescribePortfolioShareStatus",
+		"DescribePortfolioShares",
+		"DescribeProduct",
+		"DescribeProductAsAdmin",
+		"DescribeProductView",
+		"DescribeProvisionedProduct",
+		"DescribeProvisionedProductPlan",
+		"DescribeProvisioningArtifact",
+		"DescribeProvisioningParameters",
+		"DescribeRecord",
+		"DescribeServiceAction",
+		"DescribeServiceActionExecutionParameters",
+		"DescribeTagOption",
+		"DisableAWSOrganizationsAccess",
+		"DisassociateAttributeGroup",
+		"DisassociateBudgetFromResource",
+		"DisassociatePrincipalFromPortfolio",
+		"DisassociateProductFromPortfolio",
+		"DisassociateResource",
+		"DisassociateServiceActionFromProvisioningArtifact",
+		"DisassociateTagOptionFromResource",
+		"EnableAWSOrganizationsAccess",
+		"ExecuteProvisionedProductPlan",
+		"ExecuteProvisionedProductServiceAction",
+		"GetAWSOrganizationsAccessStatus",
+		"GetApplication",
+		"GetAttributeGroup",
+		"GetProvisionedProductOutputs",
+		"ImportAsProvisionedProduct",
+		"ListAcceptedPortfolioShares",
+		"ListApplications",
+		"ListAssociatedAttributeGroups",
+		"ListAssociatedResources",
+		"ListAttributeGroups",
+		"ListBudgetsForResource",
+		"ListConstraintsForPortfolio",
+		"ListLaunchPaths",
+		"ListOrganizationPortfolioAccess",
+		"ListPortfolioAccess",
+		"ListPortfolios",
+		"ListPortfoliosForProduct",
+		"ListPrincipalsForPortfolio",
+		"ListProvisionedProductPlans",
+		"ListProvisioningArtifacts",
+		"ListProvisioningArtifactsForServiceAction",
+		"ListRecordHistory",
+		"ListResourcesForTagOption",
+		"ListServiceActions",
+		"ListServiceActionsForProvisioningArtifact",
+		"ListStackInstancesForProvisionedProduct",
+		"ListTagOptions",
+		"ListTagsForResource",
+		"ProvisionProduct",
+		"RejectPortfolioShare",
+		"ScanProvisionedProducts",
+		"SearchProducts",
+		"SearchProductsAsAdmin",
+		"SearchProvisionedProducts",
+		"SyncResource",
+		"TagResource",
+		"TerminateProvisionedProduct",
+		"UntagResource",
+		"UpdateApplication",
+		"UpdateAttributeGroup",
+		"UpdateConstraint",
+		"UpdatePortfolio",
+		"UpdatePortfolioShare",
+		"UpdateProduct",
+		"UpdateProvisionedProduct",
+		"UpdateProvisionedProductProperties",
+		"UpdateProvisioningArtifact",
+		"UpdateServiceAction",
+		"UpdateTagOption",
+	},
+	"servicediscovery": {
+		"CreateHttpNamespace",
+		"CreatePrivateDnsNamespace",
+		"CreatePublicDnsNamespace",
+		"CreateService",
+		"DeleteNamespace",
+		"DeleteService",
+		"DeregisterInstance",
+		"DiscoverInstances",
+		"GetInstance",
+		"GetInstancesHealthStatus",
+		"GetNamespace",
+		"GetOperation",
+		"GetService",
+		"ListInstances",
+		"ListNamespaces",
+		"ListOperations",
+		"ListServices",
+		"ListTagsForResource",
+		"RegisterInstance",
+		"TagResource",
+		"UntagResource",
+		"UpdateInstanceCustomHealthStatus",
+		"UpdateService",
+	},
+	"servicequotas": {
+		"AssociateServiceQuotaTemplate",
+		"DeleteServiceQuotaIncreaseRequestFromTemplate",
+		"DisassociateServiceQuotaTemplate",
+		"GetAWSDefaultServiceQuota",
+		"GetAssociationForServiceQuotaTemplate",
+		"GetRequestedServiceQuotaChange",
+		"GetServiceQuota",
+		"GetServiceQuotaIncreaseRequestFromTemplate",
+		"ListAWSDefaultServiceQuotas",
+		"ListRequestedServiceQuotaChangeHistory",
+		"ListRequestedServiceQuotaChangeHistoryByQuota",
+		"ListServiceQuotaIncreaseRequestsInTemplate",
+		"ListServiceQuotas",
+		"ListServices",
+		"ListTagsForResource",
+		"PutServiceQuotaIncreaseRequestIntoTemplate",
+		"RequestServiceQuotaIncrease",
+		"TagResource",
+		"UntagResource",
+	},
+	"ses": {
+		"CloneReceiptRuleSet",
+		"CreateConfigurationSet",
+		"CreateConfigurationSetEventDestination",
+		"CreateConfigurationSetTrackingOptions",
+		"CreateContact",
+		"CreateContactList",
+		"CreateCustomVerificationEmailTemplate",
+		"CreateDedicatedIpPool",
+		"CreateDeliverabilityTestReport",
+		"CreateEmailIdentity",
+		"CreateEmailIdentityPolicy",
+		"CreateEmailTemplate",
+		"CreateImportJob",
+		"CreateReceiptFilter",
+		"CreateReceiptRule",
+		"CreateReceiptRuleSet",
+		"CreateTemplate",
+		"DeleteConfigurationSet",
+		"DeleteConfigurationSetEventDestination",
+		"DeleteConfigurationSetTrackingOptions",
+		"DeleteContact",
+		"DeleteContactList",
+		"DeleteCustomVerificationEmailTemplate",
+		"DeleteDedicatedIpPool",
+		"DeleteEmailIdentity",
+		"DeleteEmailIdentityPolicy",
+		"DeleteEmailTemplate",
+		"DeleteIdentity",
+		"DeleteIdentityPolicy",
+		"DeleteReceiptFilter",
+		"DeleteReceiptRule",
+		"DeleteReceiptRuleSet",
+		"DeleteSuppressedDestination",
+		"DeleteTemplate",
+		"DeleteVerifiedEmailAddress",
+		"DescribeActiveReceiptRuleSet",
+		"DescribeConfigurationSet",
+		"DescribeReceiptRule",
+		"DescribeReceiptRuleSet",
+		"GetAccount",
+		"GetAccountSendingEnabled",
+		"GetBlacklistReports",
+		"GetConfigurationSet",
+		"GetConfigurationSetEventDestinations",
+		"GetContact",
+		"GetContactList",
+		"GetCustomVerificationEmailTemplate",
+		"GetDedicatedIp",
+		"GetDedicatedIps",
+		"GetDeliverabilityDashboardOptions",
+		"GetDeliverabilityTestReport",
+		"GetDomainDeliverabilityCampaign",
+		"GetDomainStatisticsReport",
+		"GetEmailIdentity",
+		"GetEmailIdentityPolicies",
+		"GetEmailTemplate",
+		"GetIdentityDkimAttributes",
+		"GetIdentityMailFromDomainAttributes",
+		"GetIdentityNotificationAttributes",
+		"GetIdentityPolicies",
+		"GetIdentityVerificationAttributes",
+		"GetImportJob",
+		"GetSendQuota",
+		"GetSendStatistics",
+		"GetSuppressedDestination",
+		"GetTemplate",
+		"ListConfigurationSets",
+		"ListContactLists",
+		"ListContacts",
+		"ListCustomVerificationEmailTemplates",
+		"ListDedicatedIpPools",
+		"ListDeliverabilityTestReports",
+		"ListDomainDeliverabilityCampaigns",
+		"ListEmailIdentities",
+		"ListEmailTemplates",
+		"ListIdentities",
+		"ListIdentityPolicies",
+		"ListImportJobs",
+		"ListReceiptFilters",
+		"ListReceiptRuleSets",
+		"ListSuppressedDestinations",
+		"ListTagsForResource",
+		"ListTemplates",
+		"ListVerifiedEmailAddresses",
+		"PutAccountDedicatedIpWarmupAttributes",
+		"PutAccountDetails",
+		"PutAccountSendingAttributes",
+		"PutAccountSuppressionAttributes",
+		"PutConfigurationSetDeliveryOptions",
+		"PutConfigurationSetReputationOptions",
+		"PutConfigurationSetSendingOptions",
+		"PutConfigurationSetSuppressionOptions",
+		"PutConfigurationSetTrackingOptions",
+		"PutDedicatedIpInPool",
+		"PutDedicatedIpWarmupAttributes",
+		"PutDeliverabilityDashboardOption",
+		"PutEmailIdentityConfigurationSetAttributes",
+		"PutEmailIdentityDkimAttributes",
+		"PutEmailIdentityDkimSigningAttributes",
+		"PutEmailIdentityFeedbackAttributes",
+		"PutEmailIdentityMailFromAttributes",
+		"PutIdentityPolicy",
+		"PutSuppressedDestination",
+		"ReorderReceiptRuleSet",
+		"SendBounce",
+		"SendBulkEmail",
+		"SendBulkTemplatedEmail",
+		"SendCustomVerificationEmail",
+		"SendEmail",
+		"SendRawEmail",
+		"SendTemplatedEmail",
+		"SetActiveReceiptRuleSet",
+		"SetIdentityDkimEnabled",
+		"SetIdentityFeedbackForwardingEnabled",
+		"SetIdentityHeadersInNotificationsEnabled",
+		"SetIdentityMailFromDomain",
+		"SetIdentityNotificationTopic",
+		"SetReceiptRulePosition",
+		"TagResource",
+		"TestRenderEmailTemplate",
+		"TestRenderTemplate",
+		"UntagResource",
+		"UpdateAccountSendingEnabled",
+		"UpdateConfigurationSetEventDestination",
+		"UpdateConfigurationSetReputationMetricsEnabled",
+		"UpdateConfigurationSetSendingEnabled",
+		"UpdateConfigurationSetTrackingOptions",
+		"UpdateContact",
+		"UpdateContactList",
+		"UpdateCustomVerificationEmailTemplate",
+		"UpdateEmailIdentityPolicy",
+		"UpdateEmailTemplate",
+		"UpdateReceiptRule",
+		"UpdateTemplate",
+		"VerifyDomainDkim",
+		"VerifyDomainIdentity",
+		"VerifyEmailAddress",
+		"VerifyEmailIdentity",
+	},
+	"shield": {
+		"AssociateDRTLogBucket",
+		"AssociateDRTRole",
+		"AssociateHealthCheck",
+		"AssociateProactiveEngagementDetails",
+		"CreateProtection",
+		"CreateProtectionGroup",
+		"CreateSubscription",
+		"DeleteProtection",
+		"DeleteProtectionGroup",
+		"DeleteSubscription",
+		"DescribeAttack",
+		"DescribeAttackStatistics",
+		"DescribeDRTAccess",
+		"DescribeEmergencyContactSettings",
+		"DescribeProtection",
+		"DescribeProtectionGroup",
+		"DescribeSubscription",
+		"DisableProactiveEngagement",
+		"DisassociateDRTLogBucket",
+		"DisassociateDRTRole",
+		"DisassociateHealthCheck",
+		"EnableProactiveEngagement",
+		"GetSubscriptionState",
+		"ListAttacks",
+		"ListProtectionGroups",
+		"ListProtections",
+		"ListResourcesInProtectionGroup",
+		"ListTagsForResource",
+		"TagResource",
+		"UntagResource",
+		"UpdateEmergencyContactSettings",
+		"UpdateProtectionGroup",
+		"UpdateSubscription",
+	},
+	"signer": {
+		"AddProfilePermission",
+		"CancelSigningProfile",
+		"DescribeSigningJob",
+		"GetSigningPlatform",
+		"GetSigningProfile",
+		"ListProfilePermissions",
+		"ListSigningJobs",
+		"ListSigningPlatforms",
+		"ListSigningProfiles",
+		"ListTagsForResource",
+		"PutSigningProfile",
+		"RemoveProfilePermission",
+		"RevokeSignature",
+		"RevokeSigningProfile",
+		"StartSigningJob",
+		"TagResource",
+		"UntagResource",
+	},
+	"sms": {
+		"CreateApp",
+		"CreateReplicationJob",
+		"DeleteApp",
+		"DeleteAppLaunchConfiguration",
+		"DeleteAppReplicationConfiguration",
+		"DeleteAppValidationConfiguration",
+		"DeleteReplicationJob",
+		"DeleteServerCatalog",
+		"DisassociateConnector",
+		"GenerateChangeSet",
+		"GenerateTemplate",
+		"GetApp",
+		"GetAppLaunchConfiguration",
+		"GetAppReplicationConfiguration",
+		"GetAppValidationConfiguration",
+		"GetAppValidationOutput",
+		"GetConnectors",
+		"GetReplicationJobs",
+		"GetReplicationRuns",
+		"GetServers",
+		"ImportAppCatalog",
+		"ImportServerCatalog",
+		"LaunchApp",
+		"ListApps",
+		"NotifyAppValidationOutput",
+		"PutAppLaunchConfiguration",
+		"PutAppReplicationConfiguration",
+		"PutAppValidationConfiguration",
+		"StartAppReplication",
+		"StartOnDemandAppReplication",
+		"StartOnDemandReplicationRun",
+		"StopAppReplication",
+		"TerminateApp",
+		"UpdateApp",
+		"UpdateReplicationJob",
+	},
+	"sms-voice": {
+		"CreateConfigurationSet",
+		"CreateConfigurationSetEventDestination",
+		"DeleteConfigurationSet",
+		"DeleteConfigurationSetEventDestination",
+		"GetConfigurationSetEventDestinations",
+		"ListConfigurationSets",
+		"SendVoiceMessage",
+		"UpdateConfigurationSetEventDestination",
+	},
+	"snowball": {
+		"CancelCluster",
+		"CancelJob",
+		"CreateAddress",
+		"CreateCluster",
+		"CreateJob",
+		"CreateReturnShippingLabel",
+		"DescribeAddress",
+		"DescribeAddresses",
+		"DescribeCluster",
+		"DescribeJob",
+		"DescribeReturnShippingLabel",
+		"GetJobManifest",
+		"GetJobUnlockCode",
+		"GetSnowballUsage",
+		"GetSoftwareUpdates",
+		"ListClusterJobs",
+		"ListClusters",
+		"ListCompatibleImages",
+		"ListJobs",
+		"UpdateCluster",
+		"UpdateJob",
+		"UpdateJobShipmentState",
+	},
+	"sns": {
+		"AddPermission",
+		"CheckIfPhoneNumberIsOptedOut",
+		"ConfirmSubscription",
+		"CreatePlatformApplication",
+		"CreatePlatformEndpoint",
+		"CreateTopic",
+		"DeleteEndpoint",
+		"DeletePlatformApplication",
+		"DeleteTopic",
+		"GetEndpointAttributes",
+		"GetPlatformApplicationAttributes",
+		"GetSMSAttributes",
+		"GetSubscriptionAttributes",
+		"GetTopicAttributes",
+		"ListEndpointsByPlatformApplication",
+		"ListPhoneNumbersOptedOut",
+		"ListPlatformApplications",
+		"ListSubscriptions",
+		"ListSubscriptionsByTopic",
+		"ListTagsForResource",
+		"ListTopics",
+		"OptInPhoneNumber",
+		"Publish",
+		"RemovePermission",
+		"SetEndpointAttributes",
+		"SetPlatformApplicationAttributes",
+		"SetSMSAttributes",
+		"SetSubscriptionAttributes",
+		"SetTopicAttributes",
+		"Subscribe",
+		"TagResource",
+		"Unsubscribe",
+		"UntagResource",
+	},
+	"sqs": {
+		"AddPermission",
+		"ChangeMessageVisibility",
+		"ChangeMessageVisibilityBatch",
+		"CreateQueue",
+		"DeleteMessage",
+		"DeleteMessageBatch",
+		"DeleteQueue",
+		"GetQueueAttributes",
+		"GetQueueUrl",
+		"ListDeadLetterSourceQueues",
+		"ListQueueTags",
+		"ListQueues",
+		"PurgeQueue",
+		"ReceiveMessage",
+		"RemovePermission",
+		"SendMessage",
+		"SendMessageBatch",
+		"SetQueueAttributes",
+		"TagQueue",
+		"UntagQueue",
+	},
+	"ssm": {
+		"AddTagsToResource",
+		"CancelCommand",
+		"CancelMaintenanceWindowExecution",
+		"CreateActivation",
+		"CreateAssociation",
+		"CreateAssociationBatch",
+		"CreateDocument",
+		"CreateMaintenanceWindow",
+		"CreateOpsItem",
+		"CreateOpsMetadata",
+		"CreatePatchBaseline",
+		"CreateResourceDataSync",
+		"DeleteActivation",
+		"DeleteAssociation",
+		"DeleteDocument",
+		"DeleteInventory",
+		"DeleteMaintenanceWindow",
+		"DeleteOpsMetadata",
+		"DeleteParameter",
+		"DeleteParameters",
+		"DeletePatchBaseline",
+		"DeleteResourceDataSync",
+		"DeregisterManagedInstance",
+		"DeregisterPatchBaselineForPatchGroup",
+		"DeregisterTargetFromMaintenanceWindow",
+		"DeregisterTaskFromMaintenanceWindow",
+		"DescribeActivations",
+		"DescribeAssociation",
+		"DescribeAssociationExecutionTargets",
+		"DescribeAssociationExecutions",
+		"DescribeAutomationExecutions",
+		"DescribeAutomationStepExecutions",
+		"DescribeAvailablePatches",
+		"DescribeDocument",
+		"DescribeDocumentPermission",
+		"DescribeEffectiveInstanceAssociations",
+		"DescribeEffectivePatchesForPatchBaseline",
+		"DescribeInstanceAssociationsStatus",
+		"DescribeInstanceInformation",
+		"DescribeInstancePatchStates",
+		"DescribeInstancePatchStatesForPatchGroup",
+		"DescribeInstancePatches",
+		"DescribeInventoryDeletions",
+		"DescribeMaintenanceWindowExecutionTaskInvocations",
+		"DescribeMaintenanceWindowExecutionTasks",
+		"DescribeMaintenanceWindowExecutions",
+		"DescribeMaintenanceWindowSchedule",
+		"DescribeMaintenanceWindowTargets",
+		"DescribeMaintenanceWindowTasks",
+		"DescribeMaintenanceWindows",
+		"DescribeMaintenanceWindowsForTarget",
+		"DescribeOpsItems",
+		"DescribeParameters",
+		"DescribePatchBaselines",
+		"DescribePatchGroupState",
+		"DescribePatchGroups",
+		"DescribePatchProperties",
+		"DescribeSessions",
+		"GetAutomationExecution",
+		"GetCalendarState",
+		"GetCommandInvocation",
+		"GetConnectionStatus",
+		"GetDefaultPatchBaseline",
+		"GetDeployablePatchSnapshotForInstance",
+		"GetDocument",
+		"GetInventory",
+		"GetInventorySchema",
+		"GetMaintenanceWindow",
+		"GetMaintenanceWindowExecution",
+		"GetMaintenanceWindowExecutionTask",
+		"GetMaintenanceWindowExecutionTaskInvocation",
+		"GetMaintenanceWindowTask",
+		"GetOpsItem",
+		"GetOpsMetadata",
+		"GetOpsSummary",
+		"GetParameter",
+		"GetParameterHistory",
+		"GetParameters",
+		"GetParametersByPath",
+		"GetPatchBaseline",
+		"GetPatchBaselineForPatchGroup",
+		"GetServiceSetting",
+		"LabelParameterVersion",
+		"ListAssociationVersions",
+		"ListAssociations",
+		"ListCommandInvocations",
+		"ListCommands",
+		"ListComplianceItems",
+		"ListComplianceSummaries",
+		"ListDocumentMetadataHistory",
+		"ListDocumentVersions",
+		"ListDocuments",
+		"ListInventoryEntries",
+		"ListOpsItemEvents",
+		"ListOpsMetadata",
+		"ListResourceComplianceSummaries",
+		"ListResourceDataSync",
+		"ListTagsForResource",
+		"ModifyDocumentPermission",
+		"PutComplianceItems",
+		"PutInventory",
+		"PutParameter",
+		"RegisterDefaultPatchBaseline",
+		"RegisterPatchBaselineForPatchGroup",
+		"RegisterTargetWithMaintenanceWindow",
+		"RegisterTaskWithMaintenanceWindow",
+		"RemoveTagsFromResource",
+		"ResetServiceSetting",
+		"ResumeSession",
+		"SendAutomationSignal",
+		"SendCommand",
+		"StartAssociationsOnce",
+		"StartAutomationExecution",
+		"StartChangeRequestExecution",
+		"StartSession",
+		"StopAutomationExecution",
+		"TerminateSession",
+		"UpdateAssociation",
+		"UpdateAssociationStatus",
+		"UpdateDocument",
+		"UpdateDocumentDefaultVersion",
+		"UpdateDocumentMetadata",
+		"UpdateMaintenanceWindow",
+		"UpdateMaintenanceWindowTarget",
+		"UpdateMaintenanceWindowTask",
+		"UpdateManagedInstanceRole",
+		"UpdateOpsItem",
+		"UpdateOpsMetadata",
+		"UpdatePatchBaseline",
+		"UpdateResourceDataSync",
+		"UpdateServiceSetting",
+	},
+	"ssmmessages": {
+		"CreateControlChannel",
+		"CreateDataChannel",
+		"OpenControlChannel",
+		"OpenDataChannel",
+	},
+	"sso": {
+		"AttachManagedPolicyToPermissionSet",
+		"CreateAccountAssignment",
+		"CreateInstanceAccessControlAttributeConfiguration",
+		"CreatePermissionSet",
+		"DeleteAccountAssignment",
+		"DeleteInlinePolicyFromPermissionSet",
+		"DeleteInstanceAccessControlAttributeConfiguration",
+		"DeletePermissionSet",
+		"DescribeAccountAssignmentCreationStatus",
+		"DescribeAccountAssignmentDeletionStatus",
+		"DescribeInstanceAccessControlAttributeConfiguration",
+		"DescribePermissionSet",
+		"DescribePermissionSetProvisioningStatus",
+		"DetachManagedPolicyFromPermissionSet",
+		"GetInlinePolicyForPermissionSet",
+		"GetRoleCredentials",
+		"ListAccountAssignmentCreationStatus",
+		"ListAccountAssignmentDeletionStatus",
+		"ListAccountAssignments",
+		"ListAccountRoles",
+		"ListAccounts",
+		"ListAccountsForProvisionedPermissionSet",
+		"ListInstances",
+		"ListManagedPoliciesInPermissionSet",
+		"ListPermissionSetProvisioningStatus",
+		"ListPermissionSets",
+		"ListPermissionSetsProvisionedToAccount",
+		"ListTagsForResource",
+		"Logout",
+		"ProvisionPermissionSet",
+		"PutInlinePolicyToPermissionSet",
+		"TagResource",
+		"UntagResource",
+		"UpdateInstanceAccessControlAttributeConfiguration",
+		"UpdatePermissionSet",
+	},
+	"sso-oauth": {
+		"CreateToken",
+		"RegisterClient",
+		"StartDeviceAuthorization",
+	},
+	"states": {
+		"CreateActivity",
+		"CreateStateMachine",
+		"DeleteActivity",
+		"DeleteStateMachine",
+		"DescribeActivity",
+		"DescribeExecution",
+		"DescribeStateMachine",
+		"DescribeStateMachineForExecution",
+		"GetActivityTask",
+		"GetExecutionHistory",
+		"ListActivities",
+		"ListExecutions",
+		"ListStateMachines",
+		"ListTagsForResource",
+		"SendTaskFailure",
+		"SendTaskHeartbeat",
+		"SendTaskSuccess",
+		"StartExecution",
+		"StartSyncExecution",
+		"StopExecution",
+		"TagResource",
+		"UntagResource",
+		"UpdateStateMachine",
+	},
+	"storagegateway": {
+		"ActivateGateway",
+		"AddCache",
+		"AddTagsToResource",
+		"AddUploadBuffer",
+		"AddWorkingStorage",
+		"AssignTapePool",
+		"AttachVolume",
+		"CancelArchival",
+		"CancelRetrieval",
+		"CreateCachediSCSIVolume",
+		"CreateNFSFileShare",
+		"CreateSMBFileShare",
+		"CreateSnapshot",
+		"CreateSnapshotFromVolumeRecoveryPoint",
+		"CreateStorediSCSIVolume",
+		"CreateTapePool",
+		"CreateTapeWithBarcode",
+		"CreateTapes",
+		"DeleteAutomaticTapeCreationPolicy",
+		"DeleteBandwidthRateLimit",
+		"DeleteChapCredentials",
+		"DeleteFileShare",
+		"DeleteGateway",
+		"DeleteSnapshotSchedule",
+		"DeleteTape",
+		"DeleteTapeArchive",
+		"DeleteTapePool",
+		"DeleteVolume",
+		"DescribeAvailabilityMonitorTest",
+		"DescribeBandwidthRateLimit",
+		"DescribeBandwidthRateLimitSchedule",
+		"DescribeCache",
+		"DescribeCachediSCSIVolumes",
+		"DescribeChapCredentials",
+		"DescribeGatewayInformation",
+		"DescribeMaintenanceStartTime",
+		"DescribeNFSFileShares",
+		"DescribeSMBFileShares",
+		"DescribeSMBSettings",
+		"DescribeSnapshotSchedule",
+		"DescribeStorediSCSIVolumes",
+		"DescribeTapeArchives",
+		"DescribeTapeRecoveryPoints",
+		"DescribeTapes",
+		"DescribeUploadBuffer",
+		"DescribeVTLDevices",
+		"DescribeWorkingStorage",
+		"DetachVolume",
+		"DisableGateway",
+		"JoinDomain",
+		"ListAutomaticTapeCreationPolicies",
+		"ListFileShares",
+		"ListGateways",
+		"ListLocalDisks",
+		"ListTagsForResource",
+		"ListTapePools",
+		"ListTapes",
+		"ListVolumeInitiators",
+		"ListVolumeRecoveryPoints",
+		"ListVolumes",
+		"NotifyWhenUploaded",
+		"RefreshCache",
+		"RemoveTagsFromResource",
+		"ResetCache",
+		"RetrieveTapeArchive",
+		"RetrieveTapeRecoveryPoint",
+		"SetLocalConsolePassword",
+		"SetSMBGuestPassword",
+		"ShutdownGateway",
+		"StartAvailabilityMonitorTest",
+		"StartGateway",
+		"UpdateAutomaticTapeCreationPolicy",
+		"UpdateBandwidthRateLimit",
+		"UpdateBandwidthRateLimitSchedule",
+		"UpdateChapCredentials",
+		"UpdateGatewayInformation",
+		"UpdateGatewaySoftwareNow",
+		"UpdateMaintenanceStartTime",
+		"UpdateNFSFileShare",
+		"UpdateSMBFileShare",
+		"UpdateSMBFileShareVisibility",
+		"UpdateSMBSecurityStrategy",
+		"UpdateSnapshotSchedule",
+		"UpdateVTLDeviceType",
+	},
+	"sts": {
+		"AssumeRole",
+		"AssumeRoleWithSAML",
+		"AssumeRoleWithWebIdentity",
+		"DecodeAuthorizationMessage",
+		"GetAccessKeyInfo",
+		"GetCallerIdentity",
+		"GetFederationToken",
+		"GetServiceBearerToken",
+		"GetSessionToken",
+		"SetSourceIdentity",
+		"TagSession",
+	},
+	"support": {
+		"AddAttachmentsToSet",
+		"AddCommunicationToCase",
+		"CreateCase",
+		"DescribeAttachment",
+		"DescribeCases",
+		"DescribeCommunications",
+		"DescribeServices",
+		"DescribeSeverityLevels",
+		"DescribeTrustedAdvisorCheckRefreshStatuses",
+		"DescribeTrustedAdvisorCheckResult",
+		"DescribeTrustedAdvisorCheckSummaries",
+		"DescribeTrustedAdvisorChecks",
+		"RefreshTrustedAdvisorCheck",
+		"ResolveCase",
+	},
+	"swf": {
+		"CountClosedWorkflowExecutions",
+		"CountOpenWorkflowExecutions",
+		"CountPendingActivityTasks",
+		"CountPendingDecisionTasks",
+		"DeprecateActivityType",
+		"DeprecateDomain",
+		"DeprecateWorkflowType",
+		"DescribeActivityType",
+		"DescribeDomain",
+		"DescribeWorkflowExecution",
+		"DescribeWorkflowType",
+		"GetWorkflowExecutionHistory",
+		"ListActivityTypes",
+		"ListClosedWorkflowExecutions",
+		"ListDomains",
+		"ListOpenWorkflowExecutions",
+		"ListTagsForResource",
+		"ListWorkflowTypes",
+		"PollForActivityTask",
+		"PollForDecisionTask",
+		"RecordActivityTaskHeartbeat",
+		"RegisterActivityType",
+		"RegisterDomain",
+		"RegisterWorkflowType",
+		"RequestCancelWorkflowExecution",
+		"RespondActivityTaskCanceled",
+		"RespondActivityTaskCompleted",
+		"RespondActivityTaskFailed",
+		"RespondDecisionTaskCompleted",
+		"SignalWorkflowExecution",
+		"StartWorkflowExecution",
+		"TagResource",
+		"TerminateWorkflowExecution",
+		"UndeprecateActivityType",
+		"UndeprecateDomain",
+		"UndeprecateWorkflowType",
+		"UntagResource",
+	},
+	"synthetics": {
+		"CreateCanary",
+		"DeleteCanary",
+		"DescribeCanaries",
+		"DescribeCanariesLastRun",
+		"DescribeRuntimeVersions",
+		"GetCanary",
+		"GetCanaryRuns",
+		"ListTagsForResource",
+		"StartCanary",
+		"StopCanary",
+		"TagResource",
+		"UntagResource",
+		"UpdateCanary",
+	},
+	"tag": {
+		"DescribeReportCreation",
+		"GetComplianceSummary",
+		"GetResources",
+		"GetTagKeys",
+		"GetTagValues",
+		"StartReportCreation",
+		"TagResources",
+		"UntagResources",
+	},
+	"textract": {
+		"AnalyzeDocument",
+		"DetectDocumentText",
+		"GetDocumentAnalysis",
+		"GetDocumentTextDetection",
+		"StartDocumentAnalysis",
+		"StartDocumentTextDetection",
+	},
+	"timestream": {
+		"CancelQuery",
+		"CreateDatabase",
+		"CreateTable",
+		"DeleteDatabase",
+		"DeleteTable",
+		"DescribeDatabase",
+		"DescribeEndpoints",
+		"DescribeTable",
+		"ListDatabases",
+		"ListTables",
+		"ListTagsForResource",
+		"Query",
+		"TagResource",
+		"UntagResource",
+		"UpdateDatabase",
+		"UpdateTable",
+		"WriteRecords",
+	},
+	"transcribe": {
+		"CreateLanguageModel",
+		"CreateMedicalVocabulary",
+		"CreateVocabulary",
+		"CreateVocabularyFilter",
+		"DeleteLanguageModel",
+		"DeleteMedicalTranscriptionJob",
+		"DeleteMedicalVocabulary",
+		"DeleteTranscriptionJob",
+		"DeleteVocabulary",
+		"DeleteVocabularyFilter",
+		"DescribeLanguageModel",
+		"GetMedicalTranscriptionJob",
+		"GetMedicalVocabulary",
+		"GetTranscriptionJob",
+		"GetVocabulary",
+		"GetVocabularyFilter",
+		"ListLanguageModels",
+		"ListMedicalTranscriptionJobs",
+		"ListMedicalVocabularies",
+		"ListTranscriptionJobs",
+		"ListVocabularies",
+		"ListVocabularyFilters",
+		"StartMedicalStreamTranscription",
+		"StartMedicalTranscriptionJob",
+		"StartStreamTranscription",
+		"StartTranscriptionJob",
+		"UpdateMedicalVocabulary",
+		"UpdateVocabulary",
+		"UpdateVocabularyFilter",
+	},
+	"transfer": {
+		"CreateServer",
+		"CreateUser",
+		"DeleteServer",
+		"DeleteSshPublicKey",
+		"DeleteUser",
+		"DescribeSecurityPolicy",
+		"DescribeServer",
+		"DescribeUser",
+		"ImportSshPublicKey",
+		"ListSecurityPolicies",
+		"ListServers",
+		"ListTagsForResource",
+		"ListUsers",
+		"StartServer",
+		"StopServer",
+		"TagResource",
+		"TestIdentityProvider",
+		"UntagResource",
+		"UpdateServer",
+		"UpdateUser",
+	},
+	"translate": {
+		"CreateParallelData",
+		"DeleteParallelData",
+		"DeleteTerminology",
+		"DescribeTextTranslationJob",
+		"GetParallelData",
+		"GetTerminology",
+		"ImportTerminology",
+		"ListParallelData",
+		"ListTerminologies",
+		"ListTextTranslationJobs",
+		"StartTextTranslationJob",
+		"StopTextTranslationJob",
+		"TranslateText",
+		"UpdateParallelData",
+	},
+	"waf": {
+		"CreateByteMatchSet",
+		"CreateGeoMatchSet",
+		"CreateIPSet",
+		"CreateRateBasedRule",
+		"CreateRegexMatchSet",
+		"CreateRegexPatternSet",
+		"CreateRule",
+		"CreateRuleGroup",
+		"CreateSizeConstraintSet",
+		"CreateSqlInjectionMatchSet",
+		"CreateWebACL",
+		"CreateWebACLMigrationStack",
+		"CreateXssMatchSet",
+		"DeleteByteMatchSet",
+		"DeleteGeoMatchSet",
+		"DeleteIPSet",
+		"DeleteLoggingConfiguration",
+		"DeletePermissionPolicy",
+		"DeleteRateBasedRule",
+		"DeleteRegexMatchSet",
+		"DeleteRegexPatternSet",
+		"DeleteRule",
+		"DeleteRuleGroup",
+		"DeleteSizeConstraintSet",
+		"DeleteSqlInjectionMatchSet",
+		"DeleteWebACL",
+		"DeleteXssMatchSet",
+		"GetByteMatchSet",
+		"GetChangeToken",
+		"GetChangeTokenStatus",
+		"GetGeoMatchSet",
+		"GetIPSet",
+		"GetLoggingConfiguration",
+		"GetPermissionPolicy",
+		"GetRateBasedRule",
+		"GetRateBasedRuleManagedKeys",
+		"GetRegexMatchSet",
+		"GetRegexPatternSet",
+		"GetRule",
+		"GetRuleGroup",
+		"GetSampledRequests",
+		"GetSizeConstraintSet",
+		"GetSqlInjectionMatchSet",
+		"GetWebACL",
+		"GetXssMatchSet",
+		"ListActivatedRulesInRuleGroup",
+		"ListByteMatchSets",
+		"ListGeoMatchSets",
+		"ListIPSets",
+		"ListLoggingConfigurations",
+		"ListRateBasedRules",
+		"ListRegexMatchSets",
+		"ListRegexPatternSets",
+		"ListRuleGroups",
+		"ListRules",
+		"ListSizeConstraintSets",
+		"ListSqlInjectionMatchSets",
+		"ListSubscribedRuleGroups",
+		"ListTagsForResource",
+		"ListWebACLs",
+		"ListXssMatchSets",
+		"PutLoggingConfiguration",
+		"PutPermissionPolicy",
+		"TagResource",
+		"UntagResource",
+		"UpdateByteMatchSet",
+		"UpdateGeoMatchSet",
+		"UpdateIPSet",
+		"UpdateRateBasedRule",
+		"UpdateRegexMatchSet",
+		"UpdateRegexPatternSet",
+		"UpdateRule",
+		"UpdateRuleGroup",
+		"UpdateSizeConstraintSet",
+		"UpdateSqlInjectionMatchSet",
+		"UpdateWebACL",
+		"UpdateXssMatchSet",
+	},
+	"waf-regional": {
+		"AssociateWebACL",
+		"CreateByteMatchSet",
+		"CreateGeoMatchSet",
+		"CreateIPSet",
+		"CreateRateBasedRule",
+		"CreateRegexMatchSet",
+		"CreateRegexPatternSet",
+		"CreateRule",
+		"CreateRuleGroup",
+		"CreateSizeConstraintSet",
+		"CreateSqlInjectionMatchSet",
+		"CreateWebACL",
+		"CreateWebACLMigrationStack",
+		"CreateXssMatchSet",
+		"DeleteByteMatchSet",
+		"DeleteGeoMatchSet",
+		"DeleteIPSet",
+		"DeleteLoggingConfiguration",
+		"DeletePermissionPolicy",
+		"DeleteRateBasedRule",
+		"DeleteRegexMatchSet",
+		"DeleteRegexPatternSet",
+		"DeleteRule",
+		"DeleteRuleGroup",
+		"DeleteSizeConstraintSet",
+		"DeleteSqlInjectionMatchSet",
+		"DeleteWebACL",
+		"DeleteXssMatchSet",
+		"DisassociateWebACL",
+		"GetByteMatchSet",
+		"GetChangeToken",
+		"GetChangeTokenStatus",
+		"GetGeoMatchSet",
+		"GetIPSet",
+		"GetLoggingConfiguration",
+		"GetPermissionPolicy",
+		"GetRateBasedRule",
+		"GetRateBasedRuleManagedKeys",
+		"GetRegexMatchSet",
+		"GetRegexPatternSet",
+		"GetRule",
+		"GetRuleGroup",
+		"GetSampledRequests",
+		"GetSizeConstraintSet",
+		"GetSqlInjectionMatchSet",
+		"GetWebACL",
+		"GetWebACLForResource",
+		"GetXssMatchSet",
+		"ListActivatedRulesInRuleGroup",
+		"ListByteMatchSets",
+		"ListGeoMatchSets",
+		"ListIPSets",
+		"ListLoggingConfigurations",
+		"ListRateBasedRules",
+		"ListRegexMatchSets",
+		"ListRegexPatternSets",
+		"ListResourcesForWebACL",
+		"ListRuleGroups",
+		"ListRules",
+		"ListSizeConstraintSets",
+		"ListSqlInjectionMatchSets",
+		"ListSubscribedRuleGroups",
+		"ListTagsForResource",
+		"ListWebACLs",
+		"ListXssMatchSets",
+		"PutLoggingConfiguration",
+		"PutPermissionPolicy",
+		"TagResource",
+		"UntagResource",
+		"UpdateByteMatchSet",
+		"UpdateGeoMatchSet",
+		"UpdateIPSet",
+		"UpdateRateBasedRule",
+		"UpdateRegexMatchSet",
+		"UpdateRegexPatternSet",
+		"UpdateRule",
+		"UpdateRuleGroup",
+		"UpdateSizeConstraintSet",
+		"UpdateSqlInjectionMatchSet",
+		"UpdateWebACL",
+		"UpdateXssMatchSet",
+	},
+	"wafv2": {
+		"AssociateWebACL",
+		"CheckCapacity",
+		"CreateIPSet",
+		"CreateRegexPatternSet",
+		"CreateRuleGroup",
+		"CreateWebACL",
+		"DeleteFirewallManagerRuleGroups",
+		"DeleteIPSet",
+		"DeleteLoggingConfiguration",
+		"DeletePermissionPolicy",
+		"DeleteRegexPatternSet",
+		"DeleteRuleGroup",
+		"DeleteWebACL",
+		"DescribeManagedRuleGroup",
+		"DisassociateWebACL",
+		"GetIPSet",
+		"GetLoggingConfiguration",
+		"GetPermissionPolicy",
+		"GetRateBasedStatementManagedKeys",
+		"GetRegexPatternSet",
+		"GetRuleGroup",
+		"GetSampledRequests",
+		"GetWebACL",
+		"GetWebACLForResource",
+		"ListAvailableManagedRuleGroups",
+		"ListIPSets",
+		"ListLoggingConfigurations",
+		"ListRegexPatternSets",
+		"ListResourcesForWebACL",
+		"ListRuleGroups",
+		"ListTagsForResource",
+		"ListWebACLs",
+		"PutLoggingConfiguration",
+		"PutPermissionPolicy",
+		"TagResource",
+		"UntagResource",
+		"UpdateIPSet",
+		"UpdateRegexPatternSet",
+		"UpdateRuleGroup",
+		"UpdateWebACL",
+	},
+	"wellarchitected": {
+		"AssociateLenses",
+		"CreateMilestone",
+		"CreateWorkload",
+		"CreateWorkloadShare",
+		"DeleteWorkload",
+		"DeleteWorkloadShare",
+		"DisassociateLenses",
+		"GetAnswer",
+		"GetLensReview",
+		"GetLensReviewReport",
+		"GetLensVersionDifference",
+		"GetMilestone",
+		"GetWorkload",
+		"ListAnswers",
+		"ListLensReviewImprovements",
+		"ListLensReviews",
+		"ListLenses",
+		"ListMilestones",
+		"ListNotifications",
+		"ListShareInvitations",
+		"ListTagsForResource",
+		"ListWorkloadShares",
+		"ListWorkloads",
+		"TagResource",
+		"UntagResource",
+		"UpdateAnswer",
+		"UpdateLensReview",
+		"UpdateShareInvitation",
+		"UpdateWorkload",
+		"UpdateWorkloadShare",
+		"UpgradeLensReview",
+	},
+	"workdocs": {
+		"AbortDocumentVersionUpload",
+		"ActivateUser",
+		"AddResourcePermissions",
+		"CreateComment",
+		"CreateCustomMetadata",
+		"CreateFolder",
+		"CreateLabels",
+		"CreateNotificationSubscription",
+		"CreateUser",
+		"DeactivateUser",
+		"DeleteComment",
+		"DeleteCustomMetadata",
+		"DeleteDocument",
+		"DeleteFolder",
+		"DeleteFolderContents",
+		"DeleteLabels",
+		"DeleteNotificationSubscription",
+		"DeleteUser",
+		"DescribeActivities",
+		"DescribeComments",
+		"DescribeDocumentVersions",
+		"DescribeFolderContents",
+		"DescribeGroups",
+		"DescribeNotificationSubscriptions",
+		"DescribeResourcePermissions",
+		"DescribeRootFolders",
+		"DescribeUsers",
+		"GetCurrentUser",
+		"GetDocument",
+		"GetDocumentPath",
+		"GetDocumentVersion",
+		"GetFolder",
+		"GetFolderPath",
+		"GetResources",
+		"InitiateDocumentVersionUpload",
+		"RemoveAllResourcePermissions",
+		"RemoveResourcePermission",
+		"UpdateDocument",
+		"UpdateDocumentVersion",
+		"UpdateFolder",
+		"UpdateUser",
+	},
+	"worklink": {
+		"AssociateDomain",
+		"AssociateWebsiteAuthorizationProvider",
+		"AssociateWebsiteCertificateAuthority",
+		"CreateFleet",
+		"DeleteFleet",
+		"DescribeAuditStreamConfiguration",
+		"DescribeCompanyNetworkConfiguration",
+		"DescribeDevice",
+		"DescribeDevicePolicyConfiguration",
+		"DescribeDomain",
+		"DescribeFleetMetadata",
+		"DescribeIdentityProviderConfiguration",
+		"DescribeWebsiteCertificateAuthority",
+		"DisassociateDomain",
+		"DisassociateWebsiteAuthorizationProvider",
+		"DisassociateWebsiteCertificateAuthority",
+		"ListDevices",
+		"ListDomains",
+		"ListFleets",
+		"ListTagsForResource",
+		"ListWebsiteAuthorizationProviders",
+		"ListWebsiteCertificateAuthorities",
+		"RestoreDomainAccess",
+		"RevokeDomainAccess",
+		"SignOutUser",
+		"TagResource",
+		"UntagResource",
+		"UpdateAuditStreamConfiguration",
+		"UpdateCompanyNetworkConfiguration",
+		"UpdateDevicePolicyConfiguration",
+		"UpdateDomainMetadata",
+		"UpdateFleetMetadata",
+		"UpdateIdentityProviderConfiguration",
+	},
+	"workmail": {
+		"AssociateDelegateToResource",
+		"AssociateMemberToGroup",
+		"CancelMailboxExportJob",
+		"CreateAlias",
+		"CreateGroup",
+		"CreateOrganization",
+		"CreateResource",
+		"CreateUser",
+		"DeleteAccessControlRule",
+		"DeleteAlias",
+		"DeleteGroup",
+		"DeleteMailboxPermissions",
+		"DeleteOrganization",
+		"DeleteResource",
+		"DeleteRetentionPolicy",
+		"DeleteUser",
+		"DeregisterFromWorkMail",
+		"DescribeGroup",
+		"DescribeMailboxExportJob",
+		"DescribeOrganization",
+		"DescribeResource",
+		"DescribeUser",
+		"DisassociateDelegateFromResource",
+		"DisassociateMemberFromGroup",
+		"GetAccessControlEffect",
+		"GetDefaultRetentionPolicy",
+		"GetMailboxDetails",
+		"ListAccessControlRules",
+		"ListAliases",
+		"ListGroupMembers",
+		"ListGroups",
+		"ListMailboxExportJobs",
+		"ListMailboxPermissions",
+		"ListOrganizations",
+		"ListResourceDelegates",
+		"ListResources",
+		"ListTagsForResource",
+		"ListUsers",
+		"PutAccessControlRule",
+		"PutMailboxPermissions",
+		"PutRetentionPolicy",
+		"RegisterToWorkMail",
+		"ResetPassword",
+		"StartMailboxExportJob",
+		"TagResource",
+		"UntagResource",
+		"UpdateMailboxQuota",
+		"UpdatePrimaryEmailAddress",
+		"UpdateResource",
+	},
+	"workmailmessageflow": {
+		"GetRawMessageContent",
+		"PutRawMessageContent",
+	},
+	"workspaces": {
+		"AssociateConnectionAlias",
+		"AssociateIpGroups",
+		"AuthorizeIpRules",
+		"CopyWorkspaceImage",
+		"CreateConnectionAlias",
+		"CreateIpGroup",
+		"CreateTags",
+		"CreateWorkspaces",
+		"DeleteConnectionAlias",
+		"DeleteIpGroup",
+		"DeleteTags",
+		"DeleteWorkspaceImage",
+		"DeregisterWorkspaceDirectory",
+		"DescribeAccount",
+		"DescribeAccountModifications",
+		"DescribeClientProperties",
+		"DescribeConnectionAliasPermissions",
+		"DescribeConnectionAliases",
+		"DescribeIpGroups",
+		"DescribeTags",
+		"DescribeWorkspaceBundles",
+		"DescribeWorkspaceDirectories",
+		"DescribeWorkspaceImagePermissions",
+		"DescribeWorkspaceImages",
+		"DescribeWorkspaceSnapshots",
+		"DescribeWorkspaces",
+		"DescribeWorkspacesConnectionStatus",
+		"DisassociateConnectionAlias",
+		"DisassociateIpGroups",
+		"ImportWorkspaceImage",
+		"ListAvailableManagementCidrRanges",
+		"MigrateWorkspace",
+		"ModifyAccount",
+		"ModifyClientProperties",
+		"ModifySelfservicePermissions",
+		"ModifyWorkspaceAccessProperties",
+		"ModifyWorkspaceCreationProperties",
+		"ModifyWorkspaceProperties",
+		"ModifyWorkspaceState",
+		"RebootWorkspaces",
+		"RebuildWorkspaces",
+		"RegisterWorkspaceDirectory",
+		"RestoreWorkspace",
+		"RevokeIpRules",
+		"StartWorkspaces",
+		"StopWorkspaces",
+		"TerminateWorkspaces",
+		"UpdateConnectionAliasPermission",
+		"UpdateRulesOfIpGroup",
+		"UpdateWorkspaceImagePermission",
+	},
+	"xray": {
+		"BatchGetTraces",
+		"CreateGroup",
+		"CreateSamplingRule",
+		"DeleteGroup",
+		"DeleteSamplingRule",
+		"GetEncryptionConfig",
+		"GetGroup",
+		"GetGroups",
+		"GetInsight",
+		"GetInsightEvents",
+		"GetInsightImpactGraph",
+		"GetInsightSummaries",
+		"GetSamplingRules",
+		"GetSamplingStatisticSummaries",
+		"GetSamplingTargets",
+		"GetServiceGraph",
+		"GetTimeSeriesServiceStatistics",
+		"GetTraceGraph",
+		"GetTraceSummaries",
+		"ListTagsForResource",
+		"PutEncryptionConfig",
+		"PutTelemetryRecords",
+		"PutTraceSegments",
+		"TagResource",
+		"UntagResource",
+		"UpdateGroup",
+		"UpdateSamplingRule",
+	},
+}
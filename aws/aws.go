@@ -1,11 +1,14 @@
 package aws
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"time"
 
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	"github.com/VoodooTeam/irsa-operator/controllers"
@@ -16,40 +19,61 @@ import (
 	"github.com/aws/aws-sdk-go/service/iam"
 
 	"github.com/go-logr/logr"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 type AwsPolicy struct {
+	Name      string
 	ARN       string
 	Statement []api.StatementSpec
+	Tags      map[string]string
 }
 
 type RealAwsManager struct {
 	Client          *iam.IAM
 	log             logr.Logger
-	clusterName     string
+	naming          api.Naming
 	oidcProviderArn string
+	pageSize        *int64        // MaxItems of the list calls, aws default if nil
+	callTimeout     time.Duration // max duration of each method call (retries included), no timeout if 0
 }
 
-func NewAwsManager(sess *session.Session, logger logr.Logger, cN, oidcProviderArn string) controllers.AwsManager {
+func NewAwsManager(sess *session.Session, logger logr.Logger, naming api.Naming, oidcProviderArn string, callTimeout time.Duration) controllers.AwsManager {
 	return &RealAwsManager{
 		Client:          iam.New(sess),
 		log:             logger,
-		clusterName:     cN,
+		naming:          naming,
 		oidcProviderArn: oidcProviderArn,
+		callTimeout:     callTimeout,
 	}
 }
 
 var desc = "created by the irsa-operator"
 
-func (m RealAwsManager) GetStatement(arn string) ([]api.StatementSpec, error) {
+func (m RealAwsManager) GetStatement(ctx context.Context, arn string) ([]api.StatementSpec, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	// we retrieve the defaultVersionID by getting the policy
-	res, err := m.Client.GetPolicy(&iam.GetPolicyInput{PolicyArn: &arn})
+	res, err := m.Client.GetPolicyWithContext(ctx, &iam.GetPolicyInput{PolicyArn: &arn})
 	if err != nil {
 		return nil, err
 	}
 
-	// we get the url-encoded document of the default version of the policy
-	resPV, err := m.Client.GetPolicyVersion(&iam.GetPolicyVersionInput{PolicyArn: &arn, VersionId: res.Policy.DefaultVersionId})
+	return m.getVersionStatement(ctx, arn, aws.StringValue(res.Policy.DefaultVersionId))
+}
+
+// GetPolicyVersionStatement returns the statements of a given version of the policy
+func (m RealAwsManager) GetPolicyVersionStatement(ctx context.Context, arn, versionID string) ([]api.StatementSpec, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	return m.getVersionStatement(ctx, arn, versionID)
+}
+
+func (m RealAwsManager) getVersionStatement(ctx context.Context, arn, versionID string) ([]api.StatementSpec, error) {
+	// we get the url-encoded document of the version of the policy
+	resPV, err := m.Client.GetPolicyVersionWithContext(ctx, &iam.GetPolicyVersionInput{PolicyArn: &arn, VersionId: &versionID})
 	if err != nil {
 		return nil, err
 	}
@@ -75,18 +99,71 @@ func (m RealAwsManager) GetStatement(arn string) ([]api.StatementSpec, error) {
 	return stmtSpecs, nil
 }
 
-func (m RealAwsManager) UpdatePolicy(policy api.Policy) error {
+// ListPolicyVersions returns the versions of the policy retained by IAM, from the oldest
+func (m RealAwsManager) ListPolicyVersions(ctx context.Context, arn string) ([]controllers.IamPolicyVersion, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	versions, err := m.listPolicyVersions(ctx, arn)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []controllers.IamPolicyVersion{}
+	for _, v := range versions {
+		out = append(out, controllers.IamPolicyVersion{
+			VersionID:  aws.StringValue(v.VersionId),
+			CreateDate: aws.TimeValue(v.CreateDate),
+			IsDefault:  aws.BoolValue(v.IsDefaultVersion),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreateDate.Before(out[j].CreateDate) })
+
+	return out, nil
+}
+
+// SetDefaultPolicyVersion makes an existing version the one used by the policy, no new version is created
+func (m RealAwsManager) SetDefaultPolicyVersion(ctx context.Context, arn, versionID string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.SetDefaultPolicyVersionWithContext(ctx, &iam.SetDefaultPolicyVersionInput{PolicyArn: &arn, VersionId: &versionID}); err != nil {
+		m.logExtErr(err, "failed to set the default policy version on aws")
+		return err
+	}
+
+	m.log.Info(fmt.Sprintf("policy (%s) default version set to %s on aws", arn, versionID))
+	return nil
+}
+
+// GetPolicyDefaultVersionID returns the version of the document currently used by the policy
+func (m RealAwsManager) GetPolicyDefaultVersionID(ctx context.Context, arn string) (string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	res, err := m.Client.GetPolicyWithContext(ctx, &iam.GetPolicyInput{PolicyArn: &arn})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(res.Policy.DefaultVersionId), nil
+}
+
+func (m RealAwsManager) UpdatePolicy(ctx context.Context, policy api.Policy) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	policyDoc, err := NewPolicyDocumentString(policy.Spec)
 	if err != nil {
 		m.logExtErr(err, "failed at policy serialization")
 		return err
 	}
 
-	if err := m.deleteOldestPolicyVersionIfNeeded(policy.Spec.ARN); err != nil {
+	if err := m.deleteOldestPolicyVersionIfNeeded(ctx, policy.Spec.ARN); err != nil {
 		return err
 	}
 
-	_, err = m.Client.CreatePolicyVersion(&iam.CreatePolicyVersionInput{PolicyArn: &policy.Spec.ARN, PolicyDocument: &policyDoc, SetAsDefault: aws.Bool(true)})
+	_, err = m.Client.CreatePolicyVersionWithContext(ctx, &iam.CreatePolicyVersionInput{PolicyArn: &policy.Spec.ARN, PolicyDocument: &policyDoc, SetAsDefault: aws.Bool(true)})
 	if err != nil {
 		return err
 	}
@@ -96,21 +173,21 @@ func (m RealAwsManager) UpdatePolicy(policy api.Policy) error {
 
 // deleteOldestPolicyVersionIfNeeded deletes the oldest policy version of a manage policy
 // if it is full, ie if it has already 5 versions
-func (m RealAwsManager) deleteOldestPolicyVersionIfNeeded(arn string) error {
-	res, err := m.Client.ListPolicyVersions(&iam.ListPolicyVersionsInput{PolicyArn: &arn})
+func (m RealAwsManager) deleteOldestPolicyVersionIfNeeded(ctx context.Context, arn string) error {
+	versions, err := m.listPolicyVersions(ctx, arn)
 	if err != nil {
 		return err
 	}
 
 	// no need to delete a version if we have less than 5
-	if len(res.Versions) < 5 {
+	if len(versions) < 5 {
 		return nil
 	}
 
 	// looking for the oldest non-default version
 	var oldest *iam.PolicyVersion
 
-	for _, pv := range res.Versions {
+	for _, pv := range versions {
 		if *pv.IsDefaultVersion {
 			continue
 		}
@@ -123,11 +200,14 @@ func (m RealAwsManager) deleteOldestPolicyVersionIfNeeded(arn string) error {
 		return nil
 	}
 
-	_, err = m.Client.DeletePolicyVersion(&iam.DeletePolicyVersionInput{PolicyArn: &arn, VersionId: oldest.VersionId})
+	_, err = m.Client.DeletePolicyVersionWithContext(ctx, &iam.DeletePolicyVersionInput{PolicyArn: &arn, VersionId: oldest.VersionId})
 	return err
 }
 
-func (m RealAwsManager) CreatePolicy(policy api.Policy) error {
+func (m RealAwsManager) CreatePolicy(ctx context.Context, policy api.Policy, tags map[string]string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("policy")
 
 	policyDoc, err := NewPolicyDocumentString(policy.Spec)
@@ -136,16 +216,20 @@ func (m RealAwsManager) CreatePolicy(policy api.Policy) error {
 		return err
 	}
 
-	pn := policy.AwsName(m.clusterName)
-	pp := policy.Path(m.clusterName)
+	pn, err := policy.AwsName(m.naming)
+	if err != nil {
+		return err
+	}
+	pp := policy.Path(m.naming)
 	input := &iam.CreatePolicyInput{
 		PolicyName:     &pn,
 		PolicyDocument: &policyDoc,
 		Description:    &desc,
 		Path:           &pp,
+		Tags:           toIamTags(tags),
 	}
 
-	if _, err := m.Client.CreatePolicy(input); err != nil {
+	if _, err := m.Client.CreatePolicyWithContext(ctx, input); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusConflict {
 				// already created, nothing to do
@@ -163,8 +247,59 @@ func (m RealAwsManager) CreatePolicy(policy api.Policy) error {
 	return nil
 }
 
-func (m RealAwsManager) PolicyExists(policyARN string) (bool, error) {
-	if _, err := m.Client.GetPolicy(&iam.GetPolicyInput{PolicyArn: &policyARN}); err != nil {
+func (m RealAwsManager) GetPolicyTags(ctx context.Context, policyARN string) (map[string]string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	tags := map[string]string{}
+	input := &iam.ListPolicyTagsInput{PolicyArn: &policyARN, MaxItems: m.pageSize}
+	for { // there's no ListPolicyTagsPages
+		res, err := m.Client.ListPolicyTagsWithContext(ctx, input)
+		if err != nil {
+			m.logExtErr(err, "failed to list policy tags on aws")
+			return nil, err
+		}
+
+		for k, v := range fromIamTags(res.Tags) {
+			tags[k] = v
+		}
+
+		if !aws.BoolValue(res.IsTruncated) {
+			return tags, nil
+		}
+		input.Marker = res.Marker
+	}
+}
+
+func (m RealAwsManager) TagPolicy(ctx context.Context, policyARN string, tags map[string]string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.TagPolicyWithContext(ctx, &iam.TagPolicyInput{PolicyArn: &policyARN, Tags: toIamTags(tags)}); err != nil {
+		m.logExtErr(err, "failed to tag policy on aws")
+		return err
+	}
+
+	return nil
+}
+
+func (m RealAwsManager) UntagPolicy(ctx context.Context, policyARN string, keys []string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.UntagPolicyWithContext(ctx, &iam.UntagPolicyInput{PolicyArn: &policyARN, TagKeys: aws.StringSlice(keys)}); err != nil {
+		m.logExtErr(err, "failed to untag policy on aws")
+		return err
+	}
+
+	return nil
+}
+
+func (m RealAwsManager) PolicyExists(ctx context.Context, policyARN string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.GetPolicyWithContext(ctx, &iam.GetPolicyInput{PolicyArn: &policyARN}); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
 				m.log.Info("policy doesnt exists on aws")
@@ -178,19 +313,21 @@ func (m RealAwsManager) PolicyExists(policyARN string) (bool, error) {
 }
 
 // Gets an aws policy on aws
-func (m RealAwsManager) GetPolicyARN(pathPrefix, uniqueName string) (string, error) {
+func (m RealAwsManager) GetPolicyARN(ctx context.Context, pathPrefix, uniqueName string) (string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("policy")
 
 	// we list the policies and try to find a match
-	out, err := m.Client.ListPolicies(&iam.ListPoliciesInput{PathPrefix: &pathPrefix})
+	policies, err := m.ListPolicies(ctx, pathPrefix)
 	if err != nil {
-		m.logExtErr(err, "failed to list policies on aws")
-		return "", nil
+		return "", err
 	}
 
-	for _, p := range out.Policies {
-		if *p.PolicyName == uniqueName {
-			return *p.Arn, nil
+	for _, p := range policies {
+		if p.Name == uniqueName {
+			return p.ARN, nil
 		}
 	}
 
@@ -198,10 +335,34 @@ func (m RealAwsManager) GetPolicyARN(pathPrefix, uniqueName string) (string, err
 	return "", nil
 }
 
-func (m RealAwsManager) DeletePolicy(policyARN string) error {
+func (m RealAwsManager) ListPolicies(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	policies := []controllers.IamResource{}
+	err := m.Client.ListPoliciesPagesWithContext(ctx,
+		&iam.ListPoliciesInput{PathPrefix: &pathPrefix, Scope: aws.String(iam.PolicyScopeTypeLocal), MaxItems: m.pageSize},
+		func(out *iam.ListPoliciesOutput, _ bool) bool {
+			for _, p := range out.Policies {
+				policies = append(policies, controllers.IamResource{Name: *p.PolicyName, ARN: *p.Arn})
+			}
+			return true
+		},
+	)
+	if err != nil {
+		m.logExtErr(err, "failed to list policies on aws")
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func (m RealAwsManager) DeletePolicy(ctx context.Context, policyARN string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
 
 	// we first ensure the policy isn't already deleted
-	if _, err := m.Client.GetPolicy(&iam.GetPolicyInput{PolicyArn: &policyARN}); err != nil {
+	if _, err := m.Client.GetPolicyWithContext(ctx, &iam.GetPolicyInput{PolicyArn: &policyARN}); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
 				// already deleted, nothing to do
@@ -209,12 +370,13 @@ func (m RealAwsManager) DeletePolicy(policyARN string) error {
 				return nil
 			}
 		}
+		return err
 	}
 
 	m.log.Info("found policy")
 
 	// list what the policy is attached to
-	listRes, err := m.Client.ListEntitiesForPolicy(&iam.ListEntitiesForPolicyInput{PolicyArn: &policyARN})
+	policyRoles, err := m.listEntitiesForPolicy(ctx, policyARN)
 	if err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
@@ -227,25 +389,23 @@ func (m RealAwsManager) DeletePolicy(policyARN string) error {
 	}
 	m.log.Info("policy found")
 
-	// detach the policy from the role
-	if len(listRes.PolicyRoles) > 1 {
-		// should be attached to a single role
-		// we're conservative and return an error
-		return errors.New("policy attached to several roles, not supposed to happen")
-	}
-
-	m.log.Info(fmt.Sprintf("policy attached to %d roles", len(listRes.PolicyRoles)))
-	for _, r := range listRes.PolicyRoles {
-		// we ignore the detach errors
-		_, err := m.Client.DetachRolePolicy(&iam.DetachRolePolicyInput{RoleName: r.RoleName, PolicyArn: &policyARN})
+	// detach the policy from the roles (a shared policy is only deleted once no irsa references it, the remaining attachments are stale)
+	m.log.Info(fmt.Sprintf("policy attached to %d roles", len(policyRoles)))
+	// a failed detach doesn't stop the others, the policy can't be deleted until all of them succeed anyway
+	detachErrs := []error{}
+	for _, r := range policyRoles {
+		_, err := m.Client.DetachRolePolicyWithContext(ctx, &iam.DetachRolePolicyInput{RoleName: r.RoleName, PolicyArn: &policyARN})
 		if err != nil {
 			m.logExtErr(err, "failed to detach policy from role")
-			return err
+			detachErrs = append(detachErrs, err)
 		}
 	}
+	if err := utilerrors.NewAggregate(detachErrs); err != nil {
+		return err
+	}
 
 	m.log.Info("policy will be deleted")
-	pvv, err := m.Client.ListPolicyVersions(&iam.ListPolicyVersionsInput{PolicyArn: &policyARN})
+	versions, err := m.listPolicyVersions(ctx, policyARN)
 	if err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
@@ -253,24 +413,23 @@ func (m RealAwsManager) DeletePolicy(policyARN string) error {
 				m.log.Info("policy already deleted on aws")
 				return nil
 			}
-		} else {
-			return err
 		}
+		return err
 	}
 
-	for _, pv := range pvv.Versions {
+	for _, pv := range versions {
 		if *pv.IsDefaultVersion {
 			continue
 		}
 		// we ignore potential errors, if we didn't manage to delete all non-default versions, the DeletePolicy below will fail
-		_, _ = m.Client.DeletePolicyVersion(&iam.DeletePolicyVersionInput{
+		_, _ = m.Client.DeletePolicyVersionWithContext(ctx, &iam.DeletePolicyVersionInput{
 			PolicyArn: &policyARN,
 			VersionId: pv.VersionId,
 		})
 	}
 
 	// actually delete policy
-	if _, err := m.Client.DeletePolicy(&iam.DeletePolicyInput{PolicyArn: &policyARN}); err != nil {
+	if _, err := m.Client.DeletePolicyWithContext(ctx, &iam.DeletePolicyInput{PolicyArn: &policyARN}); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
 				// already deleted, nothing to do
@@ -284,10 +443,47 @@ func (m RealAwsManager) DeletePolicy(policyARN string) error {
 	return nil
 }
 
-func (m RealAwsManager) RoleExists(roleName string) (bool, error) {
+func (m RealAwsManager) listPolicyVersions(ctx context.Context, policyARN string) ([]*iam.PolicyVersion, error) {
+	versions := []*iam.PolicyVersion{}
+	err := m.Client.ListPolicyVersionsPagesWithContext(ctx,
+		&iam.ListPolicyVersionsInput{PolicyArn: &policyARN, MaxItems: m.pageSize},
+		func(out *iam.ListPolicyVersionsOutput, _ bool) bool {
+			versions = append(versions, out.Versions...)
+			return true
+		},
+	)
+	if err != nil {
+		m.logExtErr(err, "failed to list policy versions on aws")
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (m RealAwsManager) listEntitiesForPolicy(ctx context.Context, policyARN string) ([]*iam.PolicyRole, error) {
+	roles := []*iam.PolicyRole{}
+	err := m.Client.ListEntitiesForPolicyPagesWithContext(ctx,
+		&iam.ListEntitiesForPolicyInput{PolicyArn: &policyARN, MaxItems: m.pageSize},
+		func(out *iam.ListEntitiesForPolicyOutput, _ bool) bool {
+			roles = append(roles, out.PolicyRoles...)
+			return true
+		},
+	)
+	if err != nil {
+		m.logExtErr(err, "failed to list entities for policy on aws")
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (m RealAwsManager) RoleExists(ctx context.Context, roleName string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("role")
 
-	res, err := m.Client.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+	res, err := m.Client.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: &roleName})
 	if err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
@@ -306,10 +502,13 @@ func (m RealAwsManager) RoleExists(roleName string) (bool, error) {
 	return res.Role != nil, nil
 }
 
-func (m RealAwsManager) GetRoleARN(roleName string) (string, error) {
+func (m RealAwsManager) GetRoleARN(ctx context.Context, roleName string) (string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("role")
 
-	res, err := m.Client.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+	res, err := m.Client.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: &roleName})
 	if err != nil {
 		return "", err
 	}
@@ -321,11 +520,23 @@ func (m RealAwsManager) GetRoleARN(roleName string) (string, error) {
 	return *res.Role.Arn, nil
 }
 
-func (m RealAwsManager) GetAttachedRolePoliciesARNs(roleName string) ([]string, error) {
+func (m RealAwsManager) GetAttachedRolePoliciesARNs(ctx context.Context, roleName string) ([]string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("role")
 
 	// let's get all the policies attached to the given role
-	res, err := m.Client.ListAttachedRolePolicies(&iam.ListAttachedRolePoliciesInput{RoleName: &roleName})
+	arns := []string{}
+	err := m.Client.ListAttachedRolePoliciesPagesWithContext(ctx,
+		&iam.ListAttachedRolePoliciesInput{RoleName: &roleName, MaxItems: m.pageSize},
+		func(out *iam.ListAttachedRolePoliciesOutput, _ bool) bool {
+			for _, p := range out.AttachedPolicies {
+				arns = append(arns, *p.PolicyArn)
+			}
+			return true
+		},
+	)
 	if err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound {
@@ -341,16 +552,35 @@ func (m RealAwsManager) GetAttachedRolePoliciesARNs(roleName string) ([]string,
 		return nil, err
 	}
 
-	// otherwise, we aggregate the policies ARNs
-	arns := []string{}
-	for _, p := range res.AttachedPolicies {
-		arns = append(arns, *p.PolicyArn)
+	return arns, nil
+}
+
+func (m RealAwsManager) ListRoles(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	roles := []controllers.IamResource{}
+	err := m.Client.ListRolesPagesWithContext(ctx,
+		&iam.ListRolesInput{PathPrefix: &pathPrefix, MaxItems: m.pageSize},
+		func(out *iam.ListRolesOutput, _ bool) bool {
+			for _, r := range out.Roles {
+				roles = append(roles, controllers.IamResource{Name: *r.RoleName, ARN: *r.Arn})
+			}
+			return true
+		},
+	)
+	if err != nil {
+		m.logExtErr(err, "failed to list roles on aws")
+		return nil, err
 	}
 
-	return arns, nil
+	return roles, nil
 }
 
-func (m RealAwsManager) CreateRole(role api.Role, permissionsBoundariesPolicyARN string) error {
+func (m RealAwsManager) CreateRole(ctx context.Context, role api.Role, permissionsBoundariesPolicyARN string, tags map[string]string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("role")
 
 	roleDoc, err := NewAssumeRolePolicyDoc(role, m.oidcProviderArn)
@@ -359,17 +589,23 @@ func (m RealAwsManager) CreateRole(role api.Role, permissionsBoundariesPolicyARN
 		return err
 	}
 
-	rn := role.AwsName(m.clusterName)
+	rn, err := role.AwsName(m.naming)
+	if err != nil {
+		return err
+	}
+	rp := role.Path(m.naming)
 	roleInput := &iam.CreateRoleInput{
 		RoleName:                 &rn,
+		Path:                     &rp,
 		AssumeRolePolicyDocument: &roleDoc,
 		Description:              &desc,
+		Tags:                     toIamTags(tags),
 	}
 	if permissionsBoundariesPolicyARN != "" {
 		roleInput.PermissionsBoundary = &permissionsBoundariesPolicyARN
 	}
 
-	if _, err := m.Client.CreateRole(roleInput); err != nil {
+	if _, err := m.Client.CreateRoleWithContext(ctx, roleInput); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusConflict {
 				// the role already exists, we return without error
@@ -386,8 +622,83 @@ func (m RealAwsManager) CreateRole(role api.Role, permissionsBoundariesPolicyARN
 	return nil
 }
 
-func (m RealAwsManager) DetachRolePolicy(roleName, policyARN string) error {
-	if _, err := m.Client.DetachRolePolicy(&iam.DetachRolePolicyInput{RoleName: &roleName, PolicyArn: &policyARN}); err != nil {
+// UpdateAssumeRolePolicy replaces the trust policy of the role by the one matching its spec
+func (m RealAwsManager) UpdateAssumeRolePolicy(ctx context.Context, role api.Role) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	roleDoc, err := NewAssumeRolePolicyDoc(role, m.oidcProviderArn)
+	if err != nil {
+		m.logExtErr(err, "failed at trust policy serialization")
+		return err
+	}
+
+	rn, err := role.AwsName(m.naming)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Client.UpdateAssumeRolePolicyWithContext(ctx, &iam.UpdateAssumeRolePolicyInput{RoleName: &rn, PolicyDocument: &roleDoc}); err != nil {
+		m.logExtErr(err, "failed to update trust role policy")
+		return err
+	}
+
+	m.log.Info(fmt.Sprintf("successfully updated trust role policy (%s) on aws", rn))
+	return nil
+}
+
+func (m RealAwsManager) GetRoleTags(ctx context.Context, roleName string) (map[string]string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	tags := map[string]string{}
+	input := &iam.ListRoleTagsInput{RoleName: &roleName, MaxItems: m.pageSize}
+	for { // there's no ListRoleTagsPages
+		res, err := m.Client.ListRoleTagsWithContext(ctx, input)
+		if err != nil {
+			m.logExtErr(err, "failed to list role tags on aws")
+			return nil, err
+		}
+
+		for k, v := range fromIamTags(res.Tags) {
+			tags[k] = v
+		}
+
+		if !aws.BoolValue(res.IsTruncated) {
+			return tags, nil
+		}
+		input.Marker = res.Marker
+	}
+}
+
+func (m RealAwsManager) TagRole(ctx context.Context, roleName string, tags map[string]string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.TagRoleWithContext(ctx, &iam.TagRoleInput{RoleName: &roleName, Tags: toIamTags(tags)}); err != nil {
+		m.logExtErr(err, "failed to tag role on aws")
+		return err
+	}
+
+	return nil
+}
+
+func (m RealAwsManager) UntagRole(ctx context.Context, roleName string, keys []string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.UntagRoleWithContext(ctx, &iam.UntagRoleInput{RoleName: &roleName, TagKeys: aws.StringSlice(keys)}); err != nil {
+		m.logExtErr(err, "failed to untag role on aws")
+		return err
+	}
+
+	return nil
+}
+
+func (m RealAwsManager) DetachRolePolicy(ctx context.Context, roleName, policyARN string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.DetachRolePolicyWithContext(ctx, &iam.DetachRolePolicyInput{RoleName: &roleName, PolicyArn: &policyARN}); err != nil {
 		m.logExtErr(err, "failed to detach role policy on aws")
 		return err
 	}
@@ -395,10 +706,13 @@ func (m RealAwsManager) DetachRolePolicy(roleName, policyARN string) error {
 	return nil
 }
 
-func (m RealAwsManager) AttachRolePolicy(roleName, policyARN string) error {
+func (m RealAwsManager) AttachRolePolicy(ctx context.Context, roleName, policyARN string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
 	_ = m.log.WithName("aws").WithName("role")
 
-	if _, err := m.Client.AttachRolePolicy(&iam.AttachRolePolicyInput{RoleName: &roleName, PolicyArn: &policyARN}); err != nil {
+	if _, err := m.Client.AttachRolePolicyWithContext(ctx, &iam.AttachRolePolicyInput{RoleName: &roleName, PolicyArn: &policyARN}); err != nil {
 		m.logExtErr(err, "failed to attach role policy on aws")
 		return err
 	}
@@ -407,8 +721,11 @@ func (m RealAwsManager) AttachRolePolicy(roleName, policyARN string) error {
 	return nil
 }
 
-func (m RealAwsManager) DeleteRole(roleName string) error {
-	if _, err := m.Client.DeleteRole(&iam.DeleteRoleInput{RoleName: &roleName}); err != nil {
+func (m RealAwsManager) DeleteRole(ctx context.Context, roleName string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := m.Client.DeleteRoleWithContext(ctx, &iam.DeleteRoleInput{RoleName: &roleName}); err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == http.StatusNotFound || reqErr.StatusCode() == http.StatusConflict {
 				// already deleted, nothing to do
@@ -422,6 +739,42 @@ func (m RealAwsManager) DeleteRole(roleName string) error {
 	return nil
 }
 
+// toIamTags converts a tags map to the aws format (sorted by key, to be deterministic)
+func toIamTags(tags map[string]string) []*iam.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	iamTags := []*iam.Tag{}
+	for _, k := range keys {
+		iamTags = append(iamTags, &iam.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return iamTags
+}
+
+func fromIamTags(iamTags []*iam.Tag) map[string]string {
+	tags := map[string]string{}
+	for _, t := range iamTags {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags
+}
+
+// withTimeout bounds the duration of a call, on top of the deadline of the caller's context
+func (m RealAwsManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.callTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.callTimeout)
+}
+
 func (m RealAwsManager) logExtErr(err error, msg string) {
 	m.log.Info(fmt.Sprintf("%s : %s", msg, err))
 }
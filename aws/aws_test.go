@@ -1,7 +1,13 @@
 package aws_test
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+	irsaws "github.com/VoodooTeam/irsa-operator/aws"
+	"github.com/VoodooTeam/irsa-operator/controllers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -10,21 +16,23 @@ var (
 	validPolicy = api.NewPolicy("name", "testns", []api.StatementSpec{
 		{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"an:action"}},
 	})
+	tags = map[string]string{"irsa.voodoo.io/name": "name", "team": "a-team"}
+	ctx  = context.Background()
 )
 
 var _ = Describe("policy", func() {
 	It("given a valid policy", func() {
 
 		By("creating the policy it without error")
-		err := awsmngr.CreatePolicy(*validPolicy)
+		err := awsmngr.CreatePolicy(ctx, *validPolicy, tags)
 		Expect(err).NotTo(HaveOccurred())
 
 		By("ensuring the creation is idempotent")
-		err = awsmngr.CreatePolicy(*validPolicy)
+		err = awsmngr.CreatePolicy(ctx, *validPolicy, tags)
 		Expect(err).NotTo(HaveOccurred())
 
 		By("retrieving the policy ARN")
-		policyARN, err := awsmngr.GetPolicyARN(validPolicy.PathPrefix(clusterName), validPolicy.AwsName(clusterName))
+		policyARN, err := awsmngr.GetPolicyARN(ctx, validPolicy.Path(naming), awsNameOf(validPolicy))
 		Expect(err).NotTo(HaveOccurred())
 		Expect(policyARN).NotTo(BeEmpty())
 
@@ -32,19 +40,61 @@ var _ = Describe("policy", func() {
 		validPolicy.Spec.ARN = policyARN
 
 		for i := 0; i < 5; i++ {
-			err = awsmngr.UpdatePolicy(*validPolicy)
+			err = awsmngr.UpdatePolicy(ctx, *validPolicy)
 			Expect(err).ToNot(HaveOccurred())
 		}
 
+		By("listing the retained versions")
+		versions, err := awsmngr.ListPolicyVersions(ctx, policyARN)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(api.MaxPolicyVersions))
+		Expect(versions[len(versions)-1].IsDefault).To(BeTrue())
+
+		By("setting a previous version as the default one")
+		previous := versions[0].VersionID
+		Expect(awsmngr.SetDefaultPolicyVersion(ctx, policyARN, previous)).To(Succeed())
+		defaultVersion, err := awsmngr.GetPolicyDefaultVersionID(ctx, policyARN)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultVersion).To(Equal(previous))
+
+		stmt, err := awsmngr.GetPolicyVersionStatement(ctx, policyARN, previous)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(api.StatementEquals(stmt, validPolicy.Spec.Statement)).To(BeTrue())
+
 		By("deleting it")
 		Expect(policyARN).NotTo(BeEmpty())
-		err = awsmngr.DeletePolicy(policyARN)
+		err = awsmngr.DeletePolicy(ctx, policyARN)
 		Expect(err).NotTo(HaveOccurred())
 
 		By("ensuring deletion is also idempotent")
 		Expect(policyARN).NotTo(BeEmpty())
-		err = awsmngr.DeletePolicy(policyARN)
+		err = awsmngr.DeletePolicy(ctx, policyARN)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("policy created under a previous IAM path", func() {
+	It("is found through the path recorded in its status", func() {
+		policy := api.NewPolicy("previouspath", "testns", validPolicy.Spec.Statement)
+		policy.Status.AwsPath = "/previous-path/clustername/testns/previouspath/policy/"
+		Expect(awsmngr.CreatePolicy(ctx, *policy, tags)).To(Succeed())
+
+		policyARN, err := awsmngr.GetPolicyARN(ctx, policy.Path(naming), awsNameOf(policy))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policyARN).To(ContainSubstring(policy.Status.AwsPath))
+
+		By("not being under the current path")
+		listed, err := awsmngr.ListPolicies(ctx, naming.ClusterPathPrefix())
 		Expect(err).NotTo(HaveOccurred())
+		for _, p := range listed {
+			Expect(p.ARN).NotTo(Equal(policyARN))
+		}
+
+		listed, err = awsmngr.ListPolicies(ctx, policy.ClusterPathPrefix(naming))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(ContainElement(controllers.IamResource{Name: awsNameOf(policy), ARN: policyARN}))
+
+		Expect(awsmngr.DeletePolicy(ctx, policyARN)).To(Succeed())
 	})
 })
 
@@ -53,14 +103,14 @@ var _ = Describe("role", func() {
 
 	Context("given a valid role", func() {
 		It("doesn't exist yet", func() {
-			exists, err := awsmngr.RoleExists(role.AwsName(clusterName))
+			exists, err := awsmngr.RoleExists(ctx, awsNameOf(role))
 			Expect(err).NotTo(HaveOccurred())
 			Expect(exists).To(BeFalse())
 		})
 
 		Context("creation", func() {
 			It("can create it without error without permissionsBoundariesPolicyARN", func() {
-				err := awsmngr.CreateRole(*role, "")
+				err := awsmngr.CreateRole(ctx, *role, "", tags)
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
@@ -69,43 +119,63 @@ var _ = Describe("role", func() {
 			permissionsBoundariesPolicyARN := "arn:aws:iam::123456789012:policy/UsersManageOwnCredentials"
 
 			It("can create it without error", func() {
-				err := awsmngr.CreateRole(*role, permissionsBoundariesPolicyARN)
+				err := awsmngr.CreateRole(ctx, *role, permissionsBoundariesPolicyARN, tags)
 				Expect(err).NotTo(HaveOccurred())
 			})
 
 			Context("idempotency", func() {
 				It("creation is idempotent", func() {
-					err := awsmngr.CreateRole(*role, permissionsBoundariesPolicyARN)
+					err := awsmngr.CreateRole(ctx, *role, permissionsBoundariesPolicyARN, tags)
 					Expect(err).NotTo(HaveOccurred())
 				})
 
 				Context("exists check", func() {
 					It("can be checked for existing", func() {
-						exists, err := awsmngr.RoleExists(role.AwsName(clusterName))
+						exists, err := awsmngr.RoleExists(ctx, awsNameOf(role))
 						Expect(err).NotTo(HaveOccurred())
 						Expect(exists).To(BeTrue())
 					})
 
+					It("has been tagged", func() {
+						roleTags, err := awsmngr.GetRoleTags(ctx, awsNameOf(role))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(roleTags).To(Equal(tags))
+					})
+
+					Context("tags", func() {
+						It("can be updated", func() {
+							err := awsmngr.TagRole(ctx, awsNameOf(role), map[string]string{"team": "b-team"})
+							Expect(err).NotTo(HaveOccurred())
+
+							err = awsmngr.UntagRole(ctx, awsNameOf(role), []string{"irsa.voodoo.io/name"})
+							Expect(err).NotTo(HaveOccurred())
+
+							roleTags, err := awsmngr.GetRoleTags(ctx, awsNameOf(role))
+							Expect(err).NotTo(HaveOccurred())
+							Expect(roleTags).To(Equal(map[string]string{"team": "b-team"}))
+						})
+					})
+
 					Context("policies can be attached", func() {
 						policyARN := ""
 						It("the policy must exist first", func() {
 							var err error
-							err = awsmngr.CreatePolicy(*validPolicy)
+							err = awsmngr.CreatePolicy(ctx, *validPolicy, tags)
 							Expect(err).NotTo(HaveOccurred())
 
-							policyARN, err = awsmngr.GetPolicyARN(validPolicy.PathPrefix(clusterName), validPolicy.AwsName(clusterName))
+							policyARN, err = awsmngr.GetPolicyARN(ctx, validPolicy.Path(naming), awsNameOf(validPolicy))
 							Expect(err).NotTo(HaveOccurred())
 							Expect(policyARN).NotTo(BeEmpty())
 						})
 
 						Context("when done", func() {
 							It("actually can be attached", func() {
-								err := awsmngr.AttachRolePolicy(role.AwsName(clusterName), policyARN)
+								err := awsmngr.AttachRolePolicy(ctx, awsNameOf(role), policyARN)
 								Expect(err).NotTo(HaveOccurred())
 							})
 
 							It("and retrieved", func() {
-								attached, err := awsmngr.GetAttachedRolePoliciesARNs(role.AwsName(clusterName))
+								attached, err := awsmngr.GetAttachedRolePoliciesARNs(ctx, awsNameOf(role))
 								Expect(err).NotTo(HaveOccurred())
 								Expect(len(attached)).To(Equal(1))
 								Expect(attached[0]).To(Equal(policyARN))
@@ -113,14 +183,14 @@ var _ = Describe("role", func() {
 
 							Context("delete attached policy", func() {
 								It("the role can be deleted without error", func() {
-									err := awsmngr.DeleteRole(role.AwsName(clusterName))
+									err := awsmngr.DeleteRole(ctx, awsNameOf(role))
 									Expect(err).NotTo(HaveOccurred())
 								})
 							})
 
 							Context("delete attached policy", func() {
 								It("can be done without error", func() {
-									err := awsmngr.DeletePolicy(policyARN)
+									err := awsmngr.DeletePolicy(ctx, policyARN)
 									Expect(err).NotTo(HaveOccurred())
 								})
 
@@ -131,7 +201,7 @@ var _ = Describe("role", func() {
 								//
 								//Context("the policy now should be detached", func() {
 								//	It("and doesn't cause error to attempt to retrieve it", func() {
-								//		attached, err := awsmngr.GetAttachedRolePoliciesARNs(role.AwsName())
+								//		attached, err := awsmngr.GetAttachedRolePoliciesARNs(ctx, role.AwsName())
 								//		Expect(err).NotTo(HaveOccurred())
 								//		Expect(attached).To(BeEmpty())
 								//	})
@@ -142,13 +212,13 @@ var _ = Describe("role", func() {
 
 					Context("deletion", func() {
 						It("can be deleted without error", func() {
-							err := awsmngr.DeleteRole(role.AwsName(clusterName))
+							err := awsmngr.DeleteRole(ctx, awsNameOf(role))
 							Expect(err).NotTo(HaveOccurred())
 						})
 
 						Context("idempotency", func() {
 							It("deletion is idempotent", func() {
-								err := awsmngr.DeleteRole(role.AwsName(clusterName))
+								err := awsmngr.DeleteRole(ctx, awsNameOf(role))
 								Expect(err).NotTo(HaveOccurred())
 							})
 						})
@@ -158,3 +228,121 @@ var _ = Describe("role", func() {
 		})
 	})
 })
+
+var _ = Describe("pagination", func() {
+	var pagedmngr controllers.AwsManager
+	policies := []*api.Policy{}
+	role := api.NewRole("paginated", "testns")
+
+	BeforeEach(func() {
+		pagedmngr = irsaws.WithPageSize(awsmngr, 2)
+	})
+
+	It("given more policies than a page can hold", func() {
+		for i := 0; i < 5; i++ {
+			p := api.NewPolicy(fmt.Sprintf("paginated-%d", i), "testns", validPolicy.Spec.Statement)
+			Expect(pagedmngr.CreatePolicy(ctx, *p, tags)).To(Succeed())
+			policies = append(policies, p)
+		}
+
+		By("retrieving their ARNs")
+		policyARNs := []string{}
+		for _, p := range policies {
+			policyARN, err := pagedmngr.GetPolicyARN(ctx, naming.ClusterPathPrefix(), awsNameOf(p))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policyARN).NotTo(BeEmpty())
+			policyARNs = append(policyARNs, policyARN)
+		}
+
+		By("listing all of them")
+		listed, err := pagedmngr.ListPolicies(ctx, naming.ClusterPathPrefix())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(listed)).To(BeNumerically(">=", len(policies)))
+
+		By("attaching all of them to a role")
+		Expect(pagedmngr.CreateRole(ctx, *role, "", tags)).To(Succeed())
+		for _, pARN := range policyARNs {
+			Expect(pagedmngr.AttachRolePolicy(ctx, awsNameOf(role), pARN)).To(Succeed())
+		}
+
+		By("retrieving all the attached policies")
+		attached, err := pagedmngr.GetAttachedRolePoliciesARNs(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attached).To(ConsistOf(policyARNs))
+
+		By("cleaning up")
+		for _, pARN := range policyARNs {
+			Expect(pagedmngr.DeletePolicy(ctx, pARN)).To(Succeed())
+		}
+		Expect(pagedmngr.DeleteRole(ctx, awsNameOf(role))).To(Succeed())
+	})
+})
+
+var _ = Describe("context", func() {
+	It("given a cancelled context, calls to aws are aborted", func() {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := awsmngr.RoleExists(cancelled, "name")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("cache", func() {
+	It("given a cached aws manager, reads are served from the cache until a write invalidates them", func() {
+		cached := irsaws.NewCachedAwsManager(awsmngr, time.Hour)
+		role := api.NewRole("cached", "testns")
+
+		By("caching the absence of the role")
+		exists, err := cached.RoleExists(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		By("creating it through the cache")
+		Expect(cached.CreateRole(ctx, *role, "", tags)).To(Succeed())
+		exists, err = cached.RoleExists(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		By("tagging it through the cache")
+		roleTags, err := cached.GetRoleTags(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(roleTags).To(Equal(tags))
+
+		Expect(cached.TagRole(ctx, awsNameOf(role), map[string]string{"team": "b-team"})).To(Succeed())
+		roleTags, err = cached.GetRoleTags(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(roleTags).To(HaveKeyWithValue("team", "b-team"))
+
+		By("not seeing the changes done behind its back")
+		Expect(awsmngr.DeleteRole(ctx, awsNameOf(role))).To(Succeed())
+		exists, err = cached.RoleExists(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		By("seeing them once the cache is bypassed")
+		exists, err = cached.RoleExists(controllers.Uncached(ctx), awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		exists, err = cached.RoleExists(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		By("deleting it through the cache")
+		Expect(cached.DeleteRole(ctx, awsNameOf(role))).To(Succeed())
+		exists, err = cached.RoleExists(ctx, awsNameOf(role))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+	})
+})
+
+type awsNamed interface {
+	AwsName(api.Naming) (string, error)
+}
+
+// awsNameOf returns the name of the role or policy on aws
+func awsNameOf(o awsNamed) string {
+	name, err := o.AwsName(naming)
+	Expect(err).NotTo(HaveOccurred())
+	return name
+}
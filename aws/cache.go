@@ -0,0 +1,356 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+	"github.com/VoodooTeam/irsa-operator/controllers"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "irsa_operator_aws_cache_requests_total",
+	Help: "Number of reads of the IAM state served by the cache (hit) or by the IAM API (miss)",
+}, []string{"operation", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(cacheRequests)
+}
+
+// NewCachedAwsManager wraps next with a read-through cache : the results of the reads are kept for ttl
+// and dropped as soon as a write of the operator may have changed them
+// the lists used by the garbage collector are never cached, nor the reads done with a controllers.Uncached ctx
+func NewCachedAwsManager(next controllers.AwsManager, ttl time.Duration) controllers.AwsManager {
+	return &CachedAwsManager{
+		next:    next,
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+		now:     time.Now,
+	}
+}
+
+type CachedAwsManager struct {
+	next controllers.AwsManager
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	gen     uint64 // incremented by every invalidation, a value loaded before it must not be stored
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// cache keys, the IAM identifiers (ARNs, paths) may contain "/" so a separator forbidden in them is used
+const (
+	policyKeyPrefix    = "policy|"
+	policyARNKeyPrefix = "policyarn|"
+	roleKeyPrefix      = "role|"
+)
+
+func policyKey(arn, op string) string {
+	return policyKeyPrefix + arn + "|" + op
+}
+
+func policyARNKey(pathPrefix, name string) string {
+	return policyARNKeyPrefix + pathPrefix + "|" + name
+}
+
+func roleKey(name, op string) string {
+	return roleKeyPrefix + name + "|" + op
+}
+
+// get returns the cached value of key, or loads it (errors are never cached)
+// an uncached ctx always loads it, the loaded value replaces the cached one
+func (c *CachedAwsManager) get(ctx context.Context, op, key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	e, found := c.entries[key]
+	gen := c.gen
+	c.mu.Unlock()
+
+	if found && c.now().Before(e.expiresAt) && !controllers.IsUncached(ctx) {
+		cacheRequests.WithLabelValues(op, "hit").Inc()
+		return e.value, nil
+	}
+	cacheRequests.WithLabelValues(op, "miss").Inc()
+
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if gen == c.gen { // otherwise a write happened meanwhile, the value may be stale
+		c.entries[key] = cacheEntry{value: v, expiresAt: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return v, nil
+}
+
+func (c *CachedAwsManager) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+}
+
+func (c *CachedAwsManager) invalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *CachedAwsManager) invalidatePolicy(arn string) {
+	c.invalidatePrefix(policyKey(arn, ""))
+}
+
+func (c *CachedAwsManager) invalidateRole(name string) {
+	c.invalidate(roleKey(name, "exists"), roleKey(name, "arn"), roleKey(name, "attached"), roleKey(name, "tags"))
+}
+
+// invalidateAttachments drops the attached policies of all the roles (when a policy is detached from unknown roles)
+func (c *CachedAwsManager) invalidateAttachments() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	for k := range c.entries {
+		if strings.HasPrefix(k, roleKeyPrefix) && strings.HasSuffix(k, "|attached") {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// policy
+
+func (c *CachedAwsManager) PolicyExists(ctx context.Context, arn string) (bool, error) {
+	v, err := c.get(ctx, "PolicyExists", policyKey(arn, "exists"), func() (interface{}, error) {
+		return c.next.PolicyExists(ctx, arn)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return v.(bool), nil
+}
+
+func (c *CachedAwsManager) GetStatement(ctx context.Context, arn string) ([]api.StatementSpec, error) {
+	v, err := c.get(ctx, "GetStatement", policyKey(arn, "statement"), func() (interface{}, error) {
+		return c.next.GetStatement(ctx, arn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]api.StatementSpec{}, v.([]api.StatementSpec)...), nil
+}
+
+func (c *CachedAwsManager) GetPolicyDefaultVersionID(ctx context.Context, arn string) (string, error) {
+	v, err := c.get(ctx, "GetPolicyDefaultVersionID", policyKey(arn, "version"), func() (interface{}, error) {
+		return c.next.GetPolicyDefaultVersionID(ctx, arn)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (c *CachedAwsManager) ListPolicyVersions(ctx context.Context, arn string) ([]controllers.IamPolicyVersion, error) {
+	v, err := c.get(ctx, "ListPolicyVersions", policyKey(arn, "versions"), func() (interface{}, error) {
+		return c.next.ListPolicyVersions(ctx, arn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]controllers.IamPolicyVersion{}, v.([]controllers.IamPolicyVersion)...), nil
+}
+
+func (c *CachedAwsManager) GetPolicyVersionStatement(ctx context.Context, arn, versionID string) ([]api.StatementSpec, error) {
+	v, err := c.get(ctx, "GetPolicyVersionStatement", policyKey(arn, "statement|"+versionID), func() (interface{}, error) {
+		return c.next.GetPolicyVersionStatement(ctx, arn, versionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]api.StatementSpec{}, v.([]api.StatementSpec)...), nil
+}
+
+func (c *CachedAwsManager) SetDefaultPolicyVersion(ctx context.Context, arn, versionID string) error {
+	defer c.invalidatePolicy(arn)
+	return c.next.SetDefaultPolicyVersion(ctx, arn, versionID)
+}
+
+func (c *CachedAwsManager) GetPolicyARN(ctx context.Context, pathPrefix, uniqueName string) (string, error) {
+	v, err := c.get(ctx, "GetPolicyARN", policyARNKey(pathPrefix, uniqueName), func() (interface{}, error) {
+		return c.next.GetPolicyARN(ctx, pathPrefix, uniqueName)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (c *CachedAwsManager) GetPolicyTags(ctx context.Context, policyARN string) (map[string]string, error) {
+	v, err := c.get(ctx, "GetPolicyTags", policyKey(policyARN, "tags"), func() (interface{}, error) {
+		return c.next.GetPolicyTags(ctx, policyARN)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyTags(v.(map[string]string)), nil
+}
+
+func (c *CachedAwsManager) CreatePolicy(ctx context.Context, policy api.Policy, tags map[string]string) error {
+	// the ARN isn't known yet, all the lookups (by name or by ARN) may be stale
+	defer c.invalidatePrefix(policyARNKeyPrefix)
+	defer c.invalidatePrefix(policyKeyPrefix)
+	return c.next.CreatePolicy(ctx, policy, tags)
+}
+
+func (c *CachedAwsManager) UpdatePolicy(ctx context.Context, policy api.Policy) error {
+	defer c.invalidatePolicy(policy.Spec.ARN)
+	return c.next.UpdatePolicy(ctx, policy)
+}
+
+func (c *CachedAwsManager) DeletePolicy(ctx context.Context, policyARN string) error {
+	// the policy is detached from its roles before being deleted
+	defer c.invalidateAttachments()
+	defer c.invalidatePrefix(policyARNKeyPrefix)
+	defer c.invalidatePolicy(policyARN)
+	return c.next.DeletePolicy(ctx, policyARN)
+}
+
+func (c *CachedAwsManager) TagPolicy(ctx context.Context, policyARN string, tags map[string]string) error {
+	defer c.invalidate(policyKey(policyARN, "tags"))
+	return c.next.TagPolicy(ctx, policyARN, tags)
+}
+
+func (c *CachedAwsManager) UntagPolicy(ctx context.Context, policyARN string, keys []string) error {
+	defer c.invalidate(policyKey(policyARN, "tags"))
+	return c.next.UntagPolicy(ctx, policyARN, keys)
+}
+
+// role
+
+func (c *CachedAwsManager) RoleExists(ctx context.Context, roleName string) (bool, error) {
+	v, err := c.get(ctx, "RoleExists", roleKey(roleName, "exists"), func() (interface{}, error) {
+		return c.next.RoleExists(ctx, roleName)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return v.(bool), nil
+}
+
+func (c *CachedAwsManager) GetRoleARN(ctx context.Context, roleName string) (string, error) {
+	v, err := c.get(ctx, "GetRoleARN", roleKey(roleName, "arn"), func() (interface{}, error) {
+		return c.next.GetRoleARN(ctx, roleName)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (c *CachedAwsManager) GetAttachedRolePoliciesARNs(ctx context.Context, roleName string) ([]string, error) {
+	v, err := c.get(ctx, "GetAttachedRolePoliciesARNs", roleKey(roleName, "attached"), func() (interface{}, error) {
+		return c.next.GetAttachedRolePoliciesARNs(ctx, roleName)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{}, v.([]string)...), nil
+}
+
+func (c *CachedAwsManager) GetRoleTags(ctx context.Context, roleName string) (map[string]string, error) {
+	v, err := c.get(ctx, "GetRoleTags", roleKey(roleName, "tags"), func() (interface{}, error) {
+		return c.next.GetRoleTags(ctx, roleName)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyTags(v.(map[string]string)), nil
+}
+
+func (c *CachedAwsManager) CreateRole(ctx context.Context, role api.Role, permissionsBoundariesPolicyARN string, tags map[string]string) error {
+	// the aws name of the role depends on the naming of the operator, unknown here
+	defer c.invalidatePrefix(roleKeyPrefix)
+	return c.next.CreateRole(ctx, role, permissionsBoundariesPolicyARN, tags)
+}
+
+func (c *CachedAwsManager) UpdateAssumeRolePolicy(ctx context.Context, role api.Role) error {
+	// the trust policy isn't cached
+	return c.next.UpdateAssumeRolePolicy(ctx, role)
+}
+
+func (c *CachedAwsManager) DeleteRole(ctx context.Context, roleName string) error {
+	defer c.invalidateRole(roleName)
+	return c.next.DeleteRole(ctx, roleName)
+}
+
+func (c *CachedAwsManager) AttachRolePolicy(ctx context.Context, roleName, policyARN string) error {
+	defer c.invalidate(roleKey(roleName, "attached"))
+	return c.next.AttachRolePolicy(ctx, roleName, policyARN)
+}
+
+func (c *CachedAwsManager) DetachRolePolicy(ctx context.Context, roleName, policyARN string) error {
+	defer c.invalidate(roleKey(roleName, "attached"))
+	return c.next.DetachRolePolicy(ctx, roleName, policyARN)
+}
+
+func (c *CachedAwsManager) TagRole(ctx context.Context, roleName string, tags map[string]string) error {
+	defer c.invalidate(roleKey(roleName, "tags"))
+	return c.next.TagRole(ctx, roleName, tags)
+}
+
+func (c *CachedAwsManager) UntagRole(ctx context.Context, roleName string, keys []string) error {
+	defer c.invalidate(roleKey(roleName, "tags"))
+	return c.next.UntagRole(ctx, roleName, keys)
+}
+
+// lists, used by the garbage collector, it must see the actual state of IAM
+
+func (c *CachedAwsManager) ListPolicies(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	return c.next.ListPolicies(ctx, pathPrefix)
+}
+
+func (c *CachedAwsManager) ListRoles(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	return c.next.ListRoles(ctx, pathPrefix)
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
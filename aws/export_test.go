@@ -0,0 +1,13 @@
+package aws
+
+import "github.com/VoodooTeam/irsa-operator/controllers"
+
+// WithPageSize lowers the page size of the list calls of an AwsManager, used to test pagination
+func WithPageSize(m controllers.AwsManager, size int64) controllers.AwsManager {
+	rm := *m.(*RealAwsManager)
+	rm.pageSize = &size
+	return &rm
+}
+
+// ThrottledRequests & RateLimitedRequests expose the metrics of WithThrottling to the tests
+var ThrottledRequests, RateLimitedRequests = throttledRequests, rateLimitedRequests
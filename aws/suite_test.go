@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	irsaws "github.com/VoodooTeam/irsa-operator/aws"
 	"github.com/VoodooTeam/irsa-operator/controllers"
 	"github.com/aws/aws-sdk-go/aws"
@@ -24,7 +26,7 @@ import (
 var resource *dockertest.Resource
 var pool *dockertest.Pool
 var awsmngr controllers.AwsManager
-var clusterName string
+var naming api.Naming
 
 func TestTypes(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -44,7 +46,10 @@ var _ = BeforeSuite(func() {
 		log.Fatal("can't reach localstack on ", localStackEndpoint)
 	}
 
-	clusterName = "clustername"
+	var err error
+	naming, err = api.NewNaming("clustername", api.DefaultNameTemplate, api.DefaultRootPath)
+	Expect(err).NotTo(HaveOccurred())
+
 	awsmngr = irsaws.NewAwsManager(
 		session.Must(session.NewSession(&aws.Config{
 			Credentials: credentials.NewStaticCredentials("test", "test", ""),
@@ -53,8 +58,9 @@ var _ = BeforeSuite(func() {
 			Endpoint:    &localStackEndpoint,
 		})),
 		stdr.New(log.New(os.Stderr, "", log.LstdFlags)),
-		clusterName,
+		naming,
 		"oidcprovider.url",
+		time.Minute,
 	)
 	Expect(awsmngr).NotTo(BeNil())
 })
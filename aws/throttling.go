@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// ThrottlingConfig tunes how the operator spreads its calls to the IAM API
+type ThrottlingConfig struct {
+	RateLimit        float64       // requests per second allowed (client side), 0 disables the limiter
+	Burst            int           // requests allowed to be done at once
+	MaxRetries       int           // retries of a failed request
+	MinThrottleDelay time.Duration // the delay before retrying a throttled request, doubled (with jitter) at each retry
+	MaxThrottleDelay time.Duration // upper bound of the delay before retrying a throttled request
+}
+
+var (
+	throttledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "irsa_operator_aws_throttled_requests_total",
+		Help: "Number of requests to the IAM API throttled by AWS",
+	}, []string{"operation"})
+
+	rateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "irsa_operator_aws_rate_limited_requests_total",
+		Help: "Number of requests to the IAM API delayed by the client side rate limiter",
+	}, []string{"operation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(throttledRequests, rateLimitedRequests)
+}
+
+// WithThrottling returns a copy of sess whose requests go through a token bucket rate limiter (shared by all the clients built from it)
+// and are retried with an exponential backoff & jitter, longer when throttled by AWS
+func WithThrottling(sess *session.Session, cfg ThrottlingConfig) *session.Session {
+	s := sess.Copy(request.WithRetryer(aws.NewConfig(), client.DefaultRetryer{
+		NumMaxRetries:    cfg.MaxRetries,
+		MinThrottleDelay: cfg.MinThrottleDelay,
+		MaxThrottleDelay: cfg.MaxThrottleDelay,
+	}))
+
+	if cfg.RateLimit > 0 {
+		limiter := rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.Burst)
+		// sign handlers run before every attempt, retries consume tokens too
+		s.Handlers.Sign.PushFrontNamed(request.NamedHandler{
+			Name: "irsa-operator.RateLimiter",
+			Fn: func(r *request.Request) {
+				if limiter.Allow() {
+					return
+				}
+
+				rateLimitedRequests.WithLabelValues(r.Operation.Name).Inc()
+				if err := limiter.Wait(r.Context()); err != nil {
+					r.Error = err
+				}
+			},
+		})
+	}
+
+	s.Handlers.Retry.PushFrontNamed(request.NamedHandler{
+		Name: "irsa-operator.ThrottlingCounter",
+		Fn: func(r *request.Request) {
+			if r.IsErrorThrottle() {
+				throttledRequests.WithLabelValues(r.Operation.Name).Inc()
+			}
+		},
+	})
+
+	return s
+}
@@ -0,0 +1,78 @@
+package aws_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	irsaws "github.com/VoodooTeam/irsa-operator/aws"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	throttledResponse = `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error><RequestId>1</RequestId></ErrorResponse>`
+	listRolesResponse = `<ListRolesResponse><ListRolesResult><Roles/><IsTruncated>false</IsTruncated></ListRolesResult></ListRolesResponse>`
+)
+
+var _ = Describe("throttling", func() {
+	// iamStub answers the first throttled calls with a Throttling error, then lists no role
+	iamStub := func(throttled int32, calls *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(calls, 1) <= throttled {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(throttledResponse))
+				return
+			}
+			_, _ = w.Write([]byte(listRolesResponse))
+		}))
+	}
+
+	iamClient := func(endpoint string, cfg irsaws.ThrottlingConfig) *iam.IAM {
+		sess := session.Must(session.NewSession(&aws.Config{
+			Credentials: credentials.NewStaticCredentials("test", "test", ""),
+			Region:      aws.String(endpoints.UsWest1RegionID),
+			Endpoint:    aws.String(endpoint),
+		}))
+		return iam.New(irsaws.WithThrottling(sess, cfg))
+	}
+
+	It("given a call throttled by AWS, it's retried & counted", func() {
+		var calls int32
+		server := iamStub(2, &calls)
+		defer server.Close()
+
+		before := testutil.ToFloat64(irsaws.ThrottledRequests.WithLabelValues("ListRoles"))
+		client := iamClient(server.URL, irsaws.ThrottlingConfig{MaxRetries: 3, MinThrottleDelay: time.Millisecond, MaxThrottleDelay: 5 * time.Millisecond})
+
+		_, err := client.ListRoles(&iam.ListRolesInput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+		Expect(testutil.ToFloat64(irsaws.ThrottledRequests.WithLabelValues("ListRoles")) - before).To(Equal(2.0))
+	})
+
+	It("given more calls than the rate limit allows, they're delayed & counted", func() {
+		var calls int32
+		server := iamStub(0, &calls)
+		defer server.Close()
+
+		before := testutil.ToFloat64(irsaws.RateLimitedRequests.WithLabelValues("ListRoles"))
+		client := iamClient(server.URL, irsaws.ThrottlingConfig{RateLimit: 10, Burst: 1})
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := client.ListRoles(&iam.ListRolesInput{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond)) // 2 tokens refilled at 10/s
+		Expect(testutil.ToFloat64(irsaws.RateLimitedRequests.WithLabelValues("ListRoles")) - before).To(Equal(2.0))
+	})
+})
@@ -14,15 +14,17 @@ type PolicyDocument struct {
 }
 
 type Statement struct {
-	Effect   StatementEffect
-	Action   []string
-	Resource string
+	Effect    StatementEffect
+	Action    []string
+	Resource  string
+	Condition api.Condition `json:",omitempty"`
 }
 
 func (s Statement) ToSpec() api.StatementSpec {
 	return api.StatementSpec{
-		Resource: s.Resource,
-		Action:   s.Action,
+		Resource:  s.Resource,
+		Action:    s.Action,
+		Condition: s.Condition,
 	}
 }
 
@@ -34,14 +36,16 @@ const (
 	StatementDeny  StatementEffect = "Deny"
 )
 
+// NewPolicyDocumentString returns the policy document holding the canonical form of the statements of the spec
 func NewPolicyDocumentString(p api.PolicySpec) (string, error) {
 	stmt := []Statement{}
 
-	for _, s := range p.Statement {
+	for _, s := range api.CanonicalStatements(p.Statement) {
 		stmt = append(stmt, Statement{
-			Effect:   StatementAllow,
-			Action:   s.Action,
-			Resource: s.Resource,
+			Effect:    StatementAllow,
+			Action:    s.Action,
+			Resource:  s.Resource,
+			Condition: s.Condition,
 		})
 	}
 
@@ -65,13 +69,44 @@ type RoleDocument struct {
 
 type RoleStatement struct {
 	Effect    StatementEffect
-	Principal struct {
-		Federated string
-	} `json:"Principal"`
+	Principal RolePrincipal `json:"Principal"`
 	Action    string
-	Condition struct {
-		StringEquals map[string]string
+	Condition *RoleCondition `json:",omitempty"`
+}
+
+// RolePrincipal is either the oidc provider of the cluster (Federated) or an additional trusted principal (AWS)
+type RolePrincipal struct {
+	Federated string `json:",omitempty"`
+	AWS       string `json:",omitempty"`
+}
+
+type RoleCondition struct {
+	StringEquals map[string]StringOrSlice
+}
+
+// StringOrSlice holds the values of a condition, a single value is serialized as a string (like IAM does)
+type StringOrSlice []string
+
+func (s StringOrSlice) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]string(s))
+}
+
+func (s *StringOrSlice) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*s = StringOrSlice{single}
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
 	}
+	*s = values
+	return nil
 }
 
 func NewAssumeRolePolicyDoc(r api.Role, oidcProviderArn string) (string, error) {
@@ -84,30 +119,52 @@ func NewAssumeRolePolicyDoc(r api.Role, oidcProviderArn string) (string, error)
 		issuerHostpath = submatches[1]
 	}
 
+	statements := []RoleStatement{
+		{
+			Effect: StatementAllow,
+			Principal: RolePrincipal{
+				Federated: string(oidcProviderArn),
+			},
+			Action: "sts:AssumeRoleWithWebIdentity",
+			Condition: &RoleCondition{
+				StringEquals: map[string]StringOrSlice{
+					fmt.Sprintf("%s:sub", issuerHostpath): r.TrustedSubjects()},
+			},
+		},
+	}
+
+	if len(r.TrustedSubjects()) == 0 { // no service account can assume the role (eg. no namespace matches the selector of a cluster irsa), IAM rejects an empty condition
+		statements[0].Effect = StatementDeny
+		statements[0].Condition = nil
+	}
+
+	// the additional principals (the caller only keeps the ones that haven't expired)
+	for _, p := range r.Spec.AdditionalTrustedPrincipals {
+		stmt := RoleStatement{
+			Effect:    StatementAllow,
+			Principal: RolePrincipal{AWS: p.ARN},
+			Action:    "sts:AssumeRole",
+		}
+		if p.ExternalID != "" {
+			stmt.Condition = &RoleCondition{StringEquals: map[string]StringOrSlice{"sts:ExternalId": {p.ExternalID}}}
+		}
+		statements = append(statements, stmt)
+	}
+
 	// then create the json formatted Trust policy
 	bytes, err := json.Marshal(
 		RoleDocument{
-			Version: "2012-10-17",
-			Statement: []RoleStatement{
-				{
-					Effect: StatementAllow,
-					Principal: struct{ Federated string }{
-						Federated: string(oidcProviderArn),
-					},
-					Action: "sts:AssumeRoleWithWebIdentity",
-					Condition: struct {
-						StringEquals map[string]string
-					}{
-						StringEquals: map[string]string{
-							fmt.Sprintf("%s:sub", issuerHostpath): fmt.Sprintf("system:serviceaccount:%s:%s", r.ObjectMeta.Namespace, r.Spec.ServiceAccountName)},
-					},
-				},
-			},
+			Version:   "2012-10-17",
+			Statement: statements,
 		},
 	)
 	if err != nil {
 		return "", err
 	}
 
+	if len(bytes) > api.MaxTrustPolicySize { // IAM would reject it, it must not be widened behind the back of the user either
+		return "", fmt.Errorf("the trust policy would be %d characters long (%d service accounts & %d principals trusted), IAM accepts %d", len(bytes), len(r.TrustedSubjects()), len(r.Spec.AdditionalTrustedPrincipals), api.MaxTrustPolicySize)
+	}
+
 	return string(bytes), nil
 }
@@ -2,6 +2,7 @@ package aws_test
 
 import (
 	"encoding/json"
+	"fmt"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -38,6 +39,20 @@ var _ = Describe("PolicyDocument creation", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(*genPolicy).Should(Equal(expectedPolicyDocument))
 		})
+
+		It("has the size the policy spec expects", func() {
+			spec := api.PolicySpec{
+				Statement: []api.StatementSpec{
+					{Resource: "arn:aws:s3:::bucket/<object>", Action: []string{"s3:GetObject", "s3:PutObject"}},
+					{Resource: "arn:aws:sqs:eu-west-1:111122223333:queue", Action: []string{"sqs:*"}},
+				},
+			}
+			for _, stmts := range [][]api.StatementSpec{{}, spec.Statement[:1], spec.Statement} {
+				doc, err := irsaws.NewPolicyDocumentString(api.PolicySpec{Statement: stmts})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(len(doc)).To(Equal(api.DocumentSize(stmts)))
+			}
+		})
 	})
 
 	Context("given a valid role", func() {
@@ -46,14 +61,12 @@ var _ = Describe("PolicyDocument creation", func() {
 			Statement: []irsaws.RoleStatement{
 				{
 					Effect: irsaws.StatementAllow,
-					Principal: struct{ Federated string }{
+					Principal: irsaws.RolePrincipal{
 						Federated: "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID",
 					},
 					Action: "sts:AssumeRoleWithWebIdentity",
-					Condition: struct {
-						StringEquals map[string]string
-					}{
-						StringEquals: map[string]string{"oidc.REGION.eks.amazonaws.com/CLUSTER_ID:sub": "system:serviceaccount:namespace:serviceAccountName"},
+					Condition: &irsaws.RoleCondition{
+						StringEquals: map[string]irsaws.StringOrSlice{"oidc.REGION.eks.amazonaws.com/CLUSTER_ID:sub": {"system:serviceaccount:namespace:serviceAccountName"}},
 					},
 				},
 			},
@@ -78,6 +91,84 @@ var _ = Describe("PolicyDocument creation", func() {
 				err = json.Unmarshal([]byte(roleJSON), genPolicy)
 				Expect(*genPolicy).Should(Equal(expectedRoleDoc))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(roleJSON).To(ContainSubstring(`"system:serviceaccount:namespace:serviceAccountName"}`)) // a single subject is a string
+			})
+
+			It("trusts the service accounts of other namespaces", func() {
+				r := api.Role{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "namespace",
+					},
+					Spec: api.RoleSpec{
+						ServiceAccounts: []api.ServiceAccountRef{{Namespace: "b", Name: "sa"}, {Namespace: "a", Name: "sa"}},
+					},
+				}
+
+				roleJSON, err := irsaws.NewAssumeRolePolicyDoc(r, "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID")
+				Expect(err).NotTo(HaveOccurred())
+
+				genPolicy := &irsaws.RoleDocument{}
+				Expect(json.Unmarshal([]byte(roleJSON), genPolicy)).To(Succeed())
+				Expect(genPolicy.Statement[0].Condition.StringEquals["oidc.REGION.eks.amazonaws.com/CLUSTER_ID:sub"]).To(Equal(irsaws.StringOrSlice{
+					"system:serviceaccount:a:sa",
+					"system:serviceaccount:b:sa",
+				}))
+			})
+
+			It("trusts the additional principals", func() {
+				r := api.Role{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "namespace",
+					},
+					Spec: api.RoleSpec{
+						ServiceAccountName: "serviceAccountName",
+						AdditionalTrustedPrincipals: []api.TrustedPrincipal{
+							{ARN: "arn:aws:iam::111122223333:role/sre"},
+							{ARN: "arn:aws:iam::111122223333:role/vendor", ExternalID: "secret"},
+						},
+					},
+				}
+
+				roleJSON, err := irsaws.NewAssumeRolePolicyDoc(r, "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID")
+				Expect(err).NotTo(HaveOccurred())
+
+				genPolicy := &irsaws.RoleDocument{}
+				Expect(json.Unmarshal([]byte(roleJSON), genPolicy)).To(Succeed())
+				Expect(genPolicy.Statement[1:]).To(Equal([]irsaws.RoleStatement{
+					{Effect: irsaws.StatementAllow, Principal: irsaws.RolePrincipal{AWS: "arn:aws:iam::111122223333:role/sre"}, Action: "sts:AssumeRole"},
+					{Effect: irsaws.StatementAllow, Principal: irsaws.RolePrincipal{AWS: "arn:aws:iam::111122223333:role/vendor"}, Action: "sts:AssumeRole",
+						Condition: &irsaws.RoleCondition{StringEquals: map[string]irsaws.StringOrSlice{"sts:ExternalId": {"secret"}}}},
+				}))
+			})
+
+			It("denies every service account when none is trusted", func() {
+				r := api.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "namespace"}}
+
+				roleJSON, err := irsaws.NewAssumeRolePolicyDoc(r, "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID")
+				Expect(err).NotTo(HaveOccurred())
+
+				genPolicy := &irsaws.RoleDocument{}
+				Expect(json.Unmarshal([]byte(roleJSON), genPolicy)).To(Succeed())
+				Expect(genPolicy.Statement).To(Equal([]irsaws.RoleStatement{{
+					Effect:    irsaws.StatementDeny,
+					Principal: irsaws.RolePrincipal{Federated: "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID"},
+					Action:    "sts:AssumeRoleWithWebIdentity",
+				}}))
+			})
+
+			It("fails instead of exceeding the size IAM accepts", func() {
+				r := api.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "namespace"}}
+				for i := 0; i < 100; i++ {
+					r.Spec.ServiceAccounts = append(r.Spec.ServiceAccounts, api.ServiceAccountRef{Namespace: fmt.Sprintf("namespace-%d", i), Name: "log-shipper"})
+				}
+
+				_, err := irsaws.NewAssumeRolePolicyDoc(r, "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID")
+				Expect(err).To(MatchError(ContainSubstring("100 service accounts")))
+
+				r.Spec.ServiceAccounts = r.Spec.ServiceAccounts[:20]
+				roleJSON, err := irsaws.NewAssumeRolePolicyDoc(r, "arn:aws.iam::111122223333:oidc-provider/oidc.REGION.eks.amazonaws.com/CLUSTER_ID")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(len(roleJSON)).To(BeNumerically("<=", api.MaxTrustPolicySize))
 			})
 		})
 	})
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+)
+
+// pendingApproval returns why the statements of obj can't be applied yet (empty if they grant no sensitive permission or have been approved)
+// the rendered statements are matched against the sensitive permissions, the approval must match the digest of the statements
+// & its approver must be allowed to approve obj, resource being its plural name (eg. "iamroleserviceaccounts")
+func pendingApproval(ctx context.Context, c client.Client, perms api.SensitivePermissions, obj client.Object, resource string, rendered []api.StatementSpec, digest string) (reason string, err error) {
+	matched := perms.Match(rendered)
+	if len(matched) == 0 {
+		return "", nil
+	}
+
+	names := []string{}
+	for _, p := range matched {
+		names = append(names, p.String())
+	}
+	waiting := fmt.Sprintf("waiting for the approval of %s (%s)", digest, strings.Join(names, ", "))
+
+	approvedDigest, approver, err := api.ParseApproval(obj.GetAnnotations())
+	if err != nil {
+		return waiting + " : " + err.Error(), nil
+	}
+	if approver == "" {
+		return waiting, nil
+	}
+	if approvedDigest != digest { // the statements changed since they were approved
+		return waiting + " : the approval of " + approver + " is outdated", nil
+	}
+
+	allowed, err := mayApprove(ctx, c, approver, resource, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return waiting + " : " + approver + " isn't allowed to approve it", nil
+	}
+
+	return "", nil
+}
+
+// mayApprove tells if the user is allowed the api.ApprovalVerb on the resource (checked with a SubjectAccessReview)
+// the groups of the approver aren't known once the annotation is set, so they're never taken into account
+func mayApprove(ctx context.Context, c client.Client, user, resource, ns, name string) (bool, error) {
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: user,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:     api.GroupVersion.Group,
+				Resource:  resource,
+				Verb:      api.ApprovalVerb,
+				Namespace: ns,
+				Name:      name,
+			},
+		},
+	}
+	if err := c.Create(ctx, sar); err != nil {
+		return false, err
+	}
+	return sar.Status.Allowed, nil
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+)
+
+// deniedActions returns the (lower case) actions of the statements the requester isn't allowed to grant in the namespace
+// each action is checked with SubjectAccessReviews on the api.AwsActionsResource, until one of the verbs covering it is allowed
+func deniedActions(ctx context.Context, c client.Client, requester api.Requester, ns string, stmts []api.StatementSpec) ([]string, error) {
+	denied := []string{}
+	for _, action := range grantedActions(stmts) {
+		allowed, err := mayGrant(ctx, c, requester, ns, action)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			denied = append(denied, action)
+		}
+	}
+	return denied, nil
+}
+
+// mayGrant tells if the requester is allowed one of the verbs granting the action on its service
+func mayGrant(ctx context.Context, c client.Client, requester api.Requester, ns, action string) (bool, error) {
+	service, verbs := api.GrantVerbs(action)
+	for _, verb := range verbs {
+		sar := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   requester.Username,
+				Groups: requester.Groups,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:     api.GroupVersion.Group,
+					Resource:  api.AwsActionsResource,
+					Verb:      verb,
+					Namespace: ns,
+					Name:      service,
+				},
+			},
+		}
+		if err := c.Create(ctx, sar); err != nil {
+			return false, err
+		}
+		if sar.Status.Allowed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// grantedActions returns the (lower case) actions of the statements, without duplicates
+func grantedActions(stmts []api.StatementSpec) []string {
+	actions := []string{}
+	for _, stm := range stmts {
+		for _, a := range stm.Action {
+			if !containsString(actions, strings.ToLower(a)) {
+				actions = append(actions, strings.ToLower(a))
+			}
+		}
+	}
+	return actions
+}
+
+// grantsAny tells if one of the statements grants one of the (lower case) actions
+func grantsAny(stmts []api.StatementSpec, actions []string) bool {
+	for _, a := range grantedActions(stmts) {
+		if containsString(actions, a) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,29 +1,77 @@
 package controllers
 
 import (
+	"context"
+	"time"
+
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 )
 
+// AwsManager performs the calls to the IAM API, they're cancelled once their ctx is done
 type AwsManager interface {
 	AwsPolicyManager
 	AwsRoleManager
+	AwsResourceLister
+}
+
+// Uncached returns a ctx whose reads bypass the cache in front of the IAM API (if any), to see the changes done outside of the operator
+func Uncached(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uncachedKey{}, true)
 }
 
+// IsUncached tells if the reads done with ctx must bypass the cache
+func IsUncached(ctx context.Context) bool {
+	uncached, _ := ctx.Value(uncachedKey{}).(bool)
+	return uncached
+}
+
+type uncachedKey struct{}
+
 type AwsPolicyManager interface {
-	PolicyExists(arn string) (bool, error)
-	GetStatement(arn string) ([]api.StatementSpec, error)
-	GetPolicyARN(pathPrefix, uniqueName string) (string, error)
-	CreatePolicy(api.Policy) error
-	UpdatePolicy(api.Policy) error
-	DeletePolicy(policyARN string) error
+	PolicyExists(ctx context.Context, arn string) (bool, error)
+	GetStatement(ctx context.Context, arn string) ([]api.StatementSpec, error)
+	GetPolicyDefaultVersionID(ctx context.Context, arn string) (string, error)
+	ListPolicyVersions(ctx context.Context, arn string) ([]IamPolicyVersion, error)
+	GetPolicyVersionStatement(ctx context.Context, arn, versionID string) ([]api.StatementSpec, error)
+	SetDefaultPolicyVersion(ctx context.Context, arn, versionID string) error
+	GetPolicyARN(ctx context.Context, pathPrefix, uniqueName string) (string, error)
+	CreatePolicy(ctx context.Context, policy api.Policy, tags map[string]string) error
+	UpdatePolicy(ctx context.Context, policy api.Policy) error
+	DeletePolicy(ctx context.Context, policyARN string) error
+	GetPolicyTags(ctx context.Context, policyARN string) (map[string]string, error)
+	TagPolicy(ctx context.Context, policyARN string, tags map[string]string) error
+	UntagPolicy(ctx context.Context, policyARN string, keys []string) error
 }
 
 type AwsRoleManager interface {
-	RoleExists(roleName string) (bool, error)
-	CreateRole(role api.Role, permissionsBoundariesPolicyARN string) error
-	DeleteRole(roleName string) error
-	AttachRolePolicy(roleName, policyARN string) error
-	GetAttachedRolePoliciesARNs(roleName string) ([]string, error)
-	GetRoleARN(roleName string) (string, error)
-	DetachRolePolicy(roleName, policyARN string) error
+	RoleExists(ctx context.Context, roleName string) (bool, error)
+	CreateRole(ctx context.Context, role api.Role, permissionsBoundariesPolicyARN string, tags map[string]string) error
+	UpdateAssumeRolePolicy(ctx context.Context, role api.Role) error
+	DeleteRole(ctx context.Context, roleName string) error
+	AttachRolePolicy(ctx context.Context, roleName, policyARN string) error
+	GetAttachedRolePoliciesARNs(ctx context.Context, roleName string) ([]string, error)
+	GetRoleARN(ctx context.Context, roleName string) (string, error)
+	DetachRolePolicy(ctx context.Context, roleName, policyARN string) error
+	GetRoleTags(ctx context.Context, roleName string) (map[string]string, error)
+	TagRole(ctx context.Context, roleName string, tags map[string]string) error
+	UntagRole(ctx context.Context, roleName string, keys []string) error
+}
+
+// AwsResourceLister lists the IAM resources created by the operator
+type AwsResourceLister interface {
+	ListPolicies(ctx context.Context, pathPrefix string) ([]IamResource, error)
+	ListRoles(ctx context.Context, pathPrefix string) ([]IamResource, error)
+}
+
+// IamPolicyVersion is a version of a policy retained by IAM
+type IamPolicyVersion struct {
+	VersionID  string
+	CreateDate time.Time
+	IsDefault  bool
+}
+
+// IamResource identifies a policy or a role on AWS
+type IamResource struct {
+	Name string
+	ARN  string
 }
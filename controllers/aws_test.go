@@ -1,14 +1,18 @@
 package controllers_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	"github.com/VoodooTeam/irsa-operator/aws"
+	"github.com/VoodooTeam/irsa-operator/controllers"
 )
 
 func newAwsFake() *awsFake {
@@ -22,10 +26,14 @@ type awsFake struct {
 }
 
 type awsStack struct {
-	policy aws.AwsPolicy
-	role   awsRole
-	errors map[awsMethod]struct{}
-	events []string
+	policy         aws.AwsPolicy
+	policyVersion  int                            // incremented by each update of the policy
+	policyVersions map[string][]api.StatementSpec // the versions retained, by ID
+	defaultVersion string
+	shards         map[string]aws.AwsPolicy // the policies holding the statements that don't fit in the policy, by ARN
+	role           awsRole
+	errors         map[awsMethod]struct{}
+	events         []string
 }
 
 type awsRole struct {
@@ -33,6 +41,9 @@ type awsRole struct {
 	arn                            string
 	attachedPolicies               []string
 	permissionsBoundariesPolicyARN string
+	tags                           map[string]string
+	trustedSubjects                []string
+	trustedPrincipals              []string
 }
 
 type awsMethod string
@@ -40,22 +51,33 @@ type awsMethod string
 const (
 	policyExists                awsMethod = "policyExists"
 	getStatement                awsMethod = "getStatement"
+	getPolicyDefaultVersionID   awsMethod = "getPolicyDefaultVersionID"
+	listPolicyVersions          awsMethod = "listPolicyVersions"
+	getPolicyVersionStatement   awsMethod = "getPolicyVersionStatement"
+	setDefaultPolicyVersion     awsMethod = "setDefaultPolicyVersion"
 	updatePolicy                awsMethod = "updatePolicy"
 	createPolicy                awsMethod = "createPolicy"
 	deletePolicy                awsMethod = "deletePolicy"
 	getPolicyARN                awsMethod = "getPolicyARN"
 	createRole                  awsMethod = "createRole"
+	updateAssumeRolePolicy      awsMethod = "updateAssumeRolePolicy"
 	attachRolePolicy            awsMethod = "attachRolePolicy"
 	detachRolePolicy            awsMethod = "detachRolePolicy"
 	deleteRole                  awsMethod = "deleteRole"
 	roleExists                  awsMethod = "roleExists"
 	getRoleARN                  awsMethod = "getRoleARN"
 	getAttachedRolePoliciesARNs awsMethod = "getAttachedRolePoliciesARNs"
+	getPolicyTags               awsMethod = "getPolicyTags"
+	tagPolicy                   awsMethod = "tagPolicy"
+	untagPolicy                 awsMethod = "untagPolicy"
+	getRoleTags                 awsMethod = "getRoleTags"
+	tagRole                     awsMethod = "tagRole"
+	untagRole                   awsMethod = "untagRole"
 )
 
-func (s *awsFake) PolicyExists(arn string) (bool, error) {
+func (s *awsFake) PolicyExists(ctx context.Context, arn string) (bool, error) {
 	cN := getResourceName(arn)
-	if err := s.shouldFailAt(cN, policyExists); err != nil {
+	if err := s.shouldFailAt(ctx, cN, policyExists); err != nil {
 		return false, err
 	}
 
@@ -64,12 +86,17 @@ func (s *awsFake) PolicyExists(arn string) (bool, error) {
 		return false, nil
 	}
 
+	if shardSuffix(arn) != "" {
+		_, ok := stack.(awsStack).shards[arn]
+		return ok, nil
+	}
+
 	return stack.(awsStack).policy.ARN != "", nil
 }
 
-func (s *awsFake) CreatePolicy(policy api.Policy) error {
+func (s *awsFake) CreatePolicy(ctx context.Context, policy api.Policy, tags map[string]string) error {
 	n := policy.ObjectMeta.Name
-	if err := s.shouldFailAt(n, createPolicy); err != nil {
+	if err := s.shouldFailAt(ctx, n, createPolicy); err != nil {
 		return err
 	}
 
@@ -80,14 +107,25 @@ func (s *awsFake) CreatePolicy(policy api.Policy) error {
 	}
 	stack := raw.(awsStack)
 
-	stack.policy = aws.AwsPolicy{ARN: policyARN(policy), Statement: policy.Spec.Statement}
+	if stack.policy.ARN != "" && policy.Status.AwsName != stack.policy.Name { // a shard of the policy
+		arn := policyARN(policy) + "-" + shardSuffix(policy.Status.AwsName)
+		stack.shards = copyShards(stack.shards)
+		stack.shards[arn] = aws.AwsPolicy{Name: policy.Status.AwsName, ARN: arn, Statement: policy.Spec.Statement, Tags: copyTags(tags)}
+		s.stacks.Store(n, stack)
+		return nil
+	}
+
+	stack.policy = aws.AwsPolicy{Name: policy.Status.AwsName, ARN: policyARN(policy), Statement: policy.Spec.Statement, Tags: copyTags(tags)}
+	stack.policyVersion = 0
+	stack.policyVersions = nil
+	stack.addPolicyVersion(policy.Spec.Statement)
 	s.stacks.Store(n, stack)
 	return nil
 }
 
-func (s *awsFake) UpdatePolicy(policy api.Policy) error {
+func (s *awsFake) UpdatePolicy(ctx context.Context, policy api.Policy) error {
 	n := policy.ObjectMeta.Name
-	if err := s.shouldFailAt(n, updatePolicy); err != nil {
+	if err := s.shouldFailAt(ctx, n, updatePolicy); err != nil {
 		return err
 	}
 	raw, ok := s.stacks.Load(n)
@@ -96,14 +134,22 @@ func (s *awsFake) UpdatePolicy(policy api.Policy) error {
 	}
 
 	stack := raw.(awsStack)
-	stack.policy.Statement = policy.Spec.Statement
+	if shard, ok := stack.shards[policy.Spec.ARN]; ok {
+		stack.shards = copyShards(stack.shards)
+		shard.Statement = policy.Spec.Statement
+		stack.shards[policy.Spec.ARN] = shard
+		s.stacks.Store(n, stack)
+		return nil
+	}
+
+	stack.addPolicyVersion(policy.Spec.Statement)
 	s.stacks.Store(n, stack)
 	return nil
 }
 
-func (s *awsFake) DeletePolicy(arn string) error {
+func (s *awsFake) DeletePolicy(ctx context.Context, arn string) error {
 	cN := getResourceName(arn)
-	if err := s.shouldFailAt(cN, deletePolicy); err != nil {
+	if err := s.shouldFailAt(ctx, cN, deletePolicy); err != nil {
 		return err
 	}
 
@@ -113,14 +159,19 @@ func (s *awsFake) DeletePolicy(arn string) error {
 	}
 
 	stack := raw.(awsStack)
-	stack.policy = aws.AwsPolicy{}
+	if shardSuffix(arn) != "" {
+		stack.shards = copyShards(stack.shards)
+		delete(stack.shards, arn)
+	} else {
+		stack.policy = aws.AwsPolicy{}
+	}
 	s.stacks.Store(cN, stack)
 	return nil
 }
 
-func (s *awsFake) GetPolicyARN(pathPrefix, awsName string) (string, error) {
+func (s *awsFake) GetPolicyARN(ctx context.Context, pathPrefix, awsName string) (string, error) {
 	cN := getPolicyNameFromAwsName(awsName)
-	if err := s.shouldFailAt(cN, getPolicyARN); err != nil {
+	if err := s.shouldFailAt(ctx, cN, getPolicyARN); err != nil {
 		return "", err
 	}
 
@@ -129,12 +180,21 @@ func (s *awsFake) GetPolicyARN(pathPrefix, awsName string) (string, error) {
 		return "", errors.New("stack doesn't exists")
 	}
 
+	if shardSuffix(awsName) != "" {
+		for arn, shard := range stack.(awsStack).shards {
+			if shard.Name == awsName {
+				return arn, nil
+			}
+		}
+		return "", nil
+	}
+
 	return stack.(awsStack).policy.ARN, nil
 }
 
-func (s *awsFake) GetStatement(arn string) ([]api.StatementSpec, error) {
+func (s *awsFake) GetStatement(ctx context.Context, arn string) ([]api.StatementSpec, error) {
 	n := getResourceName(arn)
-	if err := s.shouldFailAt(n, getStatement); err != nil {
+	if err := s.shouldFailAt(ctx, n, getStatement); err != nil {
 		return nil, err
 	}
 
@@ -142,12 +202,172 @@ func (s *awsFake) GetStatement(arn string) ([]api.StatementSpec, error) {
 	if !ok {
 		return nil, errors.New("stack doesn't exists")
 	}
+	if shardSuffix(arn) != "" {
+		return stack.(awsStack).shards[arn].Statement, nil
+	}
 	return stack.(awsStack).policy.Statement, nil
 }
 
-func (s *awsFake) CreateRole(r api.Role, permissionsBoundariesPolicyARN string) error {
+func (s *awsFake) GetPolicyDefaultVersionID(ctx context.Context, arn string) (string, error) {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, getPolicyDefaultVersionID); err != nil {
+		return "", err
+	}
+
+	stack, ok := s.stacks.Load(n)
+	if !ok {
+		return "", errors.New("stack doesn't exists")
+	}
+	return stack.(awsStack).defaultVersion, nil
+}
+
+func (s *awsFake) ListPolicyVersions(ctx context.Context, arn string) ([]controllers.IamPolicyVersion, error) {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, listPolicyVersions); err != nil {
+		return nil, err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return nil, errors.New("stack doesn't exists")
+	}
+	stack := raw.(awsStack)
+
+	versions := []controllers.IamPolicyVersion{}
+	for i := 1; i <= stack.policyVersion; i++ {
+		id := fmt.Sprintf("v%d", i)
+		if _, retained := stack.policyVersions[id]; retained {
+			versions = append(versions, controllers.IamPolicyVersion{VersionID: id, CreateDate: time.Unix(int64(i), 0), IsDefault: id == stack.defaultVersion})
+		}
+	}
+	return versions, nil
+}
+
+func (s *awsFake) GetPolicyVersionStatement(ctx context.Context, arn, versionID string) ([]api.StatementSpec, error) {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, getPolicyVersionStatement); err != nil {
+		return nil, err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return nil, errors.New("stack doesn't exists")
+	}
+
+	stmt, ok := raw.(awsStack).policyVersions[versionID]
+	if !ok {
+		return nil, errors.New("version doesn't exists")
+	}
+	return stmt, nil
+}
+
+func (s *awsFake) SetDefaultPolicyVersion(ctx context.Context, arn, versionID string) error {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, setDefaultPolicyVersion); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+	stack := raw.(awsStack)
+
+	stmt, ok := stack.policyVersions[versionID]
+	if !ok {
+		return errors.New("version doesn't exists")
+	}
+	stack.policy.Statement = stmt
+	stack.defaultVersion = versionID
+	s.stacks.Store(n, stack)
+	return nil
+}
+
+// addPolicyVersion creates a new default version of the policy, the oldest one is dropped if IAM can't retain it
+func (stack *awsStack) addPolicyVersion(stmt []api.StatementSpec) {
+	versions := map[string][]api.StatementSpec{} // the stacks are stored by value, maps must not be shared between them
+	for id, st := range stack.policyVersions {
+		versions[id] = st
+	}
+
+	for i := 1; len(versions) >= api.MaxPolicyVersions; i++ {
+		if id := fmt.Sprintf("v%d", i); id != stack.defaultVersion {
+			delete(versions, id)
+		}
+	}
+
+	stack.policyVersion++
+	stack.defaultVersion = fmt.Sprintf("v%d", stack.policyVersion)
+	versions[stack.defaultVersion] = stmt
+	stack.policyVersions = versions
+	stack.policy.Statement = stmt
+}
+
+func (s *awsFake) GetPolicyTags(ctx context.Context, arn string) (map[string]string, error) {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, getPolicyTags); err != nil {
+		return nil, err
+	}
+
+	stack, ok := s.stacks.Load(n)
+	if !ok {
+		return nil, errors.New("stack doesn't exists")
+	}
+	return copyTags(stack.(awsStack).policy.Tags), nil
+}
+
+func (s *awsFake) TagPolicy(ctx context.Context, arn string, tags map[string]string) error {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, tagPolicy); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+
+	stack := raw.(awsStack)
+	stack.policy.Tags = mergeTags(stack.policy.Tags, tags)
+	s.stacks.Store(n, stack)
+	return nil
+}
+
+func (s *awsFake) UntagPolicy(ctx context.Context, arn string, keys []string) error {
+	n := getResourceName(arn)
+	if err := s.shouldFailAt(ctx, n, untagPolicy); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+
+	stack := raw.(awsStack)
+	stack.policy.Tags = removeTags(stack.policy.Tags, keys)
+	s.stacks.Store(n, stack)
+	return nil
+}
+
+func (s *awsFake) ListPolicies(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	policies := []controllers.IamResource{}
+	s.stacks.Range(func(_, raw interface{}) bool {
+		if p := raw.(awsStack).policy; p.ARN != "" {
+			policies = append(policies, controllers.IamResource{Name: p.Name, ARN: p.ARN})
+		}
+		return true
+	})
+	return policies, nil
+}
+
+func (s *awsFake) CreateRole(ctx context.Context, r api.Role, permissionsBoundariesPolicyARN string, tags map[string]string) error {
 	n := r.ObjectMeta.Name
-	if err := s.shouldFailAt(n, createRole); err != nil {
+	if err := s.shouldFailAt(ctx, n, createRole); err != nil {
 		return err
 	}
 
@@ -156,15 +376,50 @@ func (s *awsFake) CreateRole(r api.Role, permissionsBoundariesPolicyARN string)
 		return errors.New("policy doesn't exists")
 	}
 
+	if _, err := aws.NewAssumeRolePolicyDoc(r, testOidcProviderARN); err != nil { // the trust policy must be accepted by IAM
+		return err
+	}
+
+	stack := raw.(awsStack)
+	stack.role = awsRole{name: r.Status.AwsName, arn: roleArn(r), attachedPolicies: []string{}, permissionsBoundariesPolicyARN: permissionsBoundariesPolicyARN, tags: copyTags(tags), trustedSubjects: r.TrustedSubjects(), trustedPrincipals: principalARNs(r)}
+	s.stacks.Store(n, stack)
+	return nil
+}
+
+func (s *awsFake) UpdateAssumeRolePolicy(ctx context.Context, r api.Role) error {
+	n := r.ObjectMeta.Name
+	if err := s.shouldFailAt(ctx, n, updateAssumeRolePolicy); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(n)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+
+	if _, err := aws.NewAssumeRolePolicyDoc(r, testOidcProviderARN); err != nil {
+		return err
+	}
+
 	stack := raw.(awsStack)
-	stack.role = awsRole{name: roleName(r), arn: roleArn(r), attachedPolicies: []string{}, permissionsBoundariesPolicyARN: permissionsBoundariesPolicyARN}
+	stack.role.trustedSubjects = r.TrustedSubjects()
+	stack.role.trustedPrincipals = principalARNs(r)
 	s.stacks.Store(n, stack)
 	return nil
 }
 
-func (s *awsFake) DeleteRole(roleName string) error {
-	cN := getResourceName(roleName)
-	if err := s.shouldFailAt(cN, deleteRole); err != nil {
+// principalARNs returns the additional principals of the trust policy (the expired ones are already filtered out by the caller)
+func principalARNs(r api.Role) []string {
+	arns := []string{}
+	for _, p := range r.Spec.AdditionalTrustedPrincipals {
+		arns = append(arns, p.ARN)
+	}
+	return arns
+}
+
+func (s *awsFake) DeleteRole(ctx context.Context, roleName string) error {
+	cN := getClusterNameFromRoleName(roleName)
+	if err := s.shouldFailAt(ctx, cN, deleteRole); err != nil {
 		return err
 	}
 
@@ -179,9 +434,9 @@ func (s *awsFake) DeleteRole(roleName string) error {
 	return nil
 }
 
-func (s *awsFake) RoleExists(roleName string) (bool, error) {
+func (s *awsFake) RoleExists(ctx context.Context, roleName string) (bool, error) {
 	cN := getClusterNameFromRoleName(roleName)
-	if err := s.shouldFailAt(cN, roleExists); err != nil {
+	if err := s.shouldFailAt(ctx, cN, roleExists); err != nil {
 		return false, err
 	}
 
@@ -194,9 +449,9 @@ func (s *awsFake) RoleExists(roleName string) (bool, error) {
 	return stack.role.name != "", nil
 }
 
-func (s *awsFake) GetRoleARN(roleName string) (string, error) {
+func (s *awsFake) GetRoleARN(ctx context.Context, roleName string) (string, error) {
 	cN := getClusterNameFromRoleName(roleName)
-	if err := s.shouldFailAt(cN, getRoleARN); err != nil {
+	if err := s.shouldFailAt(ctx, cN, getRoleARN); err != nil {
 		return "", err
 	}
 
@@ -209,9 +464,9 @@ func (s *awsFake) GetRoleARN(roleName string) (string, error) {
 	return stack.role.arn, nil
 }
 
-func (s *awsFake) GetAttachedRolePoliciesARNs(roleName string) ([]string, error) {
+func (s *awsFake) GetAttachedRolePoliciesARNs(ctx context.Context, roleName string) ([]string, error) {
 	cN := getClusterNameFromRoleName(roleName)
-	if err := s.shouldFailAt(cN, getAttachedRolePoliciesARNs); err != nil {
+	if err := s.shouldFailAt(ctx, cN, getAttachedRolePoliciesARNs); err != nil {
 		return nil, err
 	}
 
@@ -224,9 +479,9 @@ func (s *awsFake) GetAttachedRolePoliciesARNs(roleName string) ([]string, error)
 	return stack.role.attachedPolicies, nil
 }
 
-func (s *awsFake) AttachRolePolicy(roleName, policyARN string) error {
+func (s *awsFake) AttachRolePolicy(ctx context.Context, roleName, policyARN string) error {
 	cN := getClusterNameFromRoleName(roleName)
-	if err := s.shouldFailAt(cN, attachRolePolicy); err != nil {
+	if err := s.shouldFailAt(ctx, cN, attachRolePolicy); err != nil {
 		return err
 	}
 
@@ -241,9 +496,9 @@ func (s *awsFake) AttachRolePolicy(roleName, policyARN string) error {
 	return nil
 }
 
-func (s *awsFake) DetachRolePolicy(roleName, policyARN string) error {
+func (s *awsFake) DetachRolePolicy(ctx context.Context, roleName, policyARN string) error {
 	cN := getClusterNameFromRoleName(roleName)
-	if err := s.shouldFailAt(cN, detachRolePolicy); err != nil {
+	if err := s.shouldFailAt(ctx, cN, detachRolePolicy); err != nil {
 		return err
 	}
 
@@ -264,16 +519,86 @@ func (s *awsFake) DetachRolePolicy(roleName, policyARN string) error {
 	return nil
 }
 
-// shouldFailAt does 2 (!) things :
+func (s *awsFake) ListRoles(ctx context.Context, pathPrefix string) ([]controllers.IamResource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	roles := []controllers.IamResource{}
+	s.stacks.Range(func(_, raw interface{}) bool {
+		if r := raw.(awsStack).role; r.name != "" {
+			roles = append(roles, controllers.IamResource{Name: r.name, ARN: r.arn})
+		}
+		return true
+	})
+	return roles, nil
+}
+
+func (s *awsFake) GetRoleTags(ctx context.Context, roleName string) (map[string]string, error) {
+	cN := getClusterNameFromRoleName(roleName)
+	if err := s.shouldFailAt(ctx, cN, getRoleTags); err != nil {
+		return nil, err
+	}
+
+	raw, ok := s.stacks.Load(cN)
+	if !ok {
+		return nil, errors.New("stack doesn't exists")
+	}
+
+	return copyTags(raw.(awsStack).role.tags), nil
+}
+
+func (s *awsFake) TagRole(ctx context.Context, roleName string, tags map[string]string) error {
+	cN := getClusterNameFromRoleName(roleName)
+	if err := s.shouldFailAt(ctx, cN, tagRole); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(cN)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+
+	stack := raw.(awsStack)
+	stack.role.tags = mergeTags(stack.role.tags, tags)
+	s.stacks.Store(cN, stack)
+	return nil
+}
+
+func (s *awsFake) UntagRole(ctx context.Context, roleName string, keys []string) error {
+	cN := getClusterNameFromRoleName(roleName)
+	if err := s.shouldFailAt(ctx, cN, untagRole); err != nil {
+		return err
+	}
+
+	raw, ok := s.stacks.Load(cN)
+	if !ok {
+		return errors.New("stack doesn't exists")
+	}
+
+	stack := raw.(awsStack)
+	stack.role.tags = removeTags(stack.role.tags, keys)
+	s.stacks.Store(cN, stack)
+	return nil
+}
+
+// shouldFailAt does 3 (!) things :
 // - abstract the error mechanism
 // - toggle the next result that will be returned
-func (s *awsFake) shouldFailAt(n string, m awsMethod) error {
+// - fail like the real client would if the ctx is done
+func (s *awsFake) shouldFailAt(ctx context.Context, n string, m awsMethod) error {
 	raw, found := s.stacks.Load(n)
 	if !found {
 		log.Fatal("stack not found :", n, ",", string(m))
 	}
 	stack := raw.(awsStack)
 
+	if err := ctx.Err(); err != nil {
+		stack.events = append(stack.events, fmt.Sprintf("cancelled : %s", string(m)))
+		s.stacks.Store(n, stack)
+		return err
+	}
+
 	// an error exists for method key
 	// delete the error
 	// we add this event
@@ -294,16 +619,35 @@ func (s *awsFake) shouldFailAt(n string, m awsMethod) error {
 	return nil
 }
 
+// the stacks are stored by value, tags maps must not be shared between them
+func copyTags(in map[string]string) map[string]string {
+	return mergeTags(nil, in)
+}
+
+func mergeTags(current, tags map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range current {
+		out[k] = v
+	}
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
+
+func removeTags(current map[string]string, keys []string) map[string]string {
+	out := copyTags(current)
+	for _, k := range keys {
+		delete(out, k)
+	}
+	return out
+}
+
 func policyARN(p api.Policy) string {
 	arn := genUniqueName(p.Namespace, p.Name)
 	return arn
 }
 
-func roleName(r api.Role) string {
-	rN := genUniqueName(r.Namespace, r.Name)
-	return rN
-}
-
 func roleArn(r api.Role) string {
 	rN := genUniqueName(r.Namespace, r.Name)
 	return "arn:" + rN
@@ -314,6 +658,24 @@ func genUniqueName(ns, n string) string {
 	return fmt.Sprintf("%s-%s", ns, n)
 }
 
+// shardSuffix returns the index of the shard held by a policy (given its ARN or aws name), empty if it's not a shard
+func shardSuffix(arnOrAwsName string) string {
+	parts := strings.Split(arnOrAwsName, "-")
+	last := parts[len(parts)-1]
+	if _, err := strconv.Atoi(last); err != nil || len(parts) < 3 {
+		return ""
+	}
+	return last
+}
+
+func copyShards(in map[string]aws.AwsPolicy) map[string]aws.AwsPolicy {
+	out := map[string]aws.AwsPolicy{}
+	for arn, shard := range in {
+		out[arn] = shard
+	}
+	return out
+}
+
 func getResourceName(roleNameOrPolicyARN string) string {
 	return strings.Split(roleNameOrPolicyARN, "-")[1]
 }
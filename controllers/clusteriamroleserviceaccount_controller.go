@@ -0,0 +1,418 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+)
+
+func NewClusterIrsaReconciler(client client.Client, scheme *runtime.Scheme, logger logr.Logger, namespace string, allowedResourceAccountIDs []string, placeholders api.Placeholders, sensitivePermissions api.SensitivePermissions) *ClusterIamRoleServiceAccountReconciler {
+	return &ClusterIamRoleServiceAccountReconciler{
+		Client:                    client,
+		scheme:                    scheme,
+		log:                       logger,
+		namespace:                 namespace,
+		allowedResourceAccountIDs: allowedResourceAccountIDs,
+		placeholders:              placeholders,
+		sensitivePermissions:      sensitivePermissions,
+	}
+}
+
+// ClusterIamRoleServiceAccountReconciler reconciles a ClusterIamRoleServiceAccount object
+// its Policy & Role live in the namespace of the operator, its service accounts in the selected namespaces
+type ClusterIamRoleServiceAccountReconciler struct {
+	client.Client
+	log    logr.Logger
+	scheme *runtime.Scheme
+
+	namespace                 string                   // the namespace holding the Policies & Roles of the cluster irsas
+	allowedResourceAccountIDs []string                 // the accounts the resources of the statements can belong to (any if empty)
+	placeholders              api.Placeholders         // the values of the placeholders resolved in the statements
+	sensitivePermissions      api.SensitivePermissions // the permissions only granted once approved
+}
+
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=clusteriamroleserviceaccounts,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=clusteriamroleserviceaccounts/status,verbs=get;update
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=clusteriamroleserviceaccounts/finalizers,verbs=update
+
+// Reconcile is called each time an event occurs on an api.ClusterIamRoleServiceAccount resource
+func (r *ClusterIamRoleServiceAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	irsa := &api.ClusterIamRoleServiceAccount{}
+	if err := r.Get(ctx, req.NamespacedName, irsa); err != nil {
+		// not found : it has been deleted, its policy, role & service accounts are garbage collected
+		return ctrl.Result{Requeue: !k8serrors.IsNotFound(err)}, nil
+	}
+
+	if !irsa.ObjectMeta.DeletionTimestamp.IsZero() { // nothing to do, the owned resources are garbage collected
+		return ctrl.Result{}, nil
+	}
+
+	if irsa.Status.Condition == api.IrsaSubmitted { // the resource has just been created
+		return r.admissionStep(ctx, irsa)
+	}
+
+	return r.reconcilerRoutine(ctx, irsa)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterIamRoleServiceAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.ClusterIamRoleServiceAccount{}).
+		Owns(&api.Role{}).
+		Owns(&api.Policy{}).
+		Owns(&corev1.ServiceAccount{}).
+		// namespaces can start or stop matching the selectors
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.allClusterIrsas)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 10,
+		}).
+		Complete(r)
+}
+
+//
+// privates
+//
+
+// admissionStep does spec validation
+func (r *ClusterIamRoleServiceAccountReconciler) admissionStep(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount) (ctrl.Result, error) {
+	if err := r.validate(irsa); err != nil {
+		ok := r.updateStatus(ctx, irsa, api.IrsaFailed, err.Error())
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	ok := r.updateStatus(ctx, irsa, api.IrsaProgressing, "passed validation")
+	return ctrl.Result{Requeue: !ok}, nil
+}
+
+// reconcilerRoutine makes the policy, the role (trusting the service account of each selected namespace) & the service accounts converge to the spec
+func (r *ClusterIamRoleServiceAccountReconciler) reconcilerRoutine(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount) (ctrl.Result, error) {
+	// the spec may have changed since its admission, the policy is left as is until it's valid again
+	if err := r.validate(irsa); err != nil {
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaFailed, err.Error())
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	namespaces, ok := r.selectedNamespaces(ctx, irsa)
+	if !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// the sensitive permissions are only applied once approved, the policy on AWS is left as is meanwhile
+	reason, ok := r.pendingApproval(ctx, irsa)
+	if !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if reason != "" { // we'll be requeued when the cluster irsa is annotated
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaPending, reason)
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	policy := &api.Policy{}
+	{ // policy creation & update
+		found, ok := r.getOwned(ctx, irsa, irsa.Name, r.namespace, policy)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if !found {
+			newPolicy := api.NewPolicy(irsa.Name, r.namespace, irsa.Spec.Policy.Statement)
+			newPolicy.ObjectMeta.Labels = irsa.ObjectMeta.Labels // labels are propagated as tags on aws
+			ok := r.createOwned(ctx, irsa, newPolicy)
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		if !api.StatementSpecEquals(policy.Spec.Statement, irsa.Spec.Policy.Statement) {
+			policy.Spec.Statement = irsa.Spec.Policy.Statement
+			if err := r.Update(ctx, policy); err != nil {
+				r.controllerErrLog(irsa, "update policy", err)
+				return ctrl.Result{Requeue: true}, nil
+			}
+		}
+	}
+
+	role := &api.Role{}
+	{ // role creation & update of the service accounts it trusts
+		found, ok := r.getOwned(ctx, irsa, irsa.Name, r.namespace, role)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		serviceAccounts := []api.ServiceAccountRef{}
+		for _, ns := range namespaces {
+			serviceAccounts = append(serviceAccounts, api.ServiceAccountRef{Namespace: ns, Name: irsa.Name})
+		}
+
+		if !found && len(namespaces) > 0 {
+			newRole := api.NewRole(irsa.Name, r.namespace)
+			newRole.Spec.ServiceAccountName = "" // only the service accounts of the selected namespaces are trusted
+			newRole.Spec.ServiceAccounts = serviceAccounts
+			newRole.ObjectMeta.Labels = irsa.ObjectMeta.Labels
+			ok := r.createOwned(ctx, irsa, newRole)
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		// the role is kept, only its trust policy changes (nobody can assume it while no namespace matches)
+		if found && !serviceAccountRefsEqual(role.Spec.ServiceAccounts, serviceAccounts) {
+			role.Spec.ServiceAccounts = serviceAccounts
+			if err := r.Update(ctx, role); err != nil {
+				r.controllerErrLog(irsa, "update role", err)
+				return ctrl.Result{Requeue: true}, nil
+			}
+			ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaProgressing, "trusted namespaces changed")
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+	}
+
+	// the service accounts of the namespaces that don't match anymore aren't trusted by the role anymore
+	if ok := r.deleteStaleServiceAccounts(ctx, irsa, namespaces); !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if len(namespaces) == 0 { // the role is kept until a namespace matches again
+		irsa.Status.Namespaces = nil
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaPending, "no namespace matches the namespaceSelector")
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	if role.Status.Condition == api.CrError && !stringsEqual(role.Status.TrustedSubjects, role.TrustedSubjects()) {
+		// the trust policy can't be applied (eg. too many namespaces are selected for IAM), we'll be requeued once the role changes
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaFailed, "role : "+role.Status.Reason)
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	if role.Status.Condition != api.CrOK || policy.Status.Condition != api.CrOK || role.Spec.RoleARN == "" { // we'll be requeued once they're ready
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaProgressing, "waiting for the role & policy to be created")
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	created, conflicts := []string{}, []string{}
+	for _, ns := range namespaces { // service account creation
+		owned, ok := r.ensureServiceAccount(ctx, irsa, ns, role.Spec.RoleARN)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if owned {
+			created = append(created, ns)
+		} else {
+			conflicts = append(conflicts, ns)
+		}
+	}
+
+	irsa.Status.Namespaces = created
+	if len(conflicts) > 0 {
+		ok := r.updateStatusIfNeeded(ctx, irsa, api.IrsaSaNameConflict, "serviceAccountName conflict in "+strings.Join(conflicts, ", "))
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
+	ok = r.updateStatusIfNeeded(ctx, irsa, api.IrsaOK, "all resources successfully created")
+	return ctrl.Result{Requeue: !ok}, nil
+}
+
+// validate returns an error if the spec, once its placeholders are resolved, is invalid
+func (r *ClusterIamRoleServiceAccountReconciler) validate(irsa *api.ClusterIamRoleServiceAccount) error {
+	policy, err := irsa.Spec.Policy.Render(r.placeholders, r.namespace, irsa.Name)
+	if err != nil {
+		return err
+	}
+
+	rendered := *irsa
+	rendered.Spec.Policy = policy
+	if err := rendered.Validate(); err != nil {
+		return err
+	}
+
+	return policy.ValidateResourceAccounts(r.allowedResourceAccountIDs)
+}
+
+// pendingApproval returns why the statements of the cluster irsa can't be applied yet (empty if they don't need an approval or have been approved)
+func (r *ClusterIamRoleServiceAccountReconciler) pendingApproval(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount) (reason string, completed bool) {
+	if len(r.sensitivePermissions) == 0 {
+		return "", true
+	}
+
+	rendered := irsa.Spec.Policy.Statement
+	if spec, err := irsa.Spec.Policy.Render(r.placeholders, r.namespace, irsa.Name); err == nil { // the patterns are matched against the actual ARNs
+		rendered = spec.Statement
+	}
+
+	reason, err := pendingApproval(ctx, r.Client, r.sensitivePermissions, irsa, "clusteriamroleserviceaccounts", rendered, irsa.Spec.Policy.ApprovalDigest())
+	if err != nil {
+		r.controllerErrLog(irsa, "check approval", err)
+		return "", false
+	}
+	return reason, true
+}
+
+// selectedNamespaces returns the (sorted) names of the namespaces matching the selector of the irsa
+func (r *ClusterIamRoleServiceAccountReconciler) selectedNamespaces(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount) (_ []string, completed bool) {
+	selector, err := metav1.LabelSelectorAsSelector(&irsa.Spec.NamespaceSelector)
+	if err != nil { // rejected at admission
+		r.controllerErrLog(irsa, "parse namespaceSelector", err)
+		return nil, false
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := r.List(ctx, nsList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		r.controllerErrLog(irsa, "list namespaces", err)
+		return nil, false
+	}
+
+	namespaces := []string{}
+	for _, ns := range nsList.Items {
+		if ns.Status.Phase != corev1.NamespaceTerminating {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, true
+}
+
+// getOwned gets the resource, it's an error if it's not owned by the irsa
+func (r *ClusterIamRoleServiceAccountReconciler) getOwned(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, name, ns string, obj client.Object) (found bool, completed bool) {
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, obj); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, true
+		}
+		r.controllerErrLog(irsa, "get resource", err)
+		return false, false
+	}
+
+	if !metav1.IsControlledBy(obj, irsa) {
+		r.updateStatusIfNeeded(ctx, irsa, api.IrsaFailed, fmt.Sprintf("%s/%s already exists & belongs to another resource", ns, name))
+		return true, false
+	}
+
+	return true, true
+}
+
+func (r *ClusterIamRoleServiceAccountReconciler) createOwned(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, obj client.Object) (completed bool) {
+	if err := ctrl.SetControllerReference(irsa, obj, r.scheme); err != nil {
+		r.controllerErrLog(irsa, "set the controller reference", err)
+		return false
+	}
+
+	if err := r.Create(ctx, obj); err != nil {
+		r.controllerErrLog(irsa, "create resource", err)
+		return false
+	}
+
+	return true
+}
+
+// ensureServiceAccount creates the service account in the namespace if needed, owned is false if another one with the same name already exists
+func (r *ClusterIamRoleServiceAccountReconciler) ensureServiceAccount(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, ns, roleARN string) (owned bool, completed bool) {
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: irsa.Name, Namespace: ns}, sa); err == nil {
+		return metav1.IsControlledBy(sa, irsa), true
+	} else if !k8serrors.IsNotFound(err) {
+		r.controllerErrLog(irsa, "get sa", err)
+		return false, false
+	}
+
+	newServiceAccount := &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      irsa.Name,
+			Namespace: ns,
+			Labels: map[string]string{
+				api.ClusterIrsaLabel: irsa.Name,
+			},
+			Annotations: map[string]string{
+				"eks.amazonaws.com/role-arn": roleARN,
+			},
+		},
+	}
+
+	return true, r.createOwned(ctx, irsa, newServiceAccount)
+}
+
+// deleteStaleServiceAccounts deletes the service accounts created in the namespaces that aren't selected anymore
+func (r *ClusterIamRoleServiceAccountReconciler) deleteStaleServiceAccounts(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, namespaces []string) (completed bool) {
+	sas := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, sas, client.MatchingLabels{api.ClusterIrsaLabel: irsa.Name}); err != nil {
+		r.controllerErrLog(irsa, "list sas", err)
+		return false
+	}
+
+	for i := range sas.Items {
+		sa := &sas.Items[i]
+		if containsString(namespaces, sa.Namespace) || !metav1.IsControlledBy(sa, irsa) {
+			continue
+		}
+
+		if err := r.Delete(ctx, sa); err != nil && !k8serrors.IsNotFound(err) {
+			r.controllerErrLog(irsa, "delete sa", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// allClusterIrsas returns all the cluster irsas, to reconcile them when a namespace changes
+func (r *ClusterIamRoleServiceAccountReconciler) allClusterIrsas(o client.Object) []reconcile.Request {
+	irsas := &api.ClusterIamRoleServiceAccountList{}
+	if err := r.List(context.Background(), irsas); err != nil {
+		r.log.Info(fmt.Sprintf("[%s] : Failed to list cluster irsas : %s", o.GetName(), err))
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, irsa := range irsas.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: irsa.Name}})
+	}
+	return reqs
+}
+
+func serviceAccountRefsEqual(a, b []api.ServiceAccountRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ClusterIamRoleServiceAccountReconciler) updateStatus(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, cond api.IrsaCondition, reason string) bool {
+	irsa.Status.Condition = cond
+	irsa.Status.Reason = reason
+	return r.Status().Update(ctx, irsa) == nil
+}
+
+// updateStatusIfNeeded avoids the reconcile loop a no-op status update would lead to
+func (r *ClusterIamRoleServiceAccountReconciler) updateStatusIfNeeded(ctx context.Context, irsa *api.ClusterIamRoleServiceAccount, cond api.IrsaCondition, reason string) bool {
+	current := &api.ClusterIamRoleServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: irsa.Name}, current); err == nil &&
+		current.Status.Condition == cond && current.Status.Reason == reason && stringsEqual(current.Status.Namespaces, irsa.Status.Namespaces) {
+		return true
+	}
+
+	return r.updateStatus(ctx, irsa, cond, reason)
+}
+
+func (r *ClusterIamRoleServiceAccountReconciler) controllerErrLog(resource fullNamer, msg string, err error) {
+	r.log.Info(fmt.Sprintf("[%s] : Failed to %s : %s", resource.FullName(), msg, err))
+}
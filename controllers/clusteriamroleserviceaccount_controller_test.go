@@ -0,0 +1,183 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("ClusterIamRoleServiceAccount validity check", func() {
+	It("rejects an invalid namespaceSelector", func() {
+		selector := metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Unknown"}}}
+		Expect(api.NewClusterIamRoleServiceAccount(validName(), selector, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}}).Validate()).To(MatchError(ContainSubstring("invalid namespaceSelector")))
+	})
+})
+
+var _ = Describe("ClusterIamRoleServiceAccount", func() {
+	name := validName()
+	label := validName() // the namespaces of the other tests aren't selected
+	namespaces := []string{validName(), validName()}
+
+	It("creates the service account in every selected namespace, trusted by a single role", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		for _, ns := range namespaces {
+			createResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: map[string]string{label: "selected"}}}).Should(Succeed())
+		}
+
+		createResource(api.NewClusterIamRoleServiceAccount(name,
+			metav1.LabelSelector{MatchLabels: map[string]string{label: "selected"}},
+			api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+			}},
+		)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaOK).Should(BeTrue())
+		for _, ns := range namespaces {
+			findSa(name, ns).Should(BeTrue())
+		}
+		Expect(stackOf(name).role.trustedSubjects).To(ConsistOf(
+			"system:serviceaccount:"+namespaces[0]+":"+name,
+			"system:serviceaccount:"+namespaces[1]+":"+name,
+		))
+	})
+
+	It("narrows the trust of the role when a namespace stops matching", func() {
+		ns := &corev1.Namespace{}
+		getOnK8s(namespaces[1], "", ns)
+		ns.Labels = map[string]string{}
+		Expect(k8sClient.Update(context.Background(), ns)).Should(Succeed())
+
+		Eventually(func() []string {
+			return stackOf(name).role.trustedSubjects
+		}, resourcePollTimeout, resourcePollInterval).Should(Equal([]string{"system:serviceaccount:" + namespaces[0] + ":" + name}))
+
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: namespaces[1]}, &corev1.ServiceAccount{})
+			return k8serrors.IsNotFound(err)
+		}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+
+		Expect(stackOf(name).events).To(ContainElement("success : " + string(updateAssumeRolePolicy)))
+		Expect(countOf(stackOf(name).events, "success : "+string(createRole))).To(Equal(1)) // the role isn't recreated
+	})
+
+	It("keeps the role, trusting nobody, when no namespace matches", func() {
+		roleARN := stackOf(name).role.arn
+		ns := &corev1.Namespace{}
+		getOnK8s(namespaces[0], "", ns)
+		ns.Labels = map[string]string{}
+		Expect(k8sClient.Update(context.Background(), ns)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaPending).Should(BeTrue())
+		Eventually(func() []string {
+			return stackOf(name).role.trustedSubjects
+		}, resourcePollTimeout, resourcePollInterval).Should(BeEmpty())
+		Expect(getRole(name, testns).Spec.RoleARN).To(Equal(roleARN))
+
+		By("trusting the namespace matching again, without recreating the role")
+		getOnK8s(namespaces[0], "", ns)
+		ns.Labels = map[string]string{label: "selected"}
+		Expect(k8sClient.Update(context.Background(), ns)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaOK).Should(BeTrue())
+		Expect(stackOf(name).role.trustedSubjects).To(Equal([]string{"system:serviceaccount:" + namespaces[0] + ":" + name}))
+		Expect(stackOf(name).role.arn).To(Equal(roleARN))
+		Expect(countOf(stackOf(name).events, "success : "+string(createRole))).To(Equal(1))
+	})
+})
+
+var _ = Describe("ClusterIamRoleServiceAccount selecting more namespaces than its trust policy can hold", func() {
+	name := validName()
+	label := validName()
+
+	It("fails with the size of the trust policy", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		for i := 0; i < 60; i++ {
+			createResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: validName(), Labels: map[string]string{label: "selected"}}}).Should(Succeed())
+		}
+
+		createResource(api.NewClusterIamRoleServiceAccount(name,
+			metav1.LabelSelector{MatchLabels: map[string]string{label: "selected"}},
+			api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+			}},
+		)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaFailed).Should(BeTrue())
+		Expect(getClusterIrsa(name).Status.Reason).To(ContainSubstring("60 service accounts"))
+		Expect(stackOf(name).role.arn).To(BeEmpty()) // IAM would have rejected it
+	})
+})
+
+var _ = Describe("ClusterIamRoleServiceAccount requesting sensitive permissions", func() {
+	name := validName()
+	label := validName()
+	stmts := []api.StatementSpec{
+		{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}},
+	}
+
+	It("waits for an approval", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		createResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: validName(), Labels: map[string]string{label: "selected"}}}).Should(Succeed())
+		createResource(api.NewClusterIamRoleServiceAccount(name,
+			metav1.LabelSelector{MatchLabels: map[string]string{label: "selected"}},
+			api.PolicySpec{Statement: stmts},
+		)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaPending).Should(BeTrue())
+		Expect(getClusterIrsa(name).Status.Reason).To(ContainSubstring(api.PolicySpec{Statement: stmts}.ApprovalDigest()))
+		Consistently(func() bool {
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testns}, &api.Policy{})
+			return k8serrors.IsNotFound(err)
+		}, 2*time.Second, resourcePollInterval).Should(BeTrue())
+	})
+
+	It("applies the statements once approved", func() {
+		irsa := getClusterIrsa(name)
+		irsa.Annotations = map[string]string{api.ApprovalAnnotation: api.PolicySpec{Statement: stmts}.ApprovalDigest() + ":security-lead"}
+		Expect(k8sClient.Update(context.Background(), &irsa)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaOK).Should(BeTrue())
+		Expect(stackOf(name).events).To(ContainElement("success : " + string(createPolicy)))
+	})
+
+	It("leaves the policy as approved when the statements change", func() {
+		irsa := getClusterIrsa(name)
+		irsa.Spec.Policy.Statement = append(irsa.Spec.Policy.Statement, api.StatementSpec{
+			Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-billing", Action: []string{"kms:Decrypt"},
+		})
+		Expect(k8sClient.Update(context.Background(), &irsa)).Should(Succeed())
+
+		foundClusterIrsaInCondition(name, api.IrsaPending).Should(BeTrue())
+		Expect(getClusterIrsa(name).Status.Reason).To(ContainSubstring("the approval of security-lead is outdated"))
+		Expect(getPolicy(name, testns).Spec.Statement).To(HaveLen(1))
+	})
+})
+
+func getClusterIrsa(name string) api.ClusterIamRoleServiceAccount {
+	obj := &api.ClusterIamRoleServiceAccount{}
+	getOnK8s(name, "", obj)
+	return *obj
+}
+
+func foundClusterIrsaInCondition(name string, cond api.IrsaCondition) GomegaAsyncAssertion {
+	return find(name, "", cond, &api.ClusterIamRoleServiceAccount{})
+}
+
+func countOf(events []string, e string) int {
+	n := 0
+	for _, ev := range events {
+		if ev == e {
+			n++
+		}
+	}
+	return n
+}
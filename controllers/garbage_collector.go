@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+)
+
+func NewGarbageCollector(client client.Client, awsm AwsManager, logger logr.Logger, naming api.Naming, interval, gracePeriod time.Duration, dryRun bool) *GarbageCollector {
+	return &GarbageCollector{
+		Client:       client,
+		awsM:         awsm,
+		log:          logger,
+		naming:       naming,
+		interval:     interval,
+		gracePeriod:  gracePeriod,
+		dryRun:       dryRun,
+		orphansSince: map[string]time.Time{},
+	}
+}
+
+// GarbageCollector periodically looks for the IAM resources created by the operator (under the cluster path)
+// that have no matching Policy or Role anymore (failed deletions, lost finalizers, cluster rebuilds...)
+// they're reported, and deleted if they've been orphans for longer than the grace period (unless in dry-run mode)
+type GarbageCollector struct {
+	client.Client
+	awsM   AwsManager
+	log    logr.Logger
+	naming api.Naming
+
+	interval    time.Duration
+	gracePeriod time.Duration
+	dryRun      bool
+
+	mu           sync.Mutex
+	orphansSince map[string]time.Time // ARN of the orphans -> first time they've been found orphaned
+}
+
+// Start runs the garbage collection every interval, until the context is done
+func (gc *GarbageCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(gc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := gc.Collect(ctx); err != nil {
+				gc.log.Info(fmt.Sprintf("garbage collection failed : %s", err))
+			}
+		}
+	}
+}
+
+// NeedLeaderElection ensures a single instance of the operator collects the garbage
+func (gc *GarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Collect does a single garbage collection pass
+func (gc *GarbageCollector) Collect(ctx context.Context) error {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	orphanRoles, err := gc.orphanRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	orphanPolicies, err := gc.orphanPolicies(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	seen := map[string]time.Time{}
+	for _, orphan := range append(orphanRoles, orphanPolicies...) {
+		since, ok := gc.orphansSince[orphan.ARN]
+		if !ok {
+			since = now
+		}
+		seen[orphan.ARN] = since
+	}
+	gc.orphansSince = seen // resources not orphaned anymore are forgotten
+
+	// roles are deleted first : policies still attached to them are detached along the way
+	for _, role := range orphanRoles {
+		if gc.collectable(role, now) {
+			gc.deleteRole(ctx, role)
+		}
+	}
+
+	for _, policy := range orphanPolicies {
+		if gc.collectable(policy, now) {
+			gc.deletePolicy(ctx, policy)
+		}
+	}
+
+	return nil
+}
+
+// collectable reports the orphan & tells if it can be deleted
+func (gc *GarbageCollector) collectable(orphan IamResource, now time.Time) bool {
+	since := gc.orphansSince[orphan.ARN]
+	if now.Sub(since) < gc.gracePeriod {
+		gc.log.Info(fmt.Sprintf("[%s] : orphan since %s, within grace period", orphan.ARN, since.Format(time.RFC3339)))
+		return false
+	}
+
+	if gc.dryRun {
+		gc.log.Info(fmt.Sprintf("[%s] : orphan since %s, would be deleted (dry-run)", orphan.ARN, since.Format(time.RFC3339)))
+		return false
+	}
+
+	gc.log.Info(fmt.Sprintf("[%s] : orphan since %s, will be deleted", orphan.ARN, since.Format(time.RFC3339)))
+	return true
+}
+
+func (gc *GarbageCollector) orphanPolicies(ctx context.Context) ([]IamResource, error) {
+	policies, err := gc.awsM.ListPolicies(ctx, gc.naming.ClusterPathPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	crs := &api.PolicyList{}
+	if err := gc.List(ctx, crs); err != nil {
+		return nil, err
+	}
+
+	// the policies created before the IAM path changed are under the path recorded in their status
+	prefixes := []string{}
+	for _, p := range crs.Items {
+		prefixes = append(prefixes, p.ClusterPathPrefix(gc.naming))
+	}
+	for _, prefix := range gc.previousPathPrefixes(prefixes) {
+		previous, err := gc.awsM.ListPolicies(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		policies = appendNewResources(policies, previous)
+	}
+
+	known := map[string]struct{}{}
+	for _, p := range crs.Items {
+		if name, err := p.AwsName(gc.naming); err == nil { // a name the template can't render hasn't been used on aws
+			known[name] = struct{}{}
+		}
+		known[p.Spec.ARN] = struct{}{}
+		known[p.FullName()] = struct{}{}
+		for _, arn := range p.Status.ShardARNs {
+			known[arn] = struct{}{}
+		}
+	}
+
+	orphans := []IamResource{}
+	for _, p := range policies {
+		if isKnown(known, p) {
+			continue
+		}
+
+		tags, err := gc.awsM.GetPolicyTags(ctx, p.ARN)
+		if err != nil {
+			return nil, err
+		}
+
+		if gc.ownedByKnownOrForeign(known, tags) {
+			continue
+		}
+
+		orphans = append(orphans, p)
+	}
+
+	return orphans, nil
+}
+
+func (gc *GarbageCollector) orphanRoles(ctx context.Context) ([]IamResource, error) {
+	roles, err := gc.awsM.ListRoles(ctx, gc.naming.ClusterPathPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	crs := &api.RoleList{}
+	if err := gc.List(ctx, crs); err != nil {
+		return nil, err
+	}
+
+	// the roles created before the IAM path changed are under the path recorded in their status
+	prefixes := []string{}
+	for _, r := range crs.Items {
+		prefixes = append(prefixes, r.ClusterPathPrefix(gc.naming))
+	}
+	for _, prefix := range gc.previousPathPrefixes(prefixes) {
+		previous, err := gc.awsM.ListRoles(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		roles = appendNewResources(roles, previous)
+	}
+
+	known := map[string]struct{}{}
+	for _, r := range crs.Items {
+		if name, err := r.AwsName(gc.naming); err == nil { // a name the template can't render hasn't been used on aws
+			known[name] = struct{}{}
+		}
+		known[r.Spec.RoleARN] = struct{}{}
+		known[r.FullName()] = struct{}{}
+	}
+
+	orphans := []IamResource{}
+	for _, r := range roles {
+		if isKnown(known, r) {
+			continue
+		}
+
+		tags, err := gc.awsM.GetRoleTags(ctx, r.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if gc.ownedByKnownOrForeign(known, tags) {
+			continue
+		}
+
+		orphans = append(orphans, r)
+	}
+
+	return orphans, nil
+}
+
+// previousPathPrefixes returns the distinct prefixes that aren't under the current path of the cluster
+func (gc *GarbageCollector) previousPathPrefixes(prefixes []string) []string {
+	previous := []string{}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(prefix, gc.naming.ClusterPathPrefix()) || containsString(previous, prefix) {
+			continue
+		}
+		previous = append(previous, prefix)
+	}
+	return previous
+}
+
+// appendNewResources appends the resources that aren't listed yet
+func appendNewResources(listed, resources []IamResource) []IamResource {
+	arns := map[string]struct{}{}
+	for _, res := range listed {
+		arns[res.ARN] = struct{}{}
+	}
+
+	for _, res := range resources {
+		if _, ok := arns[res.ARN]; !ok {
+			listed = append(listed, res)
+		}
+	}
+	return listed
+}
+
+func isKnown(known map[string]struct{}, res IamResource) bool {
+	_, byName := known[res.Name]
+	_, byARN := known[res.ARN]
+	return byName || byARN
+}
+
+// ownedByKnownOrForeign tells, based on its ownership tags, if a resource belongs to an existing CR or to another cluster
+// we're conservative, such resources are never collected
+func (gc *GarbageCollector) ownedByKnownOrForeign(known map[string]struct{}, tags map[string]string) bool {
+	if cN, tagged := tags[tagClusterName]; tagged && cN != gc.naming.ClusterName {
+		return true
+	}
+
+	_, ok := known[tags[tagNamespace]+"/"+tags[tagName]]
+	return ok
+}
+
+func (gc *GarbageCollector) deleteRole(ctx context.Context, role IamResource) {
+	attachedPoliciesARNs, err := gc.awsM.GetAttachedRolePoliciesARNs(ctx, role.Name)
+	if err != nil {
+		gc.log.Info(fmt.Sprintf("[%s] : failed to list attached policies : %s", role.ARN, err))
+		return
+	}
+
+	for _, pARN := range attachedPoliciesARNs {
+		if err := gc.awsM.DetachRolePolicy(ctx, role.Name, pARN); err != nil {
+			gc.log.Info(fmt.Sprintf("[%s] : failed to detach policy %s : %s", role.ARN, pARN, err))
+			return
+		}
+	}
+
+	if err := gc.awsM.DeleteRole(ctx, role.Name); err != nil {
+		gc.log.Info(fmt.Sprintf("[%s] : failed to delete role : %s", role.ARN, err))
+		return
+	}
+
+	delete(gc.orphansSince, role.ARN)
+	gc.log.Info(fmt.Sprintf("[%s] : orphan role deleted", role.ARN))
+}
+
+func (gc *GarbageCollector) deletePolicy(ctx context.Context, policy IamResource) {
+	if err := gc.awsM.DeletePolicy(ctx, policy.ARN); err != nil {
+		gc.log.Info(fmt.Sprintf("[%s] : failed to delete policy : %s", policy.ARN, err))
+		return
+	}
+
+	delete(gc.orphansSince, policy.ARN)
+	gc.log.Info(fmt.Sprintf("[%s] : orphan policy deleted", policy.ARN))
+}
@@ -0,0 +1,101 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+	"github.com/VoodooTeam/irsa-operator/aws"
+	irsaCtrl "github.com/VoodooTeam/irsa-operator/controllers"
+)
+
+var _ = Describe("garbage collector", func() {
+	newGC := func(gracePeriod time.Duration, dryRun bool) *irsaCtrl.GarbageCollector {
+		return irsaCtrl.NewGarbageCollector(
+			k8sClient,
+			st,
+			ctrl.Log.WithName("controllers").WithName("gc"),
+			clusterNaming,
+			time.Hour,
+			gracePeriod,
+			dryRun,
+		)
+	}
+
+	Context("given IAM resources without matching Policy & Role", func() {
+		orphan := validName()
+		foreign := validName()
+
+		It("are detected", func() {
+			storeOrphanStack(orphan, nil)
+			storeOrphanStack(foreign, map[string]string{"irsa.voodoo.io/cluster-name": "othercluster"})
+		})
+
+		It("are kept in dry-run mode", func() {
+			Expect(newGC(0, true).Collect(context.Background())).Should(Succeed())
+			Expect(stackOf(orphan).policy.ARN).NotTo(BeEmpty())
+			Expect(stackOf(orphan).role.name).NotTo(BeEmpty())
+		})
+
+		It("are kept during the grace period", func() {
+			Expect(newGC(time.Hour, false).Collect(context.Background())).Should(Succeed())
+			Expect(stackOf(orphan).policy.ARN).NotTo(BeEmpty())
+			Expect(stackOf(orphan).role.name).NotTo(BeEmpty())
+		})
+
+		It("are deleted once the grace period is over, unless owned by another cluster", func() {
+			Expect(newGC(0, false).Collect(context.Background())).Should(Succeed())
+			Expect(stackOf(orphan).policy.ARN).To(BeEmpty())
+			Expect(stackOf(orphan).role.name).To(BeEmpty())
+
+			Expect(stackOf(foreign).policy.ARN).NotTo(BeEmpty())
+			Expect(stackOf(foreign).role.name).NotTo(BeEmpty())
+		})
+	})
+
+	Context("given IAM resources with matching Policy & Role", func() {
+		name := validName()
+
+		It("are never deleted", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(
+				api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{
+					Statement: []api.StatementSpec{
+						{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+					},
+				}),
+			).Should(Succeed())
+			foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+
+			Expect(newGC(0, false).Collect(context.Background())).Should(Succeed())
+			Expect(stackOf(name).policy.ARN).NotTo(BeEmpty())
+			Expect(stackOf(name).role.name).NotTo(BeEmpty())
+		})
+	})
+})
+
+func storeOrphanStack(name string, tags map[string]string) {
+	awsName, err := clusterNaming.Name(&api.NewPolicy(name, testns, nil).ObjectMeta)
+	Expect(err).NotTo(HaveOccurred())
+	st.stacks.Store(name, awsStack{
+		policy: aws.AwsPolicy{Name: awsName, ARN: genUniqueName(testns, name), Tags: tags},
+		role: awsRole{
+			name:             awsName,
+			arn:              "arn:" + genUniqueName(testns, name),
+			attachedPolicies: []string{genUniqueName(testns, name)},
+			tags:             tags,
+		},
+		errors: map[awsMethod]struct{}{},
+		events: []string{},
+	})
+}
+
+func stackOf(name string) awsStack {
+	raw, ok := st.stacks.Load(name)
+	Expect(ok).To(BeTrue())
+	return raw.(awsStack)
+}
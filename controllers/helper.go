@@ -19,3 +19,16 @@ func removeString(slice []string, s string) (result []string) {
 	}
 	return
 }
+
+// stringsEqual tells if both slices hold the same strings in the same order (nil & empty are equal)
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
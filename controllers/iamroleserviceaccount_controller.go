@@ -3,16 +3,22 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	k8s "k8s.io/apimachinery/pkg/api/errors"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,12 +28,16 @@ type fullNamer interface {
 	FullName() string
 }
 
-func NewIrsaReconciler(client client.Client, scheme *runtime.Scheme, logger logr.Logger) *IamRoleServiceAccountReconciler {
+func NewIrsaReconciler(client client.Client, scheme *runtime.Scheme, logger logr.Logger, allowedResourceAccountIDs []string, placeholders api.Placeholders, sensitivePermissions api.SensitivePermissions, authorizeRequesters bool) *IamRoleServiceAccountReconciler {
 	return &IamRoleServiceAccountReconciler{
-		Client:      client,
-		scheme:      scheme,
-		log:         logger,
-		finalizerID: "irsa.irsa.voodoo.io",
+		Client:                    client,
+		scheme:                    scheme,
+		log:                       logger,
+		finalizerID:               "irsa.irsa.voodoo.io",
+		allowedResourceAccountIDs: allowedResourceAccountIDs,
+		placeholders:              placeholders,
+		sensitivePermissions:      sensitivePermissions,
+		authorizeRequesters:       authorizeRequesters,
 	}
 }
 
@@ -37,12 +47,20 @@ type IamRoleServiceAccountReconciler struct {
 	log         logr.Logger
 	scheme      *runtime.Scheme
 	finalizerID string
+
+	allowedResourceAccountIDs []string                 // the accounts the resources of the statements can belong to (any if empty)
+	placeholders              api.Placeholders         // the values of the placeholders resolved in the statements
+	sensitivePermissions      api.SensitivePermissions // the permissions needing an approval before being applied
+	authorizeRequesters       bool                     // only apply the actions the requesters are allowed to grant (recorded by the RequesterRecorder)
 }
 
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=iamroleserviceaccounts,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=iamroleserviceaccounts/status,verbs=get;update
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=iamroleserviceaccounts/finalizers,verbs=update
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=policytemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=namespaceirsadefaults,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 // Reconcile is called each time an event occurs on an api.IamRoleServiceAccount resource
 func (r *IamRoleServiceAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -87,6 +105,9 @@ func (r *IamRoleServiceAccountReconciler) SetupWithManager(mgr ctrl.Manager) err
 		Owns(&api.Role{}).
 		Owns(&api.Policy{}).
 		Owns(&corev1.ServiceAccount{}).
+		Watches(&source.Kind{Type: &api.PolicyTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.irsasUsingTemplate)).
+		Watches(&source.Kind{Type: &api.NamespaceIrsaDefaults{}}, handler.EnqueueRequestsFromMapFunc(r.irsasOfNamespace)).
+		Watches(&source.Kind{Type: &api.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.irsasReferencingPolicy)).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 10,
 		}).
@@ -100,16 +121,36 @@ func (r *IamRoleServiceAccountReconciler) SetupWithManager(mgr ctrl.Manager) err
 // admissionStep does spec validation
 func (r *IamRoleServiceAccountReconciler) admissionStep(ctx context.Context, irsa *api.IamRoleServiceAccount) (ctrl.Result, error) {
 	{ //validation
-		if err := irsa.Validate(); err != nil { // the iamroleserviceaccount spec is invalid
+		inherited, ok := r.inheritedStatements(ctx, irsa)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		policy, ok, err := r.effectivePolicy(ctx, irsa, inherited)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		if err != nil { // a template is missing or its args are invalid
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: err.Error()})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		if err := r.validate(irsa, policy); err != nil {
 			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: err.Error()})
 			return ctrl.Result{Requeue: !ok}, nil
 		}
 	}
 
 	{ //conflict check
-		if r.saWithNameExistsInNs(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace) { // serviceAccountName conflicts with an existing one
-			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: "serviceAccountName conflict"})
-			return ctrl.Result{Requeue: !ok}, nil
+		for _, name := range irsa.ServiceAccountNames() {
+			if r.saWithNameExistsInNs(ctx, name, irsa.ObjectMeta.Namespace) { // serviceAccountName conflicts with an existing one
+				reason := "serviceAccountName conflict"
+				if name != irsa.ObjectMeta.Name {
+					reason += " : " + name
+				}
+				ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: reason})
+				return ctrl.Result{Requeue: !ok}, nil
+			}
 		}
 	}
 
@@ -122,17 +163,93 @@ func (r *IamRoleServiceAccountReconciler) reconcilerRoutine(ctx context.Context,
 	var policyAlreadyExists, roleAlreadyExists, saAlreadyExists bool
 
 	{ // policy creation
-		var ok bool
+		inherited, ok := r.inheritedStatements(ctx, irsa)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if !api.StatementSpecEquals(irsa.Status.InheritedStatement, inherited) { // the namespace defaults changed
+			irsa.Status.InheritedStatement = inherited
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: irsa.Status.Condition, Reason: irsa.Status.Reason})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		policy, ok, err := r.effectivePolicy(ctx, irsa, inherited)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		if err != nil { // a template has been deleted or changed its parameters, we'll be requeued when it's fixed
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: err.Error()})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		// the spec, its templates or the namespace defaults may have changed since the admission, the policy is left as is until they're valid again
+		if err := r.validate(irsa, policy); err != nil {
+			if irsa.Status.Condition == api.IrsaFailed && irsa.Status.Reason == err.Error() { // we'll be requeued when they change
+				return ctrl.Result{}, nil
+			}
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaFailed, Reason: err.Error()})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		// the actions are only applied if the requester is allowed to grant them, checked once per statements (shared policies included)
+		if r.authorizeRequesters {
+			_, refs, ok := r.referencedStatements(ctx, irsa)
+			if !ok {
+				return ctrl.Result{Requeue: true}, nil
+			}
+
+			reason, denied, digest, ok := r.unauthorizedActions(ctx, irsa, append(append([]api.StatementSpec{}, policy.Statement...), refs...))
+			if !ok {
+				return ctrl.Result{Requeue: true}, nil
+			}
+			if reason != "" {
+				if irsa.Status.Condition == api.IrsaForbidden && irsa.Status.Reason == reason { // we'll be requeued when the statements change
+					return ctrl.Result{}, nil
+				}
+				if ok := r.detachRefsGranting(ctx, irsa, denied); !ok {
+					return ctrl.Result{Requeue: true}, nil
+				}
+				ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaForbidden, Reason: reason})
+				return ctrl.Result{Requeue: !ok}, nil
+			}
+
+			if irsa.Status.AuthorizedDigest != digest {
+				irsa.Status.AuthorizedDigest = digest
+				status := api.IamRoleServiceAccountStatus{Condition: irsa.Status.Condition, Reason: irsa.Status.Reason}
+				if irsa.Status.Condition == api.IrsaForbidden {
+					status = api.IamRoleServiceAccountStatus{Condition: api.IrsaProgressing, Reason: "passed authorization"}
+				}
+				ok = r.updateStatus(ctx, irsa, status)
+				return ctrl.Result{Requeue: !ok}, nil
+			}
+		}
+
+		// the sensitive permissions are only applied once approved, the policy on AWS is left as is meanwhile
+		reason, ok := r.pendingApproval(ctx, irsa, policy)
+		if !ok {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		if reason != "" {
+			if irsa.Status.Condition == api.IrsaPending && irsa.Status.Reason == reason { // we'll be requeued when the irsa is annotated
+				return ctrl.Result{}, nil
+			}
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaPending, Reason: reason})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
 		policyAlreadyExists, ok = r.policyAlreadyExists(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace)
 		if !ok {
 			return ctrl.Result{Requeue: true}, nil
 		}
 
-		if !policyAlreadyExists { // create policy
-			ok := r.createPolicy(ctx, irsa)
+		if !policyAlreadyExists && len(policy.Statement) == 0 && len(irsa.Spec.PolicyRefs) > 0 { // the role only gets shared policies
+			policyAlreadyExists = true
+		} else if !policyAlreadyExists { // create policy
+			ok := r.createPolicy(ctx, irsa, policy.Statement)
 			return ctrl.Result{Requeue: !ok}, nil
 		} else { // update policy
-			if ok := r.updatePolicyIfNeeded(ctx, irsa); !ok {
+			if ok := r.updatePolicyIfNeeded(ctx, irsa, policy.Statement); !ok {
 				return ctrl.Result{Requeue: true}, nil
 			}
 		}
@@ -148,20 +265,59 @@ func (r *IamRoleServiceAccountReconciler) reconcilerRoutine(ctx context.Context,
 		if !roleAlreadyExists {
 			ok := r.createRole(ctx, irsa)
 			return ctrl.Result{Requeue: !ok}, nil
+		} else if ok := r.updateRoleIfNeeded(ctx, irsa); !ok {
+			return ctrl.Result{Requeue: true}, nil
 		}
 	}
 
-	{ // service_account creation
-		var ok bool
-		saAlreadyExists, ok = r.saAlreadyExists(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace)
+	{ // service_accounts creation
+		owned, ok := r.ownedServiceAccounts(ctx, irsa)
 		if !ok {
 			return ctrl.Result{Requeue: true}, nil
 		}
 
+		missing, conflicts := []string{}, []string{}
+		for _, name := range irsa.ServiceAccountNames() {
+			if containsString(owned, name) {
+				continue
+			}
+
+			exists, ok := r.saAlreadyExists(ctx, name, irsa.ObjectMeta.Namespace)
+			if !ok {
+				return ctrl.Result{Requeue: true}, nil
+			}
+			if exists { // an additional service account has been listed after another resource created it
+				conflicts = append(conflicts, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(conflicts) > 0 {
+			reason := "serviceAccountName conflict : " + strings.Join(conflicts, ", ")
+			if irsa.Status.Condition == api.IrsaSaNameConflict && irsa.Status.Reason == reason {
+				return ctrl.Result{}, nil
+			}
+			ok := r.updateStatus(ctx, irsa, api.IamRoleServiceAccountStatus{Condition: api.IrsaSaNameConflict, Reason: reason})
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+
+		saAlreadyExists = len(missing) == 0
 		if !saAlreadyExists {
 			if r.roleIsOk(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace) &&
-				r.policyIsOK(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace) { // role & policy have been successfully created
-				if ok := r.createServiceAccount(ctx, irsa); !ok {
+				r.policyIsOK(ctx, irsa) { // role & policy have been successfully created
+				for _, name := range missing {
+					if ok := r.createServiceAccount(ctx, irsa, name); !ok {
+						return ctrl.Result{Requeue: true}, nil
+					}
+				}
+			}
+		}
+
+		// the service accounts removed from the spec aren't trusted by the role anymore
+		for _, name := range owned {
+			if !containsString(irsa.ServiceAccountNames(), name) {
+				if ok := r.deleteServiceAccount(ctx, irsa, name); !ok {
 					return ctrl.Result{Requeue: true}, nil
 				}
 			}
@@ -187,29 +343,15 @@ func (r *IamRoleServiceAccountReconciler) executeFinalizerIfPresent(ctx context.
 		return true
 	}
 
-	{ // we delete the sa we created
-		sa := &corev1.ServiceAccount{}
-		if err := r.Get(ctx, types.NamespacedName{Namespace: irsa.ObjectMeta.Namespace, Name: irsa.ObjectMeta.Name}, sa); err != nil {
-			if !k8serrors.IsNotFound(err) {
-				r.controllerErrLog(irsa, "get sa", err)
-				return false
-			}
+	{ // we delete the sas we created (the ones owned by another operator are left)
+		owned, ok := r.ownedServiceAccounts(ctx, irsa)
+		if !ok {
+			return false
 		}
 
-		{ // ensure it is not owned by another operator
-			owned := false
-			for _, or := range sa.GetOwnerReferences() {
-				if or.UID == irsa.UID {
-					owned = true
-					break
-				}
-			}
-
-			if owned { // we delete the service account
-				if err := r.Delete(ctx, sa); err != nil && !k8serrors.IsNotFound(err) {
-					r.controllerErrLog(irsa, "delete sa", err)
-					return false
-				}
+		for _, name := range owned {
+			if ok := r.deleteServiceAccount(ctx, irsa, name); !ok {
+				return false
 			}
 		}
 	}
@@ -243,10 +385,11 @@ func (r IamRoleServiceAccountReconciler) roleIsOk(ctx context.Context, name, ns
 	return role.Status.Condition == api.CrOK
 }
 
-func (r IamRoleServiceAccountReconciler) policyIsOK(ctx context.Context, name, ns string) bool {
+// policyIsOK tells if the policy of the irsa has been successfully created, the irsas only using shared policies don't have one
+func (r IamRoleServiceAccountReconciler) policyIsOK(ctx context.Context, irsa *api.IamRoleServiceAccount) bool {
 	policy := &api.Policy{}
-	if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, policy); err != nil {
-		return false
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: irsa.Name, Namespace: irsa.Namespace}, policy); err != nil {
+		return k8serrors.IsNotFound(err) && len(irsa.Spec.Policy.Statement) == 0 && len(irsa.Spec.Templates) == 0 && len(irsa.Spec.PolicyRefs) > 0
 	}
 	return policy.Status.Condition == api.CrOK
 }
@@ -271,8 +414,238 @@ func (r *IamRoleServiceAccountReconciler) resourceExists(ctx context.Context, na
 	return true, true
 }
 
-func (r *IamRoleServiceAccountReconciler) createPolicy(ctx context.Context, irsa *api.IamRoleServiceAccount) bool {
-	newPolicy := api.NewPolicy(irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace, irsa.Spec.Policy.Statement)
+// validate returns an error if the irsa, given its effective policy once its placeholders are resolved, is invalid
+func (r *IamRoleServiceAccountReconciler) validate(irsa *api.IamRoleServiceAccount, policy api.PolicySpec) error {
+	policy, err := policy.Render(r.placeholders, irsa.Namespace, irsa.Name)
+	if err != nil {
+		return err
+	}
+
+	rendered := *irsa
+	rendered.Spec.Policy = policy
+	if err := rendered.Validate(); err != nil { // the iamroleserviceaccount spec is invalid
+		return err
+	}
+
+	// a resource is in an account that isn't allowed
+	return policy.ValidateResourceAccounts(r.allowedResourceAccountIDs)
+}
+
+// effectivePolicy returns the policy of the irsa followed by the statements of its templates & the inherited ones
+// ok is false if the templates couldn't be fetched, err is set if they can't be expanded
+func (r *IamRoleServiceAccountReconciler) effectivePolicy(ctx context.Context, irsa *api.IamRoleServiceAccount, inherited []api.StatementSpec) (policy api.PolicySpec, ok bool, err error) {
+	policy = *irsa.Spec.Policy.DeepCopy()
+	for _, ref := range irsa.Spec.Templates {
+		template := &api.PolicyTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, template); err != nil {
+			if k8serrors.IsNotFound(err) {
+				return api.PolicySpec{}, true, fmt.Errorf("policy template %s not found", ref.Name)
+			}
+			r.controllerErrLog(irsa, "get policy template", err)
+			return api.PolicySpec{}, false, nil
+		}
+
+		stmts, err := template.Spec.Expand(ref.Args)
+		if err != nil {
+			return api.PolicySpec{}, true, fmt.Errorf("policy template %s : %s", ref.Name, err)
+		}
+		policy.Statement = append(policy.Statement, stmts...)
+	}
+
+	policy.Statement = append(policy.Statement, inherited...)
+	return policy, true, nil
+}
+
+// pendingApproval returns why the policy can't be applied yet (empty if it grants no sensitive permission or has been approved)
+// the statements of the shared policies it references are approved along with it, since they're granted to its role too
+func (r *IamRoleServiceAccountReconciler) pendingApproval(ctx context.Context, irsa *api.IamRoleServiceAccount, policy api.PolicySpec) (reason string, completed bool) {
+	if len(r.sensitivePermissions) == 0 {
+		return "", true
+	}
+
+	refs, renderedRefs, ok := r.referencedStatements(ctx, irsa)
+	if !ok {
+		return "", false
+	}
+
+	rendered := policy.Statement
+	if spec, err := policy.Render(r.placeholders, irsa.Namespace, irsa.Name); err == nil { // the patterns are matched against the actual ARNs
+		rendered = spec.Statement
+	}
+	approved := api.PolicySpec{Statement: append(append([]api.StatementSpec{}, policy.Statement...), refs...)}
+
+	reason, err := pendingApproval(ctx, r.Client, r.sensitivePermissions, irsa, "iamroleserviceaccounts", append(append([]api.StatementSpec{}, rendered...), renderedRefs...), approved.ApprovalDigest())
+	if err != nil {
+		r.controllerErrLog(irsa, "check approval", err)
+		return "", false
+	}
+	return reason, true
+}
+
+// referencedStatements returns the statements of the shared policies referenced by the irsa, as written & rendered
+// the missing policies are skipped, the role waits for them
+func (r *IamRoleServiceAccountReconciler) referencedStatements(ctx context.Context, irsa *api.IamRoleServiceAccount) (stmts, rendered []api.StatementSpec, completed bool) {
+	for _, name := range irsa.PolicyRefNames() {
+		policy := &api.Policy{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: irsa.Namespace}, policy); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			r.controllerErrLog(irsa, "get shared policy", err)
+			return nil, nil, false
+		}
+
+		stmts = append(stmts, policy.Spec.Statement...)
+		spec, err := policy.Spec.Render(r.placeholders, policy.Namespace, policy.Name)
+		if err != nil { // the policy is in error, it's matched as written
+			spec = policy.Spec
+		}
+		rendered = append(rendered, spec.Statement...)
+	}
+	return stmts, rendered, true
+}
+
+// unauthorizedActions returns why the requester of the irsa can't grant the actions of the statements (empty if they can), the ones denied
+// & the digest identifying what's been checked : the SubjectAccessReviews are skipped if it's the one already authorized
+func (r *IamRoleServiceAccountReconciler) unauthorizedActions(ctx context.Context, irsa *api.IamRoleServiceAccount, stmts []api.StatementSpec) (reason string, denied []string, digest string, completed bool) {
+	requester, recorded, err := irsa.Requester()
+	if err != nil {
+		return err.Error(), nil, "", true
+	}
+	if !recorded { // created before the webhook was installed, its spec must be changed to record its requester
+		return "unknown requester, the " + api.RequesterAnnotation + " annotation is set by the admission webhook when the spec changes", nil, "", true
+	}
+
+	digest = requester.AuthorizationDigest(stmts)
+	if digest == irsa.Status.AuthorizedDigest {
+		return "", nil, digest, true
+	}
+
+	denied, err = deniedActions(ctx, r.Client, requester, irsa.Namespace, stmts)
+	if err != nil {
+		r.controllerErrLog(irsa, "create subjectaccessreview", err)
+		return "", nil, "", false
+	}
+
+	if len(denied) > 0 {
+		return fmt.Sprintf("%s isn't allowed to grant %s", requester.Username, strings.Join(denied, ", ")), denied, "", true
+	}
+	return "", nil, digest, true
+}
+
+// detachRefsGranting removes from the role the shared policies granting one of the denied actions :
+// they may have been changed since the irsa was authorized, its own policy is left as last authorized
+func (r *IamRoleServiceAccountReconciler) detachRefsGranting(ctx context.Context, irsa *api.IamRoleServiceAccount, denied []string) (completed bool) {
+	role := &api.Role{}
+	exists, ok := r.resourceExists(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace, role)
+	if !ok {
+		return false
+	}
+	if !exists || len(denied) == 0 {
+		return true
+	}
+
+	kept := []string{}
+	for _, name := range role.Spec.PolicyRefs {
+		policy := &api.Policy{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: irsa.Namespace}, policy); err != nil {
+			if k8serrors.IsNotFound(err) { // nothing to detach, the role waits for it
+				kept = append(kept, name)
+				continue
+			}
+			r.controllerErrLog(irsa, "get shared policy", err)
+			return false
+		}
+		if !grantsAny(policy.Spec.Statement, denied) {
+			kept = append(kept, name)
+		}
+	}
+
+	if len(kept) == len(role.Spec.PolicyRefs) {
+		return true
+	}
+	role.Spec.PolicyRefs = kept // they're attached again once authorized
+	if err := r.Client.Update(ctx, role); err != nil {
+		r.controllerErrLog(irsa, "update role", err)
+		return false
+	}
+	return true
+}
+
+// inheritedStatements returns the statements of the NamespaceIrsaDefaults of the namespace of the irsa, by name
+func (r *IamRoleServiceAccountReconciler) inheritedStatements(ctx context.Context, irsa *api.IamRoleServiceAccount) (_ []api.StatementSpec, completed bool) {
+	defaults := &api.NamespaceIrsaDefaultsList{}
+	if err := r.List(ctx, defaults, client.InNamespace(irsa.Namespace)); err != nil {
+		r.controllerErrLog(irsa, "list namespace defaults", err)
+		return nil, false
+	}
+
+	sort.Slice(defaults.Items, func(i, j int) bool { return defaults.Items[i].Name < defaults.Items[j].Name })
+	stmts := []api.StatementSpec{}
+	for _, d := range defaults.Items {
+		if d.IsPendingDeletion() {
+			continue
+		}
+		stmts = append(stmts, d.Spec.Statement...)
+	}
+	return stmts, true
+}
+
+// irsasOfNamespace returns the irsas to reconcile when the defaults of their namespace change
+func (r *IamRoleServiceAccountReconciler) irsasOfNamespace(o client.Object) []reconcile.Request {
+	irsas := &api.IamRoleServiceAccountList{}
+	if err := r.List(context.Background(), irsas, client.InNamespace(o.GetNamespace())); err != nil {
+		r.log.Info(fmt.Sprintf("[%s/%s] : Failed to list irsas : %s", o.GetNamespace(), o.GetName(), err))
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, irsa := range irsas.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: irsa.Name, Namespace: irsa.Namespace}})
+	}
+	return reqs
+}
+
+// irsasReferencingPolicy returns the irsas to reconcile when a shared policy changes (its statements may need an approval or an authorization)
+func (r *IamRoleServiceAccountReconciler) irsasReferencingPolicy(o client.Object) []reconcile.Request {
+	irsas := &api.IamRoleServiceAccountList{}
+	if err := r.List(context.Background(), irsas, client.InNamespace(o.GetNamespace())); err != nil {
+		r.log.Info(fmt.Sprintf("[%s/%s] : Failed to list irsas : %s", o.GetNamespace(), o.GetName(), err))
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, irsa := range irsas.Items {
+		if containsString(irsa.PolicyRefNames(), o.GetName()) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: irsa.Name, Namespace: irsa.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// irsasUsingTemplate returns the irsas to reconcile when a policy template changes
+func (r *IamRoleServiceAccountReconciler) irsasUsingTemplate(o client.Object) []reconcile.Request {
+	irsas := &api.IamRoleServiceAccountList{}
+	if err := r.List(context.Background(), irsas); err != nil {
+		r.log.Info(fmt.Sprintf("[%s] : Failed to list irsas : %s", o.GetName(), err))
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, irsa := range irsas.Items {
+		for _, ref := range irsa.Spec.Templates {
+			if ref.Name == o.GetName() {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: irsa.Name, Namespace: irsa.Namespace}})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+func (r *IamRoleServiceAccountReconciler) createPolicy(ctx context.Context, irsa *api.IamRoleServiceAccount, stmts []api.StatementSpec) bool {
+	newPolicy := api.NewPolicy(irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace, stmts)
+	newPolicy.ObjectMeta.Labels = irsa.ObjectMeta.Labels // labels are propagated as tags on aws
 
 	{ // set this irsa instance as the owner of this role
 		if err := ctrl.SetControllerReference(irsa, newPolicy, r.scheme); err != nil { // another resource is already the owner...
@@ -291,14 +664,15 @@ func (r *IamRoleServiceAccountReconciler) createPolicy(ctx context.Context, irsa
 	return true
 }
 
-func (r *IamRoleServiceAccountReconciler) updatePolicyIfNeeded(ctx context.Context, irsa *api.IamRoleServiceAccount) (ok bool) {
+func (r *IamRoleServiceAccountReconciler) updatePolicyIfNeeded(ctx context.Context, irsa *api.IamRoleServiceAccount, stmts []api.StatementSpec) (ok bool) {
 	policy := &api.Policy{}
 	exists, ok := r.resourceExists(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace, policy)
 	if !ok || !exists {
 		return false
 	}
 
-	policy.Spec.Statement = irsa.Spec.Policy.Statement
+	policy.Spec.Statement = stmts
+	policy.ObjectMeta.Labels = irsa.ObjectMeta.Labels
 	if err := r.Client.Update(ctx, policy); err != nil { // we update it
 		r.controllerErrLog(irsa, "create policy", err)
 		return false
@@ -307,12 +681,42 @@ func (r *IamRoleServiceAccountReconciler) updatePolicyIfNeeded(ctx context.Conte
 	return true
 }
 
+func (r *IamRoleServiceAccountReconciler) updateRoleIfNeeded(ctx context.Context, irsa *api.IamRoleServiceAccount) (ok bool) {
+	role := &api.Role{}
+	exists, ok := r.resourceExists(ctx, irsa.ObjectMeta.Name, irsa.ObjectMeta.Namespace, role)
+	if !ok || !exists {
+		return false
+	}
+
+	if labels.Equals(role.ObjectMeta.Labels, irsa.ObjectMeta.Labels) &&
+		stringsEqual(role.Spec.PolicyRefs, irsa.PolicyRefNames()) &&
+		serviceAccountRefsEqual(role.Spec.ServiceAccounts, irsa.AdditionalServiceAccountRefs()) &&
+		api.TrustedPrincipalsEqual(role.Spec.AdditionalTrustedPrincipals, irsa.Spec.AdditionalTrustedPrincipals) { // nothing to update
+		return true
+	}
+
+	role.ObjectMeta.Labels = irsa.ObjectMeta.Labels
+	role.Spec.PolicyRefs = irsa.PolicyRefNames()
+	role.Spec.ServiceAccounts = irsa.AdditionalServiceAccountRefs() // the trust policy is narrowed when one is removed
+	role.Spec.AdditionalTrustedPrincipals = irsa.Spec.AdditionalTrustedPrincipals
+	if err := r.Client.Update(ctx, role); err != nil {
+		r.controllerErrLog(irsa, "update role", err)
+		return false
+	}
+
+	return true
+}
+
 func (r *IamRoleServiceAccountReconciler) createRole(ctx context.Context, irsa *api.IamRoleServiceAccount) bool {
 	// we initialize a new role
 	role := api.NewRole(
 		irsa.ObjectMeta.Name,
 		irsa.ObjectMeta.Namespace,
 	)
+	role.ObjectMeta.Labels = irsa.ObjectMeta.Labels // labels are propagated as tags on aws
+	role.Spec.PolicyRefs = irsa.PolicyRefNames()
+	role.Spec.ServiceAccounts = irsa.AdditionalServiceAccountRefs()
+	role.Spec.AdditionalTrustedPrincipals = irsa.Spec.AdditionalTrustedPrincipals
 
 	// set this irsa instance as the owner of this role
 	if err := ctrl.SetControllerReference(irsa, role, r.scheme); err != nil { // another resource is already the owner...
@@ -329,7 +733,7 @@ func (r *IamRoleServiceAccountReconciler) createRole(ctx context.Context, irsa *
 	return true
 }
 
-func (r *IamRoleServiceAccountReconciler) createServiceAccount(ctx context.Context, irsa *api.IamRoleServiceAccount) (ok bool) {
+func (r *IamRoleServiceAccountReconciler) createServiceAccount(ctx context.Context, irsa *api.IamRoleServiceAccount, name string) (ok bool) {
 	role := &api.Role{}
 	{ // get role details
 		if err := r.Client.Get(ctx, types.NamespacedName{Name: irsa.ObjectMeta.Name, Namespace: irsa.ObjectMeta.Namespace}, role); err != nil {
@@ -349,7 +753,7 @@ func (r *IamRoleServiceAccountReconciler) createServiceAccount(ctx context.Conte
 				Kind:       "ServiceAccount",
 			},
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      irsa.ObjectMeta.Name,
+				Name:      name,
 				Namespace: irsa.ObjectMeta.Namespace,
 				Annotations: map[string]string{
 					"eks.amazonaws.com/role-arn": role.Spec.RoleARN,
@@ -373,13 +777,41 @@ func (r *IamRoleServiceAccountReconciler) createServiceAccount(ctx context.Conte
 	return true
 }
 
+// ownedServiceAccounts returns the names of the service accounts of the namespace created by the irsa
+func (r *IamRoleServiceAccountReconciler) ownedServiceAccounts(ctx context.Context, irsa *api.IamRoleServiceAccount) (_ []string, completed bool) {
+	sas := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, sas, client.InNamespace(irsa.ObjectMeta.Namespace)); err != nil {
+		r.controllerErrLog(irsa, "list sas", err)
+		return nil, false
+	}
+
+	names := []string{}
+	for i := range sas.Items {
+		if metav1.IsControlledBy(&sas.Items[i], irsa) {
+			names = append(names, sas.Items[i].Name)
+		}
+	}
+	return names, true
+}
+
+func (r *IamRoleServiceAccountReconciler) deleteServiceAccount(ctx context.Context, irsa *api.IamRoleServiceAccount, name string) (completed bool) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: irsa.ObjectMeta.Namespace}}
+	if err := r.Delete(ctx, sa); err != nil && !k8serrors.IsNotFound(err) {
+		r.controllerErrLog(irsa, "delete sa", err)
+		return false
+	}
+	return true
+}
+
 func (r *IamRoleServiceAccountReconciler) saWithNameExistsInNs(ctx context.Context, name, ns string) bool {
 	// a bit fragile, don't check errors other than api.NotFound
 	return r.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, &corev1.ServiceAccount{}) == nil
 }
 
+// updateStatus sets the condition & reason of the irsa status, its other fields are kept
 func (r *IamRoleServiceAccountReconciler) updateStatus(ctx context.Context, obj *api.IamRoleServiceAccount, status api.IamRoleServiceAccountStatus) bool {
-	obj.Status = status
+	obj.Status.Condition = status.Condition
+	obj.Status.Reason = status.Reason
 	return r.Status().Update(ctx, obj) == nil
 }
 
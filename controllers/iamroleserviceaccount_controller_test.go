@@ -1,9 +1,17 @@
 package controllers_test
 
 import (
+	"context"
+	"time"
+
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("IamRoleServiceAccount validity check", func() {
@@ -20,7 +28,7 @@ var _ = Describe("IamRoleServiceAccount validity check", func() {
 	Context("if the spec.policy is ok", func() {
 		validPolicy := api.PolicySpec{
 			Statement: []api.StatementSpec{
-				{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"act1"}},
+				{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
 			},
 		}
 
@@ -31,4 +39,467 @@ var _ = Describe("IamRoleServiceAccount validity check", func() {
 			})
 		})
 	})
+
+	Context("if a resource belongs to an account that isn't allowed", func() {
+		name := validName()
+
+		It("fails at admission", func() {
+			createResource(
+				api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{
+					Statement: []api.StatementSpec{
+						{Resource: "arn:aws:sqs:eu-west-1:210987654321:queue", Action: []string{"sqs:SendMessage"}},
+					},
+				}),
+			).Should(Succeed())
+			foundIrsaInCondition(name, testns, api.IrsaFailed).Should(BeTrue())
+			Expect(getIrsa(name, testns).Status.Reason).To(ContainSubstring("is in account 210987654321"))
+		})
+	})
+
+	Context("if a resource of an account that isn't allowed is added once created", func() {
+		name := validName()
+		allowed := []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}
+
+		It("fails without changing the policy on AWS", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: allowed})).Should(Succeed())
+			foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+			foundPolicyInCondition(name, testns, api.CrOK).Should(BeTrue())
+
+			irsa := getIrsa(name, testns)
+			irsa.Spec.Policy.Statement = append(irsa.Spec.Policy.Statement, api.StatementSpec{
+				Resource: "arn:aws:sqs:eu-west-1:210987654321:queue", Action: []string{"sqs:SendMessage"},
+			})
+			Expect(k8sClient.Update(context.Background(), &irsa)).To(Succeed())
+
+			foundIrsaInCondition(name, testns, api.IrsaFailed).Should(BeTrue())
+			Expect(getIrsa(name, testns).Status.Reason).To(ContainSubstring("is in account 210987654321"))
+			Consistently(func() []api.StatementSpec {
+				return stackOf(name).policy.Statement
+			}, 3*time.Second, resourcePollInterval).Should(Equal(allowed))
+			Expect(getPolicy(name, testns).Spec.Statement).To(Equal(allowed))
+		})
+	})
+
+	Context("if a resource of an account that isn't allowed is added to a standalone policy once created", func() {
+		name := validName()
+		allowed := []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}
+
+		It("fails without changing the policy on AWS", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(api.NewPolicy(name, testns, allowed)).Should(Succeed())
+			foundPolicyInCondition(name, testns, api.CrOK).Should(BeTrue())
+
+			policy := getPolicy(name, testns)
+			policy.Spec.Statement = append(policy.Spec.Statement, api.StatementSpec{
+				Resource: "arn:aws:sqs:eu-west-1:210987654321:queue", Action: []string{"sqs:SendMessage"},
+			})
+			Expect(k8sClient.Update(context.Background(), &policy)).To(Succeed())
+
+			foundPolicyInCondition(name, testns, api.CrError).Should(BeTrue())
+			Expect(getPolicy(name, testns).Status.Reason).To(ContainSubstring("is in account 210987654321"))
+			Consistently(func() []api.StatementSpec {
+				return stackOf(name).policy.Statement
+			}, 3*time.Second, resourcePollInterval).Should(Equal(allowed))
+		})
+	})
+})
+
+var _ = Describe("PolicyTemplate expansion", func() {
+	prefix := "${namespace}"
+	template := api.PolicyTemplateSpec{
+		Parameters: []api.TemplateParameter{
+			{Name: "bucket"},
+			{Name: "prefix", Default: &prefix},
+			{Name: "account", Type: api.ParamAccountID},
+		},
+		Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::${params.bucket}/${params.prefix}/*", Action: []string{"s3:GetObject"}},
+			{Resource: "arn:aws:sqs:eu-west-1:${params.account}:queue", Action: []string{"sqs:ReceiveMessage"}},
+		},
+	}
+
+	It("replaces the parameters by the args & leaves the placeholders", func() {
+		stmts, err := template.Expand(map[string]string{"bucket": "b", "account": "123456789012"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stmts).To(Equal([]api.StatementSpec{
+			{Resource: "arn:aws:s3:::b/${namespace}/*", Action: []string{"s3:GetObject"}},
+			{Resource: "arn:aws:sqs:eu-west-1:123456789012:queue", Action: []string{"sqs:ReceiveMessage"}},
+		}))
+	})
+
+	It("requires the parameters without default", func() {
+		_, err := template.Expand(map[string]string{"account": "123456789012"})
+		Expect(err).To(MatchError("parameter bucket is required"))
+	})
+
+	It("rejects the args of the wrong type", func() {
+		_, err := template.Expand(map[string]string{"bucket": "b", "account": "42"})
+		Expect(err).To(MatchError(ContainSubstring("42 is not an account id")))
+	})
+
+	It("rejects the unknown args", func() {
+		_, err := template.Expand(map[string]string{"bucket": "b", "account": "123456789012", "team": "x"})
+		Expect(err).To(MatchError("unknown parameters team"))
+	})
+
+	It("rejects the parameters used but not declared", func() {
+		Expect(api.PolicyTemplateSpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::${params.bucket}", Action: []string{"s3:GetObject"}},
+		}}.Validate()).To(MatchError(ContainSubstring("parameter bucket is used but not declared")))
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount using a PolicyTemplate", func() {
+	templateName := validName()
+	name := validName()
+
+	It("gets the statements of the template in its policy", func() {
+		createResource(api.NewPolicyTemplate(templateName,
+			[]api.TemplateParameter{{Name: "queue"}},
+			[]api.StatementSpec{{Resource: "arn:aws:sqs:eu-west-1:123456789012:${params.queue}", Action: []string{"sqs:ReceiveMessage"}}},
+		)).Should(Succeed())
+
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.Spec.Templates = []api.TemplateRef{{Name: templateName, Args: map[string]string{"queue": "jobs"}}}
+		createResource(irsa).Should(Succeed())
+
+		Eventually(func() []api.StatementSpec {
+			return getPolicy(name, testns).Spec.Statement
+		}, resourcePollTimeout, resourcePollInterval).Should(Equal([]api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+			{Resource: "arn:aws:sqs:eu-west-1:123456789012:jobs", Action: []string{"sqs:ReceiveMessage"}},
+		}))
+	})
+
+	It("gets the new statements when the template changes", func() {
+		template := &api.PolicyTemplate{}
+		getOnK8s(templateName, "", template)
+		template.Spec.Statement[0].Action = []string{"sqs:ReceiveMessage", "sqs:DeleteMessage"}
+		Expect(k8sClient.Update(context.Background(), template)).Should(Succeed())
+
+		Eventually(func() []string {
+			return getPolicy(name, testns).Spec.Statement[1].Action
+		}, resourcePollTimeout, resourcePollInterval).Should(Equal([]string{"sqs:ReceiveMessage", "sqs:DeleteMessage"}))
+	})
+
+	It("fails if the template doesn't exist", func() {
+		missing := validName()
+		irsa := api.NewIamRoleServiceAccount(missing, testns, api.PolicySpec{})
+		irsa.Spec.Templates = []api.TemplateRef{{Name: validName()}}
+		createResource(irsa).Should(Succeed())
+
+		foundIrsaInCondition(missing, testns, api.IrsaFailed).Should(BeTrue())
+		Expect(getIrsa(missing, testns).Status.Reason).To(ContainSubstring("not found"))
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount using shared policies", func() {
+	shared := validName()
+	irsaNames := []string{validName(), validName()}
+
+	It("passes validation without statements of its own", func() {
+		irsa := api.NewIamRoleServiceAccount(validName(), testns, api.PolicySpec{})
+		irsa.Spec.PolicyRefs = []api.PolicyRef{{Name: shared}}
+		Expect(irsa.Validate()).To(Succeed())
+
+		irsa.Spec.PolicyRefs = append(irsa.Spec.PolicyRefs, api.PolicyRef{Name: shared})
+		Expect(irsa.Validate()).To(MatchError(ContainSubstring("referenced twice")))
+	})
+
+	It("gets the shared policy attached to its role", func() {
+		for _, n := range append([]string{shared}, irsaNames...) {
+			st.stacks.Store(n, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		}
+
+		createResource(api.NewPolicy(shared, testns, []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		})).Should(Succeed())
+		foundPolicyInCondition(shared, testns, api.CrOK).Should(BeTrue())
+		sharedARN := getPolicy(shared, testns).Spec.ARN
+
+		for _, n := range irsaNames {
+			irsa := api.NewIamRoleServiceAccount(n, testns, api.PolicySpec{})
+			irsa.Spec.PolicyRefs = []api.PolicyRef{{Name: shared}}
+			createResource(irsa).Should(Succeed())
+		}
+
+		for _, n := range irsaNames {
+			foundIrsaInCondition(n, testns, api.IrsaOK).Should(BeTrue())
+			Expect(stackOf(n).role.attachedPolicies).To(ContainElement(sharedARN))
+			Expect(stackOf(n).policy.ARN).To(BeEmpty()) // no policy of its own
+		}
+	})
+
+	It("keeps the shared policy while irsas reference it", func() {
+		policy := getPolicy(shared, testns)
+		Expect(k8sClient.Delete(context.Background(), &policy)).Should(Succeed())
+
+		foundPolicyInCondition(shared, testns, api.CrDeleting).Should(BeTrue())
+		Expect(getPolicy(shared, testns).Status.Reason).To(ContainSubstring(irsaNames[0]))
+		Expect(stackOf(shared).policy.ARN).NotTo(BeEmpty())
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount in a namespace with defaults", func() {
+	ns := validName() // the defaults would apply to the irsas of the other tests in testns
+	name := validName()
+	inline := api.StatementSpec{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}
+	baseline := api.StatementSpec{Resource: "arn:aws:logs:eu-west-1:123456789012:log-group:/${namespace}/${irsaName}:*", Action: []string{"logs:PutLogEvents"}}
+
+	It("inherits the statements of the defaults", func() {
+		createResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}).Should(Succeed())
+		createResource(api.NewNamespaceIrsaDefaults("baseline", ns, []api.StatementSpec{baseline})).Should(Succeed())
+
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		createResource(api.NewIamRoleServiceAccount(name, ns, api.PolicySpec{Statement: []api.StatementSpec{inline}})).Should(Succeed())
+
+		foundIrsaInCondition(name, ns, api.IrsaOK).Should(BeTrue())
+		Expect(getIrsa(name, ns).Status.InheritedStatement).To(Equal([]api.StatementSpec{baseline}))
+		Expect(getPolicy(name, ns).Spec.Statement).To(Equal([]api.StatementSpec{inline, baseline}))
+	})
+
+	It("gets the new statements when the defaults change", func() {
+		defaults := &api.NamespaceIrsaDefaults{}
+		getOnK8s("baseline", ns, defaults)
+		defaults.Spec.Statement[0].Action = []string{"logs:CreateLogStream", "logs:PutLogEvents"}
+		Expect(k8sClient.Update(context.Background(), defaults)).Should(Succeed())
+
+		Eventually(func() []string {
+			return getPolicy(name, ns).Spec.Statement[1].Action
+		}, resourcePollTimeout, resourcePollInterval).Should(Equal([]string{"logs:CreateLogStream", "logs:PutLogEvents"}))
+		Expect(getIrsa(name, ns).Status.InheritedStatement[0].Action).To(Equal([]string{"logs:CreateLogStream", "logs:PutLogEvents"}))
+	})
+
+	It("stops inheriting them once the defaults are deleted", func() {
+		defaults := &api.NamespaceIrsaDefaults{}
+		getOnK8s("baseline", ns, defaults)
+		Expect(k8sClient.Delete(context.Background(), defaults)).Should(Succeed())
+
+		Eventually(func() []api.StatementSpec {
+			return getPolicy(name, ns).Spec.Statement
+		}, resourcePollTimeout, resourcePollInterval).Should(Equal([]api.StatementSpec{inline}))
+		Expect(getIrsa(name, ns).Status.InheritedStatement).To(BeEmpty())
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount with additional service accounts", func() {
+	name := validName()
+	additional := []string{validName(), validName()}
+	subject := func(sa string) string { return "system:serviceaccount:" + testns + ":" + sa }
+
+	It("rejects the invalid or duplicated names", func() {
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.Spec.AdditionalServiceAccounts = []string{"Not_Valid"}
+		Expect(irsa.Validate()).To(MatchError(ContainSubstring("invalid service account name")))
+
+		irsa.Spec.AdditionalServiceAccounts = []string{name}
+		Expect(irsa.Validate()).To(MatchError(ContainSubstring("listed twice")))
+	})
+
+	It("creates every service account, all trusted by the role", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.Spec.AdditionalServiceAccounts = additional
+		createResource(irsa).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+		for _, sa := range append([]string{name}, additional...) {
+			findSa(sa, testns).Should(BeTrue())
+			obj := &corev1.ServiceAccount{}
+			getOnK8s(sa, testns, obj)
+			Expect(obj.Annotations["eks.amazonaws.com/role-arn"]).To(Equal(getRole(name, testns).Spec.RoleARN))
+		}
+		Expect(stackOf(name).role.trustedSubjects).To(ConsistOf(subject(name), subject(additional[0]), subject(additional[1])))
+	})
+
+	It("narrows the trust policy when a service account is removed", func() {
+		irsa := getIrsa(name, testns)
+		irsa.Spec.AdditionalServiceAccounts = additional[:1]
+		Expect(k8sClient.Update(context.Background(), &irsa)).Should(Succeed())
+
+		Eventually(func() []string {
+			return stackOf(name).role.trustedSubjects
+		}, resourcePollTimeout, resourcePollInterval).Should(ConsistOf(subject(name), subject(additional[0])))
+
+		Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: additional[1], Namespace: testns}, &corev1.ServiceAccount{})
+			return k8serrors.IsNotFound(err)
+		}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount with additional trusted principals", func() {
+	name := validName()
+	sre := "arn:aws:iam::123456789012:role/sre"
+
+	It("rejects the principals that aren't IAM ARNs", func() {
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.Spec.AdditionalTrustedPrincipals = []api.TrustedPrincipal{{ARN: "arn:aws:s3:::my_corporate_bucket"}}
+		Expect(irsa.Validate()).To(MatchError(ContainSubstring("is not an IAM ARN")))
+	})
+
+	It("trusts the principal until it expires", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		expiresAt := metav1.NewTime(time.Now().Add(20 * time.Second))
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.Spec.AdditionalTrustedPrincipals = []api.TrustedPrincipal{{ARN: sre, ExpiresAt: &expiresAt}}
+		createResource(irsa).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+		Expect(stackOf(name).role.trustedPrincipals).To(Equal([]string{sre}))
+
+		Eventually(func() []string {
+			return stackOf(name).role.trustedPrincipals
+		}, resourcePollTimeout, resourcePollInterval).Should(BeEmpty())
+		Expect(getRole(name, testns).Status.TrustedPrincipals).To(BeEmpty())
+	})
+
+	It("reports the changes as events", func() {
+		Eventually(func() []string {
+			events := &corev1.EventList{}
+			Expect(k8sClient.List(context.Background(), events, client.InNamespace(testns))).To(Succeed())
+
+			reasons := []string{}
+			for _, e := range events.Items {
+				if e.InvolvedObject.Name == name {
+					reasons = append(reasons, e.Reason)
+				}
+			}
+			return reasons
+		}, resourcePollTimeout, resourcePollInterval).Should(ContainElements("TrustedPrincipalAdded", "TrustedPrincipalRemoved"))
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount whose guardrail is detached outside of the operator", func() {
+	name := validName()
+
+	It("gets it attached again by the resync of its role", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		createResource(api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})).Should(Succeed())
+		foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+		Expect(stackOf(name).role.attachedPolicies).To(ContainElement(guardrailPolicyARN))
+
+		Expect(st.DetachRolePolicy(context.Background(), getRole(name, testns).Status.AwsName, guardrailPolicyARN)).To(Succeed())
+		Expect(stackOf(name).role.attachedPolicies).NotTo(ContainElement(guardrailPolicyARN))
+
+		Eventually(func() []string {
+			return stackOf(name).role.attachedPolicies
+		}, resourcePollTimeout, resourcePollInterval).Should(ContainElement(guardrailPolicyARN))
+		Expect(getRole(name, testns).Status.LastResyncTime).NotTo(BeNil())
+	})
+})
+
+var _ = Describe("Sensitive permissions", func() {
+	It("parses the action & resource patterns", func() {
+		perms, err := api.ParseSensitivePermissions([]string{"iam:*", "kms:Decrypt=arn:aws:kms:*:*:key/prod-*"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(perms).To(Equal(api.SensitivePermissions{
+			{Action: "iam:*", Resource: "*"},
+			{Action: "kms:Decrypt", Resource: "arn:aws:kms:*:*:key/prod-*"},
+		}))
+
+		_, err = api.ParseSensitivePermissions([]string{"=arn:aws:kms:*:*:key/prod-*"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("matches the statements which may grant them", func() {
+		matches := func(stm api.StatementSpec) bool {
+			return len(testSensitivePermissions.Match([]api.StatementSpec{stm})) > 0
+		}
+
+		Expect(matches(api.StatementSpec{Resource: "*", Action: []string{"IAM:PassRole"}})).To(BeTrue())
+		Expect(matches(api.StatementSpec{Resource: "arn:aws:iam::123456789012:role/app", Action: []string{"iam:*"}})).To(BeTrue())
+		Expect(matches(api.StatementSpec{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}})).To(BeTrue())
+		Expect(matches(api.StatementSpec{Resource: "arn:aws:kms:eu-west-1:123456789012:key/*", Action: []string{"kms:*"}})).To(BeTrue())
+		Expect(matches(api.StatementSpec{Resource: "arn:aws:kms:eu-west-1:123456789012:key/staging-db", Action: []string{"kms:Decrypt"}})).To(BeFalse())
+		Expect(matches(api.StatementSpec{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Encrypt"}})).To(BeFalse())
+	})
+})
+
+var _ = Describe("IamRoleServiceAccount requesting sensitive permissions", func() {
+	name := validName()
+	stmts := []api.StatementSpec{
+		{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}},
+	}
+
+	It("waits for an approval", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		createResource(api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: stmts})).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaPending).Should(BeTrue())
+		Expect(getIrsa(name, testns).Status.Reason).To(ContainSubstring(api.PolicySpec{Statement: stmts}.ApprovalDigest()))
+		Consistently(func() bool {
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testns}, &api.Policy{})
+			return k8serrors.IsNotFound(err)
+		}, 2*time.Second, resourcePollInterval).Should(BeTrue())
+	})
+
+	It("applies the statements once approved", func() {
+		irsa := getIrsa(name, testns)
+		irsa.Annotations = map[string]string{api.ApprovalAnnotation: api.PolicySpec{Statement: stmts}.ApprovalDigest() + ":security-lead"}
+		Expect(k8sClient.Update(context.Background(), &irsa)).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaOK).Should(BeTrue())
+		Expect(stackOf(name).events).To(ContainElement("success : " + string(createPolicy)))
+	})
+
+	It("invalidates the approval when the statements change", func() {
+		irsa := getIrsa(name, testns)
+		irsa.Spec.Policy.Statement = append(irsa.Spec.Policy.Statement, api.StatementSpec{
+			Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-billing", Action: []string{"kms:Decrypt"},
+		})
+		Expect(k8sClient.Update(context.Background(), &irsa)).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaPending).Should(BeTrue())
+		Expect(getIrsa(name, testns).Status.Reason).To(ContainSubstring("the approval of security-lead is outdated"))
+		Expect(getPolicy(name, testns).Spec.Statement).To(HaveLen(1)) // the policy is left as approved
+	})
+})
+
+var _ = Describe("Shared policy granting sensitive permissions", func() {
+	shared, name := validName(), validName()
+	stmts := []api.StatementSpec{
+		{Resource: "arn:aws:kms:eu-west-1:123456789012:key/prod-db", Action: []string{"kms:Decrypt"}},
+	}
+
+	It("waits for an approval of its own", func() {
+		st.stacks.Store(shared, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		createResource(api.NewPolicy(shared, testns, stmts)).Should(Succeed())
+
+		foundPolicyInCondition(shared, testns, api.CrPending).Should(BeTrue())
+		Expect(getPolicy(shared, testns).Status.Reason).To(ContainSubstring(api.PolicySpec{Statement: stmts}.ApprovalDigest()))
+		Expect(stackOf(shared).events).NotTo(ContainElement("success : " + string(createPolicy)))
+
+		policy := getPolicy(shared, testns)
+		policy.Annotations = map[string]string{api.ApprovalAnnotation: api.PolicySpec{Statement: stmts}.ApprovalDigest() + ":security-lead"}
+		Expect(k8sClient.Update(context.Background(), &policy)).Should(Succeed())
+		foundPolicyInCondition(shared, testns, api.CrOK).Should(BeTrue())
+	})
+
+	It("is approved along with the irsas referencing it", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{})
+		irsa.Spec.PolicyRefs = []api.PolicyRef{{Name: shared}}
+		createResource(irsa).Should(Succeed())
+
+		foundIrsaInCondition(name, testns, api.IrsaPending).Should(BeTrue())
+		Expect(getIrsa(name, testns).Status.Reason).To(ContainSubstring(api.PolicySpec{Statement: stmts}.ApprovalDigest()))
+	})
 })
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+)
+
+// RequesterWebhookPath is the path the RequesterRecorder is served on by the webhook server of the manager
+const RequesterWebhookPath = "/mutate-irsa-voodoo-io-v1alpha1-iamroleserviceaccount"
+
+// ApprovalWebhookPath is the path the ApprovalValidator is served on by the webhook server of the manager
+const ApprovalWebhookPath = "/validate-irsa-voodoo-io-v1alpha1-approval"
+
+func NewRequesterRecorder(operator string) *RequesterRecorder {
+	return &RequesterRecorder{operator: operator}
+}
+
+// RequesterRecorder is a mutating admission webhook recording who requested the statements of an IamRoleServiceAccount or of a Policy,
+// or the rollback of a Policy : the operator restores the statements of the version in the spec of the irsa on behalf of this requester
+type RequesterRecorder struct {
+	decoder  *admission.Decoder
+	operator string // the username of the operator, its updates never make it the requester
+}
+
+// +kubebuilder:webhook:path=/mutate-irsa-voodoo-io-v1alpha1-iamroleserviceaccount,mutating=true,failurePolicy=fail,sideEffects=None,groups=irsa.voodoo.io,resources=iamroleserviceaccounts;policies,verbs=create;update,versions=v1alpha1,name=requester.irsa.voodoo.io,admissionReviewVersions={v1,v1beta1}
+
+// Handle is called by the API server each time an api.IamRoleServiceAccount or an api.Policy is created or updated
+func (w *RequesterRecorder) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Resource.Resource == "policies" {
+		return w.handlePolicy(req)
+	}
+
+	irsa := &api.IamRoleServiceAccount{}
+	if err := w.decoder.Decode(req, irsa); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	old := &api.IamRoleServiceAccount{}
+	if req.Operation == admissionv1.Update {
+		if err := w.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	{ // the requester is whoever changed the spec, the other updates (eg. the finalizers set by the operator) keep it
+		specChanged := req.Operation == admissionv1.Create || !equality.Semantic.DeepEqual(old.Spec, irsa.Spec)
+		switch {
+		case specChanged && req.UserInfo.Username != w.operator:
+			irsa.SetRequester(api.Requester{Username: req.UserInfo.Username, Groups: req.UserInfo.Groups})
+		case specChanged && irsa.ObjectMeta.Annotations[api.RequesterAnnotation] != "": // a rollback, the operator sets the requester of the rollback
+		default: // the annotation can't be changed by hand
+			irsa.ObjectMeta.Annotations = keepRequester(irsa.ObjectMeta.Annotations, old.ObjectMeta.Annotations)
+		}
+	}
+
+	return patchResponse(req, irsa)
+}
+
+// handlePolicy records who changed the statements of the policy or asked for its rollback, the other updates keep it
+func (w *RequesterRecorder) handlePolicy(req admission.Request) admission.Response {
+	policy := &api.Policy{}
+	if err := w.decoder.Decode(req, policy); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	old := &api.Policy{}
+	if req.Operation == admissionv1.Update {
+		if err := w.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	version := policy.ObjectMeta.Annotations[api.RollbackToVersionAnnotation]
+	rollbackRequested := version != "" && version != old.ObjectMeta.Annotations[api.RollbackToVersionAnnotation]
+	statementChanged := req.Operation == admissionv1.Create || !equality.Semantic.DeepEqual(old.Spec.Statement, policy.Spec.Statement) // not its arn, set by the operator
+	if rollbackRequested || (statementChanged && req.UserInfo.Username != w.operator) {
+		policy.SetRequester(api.Requester{Username: req.UserInfo.Username, Groups: req.UserInfo.Groups})
+	} else { // the rollbacks of the operator keep it & the annotation can't be changed by hand
+		policy.ObjectMeta.Annotations = keepRequester(policy.ObjectMeta.Annotations, old.ObjectMeta.Annotations)
+	}
+
+	return patchResponse(req, policy)
+}
+
+// keepRequester returns the annotations with the requester found in the previous ones (if any)
+func keepRequester(annotations, previous map[string]string) map[string]string {
+	if requester, recorded := previous[api.RequesterAnnotation]; recorded {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[api.RequesterAnnotation] = requester
+	} else {
+		delete(annotations, api.RequesterAnnotation)
+	}
+	return annotations
+}
+
+func patchResponse(req admission.Request, obj interface{}) admission.Response {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, b)
+}
+
+// InjectDecoder is called by the webhook server when the handler is registered
+func (w *RequesterRecorder) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	return nil
+}
+
+func NewApprovalValidator(c client.Client) *ApprovalValidator {
+	return &ApprovalValidator{client: c}
+}
+
+// ApprovalValidator is a validating admission webhook making sure the approvals of the IamRoleServiceAccounts, of the ClusterIamRoleServiceAccounts & of the Policies
+// are set by the approver they name, who must be allowed to approve them : otherwise anyone allowed to edit them could approve their own statements
+type ApprovalValidator struct {
+	client client.Client
+}
+
+// +kubebuilder:webhook:path=/validate-irsa-voodoo-io-v1alpha1-approval,mutating=false,failurePolicy=fail,sideEffects=None,groups=irsa.voodoo.io,resources=iamroleserviceaccounts;clusteriamroleserviceaccounts;policies,verbs=create;update,versions=v1alpha1,name=approval.irsa.voodoo.io,admissionReviewVersions={v1,v1beta1}
+
+// Handle is called by the API server each time an api.IamRoleServiceAccount, an api.ClusterIamRoleServiceAccount or an api.Policy is created or updated
+func (w *ApprovalValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj, old := &metav1.PartialObjectMetadata{}, &metav1.PartialObjectMetadata{} // only the annotations matter
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Update {
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	approval := obj.Annotations[api.ApprovalAnnotation]
+	if approval == "" || approval == old.Annotations[api.ApprovalAnnotation] { // nothing approved by this request
+		return admission.Allowed("")
+	}
+
+	_, approver, err := api.ParseApproval(obj.Annotations)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if approver != req.UserInfo.Username {
+		return admission.Denied("the approval of " + approver + " can only be set by " + approver)
+	}
+
+	allowed, err := mayApprove(ctx, w.client, approver, req.Resource.Resource, req.Namespace, req.Name)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !allowed {
+		return admission.Denied(approver + " isn't allowed to approve " + req.Resource.Resource)
+	}
+	return admission.Allowed("")
+}
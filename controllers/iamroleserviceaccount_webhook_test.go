@@ -0,0 +1,344 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
+	irsaCtrl "github.com/VoodooTeam/irsa-operator/controllers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("AWS actions granted by a requester", func() {
+	It("are covered by the action itself & the wildcards of its literal prefix", func() {
+		service, verbs := api.GrantVerbs("s3:GetObject")
+		Expect(service).To(Equal("s3"))
+		Expect(verbs).To(Equal([]string{"getobject", "getobject*", "getobjec*", "getobje*", "getobj*", "getob*", "geto*", "get*", "ge*", "g*", "*"}))
+
+		_, verbs = api.GrantVerbs("s3:Get*")
+		Expect(verbs).To(Equal([]string{"get*", "ge*", "g*", "*"}))
+
+		_, verbs = api.GrantVerbs("s3:*")
+		Expect(verbs).To(Equal([]string{"*"}))
+
+		service, verbs = api.GrantVerbs("*")
+		Expect(service).To(Equal("*"))
+		Expect(verbs).To(Equal([]string{"*"}))
+	})
+
+	It("are checked again when the statements or the requester change", func() {
+		stmts := []api.StatementSpec{{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}}
+		alice := api.Requester{Username: "alice", Groups: []string{"team-a"}}
+		digest := alice.AuthorizationDigest(stmts)
+
+		Expect(alice.AuthorizationDigest([]api.StatementSpec{{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}})).To(Equal(digest))
+		Expect(alice.AuthorizationDigest(append(stmts, api.StatementSpec{Resource: "*", Action: []string{"iam:*"}}))).NotTo(Equal(digest))
+		Expect(api.Requester{Username: "bob", Groups: []string{"team-a"}}.AuthorizationDigest(stmts)).NotTo(Equal(digest))
+	})
+})
+
+var _ = Describe("RequesterRecorder", func() {
+	operator := authenticationv1.UserInfo{Username: "system:serviceaccount:irsa-operator:irsa-operator"}
+	recorder := irsaCtrl.NewRequesterRecorder(operator.Username)
+	BeforeEach(func() {
+		decoder, err := admission.NewDecoder(scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.InjectDecoder(decoder)).To(Succeed())
+	})
+
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"team-a"}}
+	newIrsa := func() *api.IamRoleServiceAccount {
+		irsa := api.NewIamRoleServiceAccount(validName(), testns, api.PolicySpec{Statement: []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		}})
+		irsa.TypeMeta.APIVersion, irsa.TypeMeta.Kind = api.GroupVersion.String(), "IamRoleServiceAccount"
+		return irsa
+	}
+	request := func(op admissionv1.Operation, user authenticationv1.UserInfo, obj, old *api.IamRoleServiceAccount) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: op, UserInfo: user}}
+		req.Object = runtime.RawExtension{Raw: mustMarshal(obj)}
+		if old != nil {
+			req.OldObject = runtime.RawExtension{Raw: mustMarshal(old)}
+		}
+		return req
+	}
+	requesterOf := func(res admission.Response) interface{} {
+		Expect(res.Allowed).To(BeTrue())
+		for _, p := range res.Patches {
+			if p.Path == "/metadata/annotations" {
+				return p.Value.(map[string]interface{})[api.RequesterAnnotation]
+			}
+			if p.Path == "/metadata/annotations/irsa.voodoo.io~1requester" {
+				return p.Value
+			}
+		}
+		return nil
+	}
+
+	It("records the requester at creation", func() {
+		res := recorder.Handle(context.Background(), request(admissionv1.Create, alice, newIrsa(), nil))
+		Expect(requesterOf(res)).To(Equal(`{"username":"alice","groups":["team-a"]}`))
+	})
+
+	It("keeps the requester when the spec doesn't change", func() {
+		old := newIrsa()
+		old.SetRequester(api.Requester{Username: "alice"})
+		irsa := old.DeepCopy()
+		irsa.Annotations[api.RequesterAnnotation] = `{"username":"admin"}`
+		irsa.Finalizers = []string{"irsa.irsa.voodoo.io"}
+
+		res := recorder.Handle(context.Background(), request(admissionv1.Update, authenticationv1.UserInfo{Username: "bob"}, irsa, old))
+		Expect(requesterOf(res)).To(Equal(`{"username":"alice"}`))
+	})
+
+	It("records the new requester when the spec changes", func() {
+		old := newIrsa()
+		old.SetRequester(api.Requester{Username: "alice"})
+		irsa := old.DeepCopy()
+		irsa.Spec.Policy.Statement[0].Action = []string{"s3:*"}
+
+		res := recorder.Handle(context.Background(), request(admissionv1.Update, authenticationv1.UserInfo{Username: "bob"}, irsa, old))
+		Expect(requesterOf(res)).To(Equal(`{"username":"bob"}`))
+	})
+
+	It("never records the operator, which restores the statements of a rollback on behalf of its requester", func() {
+		old := newIrsa()
+		old.SetRequester(api.Requester{Username: "alice"})
+		irsa := old.DeepCopy()
+		irsa.Spec.Policy.Statement[0].Action = []string{"s3:PutObject"}
+		delete(irsa.Annotations, api.RequesterAnnotation)
+
+		res := recorder.Handle(context.Background(), request(admissionv1.Update, operator, irsa, old))
+		Expect(requesterOf(res)).To(Equal(`{"username":"alice"}`))
+
+		irsa.SetRequester(api.Requester{Username: "bob"})
+		res = recorder.Handle(context.Background(), request(admissionv1.Update, operator, irsa, old))
+		Expect(res.Allowed).To(BeTrue())
+		Expect(res.Patches).To(BeEmpty()) // the requester it sets is kept
+
+		res = recorder.Handle(context.Background(), request(admissionv1.Update, alice, irsa, old)) // only the operator can set it
+		Expect(requesterOf(res)).To(Equal(`{"username":"alice","groups":["team-a"]}`))
+	})
+
+	It("records who asks for the rollback of a policy", func() {
+		old := api.NewPolicy(validName(), testns, []api.StatementSpec{{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}})
+		old.TypeMeta.APIVersion, old.TypeMeta.Kind = api.GroupVersion.String(), "Policy"
+		policy := old.DeepCopy()
+		policy.Annotations = map[string]string{api.RollbackToVersionAnnotation: "v1", api.RequesterAnnotation: `{"username":"admin"}`}
+
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update, UserInfo: alice,
+			Resource: metav1.GroupVersionResource{Group: api.GroupVersion.Group, Version: api.GroupVersion.Version, Resource: "policies"},
+		}}
+		req.Object = runtime.RawExtension{Raw: mustMarshal(policy)}
+		req.OldObject = runtime.RawExtension{Raw: mustMarshal(old)}
+		Expect(requesterOf(recorder.Handle(context.Background(), req))).To(Equal(`{"username":"alice","groups":["team-a"]}`))
+	})
+
+	It("records who changes the spec of a policy, but never the operator", func() {
+		old := api.NewPolicy(validName(), testns, []api.StatementSpec{{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}})
+		old.TypeMeta.APIVersion, old.TypeMeta.Kind = api.GroupVersion.String(), "Policy"
+		old.SetRequester(api.Requester{Username: "bob"})
+		policy := old.DeepCopy()
+		policy.Spec.Statement[0].Action = []string{"s3:*"}
+
+		request := func(user authenticationv1.UserInfo) admission.Request {
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update, UserInfo: user,
+				Resource: metav1.GroupVersionResource{Group: api.GroupVersion.Group, Version: api.GroupVersion.Version, Resource: "policies"},
+			}}
+			req.Object = runtime.RawExtension{Raw: mustMarshal(policy)}
+			req.OldObject = runtime.RawExtension{Raw: mustMarshal(old)}
+			return req
+		}
+		Expect(requesterOf(recorder.Handle(context.Background(), request(alice)))).To(Equal(`{"username":"alice","groups":["team-a"]}`))
+
+		res := recorder.Handle(context.Background(), request(operator)) // eg. a rollback, on behalf of its requester
+		Expect(res.Allowed).To(BeTrue())
+		Expect(res.Patches).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Requesters authorization", func() {
+	ctx := context.Background()
+	granted := api.StatementSpec{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}
+	forbidden := api.StatementSpec{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:PutObject"}}
+	grants := map[string][]string{"alice": {"s3:get*"}, "bob": {"s3:*"}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testns}}
+
+	Context("of a shared policy", func() {
+		name := validName()
+		aws := newAwsFake()
+		var c client.Client
+		var reconciler *irsaCtrl.PolicyReconciler
+		reconcile := func() api.Policy {
+			for i := 0; i < 10; i++ { // a pass per step (finalizer, admission, creation on aws...)
+				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: testns}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			policy := api.Policy{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: testns}, &policy)).To(Succeed())
+			return policy
+		}
+
+		It("is only created on aws once its requester is allowed to grant its actions", func() {
+			aws.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			policy := api.NewPolicy(name, testns, []api.StatementSpec{granted, forbidden})
+			policy.SetRequester(api.Requester{Username: "alice"})
+			c = grantersClient{Client: fake.NewClientBuilder().WithObjects(namespace, policy).Build(), grants: grants}
+			naming, err := api.NewNaming("clustername", api.DefaultNameTemplate, api.DefaultRootPath)
+			Expect(err).NotTo(HaveOccurred())
+			reconciler = irsaCtrl.NewPolicyReconciler(c, scheme.Scheme, aws, ctrl.Log.WithName("policy"), record.NewFakeRecorder(100), naming, nil, time.Hour, 0, 1, nil, testPlaceholders, nil, true)
+
+			p := reconcile()
+			Expect(p.Status.Condition).To(Equal(api.CrForbidden))
+			Expect(p.Status.Reason).To(Equal("alice isn't allowed to grant s3:putobject"))
+			v, _ := aws.stacks.Load(name)
+			Expect(v.(awsStack).events).NotTo(ContainElement("success : " + string(createPolicy)))
+
+			p.SetRequester(api.Requester{Username: "bob"}) // the webhook records whoever changes the spec
+			Expect(c.Update(ctx, &p)).To(Succeed())
+			Expect(reconcile().Status.Condition).To(Equal(api.CrOK))
+		})
+
+		It("isn't updated on aws when edited by someone not allowed to grant its actions", func() {
+			p := reconcile()
+			v, _ := aws.stacks.Load(name)
+			authorized := v.(awsStack).policy.Statement
+			p.Spec.Statement = []api.StatementSpec{granted, forbidden, {Resource: "arn:aws:iam::123456789012:role/app", Action: []string{"iam:PassRole"}}}
+			p.SetRequester(api.Requester{Username: "bob"})
+			Expect(c.Update(ctx, &p)).To(Succeed())
+
+			p = reconcile()
+			Expect(p.Status.Condition).To(Equal(api.CrForbidden))
+			Expect(p.Status.Reason).To(Equal("bob isn't allowed to grant iam:passrole"))
+			v, _ = aws.stacks.Load(name)
+			Expect(v.(awsStack).policy.Statement).To(Equal(authorized))
+		})
+	})
+
+	It("detaches the shared policies granting the actions denied to the requester of an irsa", func() {
+		name, allowedRef, deniedRef := validName(), validName(), validName()
+		irsa := api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{granted}})
+		irsa.Spec.PolicyRefs = []api.PolicyRef{{Name: allowedRef}, {Name: deniedRef}}
+		irsa.SetRequester(api.Requester{Username: "alice"})
+		irsa.Finalizers = []string{"irsa.irsa.voodoo.io"}
+		irsa.Status = api.IamRoleServiceAccountStatus{Condition: api.IrsaOK, Reason: "all done"}
+		role := api.NewRole(name, testns)
+		role.Spec.PolicyRefs = []string{allowedRef, deniedRef}
+
+		c := grantersClient{Client: fake.NewClientBuilder().WithObjects(
+			namespace, irsa, role,
+			api.NewPolicy(allowedRef, testns, []api.StatementSpec{granted}),
+			api.NewPolicy(deniedRef, testns, []api.StatementSpec{forbidden}),
+		).Build(), grants: grants}
+		reconciler := irsaCtrl.NewIrsaReconciler(c, scheme.Scheme, ctrl.Log.WithName("irsa"), nil, testPlaceholders, nil, true)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: testns}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: testns}, irsa)).To(Succeed())
+		Expect(irsa.Status.Condition).To(Equal(api.IrsaForbidden))
+		Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: testns}, role)).To(Succeed())
+		Expect(role.Spec.PolicyRefs).To(Equal([]string{allowedRef}))
+	})
+})
+
+var _ = Describe("ApprovalValidator", func() {
+	validator := irsaCtrl.NewApprovalValidator(approversClient{Client: fake.NewClientBuilder().Build(), approvers: map[string]bool{"security-lead": true}})
+
+	irsaResource := metav1.GroupVersionResource{Group: api.GroupVersion.Group, Version: api.GroupVersion.Version, Resource: "iamroleserviceaccounts"}
+	policyResource := metav1.GroupVersionResource{Group: api.GroupVersion.Group, Version: api.GroupVersion.Version, Resource: "policies"}
+	clusterIrsaResource := metav1.GroupVersionResource{Group: api.GroupVersion.Group, Version: api.GroupVersion.Version, Resource: "clusteriamroleserviceaccounts"}
+	request := func(resource metav1.GroupVersionResource, user string, obj, old client.Object) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update, Resource: resource, UserInfo: authenticationv1.UserInfo{Username: user},
+			Namespace: obj.GetNamespace(), Name: obj.GetName(),
+		}}
+		req.Object = runtime.RawExtension{Raw: mustMarshal(obj)}
+		req.OldObject = runtime.RawExtension{Raw: mustMarshal(old)}
+		return req
+	}
+	stmts := []api.StatementSpec{{Resource: "arn:aws:kms:eu-west-1:" + allowedResourceAccountID + ":key/prod-payments", Action: []string{"kms:Decrypt"}}}
+	approved := func(o client.Object, approver string) client.Object {
+		o.SetAnnotations(map[string]string{api.ApprovalAnnotation: api.PolicySpec{Statement: stmts}.ApprovalDigest() + ":" + approver})
+		return o
+	}
+
+	irsa := func() client.Object {
+		return api.NewIamRoleServiceAccount("irsa", testns, api.PolicySpec{Statement: stmts})
+	}
+	clusterIrsa := func() client.Object {
+		return api.NewClusterIamRoleServiceAccount("irsa", metav1.LabelSelector{}, api.PolicySpec{Statement: stmts})
+	}
+	DescribeTable("checks who sets the approvals",
+		func(resource metav1.GroupVersionResource, user string, obj, old client.Object, allowed bool) {
+			res := validator.Handle(context.Background(), request(resource, user, obj, old))
+			Expect(res.Allowed).To(Equal(allowed))
+		},
+		Entry("an approval set by its approver", irsaResource, "security-lead", approved(irsa(), "security-lead"), irsa(), true),
+		Entry("an approval naming someone else", irsaResource, "alice", approved(irsa(), "security-lead"), irsa(), false),
+		Entry("a self approval without the approve verb", irsaResource, "alice", approved(irsa(), "alice"), irsa(), false),
+		Entry("a self approval of a shared policy", policyResource, "alice", approved(api.NewPolicy("shared", testns, stmts), "alice"), api.NewPolicy("shared", testns, stmts), false),
+		Entry("a self approval of a cluster irsa", clusterIrsaResource, "alice", approved(clusterIrsa(), "alice"), clusterIrsa(), false),
+		Entry("an approval of a cluster irsa set by its approver", clusterIrsaResource, "security-lead", approved(clusterIrsa(), "security-lead"), clusterIrsa(), true),
+		Entry("an update keeping the approval", irsaResource, "alice", approved(irsa(), "security-lead"), approved(irsa(), "security-lead"), true),
+	)
+})
+
+// grantersClient answers the SubjectAccessReviews on the awsactions, each user is only allowed the given "<service>:<verb>"
+type grantersClient struct {
+	client.Client
+	grants map[string][]string
+}
+
+func (c grantersClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	sar, ok := obj.(*authorizationv1.SubjectAccessReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	attrs := sar.Spec.ResourceAttributes
+	for _, g := range c.grants[sar.Spec.User] {
+		sar.Status.Allowed = sar.Status.Allowed || (attrs.Resource == api.AwsActionsResource && g == attrs.Name+":"+attrs.Verb)
+	}
+	return nil
+}
+
+// approversClient answers the SubjectAccessReviews, only the approvers are allowed to approve
+type approversClient struct {
+	client.Client
+	approvers map[string]bool
+}
+
+func (c approversClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	sar, ok := obj.(*authorizationv1.SubjectAccessReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	sar.Status.Allowed = sar.Spec.ResourceAttributes.Verb == api.ApprovalVerb && c.approvers[sar.Spec.User]
+	return nil
+}
+
+func mustMarshal(o interface{}) []byte {
+	b, err := json.Marshal(o)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -78,7 +78,7 @@ func run(irsaName string, wg *sync.WaitGroup) {
 
 	submittedPolicy := api.PolicySpec{
 		Statement: []api.StatementSpec{
-			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"act1"}},
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
 		},
 	}
 
@@ -110,8 +110,7 @@ func run(irsaName string, wg *sync.WaitGroup) {
 
 		// role
 		Expect(stack.role).ShouldNot(BeNil())
-		Expect(len(stack.role.attachedPolicies)).To(Equal(1))
-		Expect(stack.role.attachedPolicies[0]).To(Equal(stack.policy.ARN))
+		Expect(stack.role.attachedPolicies).To(ConsistOf(stack.policy.ARN, guardrailPolicyARN))
 
 		{
 			iamrsa := getIrsa(irsaName, testns)
@@ -133,8 +132,11 @@ func getInitialErrs() map[awsMethod]struct{} {
 	methods := []awsMethod{
 		policyExists,
 		getStatement,
+		getPolicyDefaultVersionID,
+		listPolicyVersions,
 		updatePolicy,
 		createPolicy,
+		updateAssumeRolePolicy,
 		deletePolicy,
 		getPolicyARN,
 		createRole,
@@ -144,6 +146,12 @@ func getInitialErrs() map[awsMethod]struct{} {
 		roleExists,
 		getRoleARN,
 		getAttachedRolePoliciesARNs,
+		getPolicyTags,
+		tagPolicy,
+		untagPolicy,
+		getRoleTags,
+		tagRole,
+		untagRole,
 	}
 
 	errs := make(map[awsMethod]struct{})
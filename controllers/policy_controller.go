@@ -3,43 +3,76 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 )
 
-func NewPolicyReconciler(client client.Client, scheme *runtime.Scheme, awspm AwsPolicyManager, logger logr.Logger, cN string) *PolicyReconciler {
+func NewPolicyReconciler(client client.Client, scheme *runtime.Scheme, awspm AwsPolicyManager, logger logr.Logger, recorder record.EventRecorder, naming api.Naming, propagatedLabelKeys []string, fullSyncPeriod, updateDebounce time.Duration, maxShards int, allowedResourceAccountIDs []string, placeholders api.Placeholders, sensitivePermissions api.SensitivePermissions, authorizeRequesters bool) *PolicyReconciler {
 	return &PolicyReconciler{
-		Client:      client,
-		log:         logger,
-		scheme:      scheme,
-		awsPM:       awspm,
-		finalizerID: "policy.irsa.voodoo.io",
-		clusterName: cN,
+		Client:              client,
+		log:                 logger,
+		recorder:            recorder,
+		scheme:              scheme,
+		awsPM:               awspm,
+		finalizerID:         "policy.irsa.voodoo.io",
+		naming:              naming,
+		propagatedLabelKeys: propagatedLabelKeys,
+		fullSyncPeriod:      fullSyncPeriod,
+		updateDebounce:      updateDebounce,
+		maxShards:           maxShards,
+
+		allowedResourceAccountIDs: allowedResourceAccountIDs,
+		placeholders:              placeholders,
+		sensitivePermissions:      sensitivePermissions,
+		authorizeRequesters:       authorizeRequesters,
 	}
 }
 
 // PolicyReconciler reconciles a Policy object
 type PolicyReconciler struct {
 	client.Client
-	scheme *runtime.Scheme
-	awsPM  AwsPolicyManager
-	log    logr.Logger
-
-	finalizerID string
-	clusterName string
+	scheme   *runtime.Scheme
+	awsPM    AwsPolicyManager
+	log      logr.Logger
+	recorder record.EventRecorder // the statements revoked once expired are reported as events
+
+	finalizerID         string
+	naming              api.Naming
+	propagatedLabelKeys []string      // labels (of the policy or its namespace) set as tags on the aws policy
+	fullSyncPeriod      time.Duration // how often the document on aws is compared to the spec even if it seems up to date
+	updateDebounce      time.Duration // how long the spec must remain unchanged before a new version of the aws policy is created
+	maxShards           int           // how many aws policies the statements can be split across (they're all attached to the role)
+
+	allowedResourceAccountIDs []string                 // the accounts the resources of the statements can belong to (any if empty)
+	placeholders              api.Placeholders         // the values of the placeholders resolved in the statements
+	sensitivePermissions      api.SensitivePermissions // the permissions the shared policies can only grant once approved
+	authorizeRequesters       bool                     // only apply the actions the requesters of the shared policies are allowed to grant (recorded by the RequesterRecorder)
 }
 
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=policies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=policies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=policies/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=irsa.voodoo.io,resources=iamroleserviceaccounts,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 // Reconcile is called each time an event occurs on an api.Policy resource
 func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -76,6 +109,22 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return r.admissionStep(ctx, policy)
 	}
 
+	if versionID, ok := policy.Annotations[api.RollbackToVersionAnnotation]; ok && policy.Spec.ARN != "" {
+		return r.rollback(ctx, policy, versionID)
+	}
+
+	if policy.Status.AwsName == "" || policy.Status.AwsPath == "" { // created before the aws name & path were recorded in the status
+		name, err := policy.AwsName(r.naming)
+		if err != nil {
+			ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+		policy.Status.AwsName = name
+		policy.Status.AwsPath = policy.Path(r.naming)
+		ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(policy.Status.Condition, "aws name recorded"))
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
 	// for whatever condition we'll try to check the aws policy needs to be created or updated
 	return r.reconcilerRoutine(ctx, policy)
 }
@@ -84,6 +133,8 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.Policy{}).
+		// a shared policy waiting to be deleted is reconciled when an irsa stops referencing it
+		Watches(&source.Kind{Type: &api.IamRoleServiceAccount{}}, handler.EnqueueRequestsFromMapFunc(policiesReferencedBy)).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 10,
 		}).
@@ -96,27 +147,58 @@ func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 // admissionStep does spec validation
 func (r *PolicyReconciler) admissionStep(ctx context.Context, p *api.Policy) (ctrl.Result, error) {
-	if err := p.Validate(r.clusterName); err != nil { // the policy spec is not valid
+	if err := r.validate(p); err != nil {
 		ok := r.updateStatus(ctx, p, api.NewPolicyStatus(api.CrError, err.Error()))
 		return ctrl.Result{Requeue: !ok}, nil
 	}
 
-	// update the role status to "progressing"
+	// record the aws name (it can be rendered, Validate checked it) & path, then update the policy status to "progressing"
+	p.Status.AwsName, _ = p.AwsName(r.naming)
+	p.Status.AwsPath = p.Path(r.naming)
 	ok := r.updateStatus(ctx, p, api.NewPolicyStatus(api.CrProgressing, "passed validation"))
 	return ctrl.Result{Requeue: !ok}, nil
 }
 
 // reconcilerRoutine is an infinite loop attempting to make the aws IAM policy converge to the policy.Spec
 func (r *PolicyReconciler) reconcilerRoutine(ctx context.Context, policy *api.Policy) (ctrl.Result, error) {
+	// the spec may have changed since its admission, nothing is written on aws until it's valid again
+	if err := r.validate(policy); err != nil {
+		if policy.Status.Condition == api.CrError && policy.Status.Reason == err.Error() { // we'll be requeued when the spec changes
+			return ctrl.Result{}, nil
+		}
+		ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
 	if policy.Spec.ARN == "" { // no arn in spec
-		foundARN, err := r.awsPM.GetPolicyARN(policy.PathPrefix(r.clusterName), policy.AwsName(r.clusterName))
+		foundARN, err := r.awsPM.GetPolicyARN(ctx, policy.Path(r.naming), policy.Status.AwsName)
 		if err != nil {
 			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
 			return ctrl.Result{Requeue: true}, nil
 		}
 
 		if foundARN == "" { // no policy on aws, let's create it
-			if err := r.awsPM.CreatePolicy(*policy); err != nil { // creation failed
+			if res, ok := r.waitForAuthorization(ctx, policy); !ok {
+				return res, nil
+			}
+			if res, ok := r.waitForApproval(ctx, policy); !ok {
+				return res, nil
+			}
+
+			shards, err := r.shards(policy, time.Now())
+			if err != nil { // nothing to do until the spec changes
+				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+				return ctrl.Result{}, nil
+			}
+
+			tags, err := desiredTags(ctx, r.Client, r.naming.ClusterName, policy, r.propagatedLabelKeys)
+			if err != nil {
+				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to compute policy tags : "+err.Error()))
+				return ctrl.Result{Requeue: true}, nil
+			}
+
+			// it holds the first shard of the statements, the other ones are created once its arn is known
+			if err := r.awsPM.CreatePolicy(ctx, withStatement(*policy, shards[0]), tags); err != nil { // creation failed
 				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to create policy on AWS : "+err.Error()))
 			} else { // creation succeeded
 				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "policy created on AWS"))
@@ -124,25 +206,38 @@ func (r *PolicyReconciler) reconcilerRoutine(ctx context.Context, policy *api.Po
 			return ctrl.Result{Requeue: true}, nil
 		}
 
-		// a policy already exists on aws
+		// a policy already exists on aws, we ensure it's ours
+		tags, err := r.awsPM.GetPolicyTags(ctx, foundARN)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to get policy tags on AWS : "+err.Error()))
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if !isOwnedBy(tags, r.naming.ClusterName, policy) {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, fmt.Sprintf("policy %s found on AWS is owned by %s/%s on cluster %s", foundARN, tags[tagNamespace], tags[tagName], tags[tagClusterName])))
+			return ctrl.Result{Requeue: true}, nil
+		}
+
 		r.setPolicyArnField(ctx, foundARN, policy) // we set the policyARN field
 		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "policy found on AWS"))
 		return ctrl.Result{}, nil // modifying the policyARN field will generate a new event
 
 	} else { // policy ARN in spec
-		policyStatement, err := r.awsPM.GetStatement(policy.Spec.ARN)
+		versionID, err := r.awsPM.GetPolicyDefaultVersionID(ctx, policy.Spec.ARN)
 		if err != nil {
-			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policyStatement on AWS failed : "+err.Error()))
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policy version on AWS failed : "+err.Error()))
 			return ctrl.Result{Requeue: true}, nil
 		}
 
-		if !api.StatementEquals(policy.Spec.Statement, policyStatement) { // policy on aws doesn't correspond to the one in Spec
-			// we update the aws policy
-			if err := r.awsPM.UpdatePolicy(*policy); err != nil {
-				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "update policyStatement on AWS failed : "+err.Error()))
-				return ctrl.Result{Requeue: true}, nil
+		// the document on aws is only fetched if the spec changed since it was applied, or on a regular basis in case it's been modified outside of the operator
+		// or when a statement enters or leaves its time window
+		if !policy.IsApplied(versionID, r.placeholders, time.Now()) || r.fullSyncDue(policy) {
+			if res, completed := r.syncStatement(ctx, policy, versionID); !completed {
+				return res, nil
 			}
-			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "update policyStatement on AWS succeeded"))
+		}
+
+		if ok := r.syncTags(ctx, policy); !ok {
 			return ctrl.Result{Requeue: true}, nil
 		}
 	}
@@ -151,7 +246,580 @@ func (r *PolicyReconciler) reconcilerRoutine(ctx context.Context, policy *api.Po
 		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrOK, "all done"))
 	}
 
-	return ctrl.Result{}, nil
+	// the next statement to enter or leave its time window must be applied on time
+	requeueAfter := r.fullSyncPeriod
+	if next := policy.Spec.NextBoundary(time.Now()); next > 0 && (requeueAfter <= 0 || next < requeueAfter) {
+		requeueAfter = next
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// syncStatement makes the documents of the aws policy (& of its shards) converge to the policy.Spec
+// once they match, the applied statements & version are recorded in the status
+func (r *PolicyReconciler) syncStatement(ctx context.Context, policy *api.Policy, versionID string) (res ctrl.Result, completed bool) {
+	if res, ok := r.waitForAuthorization(ctx, policy); !ok {
+		return res, false
+	}
+	if res, ok := r.waitForApproval(ctx, policy); !ok {
+		return res, false
+	}
+
+	now := time.Now() // the statements out of their time window are left out
+	shards, err := r.shards(policy, now)
+	if err != nil { // nothing to do until the spec changes
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+		return ctrl.Result{}, false
+	}
+	hash, err := policy.ActiveHash(r.placeholders, now) // what's sent to aws, so a change of the placeholders is applied too
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+		return ctrl.Result{}, false
+	}
+
+	policyStatement, err := r.awsPM.GetStatement(ctx, policy.Spec.ARN)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policyStatement on AWS failed : "+err.Error()))
+		return ctrl.Result{Requeue: true}, false
+	}
+
+	states, staleARNs, ok := r.getShardStates(ctx, policy, shards[1:])
+	if !ok {
+		return ctrl.Result{Requeue: true}, false
+	}
+
+	inSync := api.StatementEquals(shards[0], policyStatement)
+	if !inSync || !shardsInSync(states) || len(staleARNs) > 0 { // policy on aws doesn't correspond to the one in Spec
+		if wait, ok := r.debounce(ctx, policy, hash); !ok {
+			return ctrl.Result{Requeue: true}, false
+		} else if wait > 0 {
+			// the reason must not change between passes, otherwise each status update would trigger a new pass
+			until := policy.Status.PendingSince.Add(r.updateDebounce).Format(time.RFC3339)
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "waiting for the spec to settle before updating the policy on AWS (until "+until+")"))
+			return ctrl.Result{RequeueAfter: wait}, false
+		}
+
+		// we update the aws policy
+		if !inSync {
+			if version, ok := r.retainedVersion(ctx, policy, hash, len(shards)); !ok {
+				return ctrl.Result{Requeue: true}, false
+			} else if version != "" { // the statements of a previous version (eg. a rollback), it becomes the default one again
+				if err := r.awsPM.SetDefaultPolicyVersion(ctx, policy.Spec.ARN, version); err != nil {
+					r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "set default policy version on AWS failed : "+err.Error()))
+					return ctrl.Result{Requeue: true}, false
+				}
+			} else if err := r.awsPM.UpdatePolicy(ctx, withStatement(*policy, shards[0])); err != nil {
+				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "update policyStatement on AWS failed : "+err.Error()))
+				return ctrl.Result{Requeue: true}, false
+			}
+		}
+
+		if ok := r.applyShards(ctx, policy, shards[1:], states, staleARNs); !ok {
+			return ctrl.Result{Requeue: true}, false
+		}
+		r.recordRevocations(policy, now)
+
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "update policyStatement on AWS succeeded"))
+		return ctrl.Result{Requeue: true}, false // the new version will be recorded during the next pass
+	}
+
+	retained, err := r.awsPM.ListPolicyVersions(ctx, policy.Spec.ARN)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "list policy versions on AWS failed : "+err.Error()))
+		return ctrl.Result{Requeue: true}, false
+	}
+
+	syncTime := metav1.NewTime(now)
+	if policy.Status.AppliedVersionID != versionID {
+		policy.Status.RecordVersion(api.PolicyVersion{VersionID: versionID, Generation: policy.Generation, DocumentHash: hash})
+	}
+	policy.Status.SetRetainedVersions(toPolicyVersions(retained))
+	policy.Status.AppliedHash = hash
+	policy.Status.AppliedVersionID = versionID
+	policy.Status.LastFullSyncTime = &syncTime
+	policy.Status.PendingHash = ""
+	policy.Status.PendingSince = nil
+	policy.Status.ShardARNs = shardARNs(states)
+	policy.Status.RenderedStatement = concatShards(shards)
+	if err := r.Status().Update(ctx, policy); err != nil {
+		r.controllerErrLog(policy, "record applied statement", err)
+		return ctrl.Result{Requeue: true}, false
+	}
+
+	return ctrl.Result{}, true
+}
+
+// waitForApproval only completes once the statements of a shared policy don't need an approval or have been approved, the policy on aws is left as is meanwhile
+// the policies of the irsas & of the cluster irsas are approved along with them (their spec is only updated once approved)
+func (r *PolicyReconciler) waitForApproval(ctx context.Context, policy *api.Policy) (res ctrl.Result, completed bool) {
+	if len(r.sensitivePermissions) == 0 || coveredByOwner(policy) {
+		return ctrl.Result{}, true
+	}
+
+	rendered := policy.Spec.Statement
+	if spec, err := r.renderedSpec(policy); err == nil { // the patterns are matched against the actual ARNs
+		rendered = spec.Statement
+	}
+
+	reason, err := pendingApproval(ctx, r.Client, r.sensitivePermissions, policy, "policies", rendered, policy.Spec.ApprovalDigest())
+	if err != nil {
+		r.controllerErrLog(policy, "check approval", err)
+		return ctrl.Result{Requeue: true}, false
+	}
+	if reason == "" {
+		return ctrl.Result{}, true
+	}
+
+	if policy.Status.Condition != api.CrPending || policy.Status.Reason != reason { // we'll be requeued when the policy is annotated
+		if ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrPending, reason)); !ok {
+			return ctrl.Result{Requeue: true}, false
+		}
+	}
+	return ctrl.Result{}, false
+}
+
+// waitForAuthorization only completes once the requester of a shared policy (who last changed its statements or asked for its rollback) is allowed to grant its actions,
+// the policy on aws is left as is meanwhile : the SubjectAccessReviews are skipped if its statements & requester are the ones already authorized
+// the policies of the irsas are authorized along with them & the ones of the cluster irsas (created by the cluster admins) don't need any
+func (r *PolicyReconciler) waitForAuthorization(ctx context.Context, policy *api.Policy) (res ctrl.Result, completed bool) {
+	if !r.authorizeRequesters || coveredByOwner(policy) {
+		return ctrl.Result{}, true
+	}
+
+	reason, digest, ok := r.unauthorizedActions(ctx, policy)
+	if !ok {
+		return ctrl.Result{Requeue: true}, false
+	}
+	if reason != "" {
+		if policy.Status.Condition != api.CrForbidden || policy.Status.Reason != reason { // we'll be requeued when the spec changes
+			if ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrForbidden, reason)); !ok {
+				return ctrl.Result{Requeue: true}, false
+			}
+		}
+		return ctrl.Result{}, false
+	}
+
+	if policy.Status.AuthorizedDigest != digest {
+		policy.Status.AuthorizedDigest = digest
+		if err := r.Status().Update(ctx, policy); err != nil {
+			r.controllerErrLog(policy, "record authorization", err)
+			return ctrl.Result{Requeue: true}, false
+		}
+	}
+	return ctrl.Result{}, true
+}
+
+// unauthorizedActions returns why the requester of the policy can't grant the actions of its statements (empty if they can) & the digest identifying what's been checked
+func (r *PolicyReconciler) unauthorizedActions(ctx context.Context, policy *api.Policy) (reason, digest string, completed bool) {
+	requester, recorded, err := policy.Requester()
+	if err != nil {
+		return err.Error(), "", true
+	}
+	if !recorded { // created before the webhook was installed, its spec must be changed to record its requester
+		return "unknown requester, the " + api.RequesterAnnotation + " annotation is set by the admission webhook when the spec changes", "", true
+	}
+
+	digest = requester.AuthorizationDigest(policy.Spec.Statement)
+	if digest == policy.Status.AuthorizedDigest {
+		return "", digest, true
+	}
+
+	denied, err := deniedActions(ctx, r.Client, requester, policy.Namespace, policy.Spec.Statement)
+	if err != nil {
+		r.controllerErrLog(policy, "create subjectaccessreview", err)
+		return "", "", false
+	}
+	if len(denied) > 0 {
+		return fmt.Sprintf("%s isn't allowed to grant %s", requester.Username, strings.Join(denied, ", ")), "", true
+	}
+	return "", digest, true
+}
+
+// retainedVersion returns the version retained on aws holding the statements of the given hash (empty if none does)
+// only a policy held by a single document can be restored this way, a version only holds the first shard of the statements
+func (r *PolicyReconciler) retainedVersion(ctx context.Context, policy *api.Policy, hash string, shards int) (versionID string, completed bool) {
+	v, ok := policy.Status.VersionWithHash(hash)
+	if !ok || shards > 1 {
+		return "", true
+	}
+
+	retained, err := r.awsPM.ListPolicyVersions(ctx, policy.Spec.ARN) // it may have been deleted since the versions were recorded
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "list policy versions on AWS failed : "+err.Error()))
+		return "", false
+	}
+	for _, rv := range retained {
+		if rv.VersionID == v.VersionID {
+			return v.VersionID, true
+		}
+	}
+	return "", true
+}
+
+// shardState is what's on aws for a shard of the statements
+type shardState struct {
+	arn    string // empty if the shard policy doesn't exist yet
+	inSync bool
+}
+
+func shardsInSync(states []shardState) bool {
+	for _, st := range states {
+		if !st.inSync {
+			return false
+		}
+	}
+	return true
+}
+
+func shardARNs(states []shardState) []string {
+	arns := []string{}
+	for _, st := range states {
+		arns = append(arns, st.arn)
+	}
+	return arns
+}
+
+// renderedSpec returns the spec of the policy with its placeholders resolved
+// validate returns an error if the spec, once its placeholders are resolved, is invalid or can't be applied on aws
+func (r *PolicyReconciler) validate(p *api.Policy) error {
+	spec, err := r.renderedSpec(p)
+	if err != nil {
+		return err
+	}
+
+	rendered := *p
+	rendered.Spec = spec
+	if err := rendered.Validate(r.naming); err != nil { // the policy spec is not valid
+		return err
+	}
+
+	if err := spec.ValidateResourceAccounts(r.allowedResourceAccountIDs); err != nil { // a resource is in an account that isn't allowed
+		return err
+	}
+
+	if _, err := spec.Shards(r.maxShards); err != nil { // the statements don't fit in the policies a role can have
+		return err
+	}
+
+	return nil
+}
+
+func (r *PolicyReconciler) renderedSpec(policy *api.Policy) (api.PolicySpec, error) {
+	return policy.Spec.Render(r.placeholders, policy.Namespace, policy.Name)
+}
+
+// shards returns the rendered statements of the policy active at the given time, split across the aws policies holding them
+func (r *PolicyReconciler) shards(policy *api.Policy, now time.Time) ([][]api.StatementSpec, error) {
+	spec, err := r.renderedSpec(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec.ActiveAt(now).Shards(r.maxShards)
+}
+
+// recordRevocations emits an event for each statement that expired since the policy on aws was last known to be in sync
+func (r *PolicyReconciler) recordRevocations(policy *api.Policy, now time.Time) {
+	lastSync := policy.Status.LastFullSyncTime
+	if lastSync == nil { // the first version of the policy, nothing was granted before
+		return
+	}
+
+	for i, stm := range policy.Spec.Statement {
+		if stm.ExpiresAt == nil || !stm.IsActive(lastSync.Time) || stm.IsActive(now) {
+			continue
+		}
+		r.recorder.Event(policy, corev1.EventTypeNormal, "StatementRevoked", fmt.Sprintf("statement :%d on %s expired at %s", i, stm.Resource, stm.ExpiresAt.UTC().Format(time.RFC3339)))
+	}
+}
+
+// coveredByOwner tells if the policy is controlled by a resource whose approval (& authorization) covers its statements
+func coveredByOwner(policy *api.Policy) bool {
+	owner := metav1.GetControllerOf(policy)
+	if owner == nil || owner.APIVersion != api.GroupVersion.String() {
+		return false
+	}
+	return owner.Kind == "IamRoleServiceAccount" || owner.Kind == "ClusterIamRoleServiceAccount"
+}
+
+func concatShards(shards [][]api.StatementSpec) []api.StatementSpec {
+	stmts := []api.StatementSpec{}
+	for _, shard := range shards {
+		stmts = append(stmts, shard...)
+	}
+	return stmts
+}
+
+// withStatement returns a copy of the policy holding the given statements
+func withStatement(policy api.Policy, stmt []api.StatementSpec) api.Policy {
+	policy.Spec.Statement = stmt
+	return policy
+}
+
+// shardPolicy returns a copy of the policy standing for the aws policy holding its i-th shard of statements
+func (r *PolicyReconciler) shardPolicy(policy api.Policy, i int, arn string, stmt []api.StatementSpec) api.Policy {
+	policy.Status.AwsName, _ = policy.ShardAwsName(r.naming, i) // the aws name of the policy is recorded before its shards are handled
+	policy.Spec.ARN = arn
+	policy.Spec.Statement = stmt
+	return policy
+}
+
+// getShardStates compares the shards of the statements (the first one excluded, it's held by the policy itself) to the policies on aws
+// the stale shards are the ones recorded in the status that are no longer needed but still exist on aws
+func (r *PolicyReconciler) getShardStates(ctx context.Context, policy *api.Policy, shards [][]api.StatementSpec) (states []shardState, staleARNs []string, completed bool) {
+	for i, shard := range shards {
+		name, err := policy.ShardAwsName(r.naming, i+1)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, err.Error()))
+			return nil, nil, false
+		}
+
+		arn, err := r.awsPM.GetPolicyARN(ctx, policy.Path(r.naming), name)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policy shard ARN on AWS failed : "+err.Error()))
+			return nil, nil, false
+		}
+
+		if arn == "" {
+			states = append(states, shardState{})
+			continue
+		}
+
+		stmt, err := r.awsPM.GetStatement(ctx, arn)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policy shard statement on AWS failed : "+err.Error()))
+			return nil, nil, false
+		}
+		states = append(states, shardState{arn: arn, inSync: api.StatementEquals(shard, stmt)})
+	}
+
+	for _, arn := range policy.Status.ShardARNs {
+		if containsString(shardARNs(states), arn) {
+			continue
+		}
+
+		exists, err := r.awsPM.PolicyExists(ctx, arn)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "check if policy shard exists on AWS failed : "+err.Error()))
+			return nil, nil, false
+		}
+		if exists {
+			staleARNs = append(staleARNs, arn)
+		}
+	}
+
+	return states, staleARNs, true
+}
+
+// applyShards creates or updates the aws policies holding the shards of the statements & deletes the ones no longer needed
+func (r *PolicyReconciler) applyShards(ctx context.Context, policy *api.Policy, shards [][]api.StatementSpec, states []shardState, staleARNs []string) (completed bool) {
+	for i, st := range states {
+		if st.inSync {
+			continue
+		}
+
+		shard := r.shardPolicy(*policy, i+1, st.arn, shards[i])
+		if st.arn != "" {
+			if err := r.awsPM.UpdatePolicy(ctx, shard); err != nil {
+				r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "update policy shard on AWS failed : "+err.Error()))
+				return false
+			}
+			continue
+		}
+
+		tags, err := desiredTags(ctx, r.Client, r.naming.ClusterName, policy, r.propagatedLabelKeys)
+		if err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to compute policy tags : "+err.Error()))
+			return false
+		}
+
+		if err := r.awsPM.CreatePolicy(ctx, shard, tags); err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "create policy shard on AWS failed : "+err.Error()))
+			return false
+		}
+	}
+
+	for _, arn := range staleARNs { // detached from the role by the deletion
+		if err := r.awsPM.DeletePolicy(ctx, arn); err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "delete stale policy shard on AWS failed : "+err.Error()))
+			return false
+		}
+	}
+
+	return true
+}
+
+// debounce returns how long to wait before pushing the statements (of the given Policy.ActiveHash) on aws, so quick successive edits produce a single policy version
+// there's no wait for the first version, nor to revert the changes done on aws outside of the operator, nor to restore a previous version
+func (r *PolicyReconciler) debounce(ctx context.Context, policy *api.Policy, hash string) (wait time.Duration, completed bool) {
+	if r.updateDebounce <= 0 || policy.Status.AppliedHash == "" || policy.Status.AppliedHash == hash {
+		return 0, true
+	}
+
+	if _, ok := policy.Status.VersionWithHash(hash); ok { // a previous version is restored, no new version is created
+		return 0, true
+	}
+
+	if applied, ok := policy.Status.Version(policy.Status.AppliedVersionID); ok && applied.Generation == policy.Generation { // the spec didn't change, only the clock did : a revocation mustn't wait
+		return 0, true
+	}
+
+	if policy.Status.PendingHash != hash { // the spec changed (again), the window starts over
+		now := metav1.Now()
+		policy.Status.PendingHash = hash
+		policy.Status.PendingSince = &now
+		if err := r.Status().Update(ctx, policy); err != nil {
+			r.controllerErrLog(policy, "record pending statement", err)
+			return 0, false
+		}
+		return r.updateDebounce, true
+	}
+
+	if elapsed := time.Since(policy.Status.PendingSince.Time); elapsed < r.updateDebounce {
+		return r.updateDebounce - elapsed, true
+	}
+
+	return 0, true
+}
+
+// rollback restores the statements of a previous version of the aws policy in the spec of the policy, or of the irsa owning it (on behalf of the requester of the rollback)
+// they're approved & authorized like any change of the spec, the version is then made the default one again instead of creating a new one (see syncStatement)
+func (r *PolicyReconciler) rollback(ctx context.Context, policy *api.Policy, versionID string) (ctrl.Result, error) {
+	retained, err := r.awsPM.ListPolicyVersions(ctx, policy.Spec.ARN)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "list policy versions on AWS failed : "+err.Error()))
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	found := false
+	for _, v := range retained {
+		found = found || v.VersionID == versionID
+	}
+	if !found { // nothing we can do, the annotation is dropped
+		return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, it's not retained on AWS", versionID))
+	}
+
+	if len(policy.Status.ShardARNs) > 0 { // the version only holds the first shard of the statements
+		return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, the statements are split across several policies on AWS", versionID))
+	}
+
+	// the version only holds the statements as applied, they'd replace the placeholders by their values & the time windows by what was active back then
+	if policy.Spec.HasPlaceholders() {
+		return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, the statements hold placeholders", versionID))
+	}
+	if policy.Spec.HasTimeWindows() {
+		return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, the statements have time windows", versionID))
+	}
+
+	var irsa *api.IamRoleServiceAccount
+	if owner := metav1.GetControllerOf(policy); owner != nil {
+		if owner.Kind != "IamRoleServiceAccount" { // the cluster irsa would restore its statements
+			return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, the policy belongs to the %s %s", versionID, owner.Kind, owner.Name))
+		}
+
+		irsa = &api.IamRoleServiceAccount{}
+		if err := r.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: policy.Namespace}, irsa); err != nil {
+			r.controllerErrLog(policy, "get irsa", err)
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if len(irsa.Spec.Templates) > 0 || len(irsa.Status.InheritedStatement) > 0 { // the statements of the version can't be split back between the irsa, its templates & its namespace defaults
+			return r.refuseRollback(ctx, policy, fmt.Sprintf("can't rollback to version %s, the statements come from policy templates or namespace defaults", versionID))
+		}
+	}
+
+	stmt, err := r.awsPM.GetPolicyVersionStatement(ctx, policy.Spec.ARN, versionID)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "get policy version on AWS failed : "+err.Error()))
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if irsa != nil { // the policy is updated by the irsa once its statements are authorized & approved
+		if !api.StatementEquals(irsa.Spec.Policy.Statement, stmt) {
+			irsa.Spec.Policy.Statement = stmt
+			if requester, recorded, _ := policy.Requester(); recorded { // the admission webhook lets the operator set it
+				irsa.SetRequester(requester)
+			}
+			if err := r.Update(ctx, irsa); err != nil {
+				r.controllerErrLog(policy, "rollback irsa spec", err)
+				return ctrl.Result{Requeue: true}, nil
+			}
+		}
+	} else { // the policy is applied once its statements are approved
+		policy.Spec.Statement = stmt
+	}
+
+	delete(policy.Annotations, api.RollbackToVersionAnnotation)
+	if err := r.Update(ctx, policy); err != nil {
+		r.controllerErrLog(policy, "rollback spec", err)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// the version is recognized when its statements are applied, even if it hasn't been created by the operator
+	policy.Status.SetRetainedVersions(toPolicyVersions(retained))
+	for i, v := range policy.Status.Versions {
+		if v.VersionID == versionID {
+			policy.Status.Versions[i].DocumentHash = api.PolicySpec{Statement: stmt}.ActiveAt(time.Now()).Hash()
+		}
+	}
+	ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrProgressing, "rolling back to version "+versionID))
+	return ctrl.Result{Requeue: !ok}, nil
+}
+
+// refuseRollback drops the rollback annotation & reports why
+func (r *PolicyReconciler) refuseRollback(ctx context.Context, policy *api.Policy, reason string) (ctrl.Result, error) {
+	delete(policy.Annotations, api.RollbackToVersionAnnotation)
+	if err := r.Update(ctx, policy); err != nil {
+		r.controllerErrLog(policy, "remove rollback annotation", err)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	ok := r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, reason))
+	return ctrl.Result{Requeue: !ok}, nil
+}
+
+func toPolicyVersions(versions []IamPolicyVersion) []api.PolicyVersion {
+	out := []api.PolicyVersion{}
+	for _, v := range versions {
+		createDate := metav1.NewTime(v.CreateDate)
+		out = append(out, api.PolicyVersion{VersionID: v.VersionID, CreateDate: &createDate, IsDefault: v.IsDefault})
+	}
+	return out
+}
+
+// fullSyncDue tells if the document on aws must be compared to the spec, even if it seems up to date
+func (r *PolicyReconciler) fullSyncDue(policy *api.Policy) bool {
+	return policy.Status.LastFullSyncTime == nil || time.Since(policy.Status.LastFullSyncTime.Time) >= r.fullSyncPeriod
+}
+
+// syncTags makes the tags of the aws policy converge to the desired ones
+func (r *PolicyReconciler) syncTags(ctx context.Context, policy *api.Policy) (completed bool) {
+	desired, err := desiredTags(ctx, r.Client, r.naming.ClusterName, policy, r.propagatedLabelKeys)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to compute policy tags : "+err.Error()))
+		return false
+	}
+
+	current, err := r.awsPM.GetPolicyTags(ctx, policy.Spec.ARN)
+	if err != nil {
+		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to get policy tags on AWS : "+err.Error()))
+		return false
+	}
+
+	toSet, toRemove := tagsDiff(current, desired, r.propagatedLabelKeys)
+	if len(toSet) > 0 {
+		if err := r.awsPM.TagPolicy(ctx, policy.Spec.ARN, toSet); err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to tag policy on AWS : "+err.Error()))
+			return false
+		}
+	}
+
+	if len(toRemove) > 0 {
+		if err := r.awsPM.UntagPolicy(ctx, policy.Spec.ARN, toRemove); err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "failed to untag policy on AWS : "+err.Error()))
+			return false
+		}
+	}
+
+	return true
 }
 
 func (r *PolicyReconciler) executeFinalizerIfPresent(ctx context.Context, policy *api.Policy) (completed bool) {
@@ -159,16 +827,39 @@ func (r *PolicyReconciler) executeFinalizerIfPresent(ctx context.Context, policy
 		return true
 	}
 
+	{ // a shared policy is kept as long as irsas reference it
+		irsas, ok := r.irsasReferencing(ctx, policy)
+		if !ok {
+			return false
+		}
+
+		if len(irsas) > 0 {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrDeleting, "still referenced by "+strings.Join(irsas, ", ")))
+			return false
+		}
+	}
+
 	if policy.Spec.ARN == "" { // the operator hasn't created the policy yet, all done
 		return r.removeFinalizer(ctx, policy)
 	}
 
-	if exists, err := r.awsPM.PolicyExists(policy.Spec.ARN); !exists && err == nil { // policy already deleted, all done
+	for _, arn := range policy.Status.ShardARNs { // the shards go first, they're only known from the status
+		if exists, err := r.awsPM.PolicyExists(ctx, arn); !exists && err == nil {
+			continue
+		}
+
+		if err := r.awsPM.DeletePolicy(ctx, arn); err != nil {
+			r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "delete policy shard on AWS failed : "+err.Error()))
+			return false
+		}
+	}
+
+	if exists, err := r.awsPM.PolicyExists(ctx, policy.Spec.ARN); !exists && err == nil { // policy already deleted, all done
 		return r.removeFinalizer(ctx, policy)
 	}
 
 	// delete the policy on AWS
-	if err := r.awsPM.DeletePolicy(policy.Spec.ARN); err != nil { // deletion failed
+	if err := r.awsPM.DeletePolicy(ctx, policy.Spec.ARN); err != nil { // deletion failed
 		r.updateStatus(ctx, policy, api.NewPolicyStatus(api.CrError, "delete Policy on AWS failed : "+err.Error()))
 		return false
 	}
@@ -183,13 +874,46 @@ func (r *PolicyReconciler) executeFinalizerIfPresent(ctx context.Context, policy
 	return r.removeFinalizer(ctx, policy)
 }
 
+// irsasReferencing returns the names of the irsas referencing the policy in their policyRefs
+func (r *PolicyReconciler) irsasReferencing(ctx context.Context, policy *api.Policy) (_ []string, completed bool) {
+	irsas := &api.IamRoleServiceAccountList{}
+	if err := r.List(ctx, irsas, client.InNamespace(policy.Namespace)); err != nil {
+		r.controllerErrLog(policy, "list irsas", err)
+		return nil, false
+	}
+
+	names := []string{}
+	for _, irsa := range irsas.Items {
+		if containsString(irsa.PolicyRefNames(), policy.Name) {
+			names = append(names, irsa.Name)
+		}
+	}
+	return names, true
+}
+
+// policiesReferencedBy returns the policies referenced by an irsa
+func policiesReferencedBy(o client.Object) []reconcile.Request {
+	irsa, ok := o.(*api.IamRoleServiceAccount)
+	if !ok {
+		return nil
+	}
+
+	reqs := []reconcile.Request{}
+	for _, name := range irsa.PolicyRefNames() {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: irsa.Namespace}})
+	}
+	return reqs
+}
+
 func (r *PolicyReconciler) removeFinalizer(ctx context.Context, p *api.Policy) bool {
 	p.ObjectMeta.Finalizers = removeString(p.ObjectMeta.Finalizers, r.finalizerID)
 	return r.Update(ctx, p) == nil
 }
 
+// updateStatus sets the condition & reason of the policy status, its other fields are kept
 func (r *PolicyReconciler) updateStatus(ctx context.Context, p *api.Policy, status api.PolicyStatus) bool {
-	p.Status = status
+	p.Status.Condition = status.Condition
+	p.Status.Reason = status.Reason
 	return r.Status().Update(ctx, p) == nil
 }
 
@@ -1,19 +1,28 @@
 package controllers_test
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 )
 
 var _ = Describe("Awspolicy validity check", func() {
 	Context("When creating an Awspolicy", func() {
-		clusterName := randString()
+		naming, _ := api.NewNaming(randString(), api.DefaultNameTemplate, api.DefaultRootPath)
 		Context("if the spec.statement is nil", func() {
 			It("fails at submission", func() {
 				Expect(
-					api.NewPolicy(validName(), testns, nil).Validate(clusterName),
+					api.NewPolicy(validName(), testns, nil).Validate(naming),
 				).ShouldNot(Succeed())
 			})
 		})
@@ -22,7 +31,7 @@ var _ = Describe("Awspolicy validity check", func() {
 			name := validName()
 			It("fails at validation", func() {
 				Expect(
-					api.NewPolicy(name, testns, []api.StatementSpec{}).Validate(clusterName),
+					api.NewPolicy(name, testns, []api.StatementSpec{}).Validate(naming),
 				).ShouldNot(Succeed())
 			})
 		})
@@ -34,7 +43,7 @@ var _ = Describe("Awspolicy validity check", func() {
 				Expect(
 					api.NewPolicy(name, testns, []api.StatementSpec{
 						{Resource: "not an arn", Action: []string{"do something"}},
-					}).Validate(clusterName),
+					}).Validate(naming),
 				).ShouldNot(Succeed())
 			})
 		})
@@ -47,11 +56,95 @@ var _ = Describe("Awspolicy validity check", func() {
 				Expect(
 					api.NewPolicy(name, testns, []api.StatementSpec{
 						{Resource: validARN, Action: []string{}},
-					}).Validate(clusterName),
+					}).Validate(naming),
 				).ShouldNot(Succeed())
 			})
 		})
 
+		Context("if the spec.statement[*].resource is not valid for its service", func() {
+			invalid := map[string]string{
+				"arn:aws:s3:eu-west-1:123456789012:bucket":        "is not a valid s3 ARN",
+				"arn:aws:dynamodb:eu-west-1:123456789012:mytable": "is not a valid dynamodb ARN",
+				"arn:aws:sqs:eu-west-1::queue":                    "is not a valid sqs ARN",
+				"arn:aws:kms:eu-west-1:123456789012:mykey":        "is not a valid kms ARN",
+				"arn:aws:sqs:eu-west-1:1234:queue":                "invalid account 1234",
+				"arn:aws:sqs:cn-north-1:123456789012:queue":       "belongs to the aws-cn partition",
+				"arn:awz:sqs:eu-west-1:123456789012:queue":        "unknown partition awz",
+			}
+
+			for resource, reason := range invalid {
+				resource, reason := resource, reason
+				It("fails at validation : "+resource, func() {
+					Expect(
+						api.NewPolicy(validName(), testns, []api.StatementSpec{
+							{Resource: resource, Action: []string{"*"}},
+						}).Validate(naming),
+					).Should(MatchError(ContainSubstring(reason)))
+				})
+			}
+
+			It("accepts the valid ones", func() {
+				Expect(
+					api.NewPolicy(validName(), testns, []api.StatementSpec{
+						{Resource: "arn:aws:s3:::my-bucket/*", Action: []string{"s3:GetObject"}},
+						{Resource: "arn:aws:dynamodb:eu-west-1:123456789012:table/my-table/index/*", Action: []string{"dynamodb:Query"}},
+						{Resource: "arn:aws-cn:sqs:cn-north-1:123456789012:queue.fifo", Action: []string{"sqs:SendMessage"}},
+						{Resource: "arn:aws:kms:*:123456789012:alias/my-key", Action: []string{"kms:Decrypt"}},
+						{Resource: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db-*", Action: []string{"secretsmanager:GetSecretValue"}},
+					}).Validate(naming),
+				).Should(Succeed())
+			})
+		})
+
+		Context("if the spec.statement[*].resource belongs to another account", func() {
+			spec := api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: "arn:aws:s3:::my-bucket", Action: []string{"s3:ListBucket"}},
+				{Resource: "arn:aws:sqs:eu-west-1:210987654321:queue", Action: []string{"sqs:SendMessage"}},
+			}}
+
+			It("is rejected unless the account is allowed", func() {
+				Expect(spec.ValidateResourceAccounts(nil)).To(Succeed())
+				Expect(spec.ValidateResourceAccounts([]string{"210987654321"})).To(Succeed())
+				Expect(spec.ValidateResourceAccounts([]string{"123456789012"})).To(MatchError(ContainSubstring("statement :1")))
+			})
+		})
+
+		Context("if the spec.statement[*].action is not an IAM action", func() {
+			validARN := "arn:aws:s3:::my_corporate_bucket/exampleobject.png"
+
+			It("fails at validation, suggesting the closest action", func() {
+				Expect(
+					api.NewPolicy(validName(), testns, []api.StatementSpec{
+						{Resource: validARN, Action: []string{"s3:GetObjects"}},
+					}).Validate(naming),
+				).Should(MatchError(ContainSubstring("did you mean s3:GetObject ?")))
+			})
+
+			It("fails at validation if the service doesn't exist", func() {
+				Expect(
+					api.NewPolicy(validName(), testns, []api.StatementSpec{
+						{Resource: validARN, Action: []string{"s4:GetObject"}},
+					}).Validate(naming),
+				).Should(MatchError(ContainSubstring("s4 is not an IAM service")))
+			})
+
+			It("fails at validation if a wildcard doesn't match any action", func() {
+				Expect(
+					api.NewPolicy(validName(), testns, []api.StatementSpec{
+						{Resource: validARN, Action: []string{"s3:Gt*"}},
+					}).Validate(naming),
+				).ShouldNot(Succeed())
+			})
+
+			It("accepts wildcards & actions that only exist in IAM", func() {
+				Expect(
+					api.NewPolicy(validName(), testns, []api.StatementSpec{
+						{Resource: validARN, Action: []string{"s3:Get*", "s3:ListBucket", "sqs:sendmessage", "*"}},
+					}).Validate(naming),
+				).Should(Succeed())
+			})
+		})
+
 		Context("if everything is ok", func() {
 			name := validName()
 			validARN := "arn:aws:s3:::my_corporate_bucket/exampleobject.png"
@@ -59,10 +152,417 @@ var _ = Describe("Awspolicy validity check", func() {
 			It("passes the api submission", func() {
 				Expect(
 					api.NewPolicy(name, testns, []api.StatementSpec{
-						{Resource: validARN, Action: []string{"an:action"}},
-					}).Validate(clusterName),
+						{Resource: validARN, Action: []string{"s3:GetObject"}},
+					}).Validate(naming),
 				).Should(Succeed())
 			})
 		})
+
+		Context("if the generated aws name is too long", func() {
+			name := strings.Repeat("a", 63)
+			validARN := "arn:aws:s3:::my_corporate_bucket/exampleobject.png"
+
+			It("is truncated & hashed deterministically", func() {
+				p := api.NewPolicy(name, testns, []api.StatementSpec{
+					{Resource: validARN, Action: []string{"s3:GetObject"}},
+				})
+				Expect(p.Validate(naming)).Should(Succeed())
+				awsName, err := p.AwsName(naming)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(len(awsName)).To(BeNumerically("<=", 64))
+				Expect(api.NewPolicy(name, testns, nil).AwsName(naming)).To(Equal(awsName))
+				Expect(api.NewPolicy(name+"b", testns, nil).AwsName(naming)).NotTo(Equal(awsName))
+			})
+		})
+
+		Context("if the aws name has been recorded in the status", func() {
+			It("is kept even if the name template changes", func() {
+				p := api.NewPolicy(validName(), testns, nil)
+				recorded, err := p.AwsName(naming)
+				Expect(err).NotTo(HaveOccurred())
+				p.Status.AwsName = recorded
+
+				otherNaming, err := api.NewNaming(naming.ClusterName, "{{ .Namespace }}-{{ .Name }}", api.DefaultRootPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(p.AwsName(otherNaming)).To(Equal(recorded))
+			})
+		})
+
+		Context("if the name template can't be rendered for the policy", func() {
+			It("fails at validation instead of panicking", func() {
+				partial, err := api.NewNaming(naming.ClusterName, `{{ if eq .Namespace "prod" }}{{ slice .Name 0 30 }}{{ else }}{{ .Name }}{{ end }}`, api.DefaultRootPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				p := api.NewPolicy("short", "prod", []api.StatementSpec{{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}})
+				Expect(p.Validate(partial)).To(MatchError(ContainSubstring("can't render the name template for prod/short")))
+			})
+		})
+	})
+
+	Context("When an Awspolicy has been applied on aws", func() {
+		p := api.NewPolicy(validName(), testns, []api.StatementSpec{
+			{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+		})
+		p.Status.AppliedHash = p.Spec.Hash()
+		p.Status.AppliedVersionID = "v2"
+
+		It("is up to date as long as the default version is the applied one", func() {
+			Expect(p.IsApplied("v2", testPlaceholders, time.Now())).To(BeTrue())
+			Expect(p.IsApplied("v3", testPlaceholders, time.Now())).To(BeFalse())
+		})
+
+		It("isn't up to date anymore once a statement expires", func() {
+			expiring := *p.DeepCopy()
+			expiresAt := metav1.NewTime(time.Now().Add(time.Hour))
+			expiring.Spec.Statement = append(expiring.Spec.Statement, api.StatementSpec{Resource: "arn:aws:s3:::backfill", Action: []string{"s3:PutObject"}, ExpiresAt: &expiresAt})
+			expiring.Status.AppliedHash = expiring.Spec.ActiveAt(time.Now()).Hash()
+
+			Expect(expiring.IsApplied("v2", testPlaceholders, time.Now())).To(BeTrue())
+			Expect(expiring.IsApplied("v2", testPlaceholders, time.Now().Add(2*time.Hour))).To(BeFalse())
+		})
+
+		It("only keeps the versions retained by IAM", func() {
+			status := api.PolicyStatus{}
+			for i := 1; i <= api.MaxPolicyVersions+2; i++ {
+				status.RecordVersion(api.PolicyVersion{VersionID: fmt.Sprintf("v%d", i), Generation: int64(i)})
+			}
+			status.RecordVersion(api.PolicyVersion{VersionID: "v7", Generation: 8})
+
+			Expect(status.Versions).To(HaveLen(api.MaxPolicyVersions))
+			Expect(status.Versions[0].VersionID).To(Equal("v3"))
+			Expect(status.Versions[api.MaxPolicyVersions-1].Generation).To(Equal(int64(7)))
+		})
+
+		It("keeps what's known about the versions retained on aws", func() {
+			status := api.PolicyStatus{}
+			status.RecordVersion(api.PolicyVersion{VersionID: "v1", Generation: 1, DocumentHash: "h1"})
+			status.RecordVersion(api.PolicyVersion{VersionID: "v2", Generation: 3, DocumentHash: "h3"})
+			status.SetRetainedVersions([]api.PolicyVersion{{VersionID: "v2", IsDefault: true}, {VersionID: "v3"}})
+
+			Expect(status.Versions).To(Equal([]api.PolicyVersion{
+				{VersionID: "v2", IsDefault: true, Generation: 3, DocumentHash: "h3"},
+				{VersionID: "v3"},
+			}))
+		})
+
+		It("is outdated as soon as its statements change", func() {
+			changed := p.DeepCopy()
+			changed.Spec.Statement[0].Action = []string{"s3:PutObject"}
+			Expect(changed.IsApplied("v2", testPlaceholders, time.Now())).To(BeFalse())
+		})
+
+		It("is outdated when the rendering of its placeholders changes", func() {
+			rendered := p.DeepCopy()
+			rendered.Spec.Statement[0].Resource = "arn:aws:sqs:${region}:${accountId}:queue"
+			hash, err := rendered.ActiveHash(testPlaceholders, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal(api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: "arn:aws:sqs:eu-west-1:" + allowedResourceAccountID + ":queue", Action: []string{"s3:GetObject"}},
+			}}.Hash()))
+			rendered.Status.AppliedHash = hash
+
+			Expect(rendered.IsApplied("v2", testPlaceholders, time.Now())).To(BeTrue())
+			otherRegion := testPlaceholders
+			otherRegion.Region = "us-east-1"
+			Expect(rendered.IsApplied("v2", otherRegion, time.Now())).To(BeFalse())
+		})
+	})
+
+	Context("When an Awspolicy is reconciled", func() {
+		name := validName()
+
+		It("records the applied statements & version", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(
+				api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{
+					Statement: []api.StatementSpec{
+						{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}},
+					},
+				}),
+			).Should(Succeed())
+			foundPolicyInCondition(name, testns, api.CrOK).Should(BeTrue())
+
+			Eventually(func() bool {
+				p := getPolicy(name, testns)
+				return p.IsApplied("v1", testPlaceholders, time.Now()) && p.Status.LastFullSyncTime != nil
+			}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+		})
+
+		It("records the generation that produced each version", func() {
+			Eventually(func() error { // the irsa may be updated concurrently by its controller
+				irsa := getIrsa(name, testns)
+				irsa.Spec.Policy.Statement[0].Action = []string{"s3:GetObject", "s3:PutObject"}
+				return k8sClient.Update(context.Background(), &irsa)
+			}, resourcePollTimeout, resourcePollInterval).Should(Succeed())
+
+			Eventually(func() bool {
+				p := getPolicy(name, testns)
+				return p.IsApplied("v2", testPlaceholders, time.Now())
+			}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+
+			p := getPolicy(name, testns)
+			Expect(p.Status.Versions).To(HaveLen(2))
+			Expect(p.Status.Versions[0].VersionID).To(Equal("v1"))
+			Expect(p.Status.Versions[1].VersionID).To(Equal("v2"))
+			Expect(p.Status.Versions[1].Generation).To(Equal(p.Generation))
+			Expect(p.Status.Versions[1].DocumentHash).To(Equal(p.Spec.Hash()))
+			Expect(p.Status.Versions[1].IsDefault).To(BeTrue())
+		})
+
+		It("rolls back to a previous version without creating a new one", func() {
+			Eventually(func() error {
+				p := getPolicy(name, testns)
+				if p.Annotations == nil {
+					p.Annotations = map[string]string{}
+				}
+				p.Annotations[api.RollbackToVersionAnnotation] = "v1"
+				return k8sClient.Update(context.Background(), &p)
+			}, resourcePollTimeout, resourcePollInterval).Should(Succeed())
+
+			Eventually(func() bool {
+				p := getPolicy(name, testns)
+				_, annotated := p.Annotations[api.RollbackToVersionAnnotation]
+				return !annotated && p.IsApplied("v1", testPlaceholders, time.Now())
+			}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+
+			Expect(stackOf(name).defaultVersion).To(Equal("v1"))
+			Expect(stackOf(name).policyVersion).To(Equal(2))
+			Expect(getPolicy(name, testns).Spec.Statement[0].Action).To(Equal([]string{"s3:GetObject"}))
+			Expect(getIrsa(name, testns).Spec.Policy.Statement[0].Action).To(Equal([]string{"s3:GetObject"}))
+
+			v, ok := getPolicy(name, testns).Status.Version("v1")
+			Expect(ok).To(BeTrue())
+			Expect(v.IsDefault).To(BeTrue())
+		})
+
+		It("refuses to rollback statements holding placeholders", func() {
+			Eventually(func() error {
+				irsa := getIrsa(name, testns)
+				irsa.Spec.Policy.Statement[0].Resource = "arn:aws:s3:::${clusterName}-${namespace}/*"
+				return k8sClient.Update(context.Background(), &irsa)
+			}, resourcePollTimeout, resourcePollInterval).Should(Succeed())
+			Eventually(func() bool {
+				return getPolicy(name, testns).IsApplied("v3", testPlaceholders, time.Now())
+			}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+
+			Eventually(func() error {
+				p := getPolicy(name, testns)
+				p.Annotations = map[string]string{api.RollbackToVersionAnnotation: "v1"}
+				return k8sClient.Update(context.Background(), &p)
+			}, resourcePollTimeout, resourcePollInterval).Should(Succeed())
+
+			foundPolicyInCondition(name, testns, api.CrError).Should(BeTrue())
+			Expect(getPolicy(name, testns).Status.Reason).To(ContainSubstring("the statements hold placeholders"))
+			Expect(getIrsa(name, testns).Spec.Policy.Statement[0].Resource).To(Equal("arn:aws:s3:::${clusterName}-${namespace}/*"))
+			Expect(stackOf(name).defaultVersion).To(Equal("v3"))
+		})
+	})
+
+	Context("When the statements are redundant", func() {
+		bucket := "arn:aws:s3:::my_corporate_bucket/exampleobject.png"
+		queue := "arn:aws:sqs:eu-west-1:111122223333:queue"
+		stmts := []api.StatementSpec{
+			{Resource: queue, Action: []string{"sqs:SendMessage"}},
+			{Resource: bucket, Action: []string{"s3:PutObject", "s3:GetObject", "s3:getobject"}},
+			{Resource: bucket, Action: []string{"s3:Get*", "s3:PutObject"}},
+			{Resource: queue, Action: []string{"sqs:*", "sqs:**"}},
+		}
+
+		It("merges them by resource, dedupes & sorts them", func() {
+			Expect(api.CanonicalStatements(stmts)).To(Equal([]api.StatementSpec{
+				{Resource: bucket, Action: []string{"s3:Get*", "s3:PutObject"}},
+				{Resource: queue, Action: []string{"sqs:*"}},
+			}))
+		})
+
+		It("compares them by their canonical form", func() {
+			Expect(api.StatementEquals(stmts, api.CanonicalStatements(stmts))).To(BeTrue())
+			Expect(api.StatementEquals(
+				[]api.StatementSpec{{Resource: bucket, Action: []string{"s3:GetObject", "s3:GetObject"}}},
+				[]api.StatementSpec{{Resource: bucket, Action: []string{"s3:GetObject", "s3:PutObject"}}},
+			)).To(BeFalse())
+			Expect(api.PolicySpec{Statement: stmts}.Hash()).To(Equal(api.PolicySpec{Statement: api.CanonicalStatements(stmts)}.Hash()))
+		})
+	})
+
+	Context("When the statements exceed the size of a policy", func() {
+		It("splits them in order across documents fitting in IAM limits", func() {
+			spec := api.PolicySpec{Statement: largeStatements(60)}
+			shards, err := spec.Shards(api.MaxAttachedPoliciesPerRole)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(shards)).To(BeNumerically(">", 1))
+
+			all := []api.StatementSpec{}
+			for _, shard := range shards {
+				Expect(api.DocumentSize(shard)).To(BeNumerically("<=", api.MaxPolicyDocumentSize))
+				all = append(all, shard...)
+			}
+			Expect(all).To(Equal(api.CanonicalStatements(spec.Statement)))
+		})
+
+		It("fails if more documents than allowed are needed", func() {
+			_, err := api.PolicySpec{Statement: largeStatements(60)}.Shards(1)
+			Expect(err).To(MatchError(ContainSubstring("only 1 can be attached to the role")))
+		})
+
+		It("fails if a single statement doesn't fit in a document", func() {
+			resource := "arn:aws:s3:::" + strings.Repeat("a", api.MaxPolicyDocumentSize)
+			_, err := api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: resource, Action: []string{"s3:GetObject"}},
+			}}.Shards(api.MaxAttachedPoliciesPerRole)
+			Expect(err).To(MatchError(ContainSubstring("statement on " + resource)))
+		})
+	})
+
+	Context("When an Awspolicy is reconciled with statements exceeding the size of a policy", func() {
+		name := validName()
+
+		It("creates the shards & attaches them to the role", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(
+				api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: largeStatements(60)}),
+			).Should(Succeed())
+
+			Eventually(func() bool {
+				return len(getPolicy(name, testns).Status.ShardARNs) > 0
+			}, resourcePollTimeout, resourcePollInterval).Should(BeTrue())
+
+			p := getPolicy(name, testns)
+			Eventually(func() []string {
+				return stackOf(name).role.attachedPolicies
+			}, resourcePollTimeout, resourcePollInterval).Should(ContainElements(append([]string{p.Spec.ARN}, p.Status.ShardARNs...)))
+			Expect(stackOf(name).shards).To(HaveLen(len(p.Status.ShardARNs)))
+		})
+	})
+
+	Context("When the statements contain placeholders", func() {
+		placeholders := api.Placeholders{ClusterName: "clustername", AccountID: "123456789012", Region: "eu-west-1"}
+
+		It("resolves them in the resources & condition values", func() {
+			spec, err := api.PolicySpec{Statement: []api.StatementSpec{
+				{
+					Resource:  "arn:aws:sqs:${region}:${accountId}:${clusterName}-${namespace}-${irsaName}",
+					Action:    []string{"sqs:SendMessage"},
+					Condition: api.Condition{"StringEquals": {"aws:ResourceTag/namespace": {"${namespace}"}}},
+				},
+			}}.Render(placeholders, "ns", "name")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec.Statement[0].Resource).To(Equal("arn:aws:sqs:eu-west-1:123456789012:clustername-ns-name"))
+			Expect(spec.Statement[0].Condition["StringEquals"]["aws:ResourceTag/namespace"]).To(Equal([]string{"ns"}))
+		})
+
+		It("leaves the original spec untouched", func() {
+			original := api.PolicySpec{Statement: []api.StatementSpec{
+				{
+					Resource:  "arn:aws:s3:::bucket/${namespace}/*",
+					Action:    []string{"s3:GetObject"},
+					Condition: api.Condition{"StringLike": {"s3:prefix": {"${namespace}/*"}}},
+				},
+			}}
+			_, err := original.Render(placeholders, "ns", "name")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(original.Statement[0].Resource).To(Equal("arn:aws:s3:::bucket/${namespace}/*"))
+			Expect(original.Statement[0].Condition["StringLike"]["s3:prefix"]).To(Equal([]string{"${namespace}/*"}))
+		})
+
+		It("rejects the unknown ones", func() {
+			_, err := api.PolicySpec{Statement: []api.StatementSpec{
+				{Resource: "arn:aws:s3:::bucket/${team}/*", Action: []string{"s3:GetObject"}},
+			}}.Render(placeholders, "ns", "name")
+			Expect(err).To(MatchError(ContainSubstring("unknown placeholder ${team}")))
+		})
+
+		It("parses the account & region from the oidc provider ARN", func() {
+			p, err := api.NewPlaceholders("clustername", "arn:aws:iam::123456789012:oidc-provider/oidc.eks.eu-west-1.amazonaws.com/id/ABCDEF")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(Equal(placeholders))
+		})
+	})
+
+	Context("When an Awspolicy with placeholders is reconciled", func() {
+		name := validName()
+
+		It("records the rendered statements in its status", func() {
+			st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+			createResource(
+				api.NewIamRoleServiceAccount(name, testns, api.PolicySpec{Statement: []api.StatementSpec{
+					{Resource: "arn:aws:sqs:${region}:${accountId}:${namespace}-${irsaName}", Action: []string{"sqs:SendMessage"}},
+				}}),
+			).Should(Succeed())
+
+			Eventually(func() []api.StatementSpec {
+				return getPolicy(name, testns).Status.RenderedStatement
+			}, resourcePollTimeout, resourcePollInterval).Should(Equal([]api.StatementSpec{
+				{Resource: "arn:aws:sqs:eu-west-1:123456789012:" + testns + "-" + name, Action: []string{"sqs:SendMessage"}},
+			}))
+		})
+	})
+})
+
+// largeStatements returns statements too large to fit in a single policy document
+func largeStatements(n int) []api.StatementSpec {
+	stmts := []api.StatementSpec{}
+	for i := 0; i < n; i++ {
+		stmts = append(stmts, api.StatementSpec{
+			Resource: fmt.Sprintf("arn:aws:s3:::my_corporate_bucket/%s/%d", strings.Repeat("x", 200), i),
+			Action:   []string{"s3:GetObject", "s3:PutObject"},
+		})
+	}
+	return stmts
+}
+
+var _ = Describe("Policy with time-bound statements", func() {
+	name := validName()
+	permanent := api.StatementSpec{Resource: "arn:aws:s3:::my_corporate_bucket/exampleobject.png", Action: []string{"s3:GetObject"}}
+	now := time.Now()
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+
+	It("only keeps the statements in their time window", func() {
+		spec := api.PolicySpec{Statement: []api.StatementSpec{
+			permanent,
+			{Resource: "arn:aws:s3:::expired", Action: []string{"s3:GetObject"}, ExpiresAt: at(-time.Minute)},
+			{Resource: "arn:aws:s3:::later", Action: []string{"s3:GetObject"}, NotBefore: at(time.Hour)},
+		}}
+
+		Expect(spec.ActiveAt(now).Statement).To(Equal([]api.StatementSpec{permanent}))
+		Expect(spec.NextBoundary(now)).To(Equal(time.Hour))
+		Expect(api.PolicySpec{Statement: spec.Statement[1:]}.ActiveAt(now).Statement).To(Equal([]api.StatementSpec{api.NoopStatement}))
+	})
+
+	It("rejects a statement expiring before it starts", func() {
+		stm := api.StatementSpec{Resource: "arn:aws:s3:::backfill", Action: []string{"s3:PutObject"}, NotBefore: at(time.Hour), ExpiresAt: at(time.Minute)}
+		Expect(stm.Validate()).To(MatchError(ContainSubstring("expiresAt must be after notBefore")))
+	})
+
+	It("revokes the statement on AWS once it expires", func() {
+		st.stacks.Store(name, awsStack{errors: map[awsMethod]struct{}{}, events: []string{}})
+		backfill := api.StatementSpec{Resource: "arn:aws:s3:::backfill", Action: []string{"s3:PutObject"}, ExpiresAt: at(20 * time.Second)}
+		createResource(api.NewPolicy(name, testns, []api.StatementSpec{permanent, backfill})).Should(Succeed())
+
+		foundPolicyInCondition(name, testns, api.CrOK).Should(BeTrue())
+		Expect(stackOf(name).policy.Statement).To(HaveLen(2))
+
+		Eventually(func() []api.StatementSpec {
+			return stackOf(name).policy.Statement
+		}, resourcePollTimeout, resourcePollInterval).Should(HaveLen(1))
+		Expect(stackOf(name).policy.Statement[0].Resource).To(Equal(permanent.Resource))
+	})
+
+	It("reports the revocation as an event", func() {
+		Eventually(func() []string {
+			events := &corev1.EventList{}
+			Expect(k8sClient.List(context.Background(), events, client.InNamespace(testns))).To(Succeed())
+
+			reasons := []string{}
+			for _, e := range events.Items {
+				if e.InvolvedObject.Name == name {
+					reasons = append(reasons, e.Reason)
+				}
+			}
+			return reasons
+		}, resourcePollTimeout, resourcePollInterval).Should(ContainElement("StatementRevoked"))
 	})
 })
@@ -6,12 +6,18 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	api "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 )
@@ -21,16 +27,24 @@ func NewRoleReconciler(
 	scheme *runtime.Scheme,
 	awsrm AwsRoleManager,
 	logger logr.Logger,
-	clusterName,
-	permissionsBoundariesPolicyARN string) *RoleReconciler {
+	recorder record.EventRecorder,
+	naming api.Naming,
+	permissionsBoundariesPolicyARN string,
+	guardrailPolicyARNs,
+	propagatedLabelKeys []string,
+	resyncPeriod time.Duration) *RoleReconciler {
 	return &RoleReconciler{
 		Client:                         client,
 		scheme:                         scheme,
 		awsRM:                          awsrm,
 		log:                            logger,
+		recorder:                       recorder,
 		finalizerID:                    "role.irsa.voodoo.io",
-		clusterName:                    clusterName,
+		naming:                         naming,
 		permissionsBoundariesPolicyARN: permissionsBoundariesPolicyARN,
+		guardrailPolicyARNs:            guardrailPolicyARNs,
+		propagatedLabelKeys:            propagatedLabelKeys,
+		resyncPeriod:                   resyncPeriod,
 	}
 }
 
@@ -38,16 +52,22 @@ func NewRoleReconciler(
 type RoleReconciler struct {
 	client.Client
 	log                            logr.Logger
+	recorder                       record.EventRecorder // the changes of the trusted principals are reported as events
 	scheme                         *runtime.Scheme
 	awsRM                          AwsRoleManager
 	finalizerID                    string
-	clusterName                    string
+	naming                         api.Naming
 	permissionsBoundariesPolicyARN string
+	guardrailPolicyARNs            []string      // policies attached to every role managed by the operator
+	propagatedLabelKeys            []string      // labels (of the role or its namespace) set as tags on the aws role
+	resyncPeriod                   time.Duration // how often the attached policies are read on aws bypassing the cache (0 disables the resync)
 }
 
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=roles/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=irsa.voodoo.io,resources=roles/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var role *api.Role
@@ -67,7 +87,7 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	{ // finalizer registration & execution
 		if role.IsPendingDeletion() {
 			// deletion requested, execute finalizer
-			if ok := r.executeFinalizerIfPresent(role); !ok {
+			if ok := r.executeFinalizerIfPresent(ctx, role); !ok {
 				return ctrl.Result{Requeue: true}, nil
 			}
 			// all done, no requeue
@@ -84,6 +104,18 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return r.admissionStep(ctx, role)
 	}
 
+	if role.Status.AwsName == "" || role.Status.AwsPath == "" { // created before the aws name & path were recorded in the status
+		name, err := role.AwsName(r.naming)
+		if err != nil {
+			ok := r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, err.Error()))
+			return ctrl.Result{Requeue: !ok}, nil
+		}
+		role.Status.AwsName = name
+		role.Status.AwsPath = role.Path(r.naming)
+		ok := r.updateStatus(ctx, role, api.NewRoleStatus(role.Status.Condition, "aws name recorded"))
+		return ctrl.Result{Requeue: !ok}, nil
+	}
+
 	return r.reconcilerRoutine(ctx, role)
 }
 
@@ -91,6 +123,9 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 func (r *RoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.Role{}).
+		// a role has the name of its policy, the shards of the policy must be attached once they're recorded in its status
+		// the roles referencing a shared policy are also reconciled when it changes
+		Watches(&source.Kind{Type: &api.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.rolesUsingPolicy)).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 10,
 		}).
@@ -103,12 +138,14 @@ func (r *RoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 // admissionStep does spec validation
 func (r *RoleReconciler) admissionStep(ctx context.Context, role *api.Role) (ctrl.Result, error) {
-	if err := role.Validate(r.clusterName); err != nil { // the role spec is invalid
+	if err := role.Validate(r.naming); err != nil { // the role spec is invalid
 		ok := r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, err.Error()))
 		return ctrl.Result{Requeue: !ok}, nil
 	}
 
-	// update the role to "progressing"
+	// record the aws name (it can be rendered, Validate checked it) & path, then update the role to "progressing"
+	role.Status.AwsName, _ = role.AwsName(r.naming)
+	role.Status.AwsPath = role.Path(r.naming)
 	ok := r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "passed validation"))
 	return ctrl.Result{Requeue: !ok}, nil
 }
@@ -116,13 +153,17 @@ func (r *RoleReconciler) admissionStep(ctx context.Context, role *api.Role) (ctr
 // reconcilerRoutine is an infinite loop attempting to make the aws IAM role, with it's attachment converge to the role.Spec
 func (r *RoleReconciler) reconcilerRoutine(ctx context.Context, role *api.Role) (ctrl.Result, error) {
 	if role.Spec.RoleARN == "" { // no arn in spec
-		roleExistsOnAws, err := r.awsRM.RoleExists(role.AwsName(r.clusterName))
+		roleExistsOnAws, err := r.awsRM.RoleExists(ctx, role.Status.AwsName)
 		if err != nil { // failed to check if roles exists on AWS
 			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to check if role exists on AWS"))
 			return ctrl.Result{Requeue: true}, nil
 		}
 
 		if roleExistsOnAws {
+			if ok := r.checkRoleOwnership(ctx, role); !ok {
+				return ctrl.Result{Requeue: true}, nil
+			}
+
 			r.setRoleArnField(ctx, role)
 			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "role found on AWS"))
 			return ctrl.Result{}, nil // updating the role leads to an automatic requeue
@@ -134,7 +175,7 @@ func (r *RoleReconciler) reconcilerRoutine(ctx context.Context, role *api.Role)
 		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "role created on AWS"))
 	}
 
-	if role.Spec.PolicyARN == "" { // the role doesn't have the policyARN set in Spec
+	if role.Spec.PolicyARN == "" && !r.onlySharedPolicies(ctx, role) { // the role doesn't have the policyARN set in Spec
 		if ok := r.setPolicyArnFieldIfPossible(ctx, role); !ok { // we try to grab it from the policy resource and set it
 			return ctrl.Result{Requeue: true}, nil
 		}
@@ -142,21 +183,63 @@ func (r *RoleReconciler) reconcilerRoutine(ctx context.Context, role *api.Role)
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	if ok := r.setPolicyShardARNsField(ctx, role); !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if ok := r.syncTrust(ctx, role); !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	refARNs, ok := r.policyRefARNs(ctx, role)
+	if !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// the attachments are regularly read on aws bypassing the cache, in case they've been modified outside of the operator (eg. a guardrail detached)
+	resync := r.resyncDue(role)
+	attachCtx := ctx
+	if resync {
+		attachCtx = Uncached(ctx)
+	}
+
 	// the role already has a policyARN in Spec
-	if ok := r.attachPolicyToRoleIfNeeded(ctx, role); !ok { // we attach the policy with the role on aws
+	if ok := r.attachPoliciesToRoleIfNeeded(attachCtx, role, refARNs); !ok { // we attach the policies with the role on aws
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	if role.Status.Condition != api.CrOK {
+	if ok := r.syncTags(ctx, role); !ok {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if resync {
+		now := metav1.Now()
+		role.Status.LastResyncTime = &now
+	}
+
+	if role.Status.Condition != api.CrOK || resync {
 		_ = r.updateStatus(ctx, role, api.NewRoleStatus(api.CrOK, "all done"))
 	}
 
-	return ctrl.Result{}, nil
+	// the next additional principal to expire must be removed from the trust policy on time
+	requeueAfter := r.resyncPeriod
+	if next := role.NextPrincipalExpiry(time.Now()); next > 0 && (requeueAfter <= 0 || next < requeueAfter) {
+		requeueAfter = next
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// resyncDue tells if the policies attached to the role must be read on aws bypassing the cache
+func (r *RoleReconciler) resyncDue(role *api.Role) bool {
+	if r.resyncPeriod <= 0 {
+		return false
+	}
+	return role.Status.LastResyncTime == nil || time.Since(role.Status.LastResyncTime.Time) >= r.resyncPeriod
 }
 
 func (r *RoleReconciler) setRoleArnField(ctx context.Context, role *api.Role) (completed bool) {
 	// we get the role details from aws
-	roleArn, err := r.awsRM.GetRoleARN(role.AwsName(r.clusterName))
+	roleArn, err := r.awsRM.GetRoleARN(ctx, role.Status.AwsName)
 	if err != nil {
 		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to get role ARN on AWS : "+err.Error()))
 		return false
@@ -172,47 +255,278 @@ func (r *RoleReconciler) setRoleArnField(ctx context.Context, role *api.Role) (c
 	return true
 }
 
+// checkRoleOwnership ensures the role found on aws with the expected name actually belongs to this role
+func (r *RoleReconciler) checkRoleOwnership(ctx context.Context, role *api.Role) (completed bool) {
+	tags, err := r.awsRM.GetRoleTags(ctx, role.Status.AwsName)
+	if err != nil {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to get role tags on AWS : "+err.Error()))
+		return false
+	}
+
+	if !isOwnedBy(tags, r.naming.ClusterName, role) {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, fmt.Sprintf("role found on AWS is owned by %s/%s on cluster %s", tags[tagNamespace], tags[tagName], tags[tagClusterName])))
+		return false
+	}
+
+	return true
+}
+
 func (r *RoleReconciler) createRoleOnAws(ctx context.Context, role *api.Role, permissionsBoundariesPolicyARN string) (completed bool) {
-	if err := r.awsRM.CreateRole(*role, permissionsBoundariesPolicyARN); err != nil {
+	tags, err := desiredTags(ctx, r.Client, r.naming.ClusterName, role, r.propagatedLabelKeys)
+	if err != nil {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to compute role tags : "+err.Error()))
+		return false
+	}
+
+	trusted, active := r.trustedRole(role)
+	if err := r.awsRM.CreateRole(ctx, trusted, permissionsBoundariesPolicyARN, tags); err != nil {
 		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to create roleArn on aws : "+err.Error()))
 		return false
 	}
+
+	// recorded by the status update following the creation
+	role.Status.TrustedSubjects = role.TrustedSubjects()
+	r.recordPrincipalChanges(role, nil, active)
+	role.Status.TrustedPrincipals = active
 	return true
 }
 
-func (r *RoleReconciler) attachPolicyToRoleIfNeeded(ctx context.Context, role *api.Role) (completed bool) {
-	awsRoleName := role.AwsName(r.clusterName)
-	roleAlreadyCreatedOnAws, err := r.awsRM.RoleExists(awsRoleName)
+// trustedRole returns a copy of the role only holding the additional principals that haven't expired, to build its trust policy
+func (r *RoleReconciler) trustedRole(role *api.Role) (_ api.Role, active []api.TrustedPrincipal) {
+	trusted := *role.DeepCopy()
+	trusted.Spec.AdditionalTrustedPrincipals = role.ActivePrincipals(time.Now())
+	return trusted, trusted.Spec.AdditionalTrustedPrincipals
+}
+
+// recordPrincipalChanges emits an event for each additional principal added to or removed from the trust policy
+func (r *RoleReconciler) recordPrincipalChanges(role *api.Role, previous, current []api.TrustedPrincipal) {
+	arns := func(principals []api.TrustedPrincipal) []string {
+		s := []string{}
+		for _, p := range principals {
+			s = append(s, p.ARN)
+		}
+		return s
+	}
+
+	for _, p := range current {
+		if !containsString(arns(previous), p.ARN) {
+			r.recorder.Event(role, corev1.EventTypeNormal, "TrustedPrincipalAdded", fmt.Sprintf("%s can assume the role", p))
+		}
+	}
+
+	for _, p := range previous {
+		if containsString(arns(current), p.ARN) {
+			continue
+		}
+
+		reason := "removed from the spec"
+		if !p.IsActive(time.Now()) {
+			reason = "expired"
+		}
+		r.recorder.Event(role, corev1.EventTypeNormal, "TrustedPrincipalRemoved", fmt.Sprintf("%s can't assume the role anymore (%s)", p.ARN, reason))
+	}
+}
+
+// syncTrust makes the trust policy of the aws role converge to the service accounts & the (unexpired) additional principals of its spec
+func (r *RoleReconciler) syncTrust(ctx context.Context, role *api.Role) (completed bool) {
+	subjects := role.TrustedSubjects()
+	trusted, active := r.trustedRole(role)
+	if stringsEqual(role.Status.TrustedSubjects, subjects) && api.TrustedPrincipalsEqual(role.Status.TrustedPrincipals, active) {
+		return true
+	}
+
+	if err := r.awsRM.UpdateAssumeRolePolicy(ctx, trusted); err != nil {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to update the trust policy on AWS : "+err.Error()))
+		return false
+	}
+
+	r.recordPrincipalChanges(role, role.Status.TrustedPrincipals, active)
+	role.Status.TrustedSubjects = subjects
+	role.Status.TrustedPrincipals = active
+	return r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "trust policy updated"))
+}
+
+// attachPoliciesToRoleIfNeeded makes the policies attached to the role on aws converge to the expected ones :
+// missing policies are attached & stale attachments are detached
+func (r *RoleReconciler) attachPoliciesToRoleIfNeeded(ctx context.Context, role *api.Role, refARNs []string) (completed bool) {
+	awsRoleName := role.Status.AwsName
+	roleAlreadyCreatedOnAws, err := r.awsRM.RoleExists(ctx, awsRoleName)
 	if err != nil {
 		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to check if the role exists : "+err.Error()))
 		return false
 	}
 
 	if !roleAlreadyCreatedOnAws {
-		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "role not created on AWS yet : "+err.Error()))
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "role not created on AWS yet"))
 		return false
 	}
 
-	// maybe the policy is already attached to it ?
-	policiesARNs, err := r.awsRM.GetAttachedRolePoliciesARNs(awsRoleName)
+	// maybe the policies are already attached to it ?
+	policiesARNs, err := r.awsRM.GetAttachedRolePoliciesARNs(ctx, awsRoleName)
 	if err != nil {
 		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to retrieve attached role policies : "+err.Error()))
 		return false
 	}
 
-	for _, pARN := range policiesARNs { // iterate over found policies
-		if pARN == role.Spec.PolicyARN {
-			return true
+	expectedARNs := r.expectedPolicyARNs(role, refARNs)
+	if len(expectedARNs) > api.MaxAttachedPoliciesPerRole {
+		own := len(role.Spec.PolicyShardARNs)
+		if role.Spec.PolicyARN != "" {
+			own++
 		}
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, fmt.Sprintf("%d policies must be attached to the role (%d for its policy, %d shared, %d guardrails), IAM accepts %d", len(expectedARNs), own, len(refARNs), len(r.guardrailPolicyARNs), api.MaxAttachedPoliciesPerRole)))
+		return false
 	}
 
-	// the policy is not attached yet
-	if err := r.awsRM.AttachRolePolicy(awsRoleName, role.Spec.PolicyARN); err != nil { // we attach the policy
-		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to attach policy to role : "+err.Error()))
+	attached := false
+	for _, pARN := range expectedARNs { // attach the policies that are missing (eg. a guardrail removed out-of-band)
+		if containsString(policiesARNs, pARN) {
+			continue
+		}
+
+		if err := r.awsRM.AttachRolePolicy(ctx, awsRoleName, pARN); err != nil {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to attach policy to role : "+err.Error()))
+			return false
+		}
+		attached = true
+	}
+
+	for _, pARN := range policiesARNs { // detach the stale ones
+		if containsString(expectedARNs, pARN) {
+			continue
+		}
+
+		if err := r.awsRM.DetachRolePolicy(ctx, awsRoleName, pARN); err != nil {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to detach stale policy from role : "+err.Error()))
+			return false
+		}
+	}
+
+	if attached {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "policy attached to role"))
+	}
+	return true
+}
+
+// syncTags makes the tags of the aws role converge to the desired ones
+func (r *RoleReconciler) syncTags(ctx context.Context, role *api.Role) (completed bool) {
+	awsRoleName := role.Status.AwsName
+	desired, err := desiredTags(ctx, r.Client, r.naming.ClusterName, role, r.propagatedLabelKeys)
+	if err != nil {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to compute role tags : "+err.Error()))
+		return false
+	}
+
+	current, err := r.awsRM.GetRoleTags(ctx, awsRoleName)
+	if err != nil {
+		r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to get role tags on AWS : "+err.Error()))
+		return false
+	}
+
+	toSet, toRemove := tagsDiff(current, desired, r.propagatedLabelKeys)
+	if len(toSet) > 0 {
+		if err := r.awsRM.TagRole(ctx, awsRoleName, toSet); err != nil {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to tag role on AWS : "+err.Error()))
+			return false
+		}
+	}
+
+	if len(toRemove) > 0 {
+		if err := r.awsRM.UntagRole(ctx, awsRoleName, toRemove); err != nil {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, "failed to untag role on AWS : "+err.Error()))
+			return false
+		}
+	}
+
+	return true
+}
+
+// expectedPolicyARNs lists the policies that must be attached to the role on aws
+func (r *RoleReconciler) expectedPolicyARNs(role *api.Role, refARNs []string) []string {
+	arns := []string{}
+	if role.Spec.PolicyARN != "" {
+		arns = append(arns, role.Spec.PolicyARN)
+	}
+	arns = append(arns, role.Spec.PolicyShardARNs...)
+	for _, pARN := range append(refARNs, r.guardrailPolicyARNs...) {
+		if !containsString(arns, pARN) {
+			arns = append(arns, pARN)
+		}
+	}
+	return arns
+}
+
+// onlySharedPolicies tells if the role doesn't have a policy of its own, only the shared ones it references
+func (r *RoleReconciler) onlySharedPolicies(ctx context.Context, role *api.Role) bool {
+	if len(role.Spec.PolicyRefs) == 0 {
+		return false
+	}
+
+	policy, ok := r.getPolicy(ctx, role.Name, role.Namespace)
+	return ok && policy == nil
+}
+
+// policyRefARNs returns the ARNs of the shared policies referenced by the role (shards included)
+func (r *RoleReconciler) policyRefARNs(ctx context.Context, role *api.Role) (_ []string, completed bool) {
+	arns := []string{}
+	for _, name := range role.Spec.PolicyRefs {
+		policy, ok := r.getPolicy(ctx, name, role.Namespace)
+		if !ok {
+			return nil, false
+		}
+
+		if policy == nil {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrError, fmt.Sprintf("shared policy %s not found", name)))
+			return nil, false
+		}
+
+		if policy.Spec.ARN == "" || policy.IsPendingDeletion() {
+			r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, fmt.Sprintf("waiting for shared policy %s to be created on AWS", name)))
+			return nil, false
+		}
+
+		arns = append(arns, policy.Spec.ARN)
+		arns = append(arns, policy.Status.ShardARNs...)
+	}
+
+	return arns, true
+}
+
+// rolesUsingPolicy returns the roles to reconcile when a policy changes : the one having its name & the ones referencing it
+func (r *RoleReconciler) rolesUsingPolicy(o client.Object) []reconcile.Request {
+	reqs := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()}}}
+
+	roles := &api.RoleList{}
+	if err := r.List(context.Background(), roles, client.InNamespace(o.GetNamespace())); err != nil {
+		r.log.Info(fmt.Sprintf("[%s/%s] : Failed to list roles : %s", o.GetNamespace(), o.GetName(), err))
+		return reqs
+	}
+
+	for _, role := range roles.Items {
+		if role.Name != o.GetName() && containsString(role.Spec.PolicyRefs, o.GetName()) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: role.Name, Namespace: role.Namespace}})
+		}
+	}
+	return reqs
+}
+
+// setPolicyShardARNsField keeps the shards of the policy (recorded in its status) in the role spec
+func (r *RoleReconciler) setPolicyShardARNsField(ctx context.Context, role *api.Role) (completed bool) {
+	policy, ok := r.getPolicy(ctx, role.Name, role.Namespace)
+	if !ok {
+		return false
+	}
+
+	if policy == nil || stringsEqual(policy.Status.ShardARNs, role.Spec.PolicyShardARNs) {
+		return true
+	}
+
+	role.Spec.PolicyShardARNs = policy.Status.ShardARNs
+	if err := r.Update(ctx, role); err != nil {
+		r.controllerErrLog(role, "set policyShardARNs in role spec", err)
 		return false
 	}
 
-	r.updateStatus(ctx, role, api.NewRoleStatus(api.CrProgressing, "policy attached to role"))
 	return true
 }
 
@@ -251,13 +565,18 @@ func (r *RoleReconciler) registerFinalizerIfNeeded(role *api.Role) (completed bo
 	return true
 }
 
-func (r *RoleReconciler) executeFinalizerIfPresent(role *api.Role) (completed bool) {
+func (r *RoleReconciler) executeFinalizerIfPresent(ctx context.Context, role *api.Role) (completed bool) {
 	if !containsString(role.ObjectMeta.Finalizers, r.finalizerID) { // no finalizer to execute
 		return true
 	}
 
-	for { // if some policies are attached to the role, wait till they're detached
-		attachedPoliciesARNs, err := r.awsRM.GetAttachedRolePoliciesARNs(role.AwsName(r.clusterName))
+	awsRoleName, err := role.AwsName(r.naming)
+	if err != nil { // the name can't be rendered, the role can't have been created on aws
+		awsRoleName = ""
+	}
+
+	for awsRoleName != "" { // if some policies are attached to the role, wait till they're detached
+		attachedPoliciesARNs, err := r.awsRM.GetAttachedRolePoliciesARNs(ctx, awsRoleName)
 		if err != nil {
 			r.controllerErrLog(role, "list attached policies", err)
 			return false
@@ -272,13 +591,18 @@ func (r *RoleReconciler) executeFinalizerIfPresent(role *api.Role) (completed bo
 		// policy should also try to detach policies on its side
 		r.updateStatus(context.TODO(), role, api.NewRoleStatus(api.CrDeleting, fmt.Sprintf("%d policies still attached, waiting for them to be detached", len(attachedPoliciesARNs))))
 		for _, attachedPolicyARN := range attachedPoliciesARNs {
-			_ = r.awsRM.DetachRolePolicy(role.AwsName(r.clusterName), attachedPolicyARN)
+			_ = r.awsRM.DetachRolePolicy(ctx, awsRoleName, attachedPolicyARN)
+		}
+		select {
+		case <-ctx.Done(): // the reconcile has been cancelled, we'll try again later
+			r.controllerErrLog(role, "waiting for policies to be detached", ctx.Err())
+			return false
+		case <-time.After(time.Second * 5):
 		}
-		time.Sleep(time.Second * 5)
 	}
 
-	{ // delete the role on AWS
-		if err := r.awsRM.DeleteRole(role.AwsName(r.clusterName)); err != nil {
+	if awsRoleName != "" { // delete the role on AWS
+		if err := r.awsRM.DeleteRole(ctx, awsRoleName); err != nil {
 			r.controllerErrLog(role, "aws role deletion", err)
 			return false
 		}
@@ -325,8 +649,10 @@ func (r *RoleReconciler) getRoleFromReq(ctx context.Context, req ctrl.Request) (
 	return role, true
 }
 
+// updateStatus sets the condition & reason of the role status, its other fields are kept
 func (r *RoleReconciler) updateStatus(ctx context.Context, role *api.Role, status api.RoleStatus) bool {
-	role.Status = status
+	role.Status.Condition = status.Condition
+	role.Status.Reason = status.Reason
 	return r.Status().Update(ctx, role) == nil
 }
 
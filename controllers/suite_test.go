@@ -4,6 +4,7 @@ import (
 	"log"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -23,6 +24,19 @@ import (
 var k8sClient client.Client
 var testEnv *envtest.Environment
 var st *awsFake
+var clusterNaming irsav1alpha1.Naming
+var testPlaceholders = irsav1alpha1.Placeholders{ClusterName: "clustername", AccountID: allowedResourceAccountID, Region: "eu-west-1"}
+var testSensitivePermissions = irsav1alpha1.SensitivePermissions{
+	{Action: "iam:PassRole", Resource: "*"},
+	{Action: "kms:Decrypt", Resource: "arn:aws:kms:*:*:key/prod-*"},
+}
+
+const (
+	guardrailPolicyARN       = "arn:aws:iam::123456789012:policy/guardrail"
+	propagatedLabelKey       = "team"
+	allowedResourceAccountID = "123456789012"
+	testOidcProviderARN      = "arn:aws:iam::123456789012:oidc-provider/oidc.eks.eu-west-1.amazonaws.com/id/EXAMPLED539D4633E53DE1B71EXAMPLE"
+)
 
 func CustomFail(message string, callerSkip ...int) {
 	log.Println(message)
@@ -72,19 +86,46 @@ var _ = BeforeSuite(func() {
 		k8sManager.GetClient(),
 		scheme.Scheme,
 		ctrl.Log.WithName("controllers").WithName("irsa"),
+		[]string{allowedResourceAccountID},
+		testPlaceholders,
+		testSensitivePermissions,
+		false,
 	)
 	err = iR.SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	// cluster irsa reconcilier, its policies & roles are created in the test namespace
+	cR := irsaCtrl.NewClusterIrsaReconciler(
+		k8sManager.GetClient(),
+		scheme.Scheme,
+		ctrl.Log.WithName("controllers").WithName("clusterirsa"),
+		testns,
+		[]string{allowedResourceAccountID},
+		testPlaceholders,
+		testSensitivePermissions,
+	)
+	err = cR.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
 	// policy reconcilier
-	clusterName := "clustername"
+	clusterNaming, err = irsav1alpha1.NewNaming("clustername", irsav1alpha1.DefaultNameTemplate, irsav1alpha1.DefaultRootPath)
+	Expect(err).ToNot(HaveOccurred())
 	st = newAwsFake()
 	pR := irsaCtrl.NewPolicyReconciler(
 		k8sManager.GetClient(),
 		scheme.Scheme,
 		st,
 		ctrl.Log.WithName("controllers").WithName("policy"),
-		clusterName,
+		k8sManager.GetEventRecorderFor("policy-controller"),
+		clusterNaming,
+		[]string{propagatedLabelKey},
+		time.Hour,
+		0,
+		irsav1alpha1.MaxAttachedPoliciesPerRole-1, // the guardrail takes a slot
+		[]string{allowedResourceAccountID},
+		testPlaceholders,
+		testSensitivePermissions,
+		false,
 	)
 
 	err = pR.SetupWithManager(k8sManager)
@@ -96,8 +137,12 @@ var _ = BeforeSuite(func() {
 		scheme.Scheme,
 		st,
 		ctrl.Log.WithName("controllers").WithName("role"),
-		clusterName,
+		k8sManager.GetEventRecorderFor("role-controller"),
+		clusterNaming,
 		"",
+		[]string{guardrailPolicyARN},
+		[]string{propagatedLabelKey},
+		time.Second,
 	)
 	err = rR.SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
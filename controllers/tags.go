@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tags set on every IAM resource created by the operator, used to know which CR owns it
+const (
+	tagClusterName = "irsa.voodoo.io/cluster-name"
+	tagNamespace   = "irsa.voodoo.io/namespace"
+	tagName        = "irsa.voodoo.io/name"
+	tagUID         = "irsa.voodoo.io/uid"
+)
+
+// desiredTags returns the tags the IAM resource backing obj must have :
+// the ownership tags & the propagated labels found on obj or on its namespace (obj labels take precedence)
+func desiredTags(ctx context.Context, c client.Client, cN string, obj client.Object, propagatedLabelKeys []string) (map[string]string, error) {
+	tags := ownershipTags(cN, obj)
+	if len(propagatedLabelKeys) == 0 {
+		return tags, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		return nil, err
+	}
+
+	for _, k := range propagatedLabelKeys {
+		if v, ok := obj.GetLabels()[k]; ok {
+			tags[k] = v
+		} else if v, ok := ns.GetLabels()[k]; ok {
+			tags[k] = v
+		}
+	}
+
+	return tags, nil
+}
+
+func ownershipTags(cN string, obj client.Object) map[string]string {
+	return map[string]string{
+		tagClusterName: cN,
+		tagNamespace:   obj.GetNamespace(),
+		tagName:        obj.GetName(),
+		tagUID:         string(obj.GetUID()),
+	}
+}
+
+// isOwnedBy checks the ownership tags of an IAM resource found on aws designate obj
+// resources created before the operator tagged them are considered owned (they've been found by name already)
+func isOwnedBy(tags map[string]string, cN string, obj client.Object) bool {
+	if _, tagged := tags[tagClusterName]; !tagged {
+		return true
+	}
+
+	return tags[tagClusterName] == cN &&
+		tags[tagNamespace] == obj.GetNamespace() &&
+		tags[tagName] == obj.GetName()
+}
+
+// tagsDiff returns the tags to set & the tag keys to remove to make current converge to desired
+// only the keys managed by the operator are removed, other tags are left untouched
+func tagsDiff(current, desired map[string]string, propagatedLabelKeys []string) (toSet map[string]string, toRemove []string) {
+	toSet = map[string]string{}
+	for k, v := range desired {
+		if cV, ok := current[k]; !ok || cV != v {
+			toSet[k] = v
+		}
+	}
+
+	for _, k := range append([]string{tagClusterName, tagNamespace, tagName, tagUID}, propagatedLabelKeys...) {
+		if _, ok := current[k]; !ok {
+			continue
+		}
+		if _, ok := desired[k]; !ok {
+			toRemove = append(toRemove, k)
+		}
+	}
+
+	return toSet, toRemove
+}
@@ -0,0 +1,201 @@
+// iamcatalog generates the catalog of the IAM actions the statements of a policy are validated against
+//
+// The actions are the operations of the API models shipped with the AWS SDK (so the catalog follows the version in go.mod),
+// completed by overrides.txt for the services & actions IAM names differently.
+// It's run by `go generate ./api/...` (or `make iam-catalog`).
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type apiModel struct {
+	Metadata struct {
+		SigningName    string `json:"signingName"`
+		EndpointPrefix string `json:"endpointPrefix"`
+	} `json:"metadata"`
+	Operations map[string]struct {
+		Name string `json:"name"`
+	} `json:"operations"`
+}
+
+type overrides struct {
+	services map[string]string // iam prefix by model directory, "-" to ignore the model
+	actions  []string          // <iam prefix>:<action>
+}
+
+func main() {
+	modelsDir := flag.String("models", "", "directory of the AWS SDK API models (defaults to the ones of the aws-sdk-go module in go.mod)")
+	overridesPath := flag.String("overrides", "", "overrides file (defaults to overrides.txt next to this generator)")
+	out := flag.String("out", "zz_generated.iamcatalog.go", "generated file")
+	pkg := flag.String("package", "v1alpha1", "package of the generated file")
+	flag.Parse()
+
+	if *modelsDir == "" {
+		dir, err := moduleDir("github.com/aws/aws-sdk-go")
+		if err != nil {
+			log.Fatal(err)
+		}
+		*modelsDir = filepath.Join(dir, "models", "apis")
+	}
+
+	if *overridesPath == "" {
+		dir, err := moduleDir("github.com/VoodooTeam/irsa-operator")
+		if err != nil {
+			log.Fatal(err)
+		}
+		*overridesPath = filepath.Join(dir, "hack", "iamcatalog", "overrides.txt")
+	}
+
+	ov, err := readOverrides(*overridesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	catalog, err := readModels(*modelsDir, ov)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, a := range ov.actions {
+		parts := strings.SplitN(a, ":", 2)
+		addAction(catalog, parts[0], parts[1])
+	}
+
+	src, err := render(*pkg, catalog)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func moduleDir(module string) (string, error) {
+	b, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate module %s : %s", module, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func readOverrides(path string) (overrides, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return overrides{}, err
+	}
+	defer f.Close()
+
+	ov := overrides{services: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch {
+		case fields[0] == "service" && len(fields) == 3:
+			ov.services[fields[1]] = fields[2]
+		case fields[0] == "action" && len(fields) == 2 && strings.Count(fields[1], ":") == 1:
+			ov.actions = append(ov.actions, fields[1])
+		default:
+			return overrides{}, fmt.Errorf("%s:%d : invalid override %q", path, line, scanner.Text())
+		}
+	}
+
+	return ov, scanner.Err()
+}
+
+// readModels returns the actions of each IAM prefix (lower case)
+func readModels(dir string, ov overrides) (map[string]map[string]struct{}, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "api-2.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no api model found in %s", dir)
+	}
+
+	catalog := map[string]map[string]struct{}{}
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		m := apiModel{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("%s : %s", p, err)
+		}
+
+		prefix := m.Metadata.SigningName
+		if prefix == "" {
+			prefix = m.Metadata.EndpointPrefix
+		}
+		if override, ok := ov.services[filepath.Base(filepath.Dir(filepath.Dir(p)))]; ok {
+			prefix = override
+		}
+		if prefix == "-" {
+			continue
+		}
+
+		for _, op := range m.Operations {
+			addAction(catalog, prefix, op.Name)
+		}
+	}
+
+	return catalog, nil
+}
+
+func addAction(catalog map[string]map[string]struct{}, prefix, action string) {
+	prefix = strings.ToLower(prefix)
+	if _, ok := catalog[prefix]; !ok {
+		catalog[prefix] = map[string]struct{}{}
+	}
+	catalog[prefix][action] = struct{}{}
+}
+
+func render(pkg string, catalog map[string]map[string]struct{}) ([]byte, error) {
+	prefixes := []string{}
+	for p := range catalog {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "// Code generated by hack/iamcatalog. DO NOT EDIT.")
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	fmt.Fprintln(buf, "// iamCatalog lists the actions of each IAM service prefix (lower case)")
+	fmt.Fprintln(buf, "var iamCatalog = map[string][]string{")
+	for _, p := range prefixes {
+		actions := []string{}
+		for a := range catalog[p] {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+
+		fmt.Fprintf(buf, "%q: {\n", p)
+		for _, a := range actions {
+			fmt.Fprintf(buf, "%q,\n", a)
+		}
+		fmt.Fprintln(buf, "},")
+	}
+	fmt.Fprintln(buf, "}")
+
+	return format.Source(buf.Bytes())
+}
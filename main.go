@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -17,12 +20,14 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	irsav1alpha1 "github.com/VoodooTeam/irsa-operator/api/v1alpha1"
 	irsaws "github.com/VoodooTeam/irsa-operator/aws"
 	"github.com/VoodooTeam/irsa-operator/controllers"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -47,6 +52,24 @@ func main() {
 	var clusterName string
 	var oidcProviderARN string
 	var permissionsBoundariesPolicyARN string
+	var guardrailPolicyARNs string
+	var propagatedLabelKeys string
+	var allowedResourceAccountIDs string
+	var sensitivePermissions string
+	var authorizeRequesters bool
+	var operatorUsername string
+	var iamNameTemplate string
+	var iamPath string
+	var gcInterval time.Duration
+	var gcGracePeriod time.Duration
+	var gcDryRun bool
+	var awsThrottling irsaws.ThrottlingConfig
+	var awsCallTimeout time.Duration
+	var awsCacheTTL time.Duration
+	var policyFullSyncPeriod time.Duration
+	var policyUpdateDebounce time.Duration
+	var roleResyncPeriod time.Duration
+	var clusterResourcesNamespace string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -57,6 +80,33 @@ func main() {
 	flag.StringVar(&clusterName, "cluster-name", "", "The cluster name, used to avoid name collisions on aws, set this to the name of the eks cluster")
 	flag.StringVar(&oidcProviderARN, "oidc-provider-arn", "", "The ARN of the oidc provider to use.")
 	flag.StringVar(&permissionsBoundariesPolicyARN, "permissions-boundaries-policy-arn", "", "The ARN of the policy used as permissions boundaries")
+	flag.StringVar(&guardrailPolicyARNs, "guardrail-policy-arns", "", "Comma separated list of the ARNs of the policies attached to every role created by the operator (eg. a deny-list)")
+	flag.StringVar(&allowedResourceAccountIDs, "allowed-resource-account-ids", "", "Comma separated list of the AWS accounts the resources of the policies can belong to (any if empty), the other ones are rejected")
+	flag.StringVar(&propagatedLabelKeys, "propagated-label-keys", "", "Comma separated list of the label keys (eg. team,cost-center) of the IamRoleServiceAccount or of its namespace set as tags on the IAM resources")
+	flag.BoolVar(&authorizeRequesters, "authorize-requesters", false, "Record the users requesting the IamRoleServiceAccounts & the shared Policies with an admission webhook & only apply the AWS actions they're allowed to grant (SubjectAccessReviews on the awsactions.irsa.voodoo.io virtual resource)")
+	flag.StringVar(&operatorUsername, "operator-username", "", "The username of the operator on the API server (eg. system:serviceaccount:irsa-operator:irsa-operator), it restores the statements of the rolled back policies on behalf of their requester")
+	flag.StringVar(&sensitivePermissions, "sensitive-permissions", "", "Comma separated list of the <action pattern>[=<resource pattern>] (eg. iam:*,kms:Decrypt=arn:aws:kms:*:*:key/prod-*) the IamRoleServiceAccounts can only get once approved")
+
+	flag.StringVar(&iamNameTemplate, "iam-name-template", irsav1alpha1.DefaultNameTemplate, "The template (text/template, using .ClusterName, .Namespace & .Name) of the names of the IAM resources, names longer than 64 characters are truncated & suffixed by a hash")
+	flag.StringVar(&clusterResourcesNamespace, "cluster-resources-namespace", "irsa-operator-system", "The namespace holding the Policies & Roles of the ClusterIamRoleServiceAccounts (usually the one of the operator)")
+	flag.StringVar(&iamPath, "iam-path", irsav1alpha1.DefaultRootPath, "The IAM path under which the IAM resources are created")
+
+	flag.DurationVar(&policyFullSyncPeriod, "policy-full-sync-period", 10*time.Hour, "How often the documents of the policies on AWS are compared to their spec, even if they seem up to date (ie. to revert the changes done outside of the operator)")
+	flag.DurationVar(&policyUpdateDebounce, "policy-update-debounce", 30*time.Second, "How long the spec of a policy must remain unchanged before a new version is created on AWS (IAM only keeps 5 versions), 0 disables the debounce")
+
+	flag.DurationVar(&roleResyncPeriod, "role-resync-period", time.Hour, "How often the policies attached to the roles on AWS are compared to the expected ones, bypassing the cache (ie. to attach again the guardrails detached outside of the operator), 0 disables the resync")
+
+	flag.DurationVar(&gcInterval, "gc-interval", time.Hour, "How often the IAM resources without matching Policy or Role are looked for (0 disables the garbage collection)")
+	flag.DurationVar(&gcGracePeriod, "gc-grace-period", 24*time.Hour, "How long an IAM resource must have been orphaned before being deleted")
+	flag.BoolVar(&gcDryRun, "gc-dry-run", true, "Only report the orphaned IAM resources, without deleting them")
+
+	flag.Float64Var(&awsThrottling.RateLimit, "aws-rate-limit", 5, "The maximum number of requests per second sent to the IAM API (0 disables the rate limiting)")
+	flag.IntVar(&awsThrottling.Burst, "aws-burst", 10, "The number of requests allowed to be sent at once to the IAM API")
+	flag.IntVar(&awsThrottling.MaxRetries, "aws-max-retries", 5, "How many times a failed request to the IAM API is retried")
+	flag.DurationVar(&awsThrottling.MinThrottleDelay, "aws-min-throttle-delay", 500*time.Millisecond, "The delay before retrying a request throttled by AWS, doubled (with jitter) at each retry")
+	flag.DurationVar(&awsThrottling.MaxThrottleDelay, "aws-max-throttle-delay", 30*time.Second, "The maximum delay before retrying a request throttled by AWS")
+	flag.DurationVar(&awsCacheTTL, "aws-cache-ttl", time.Minute, "How long the state read on IAM is cached (invalidated by the writes of the operator), 0 disables the cache")
+	flag.DurationVar(&awsCallTimeout, "aws-call-timeout", 2*time.Minute, "The maximum duration of an operation on AWS (retries included), 0 disables the timeout")
 
 	opts := zap.Options{
 		Development: true,
@@ -80,6 +130,64 @@ func main() {
 	} else {
 		setupLog.Info(fmt.Sprintf("permissions boundaries policy arn is : %s", permissionsBoundariesPolicyARN))
 	}
+	guardrails := splitList(guardrailPolicyARNs)
+	for _, g := range guardrails {
+		if !arn.IsARN(g) {
+			setupLog.Error(fmt.Errorf("%s is an invalid ARN", g), "unable to start manager")
+			os.Exit(1)
+		}
+		setupLog.Info(fmt.Sprintf("guardrail policy arn is : %s", g))
+	}
+	// the statements of a policy can be split across the policies left once the guardrails are attached to the role
+	maxPolicyShards := irsav1alpha1.MaxAttachedPoliciesPerRole - len(guardrails)
+	if maxPolicyShards < 1 {
+		setupLog.Error(fmt.Errorf("at most %d guardrail policies can be attached to a role", irsav1alpha1.MaxAttachedPoliciesPerRole-1), "unable to start manager")
+		os.Exit(1)
+	}
+	labelKeys := splitList(propagatedLabelKeys)
+	placeholders, err := irsav1alpha1.NewPlaceholders(clusterName, oidcProviderARN)
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+	if placeholders.AccountID == "" || placeholders.Region == "" {
+		setupLog.Info("the aws account id or region can't be found in the oidc provider arn, the policies using ${accountId} or ${region} will fail")
+	}
+	setupLog.Info(fmt.Sprintf("aws account id is : %s, region is : %s", placeholders.AccountID, placeholders.Region))
+	allowedAccounts := splitList(allowedResourceAccountIDs)
+	for _, id := range allowedAccounts {
+		if !accountIDRegexp.MatchString(id) {
+			setupLog.Error(fmt.Errorf("%s is an invalid AWS account id", id), "unable to start manager")
+			os.Exit(1)
+		}
+		setupLog.Info(fmt.Sprintf("allowed resource account id is : %s", id))
+	}
+
+	sensitive, err := irsav1alpha1.ParseSensitivePermissions(splitList(sensitivePermissions))
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+	for _, p := range sensitive {
+		setupLog.Info(fmt.Sprintf("sensitive permission is : %s", p))
+	}
+
+	naming, err := irsav1alpha1.NewNaming(clusterName, iamNameTemplate, iamPath)
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+	setupLog.Info(fmt.Sprintf("iam name template is : %s", iamNameTemplate))
+	setupLog.Info(fmt.Sprintf("iam path is : %s", iamPath))
+	if awsThrottling.RateLimit > 0 && awsThrottling.Burst < 1 {
+		setupLog.Error(errors.New("aws-burst must be at least 1 when the rate limit is enabled"), "unable to start manager")
+		os.Exit(1)
+	}
+	if awsThrottling.RateLimit <= 0 {
+		setupLog.Info("no rate limit set on the calls to the IAM API")
+	} else {
+		setupLog.Info(fmt.Sprintf("IAM API rate limit is : %g req/s (burst %d)", awsThrottling.RateLimit, awsThrottling.Burst))
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -99,23 +207,64 @@ func main() {
 		mgr.GetClient(),
 		mgr.GetScheme(),
 		ctrl.Log.WithName("controllers").WithName("IamRoleServiceAccount"),
+		allowedAccounts,
+		placeholders,
+		sensitive,
+		authorizeRequesters,
 	).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IamRoleServiceAccount")
 		os.Exit(1)
 	}
 
-	awsCfg := getAwsConfig()
+	if authorizeRequesters {
+		setupLog.Info("the requesters must be allowed to grant the actions of their IamRoleServiceAccounts & shared Policies")
+		mgr.GetWebhookServer().Register(controllers.RequesterWebhookPath, &webhook.Admission{Handler: controllers.NewRequesterRecorder(operatorUsername)})
+	}
+
+	if len(sensitive) > 0 { // the approvals must be checked on admission, whoever may edit an irsa could otherwise approve it
+		mgr.GetWebhookServer().Register(controllers.ApprovalWebhookPath, &webhook.Admission{Handler: controllers.NewApprovalValidator(mgr.GetClient())})
+	}
+
+	if err = controllers.NewClusterIrsaReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+		ctrl.Log.WithName("controllers").WithName("ClusterIamRoleServiceAccount"),
+		clusterResourcesNamespace,
+		allowedAccounts,
+		placeholders,
+		sensitive,
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterIamRoleServiceAccount")
+		os.Exit(1)
+	}
+
+	// a single aws manager is shared by all the controllers so the rate limit applies to the whole operator
+	awsm := irsaws.NewAwsManager(
+		irsaws.WithThrottling(getAwsConfig(), awsThrottling),
+		ctrl.Log.WithName("aws"),
+		naming,
+		oidcProviderARN,
+		awsCallTimeout,
+	)
+	if awsCacheTTL > 0 {
+		awsm = irsaws.NewCachedAwsManager(awsm, awsCacheTTL)
+	}
 
 	if err = controllers.NewPolicyReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
-		irsaws.NewAwsManager(
-			awsCfg,
-			ctrl.Log.WithName("aws").WithName("Policy"), clusterName,
-			oidcProviderARN,
-		),
+		awsm,
 		ctrl.Log.WithName("controllers").WithName("Policy"),
-		clusterName,
+		mgr.GetEventRecorderFor("policy-controller"),
+		naming,
+		labelKeys,
+		policyFullSyncPeriod,
+		policyUpdateDebounce,
+		maxPolicyShards,
+		allowedAccounts,
+		placeholders,
+		sensitive,
+		authorizeRequesters,
 	).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Policy")
 		os.Exit(1)
@@ -124,15 +273,34 @@ func main() {
 	if err = controllers.NewRoleReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
-		irsaws.NewAwsManager(awsCfg, ctrl.Log.WithName("controllers").WithName("Aws"), clusterName, oidcProviderARN),
+		awsm,
 		ctrl.Log.WithName("controllers").WithName("Role"),
-		clusterName,
+		mgr.GetEventRecorderFor("role-controller"),
+		naming,
 		permissionsBoundariesPolicyARN,
+		guardrails,
+		labelKeys,
+		roleResyncPeriod,
 	).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Role")
 		os.Exit(1)
 	}
 
+	if gcInterval > 0 {
+		if err = mgr.Add(controllers.NewGarbageCollector(
+			mgr.GetClient(),
+			awsm,
+			ctrl.Log.WithName("controllers").WithName("GarbageCollector"),
+			naming,
+			gcInterval,
+			gcGracePeriod,
+			gcDryRun,
+		)); err != nil {
+			setupLog.Error(err, "unable to add garbage collector")
+			os.Exit(1)
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
@@ -170,3 +338,16 @@ func getAwsConfig() *session.Session {
 
 	return session.Must(session.NewSession())
 }
+
+// splitList splits a comma separated flag value, ignoring empty elements
+var accountIDRegexp = regexp.MustCompile(`^\d{12}$`)
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}